@@ -2,33 +2,43 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/api"
 	"github.com/dunamismax/pixelflow/internal/config"
+	"github.com/dunamismax/pixelflow/internal/httpsource"
 	"github.com/dunamismax/pixelflow/internal/queue"
 	"github.com/dunamismax/pixelflow/internal/ratelimit"
 	"github.com/dunamismax/pixelflow/internal/storage"
 	"github.com/dunamismax/pixelflow/internal/store"
 	"github.com/dunamismax/pixelflow/internal/telemetry"
+	"github.com/dunamismax/pixelflow/internal/video"
+	"github.com/dunamismax/pixelflow/internal/webhook"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	cfg := config.Load()
 	logger := log.New(os.Stdout, "[api] ", log.LstdFlags|log.Lmsgprefix)
+	cfg, err := config.Resolve()
+	if err != nil {
+		logger.Fatalf("config load failed: %v", err)
+	}
 
 	traceShutdown, err := telemetry.SetupTracing(context.Background(), telemetry.TraceConfig{
 		ServiceName:  "pixelflow-api",
 		Exporter:     cfg.Telemetry.TracesExporter,
 		OTLPEndpoint: cfg.Telemetry.OTLPTraceEndpoint,
+		OTLPProtocol: cfg.Telemetry.OTLPProtocol,
 		OTLPInsecure: cfg.Telemetry.OTLPInsecure,
+		SampleRatio:  cfg.Telemetry.SampleRatio,
 	}, logger)
 	if err != nil {
 		logger.Fatalf("tracing init failed: %v", err)
@@ -41,19 +51,48 @@ func main() {
 		}
 	}()
 
-	queueClient := queue.NewClient(cfg.Queue.RedisClientOpt(), cfg.Queue.Name)
+	queueClient := queue.NewClient(
+		cfg.Queue.RedisClientOpt(),
+		cfg.Queue.Name,
+		queue.WithMaxRetry(cfg.Queue.MaxRetry),
+		queue.WithTimeout(cfg.Queue.Timeout),
+	)
 	defer func() {
 		if err := queueClient.Close(); err != nil {
 			logger.Printf("queue client close error: %v", err)
 		}
 	}()
 
-	storageClient, err := storage.NewClient(storage.Config{
-		Endpoint: cfg.Storage.Endpoint,
-		Access:   cfg.Storage.AccessKey,
-		Secret:   cfg.Storage.SecretKey,
-		Bucket:   cfg.Storage.Bucket,
-		UseSSL:   cfg.Storage.UseSSL,
+	jobCancels := queue.NewJobCancels(cfg.Queue.RedisClientOpt())
+	defer func() {
+		if err := jobCancels.Close(); err != nil {
+			logger.Printf("job cancel publisher close error: %v", err)
+		}
+	}()
+
+	storageClient, err := storage.Open(storage.Config{
+		Provider:        cfg.Storage.Provider,
+		Endpoint:        cfg.Storage.Endpoint,
+		Access:          cfg.Storage.AccessKey,
+		Secret:          cfg.Storage.SecretKey,
+		Bucket:          cfg.Storage.Bucket,
+		UseSSL:          cfg.Storage.UseSSL,
+		Credentials:     cfg.Storage.Credentials,
+		Region:          cfg.Storage.Region,
+		SSEMode:         cfg.Storage.SSEMode,
+		SSEKMSKeyID:     cfg.Storage.SSEKMSKeyID,
+		RetryAttempts:   cfg.Storage.RetryAttempts,
+		RetryBackoff:    cfg.Storage.RetryBackoff,
+		RetryMaxBackoff: cfg.Storage.RetryMaxBackoff,
+		Logger:          logger,
+		AzureAccount:    cfg.Storage.AzureAccount,
+		AzureKey:        cfg.Storage.AzureKey,
+		AzureContainer:  cfg.Storage.AzureContainer,
+		AzureEndpoint:   cfg.Storage.AzureEndpoint,
+
+		FilesystemRoot:          cfg.Storage.FilesystemRoot,
+		FilesystemPublicURL:     cfg.Storage.FilesystemPublicURL,
+		FilesystemSigningSecret: cfg.Storage.FilesystemSigningSecret,
 	})
 	if err != nil {
 		logger.Fatalf("storage init failed: %v", err)
@@ -66,7 +105,11 @@ func main() {
 		logger.Fatalf("storage bucket check failed: %v", err)
 	}
 
-	jobStore, err := store.NewPostgresJobStore(startupCtx, cfg.Database.DSN)
+	jobStore, err := store.Open(startupCtx, cfg.Database.DSN, store.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	})
 	if err != nil {
 		logger.Fatalf("job store init failed: %v", err)
 	}
@@ -76,8 +119,42 @@ func main() {
 		}
 	}()
 
+	httpSource, err := httpsource.NewClient(httpsource.Config{
+		Timeout:    cfg.HTTPSource.Timeout,
+		AllowCIDRs: cfg.HTTPSource.AllowCIDRs,
+		DenyCIDRs:  cfg.HTTPSource.DenyCIDRs,
+	})
+	if err != nil {
+		logger.Fatalf("http source init failed: %v", err)
+	}
+
 	serverOpts := []api.Option{
+		api.WithHTTPSource(httpSource),
 		api.WithRateLimiter(nil, cfg.API.RateLimitUserID),
+		api.WithMultipartUpload(cfg.Storage.MultipartThreshold, cfg.Storage.MultipartPartSize),
+		api.WithTerminalCacheMaxAge(cfg.API.TerminalCacheMaxAge),
+		api.WithMaxJobRetries(cfg.API.MaxJobRetries),
+		api.WithMaxPipelineSteps(cfg.API.MaxPipelineSteps),
+		api.WithMaxTaskTimeout(cfg.Queue.MaxTimeout),
+		api.WithMaxBodyBytes(cfg.API.MaxBodyBytes),
+		api.WithMaxUploadBytes(cfg.Worker.MaxInputBytes),
+		api.WithUploadTimeout(cfg.API.UploadTimeout),
+		api.WithOverlayAssetsPrefix(cfg.Worker.OverlayAssetsPrefix),
+		api.WithCORS(api.CORSConfig{
+			AllowedOrigins: cfg.API.CORSAllowedOrigins,
+			AllowedMethods: cfg.API.CORSAllowedMethods,
+			AllowedHeaders: cfg.API.CORSAllowedHeaders,
+			MaxAge:         cfg.API.CORSMaxAge,
+		}),
+		api.WithAllowedSourceTypes(cfg.API.AllowedSourceTypes),
+		api.WithVideoSources(video.Supported),
+		api.WithJobCanceller(jobCancels),
+		api.WithQueueTiers(cfg.API.TierHeader, cfg.Queue.TierQueues),
+		api.WithWebhookTester(webhook.NewClient(webhook.Config{
+			SigningSecret: cfg.Webhook.SigningSecret,
+			Timeout:       cfg.Webhook.Timeout,
+			AllowAddr:     httpSource.Allowed,
+		})),
 	}
 	if cfg.API.RateLimitEnabled {
 		redisClient := redis.NewClient(&redis.Options{
@@ -94,31 +171,75 @@ func main() {
 			}
 		}()
 
-		limiter, err := ratelimit.NewRedisTokenBucket(
-			redisClient,
-			cfg.API.RateLimitCapacity,
-			cfg.API.RateLimitWindow,
-			"pixelflow:api:ratelimit",
-		)
+		limiter, err := newRateLimiter(redisClient, cfg.API.RateLimitStrategy, cfg.API.RateLimitCapacity, cfg.API.RateLimitWindow, "pixelflow:api:ratelimit")
 		if err != nil {
 			logger.Fatalf("rate limiter init failed: %v", err)
 		}
 		serverOpts = append(serverOpts, api.WithRateLimiter(limiter, cfg.API.RateLimitUserID))
+
+		routes := make([]string, 0, len(cfg.API.RouteRateLimits))
+		for route := range cfg.API.RouteRateLimits {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+		for _, route := range routes {
+			limit := cfg.API.RouteRateLimits[route]
+			routeLimiter, err := newRateLimiter(redisClient, cfg.API.RateLimitStrategy, limit.Capacity, limit.Window, routeRateLimitKeyPrefix(route))
+			if err != nil {
+				logger.Fatalf("rate limiter init failed for route=%s: %v", route, err)
+			}
+			serverOpts = append(serverOpts, api.WithRouteRateLimiter(route, routeLimiter))
+			logger.Printf("rate limit route=%s capacity=%d window=%s", route, limit.Capacity, limit.Window)
+		}
 	}
 
 	app := api.NewServer(logger, queueClient, jobStore, storageClient, cfg.Storage.PresignPutExpiry, serverOpts...)
 
 	httpServer := &http.Server{
-		Addr:         cfg.API.Addr,
-		Handler:      app.Handler(),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              cfg.API.Addr,
+		Handler:           app.Handler(),
+		ReadTimeout:       cfg.API.ReadTimeout,
+		ReadHeaderTimeout: cfg.API.ReadHeaderTimeout,
+		WriteTimeout:      cfg.API.WriteTimeout,
+		IdleTimeout:       cfg.API.IdleTimeout,
+		MaxHeaderBytes:    cfg.API.MaxHeaderBytes,
+	}
+
+	certFile, keyFile := strings.TrimSpace(cfg.API.TLSCertFile), strings.TrimSpace(cfg.API.TLSKeyFile)
+	if (certFile == "") != (keyFile == "") {
+		logger.Fatalf("tls config invalid: PIXELFLOW_API_TLS_CERT_FILE and PIXELFLOW_API_TLS_KEY_FILE must be set together")
+	}
+	if certFile != "" {
+		certs, err := api.NewCertReloader(certFile, keyFile)
+		if err != nil {
+			logger.Fatalf("tls init failed: %v", err)
+		}
+		httpServer.TLSConfig = certs.TLSConfig()
+
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		defer signal.Stop(reload)
+		go func() {
+			for range reload {
+				if err := certs.Reload(); err != nil {
+					logger.Printf("tls certificate reload failed: %v", err)
+					continue
+				}
+				logger.Printf("tls certificate reloaded from %s", certFile)
+			}
+		}()
 	}
 
 	go func() {
-		logger.Printf("listening on %s", cfg.API.Addr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if httpServer.TLSConfig != nil {
+			logger.Printf("listening on %s (tls)", cfg.API.Addr)
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			logger.Printf("listening on %s", cfg.API.Addr)
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("server failed: %v", err)
 		}
 	}()
@@ -140,6 +261,17 @@ func main() {
 		}()
 	}
 
+	var pprofServer *http.Server
+	if strings.TrimSpace(cfg.API.PprofAddr) != "" {
+		pprofServer = telemetry.NewPprofServer(cfg.API.PprofAddr)
+		go func() {
+			logger.Printf("pprof listening on %s", cfg.API.PprofAddr)
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("pprof server failed: %v", err)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
@@ -156,4 +288,25 @@ func main() {
 			logger.Printf("metrics shutdown failed: %v", err)
 		}
 	}
+	if pprofServer != nil {
+		if err := pprofServer.Shutdown(ctx); err != nil {
+			logger.Printf("pprof shutdown failed: %v", err)
+		}
+	}
+}
+
+func routeRateLimitKeyPrefix(route string) string {
+	route = strings.NewReplacer("{", "", "}", "").Replace(strings.Trim(route, "/"))
+	return "pixelflow:api:ratelimit:route:" + strings.ReplaceAll(route, "/", ":")
+}
+
+func newRateLimiter(client *redis.Client, strategy string, capacity int, window time.Duration, keyPrefix string) (api.RateLimiter, error) {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "sliding_window":
+		return ratelimit.NewRedisSlidingWindow(client, capacity, window, keyPrefix+":sliding")
+	case "", "token_bucket":
+		return ratelimit.NewRedisTokenBucket(client, capacity, window, keyPrefix)
+	default:
+		return nil, fmt.Errorf("unsupported rate limit strategy: %s", strategy)
+	}
 }
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/config"
+	"github.com/dunamismax/pixelflow/internal/events"
+	"github.com/dunamismax/pixelflow/internal/httpsource"
 	"github.com/dunamismax/pixelflow/internal/pipeline"
 	"github.com/dunamismax/pixelflow/internal/storage"
 	"github.com/dunamismax/pixelflow/internal/store"
@@ -18,14 +20,19 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
 	logger := log.New(os.Stdout, "[worker] ", log.LstdFlags|log.Lmsgprefix)
+	cfg, err := config.Resolve()
+	if err != nil {
+		logger.Fatalf("config load failed: %v", err)
+	}
 
 	traceShutdown, err := telemetry.SetupTracing(context.Background(), telemetry.TraceConfig{
 		ServiceName:  "pixelflow-worker",
 		Exporter:     cfg.Telemetry.TracesExporter,
 		OTLPEndpoint: cfg.Telemetry.OTLPTraceEndpoint,
+		OTLPProtocol: cfg.Telemetry.OTLPProtocol,
 		OTLPInsecure: cfg.Telemetry.OTLPInsecure,
+		SampleRatio:  cfg.Telemetry.SampleRatio,
 	}, logger)
 	if err != nil {
 		logger.Fatalf("tracing init failed: %v", err)
@@ -39,11 +46,12 @@ func main() {
 	}()
 
 	logger.Printf(
-		"starting worker concurrency=%d max_active_jobs=%d queue=%s redis=%s",
+		"starting worker concurrency=%d max_active_jobs=%d queue=%s redis=%s transformer=%s",
 		cfg.Worker.Concurrency,
 		cfg.Worker.MaxActiveJobs,
 		cfg.Queue.Name,
 		cfg.Queue.RedisAddr,
+		pipeline.BackendName(),
 	)
 
 	if err := pipeline.Startup(); err != nil {
@@ -51,14 +59,43 @@ func main() {
 	}
 	defer pipeline.Shutdown()
 
+	if cfg.Worker.SkipSelfTest {
+		logger.Printf("pipeline self-test skipped")
+	} else {
+		selfTestCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		backend, err := pipeline.SelfTest(selfTestCtx)
+		cancel()
+		if err != nil {
+			logger.Fatalf("pipeline self-test failed: %v", err)
+		}
+		logger.Printf("pipeline self-test passed transformer=%s", backend)
+	}
+
 	logger.Printf("local output dir=%s", cfg.Worker.LocalOutputDir)
 
-	storageClient, err := storage.NewClient(storage.Config{
-		Endpoint: cfg.Storage.Endpoint,
-		Access:   cfg.Storage.AccessKey,
-		Secret:   cfg.Storage.SecretKey,
-		Bucket:   cfg.Storage.Bucket,
-		UseSSL:   cfg.Storage.UseSSL,
+	storageClient, err := storage.Open(storage.Config{
+		Provider:        cfg.Storage.Provider,
+		Endpoint:        cfg.Storage.Endpoint,
+		Access:          cfg.Storage.AccessKey,
+		Secret:          cfg.Storage.SecretKey,
+		Bucket:          cfg.Storage.Bucket,
+		UseSSL:          cfg.Storage.UseSSL,
+		Credentials:     cfg.Storage.Credentials,
+		Region:          cfg.Storage.Region,
+		SSEMode:         cfg.Storage.SSEMode,
+		SSEKMSKeyID:     cfg.Storage.SSEKMSKeyID,
+		RetryAttempts:   cfg.Storage.RetryAttempts,
+		RetryBackoff:    cfg.Storage.RetryBackoff,
+		RetryMaxBackoff: cfg.Storage.RetryMaxBackoff,
+		Logger:          logger,
+		AzureAccount:    cfg.Storage.AzureAccount,
+		AzureKey:        cfg.Storage.AzureKey,
+		AzureContainer:  cfg.Storage.AzureContainer,
+		AzureEndpoint:   cfg.Storage.AzureEndpoint,
+
+		FilesystemRoot:          cfg.Storage.FilesystemRoot,
+		FilesystemPublicURL:     cfg.Storage.FilesystemPublicURL,
+		FilesystemSigningSecret: cfg.Storage.FilesystemSigningSecret,
 	})
 	if err != nil {
 		logger.Fatalf("storage init failed: %v", err)
@@ -71,15 +108,32 @@ func main() {
 		logger.Fatalf("storage bucket check failed: %v", err)
 	}
 
+	httpSource, err := httpsource.NewClient(httpsource.Config{
+		Timeout:    cfg.HTTPSource.Timeout,
+		AllowCIDRs: cfg.HTTPSource.AllowCIDRs,
+		DenyCIDRs:  cfg.HTTPSource.DenyCIDRs,
+	})
+	if err != nil {
+		logger.Fatalf("http source init failed: %v", err)
+	}
+
 	webhookClient := webhook.NewClient(webhook.Config{
-		SigningSecret:  cfg.Webhook.SigningSecret,
-		Timeout:        cfg.Webhook.Timeout,
-		MaxAttempts:    cfg.Webhook.MaxAttempts,
-		InitialBackoff: cfg.Webhook.InitialBackoff,
-		MaxBackoff:     cfg.Webhook.MaxBackoff,
+		SigningSecret:    cfg.Webhook.SigningSecret,
+		Timeout:          cfg.Webhook.Timeout,
+		MaxAttempts:      cfg.Webhook.MaxAttempts,
+		InitialBackoff:   cfg.Webhook.InitialBackoff,
+		MaxBackoff:       cfg.Webhook.MaxBackoff,
+		Jitter:           cfg.Webhook.BackoffJitter,
+		Events:           cfg.Webhook.Events,
+		BreakerThreshold: cfg.Webhook.BreakerThreshold,
+		BreakerCooldown:  cfg.Webhook.BreakerCooldown,
 	})
 
-	jobStore, err := store.NewPostgresJobStore(startupCtx, cfg.Database.DSN)
+	jobStore, err := store.Open(startupCtx, cfg.Database.DSN, store.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	})
 	if err != nil {
 		logger.Fatalf("job store init failed: %v", err)
 	}
@@ -89,7 +143,38 @@ func main() {
 		}
 	}()
 
-	srv, err := worker.NewServer(logger, cfg.Queue, cfg.Worker, storageClient, webhookClient, jobStore, jobStore)
+	var serverOpts []worker.Option
+	closeSink := func(name string, sink events.ClosableSink) {
+		if err := sink.Close(); err != nil {
+			logger.Printf("%s event sink close error: %v", name, err)
+		}
+	}
+	if len(cfg.Events.KafkaBrokers) > 0 {
+		kafkaSink, err := events.NewKafkaSink(events.KafkaConfig{
+			Brokers: cfg.Events.KafkaBrokers,
+			Topic:   cfg.Events.KafkaTopic,
+		})
+		if err != nil {
+			logger.Fatalf("kafka event sink init failed: %v", err)
+		}
+		defer closeSink("kafka", kafkaSink)
+		serverOpts = append(serverOpts, worker.WithEventSinks(kafkaSink))
+		logger.Printf("kafka events enabled topic=%s brokers=%s", cfg.Events.KafkaTopic, strings.Join(cfg.Events.KafkaBrokers, ","))
+	}
+	if strings.TrimSpace(cfg.Events.NATSURL) != "" {
+		natsSink, err := events.NewNATSSink(startupCtx, events.NATSConfig{
+			URL:     cfg.Events.NATSURL,
+			Subject: cfg.Events.NATSSubject,
+		})
+		if err != nil {
+			logger.Fatalf("nats event sink init failed: %v", err)
+		}
+		defer closeSink("nats", natsSink)
+		serverOpts = append(serverOpts, worker.WithEventSinks(natsSink))
+		logger.Printf("nats events enabled subject=%s", cfg.Events.NATSSubject)
+	}
+
+	srv, err := worker.NewServer(logger, cfg.Queue, cfg.Worker, storageClient, httpSource, webhookClient, jobStore, jobStore, serverOpts...)
 	if err != nil {
 		logger.Fatalf("worker init failed: %v", err)
 	}
@@ -118,6 +203,41 @@ func main() {
 		}()
 	}
 
+	if strings.TrimSpace(cfg.Worker.PprofAddr) != "" {
+		pprofServer := telemetry.NewPprofServer(cfg.Worker.PprofAddr)
+		go func() {
+			logger.Printf("pprof listening on %s", cfg.Worker.PprofAddr)
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("pprof server failed: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := pprofServer.Shutdown(shutdownCtx); err != nil {
+				logger.Printf("pprof shutdown failed: %v", err)
+			}
+		}()
+	}
+
+	pruneCtx, pruneCancel := context.WithCancel(context.Background())
+	defer pruneCancel()
+	if cfg.Worker.ObjectTTL > 0 {
+		logger.Printf("object pruning enabled ttl=%s interval=%s", cfg.Worker.ObjectTTL, cfg.Worker.PruneInterval)
+		go worker.RunPruner(pruneCtx, logger, storageClient, cfg.Worker.ObjectTTL, cfg.Worker.PruneInterval)
+	}
+
+	queueMetricsCtx, queueMetricsCancel := context.WithCancel(context.Background())
+	queueMetricsDone := make(chan struct{})
+	go func() {
+		defer close(queueMetricsDone)
+		srv.RunQueueMetrics(queueMetricsCtx, cfg.Worker.QueueMetricsInterval)
+	}()
+	defer func() {
+		queueMetricsCancel()
+		<-queueMetricsDone
+	}()
+
 	if err := srv.Run(); err != nil {
 		logger.Fatalf("worker failed: %v", err)
 	}
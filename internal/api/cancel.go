@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/requestid"
+	"github.com/dunamismax/pixelflow/internal/store"
+)
+
+// jobCanceller tells workers to interrupt a running job. It returns how many
+// workers received the signal.
+type jobCanceller interface {
+	PublishCancel(ctx context.Context, jobID string) (int64, error)
+}
+
+// queueTaskDeleter is implemented by queue clients that can drop a task before
+// a worker picks it up.
+type queueTaskDeleter interface {
+	DeleteProcessImageTask(ctx context.Context, queueName, jobID string, retryCount int) error
+}
+
+// WithJobCanceller lets POST /v1/jobs/{id}/cancel interrupt processing jobs;
+// without it only jobs that have not started can be cancelled.
+func WithJobCanceller(canceller jobCanceller) Option {
+	return func(s *Server) {
+		s.jobCanceller = canceller
+	}
+}
+
+// handleCancelJob marks an unfinished job cancelled. A queued job's task is
+// removed from the queue it was started on (workers also skip cancelled jobs
+// they still receive), and processing jobs are signalled so the worker cancels the
+// pipeline's context. The status change only applies while the job is
+// unfinished, so a job that finishes first keeps its result and the request
+// gets 409; once cancelled, the worker cannot overwrite the status.
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
+
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if domain.IsTerminalStatus(job.Status) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("job has already finished (status=%s)", job.Status)})
+		return
+	}
+	if job.Status == domain.JobStatusProcessing && s.jobCanceller == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "cancelling processing jobs is not enabled"})
+		return
+	}
+
+	previous := job.Status
+	if _, err := s.jobStore.ClaimCancel(r.Context(), job.ID, "cancelled by request"); err != nil {
+		switch {
+		case errors.Is(err, store.ErrJobNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		case errors.Is(err, store.ErrCancelNotAllowed):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "job has already finished"})
+		default:
+			requestid.Logf(r.Context(), s.logger, "cancel job failed for job %s: %v", job.ID, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to cancel job"})
+		}
+		return
+	}
+
+	if deleter, ok := s.queueClient.(queueTaskDeleter); ok && previous == domain.JobStatusQueued {
+		if err := deleter.DeleteProcessImageTask(r.Context(), job.Queue, job.ID, job.RetryCount); err != nil {
+			requestid.Logf(r.Context(), s.logger, "delete queued task failed for job %s: %v", job.ID, err)
+		}
+	}
+
+	// A queued job may have been picked up since it was loaded, so signal it
+	// too; workers ignore ids they are not running.
+	var signalled int64
+	if s.jobCanceller != nil && (previous == domain.JobStatusQueued || previous == domain.JobStatusProcessing) {
+		signalled, err = s.jobCanceller.PublishCancel(r.Context(), job.ID)
+		if err != nil {
+			requestid.Logf(r.Context(), s.logger, "publish cancel failed for job %s: %v", job.ID, err)
+		}
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"job_id":          job.ID,
+		"status":          domain.JobStatusCancelled,
+		"previous_status": previous,
+		"signalled":       signalled,
+	})
+}
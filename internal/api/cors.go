@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/requestid"
+)
+
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+}
+
+type cors struct {
+	origins      []string
+	allowMethods string
+	allowHeaders string
+	maxAge       string
+}
+
+var corsExposedHeaders = strings.Join([]string{"ETag", "Retry-After", "X-RateLimit-Remaining", requestid.Header}, ", ")
+
+func WithCORS(cfg CORSConfig) Option {
+	return func(s *Server) {
+		if len(cfg.AllowedOrigins) == 0 {
+			s.cors = nil
+			return
+		}
+
+		origins := make([]string, 0, len(cfg.AllowedOrigins))
+		for _, origin := range cfg.AllowedOrigins {
+			if origin = strings.ToLower(strings.TrimSpace(origin)); origin != "" {
+				origins = append(origins, origin)
+			}
+		}
+		methods := cfg.AllowedMethods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+		}
+		// Clients may always send their own request ID; withRequestID echoes it.
+		headers := slices.Clone(cfg.AllowedHeaders)
+		if !slices.ContainsFunc(headers, func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), requestid.Header) }) {
+			headers = append(headers, requestid.Header)
+		}
+
+		s.cors = &cors{
+			origins:      origins,
+			allowMethods: strings.ToUpper(strings.Join(methods, ", ")),
+			allowHeaders: strings.Join(headers, ", "),
+		}
+		if cfg.MaxAge > 0 {
+			s.cors.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+		}
+	}
+}
+
+// allowOrigin reports whether origin matches an entry exactly, the "*"
+// wildcard, or a "*.example.com" pattern (any subdomain, any scheme or port).
+func (c *cors) allowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	host := origin
+	if parsed, err := url.Parse(origin); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+	for _, allowed := range c.origins {
+		switch {
+		case allowed == "*", allowed == origin:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) withCORS(next http.Handler) http.Handler {
+	if s.cors == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := s.cors.allowOrigin(origin)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if !preflight {
+			if allowed {
+				w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", s.cors.allowMethods)
+			if s.cors.allowHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", s.cors.allowHeaders)
+			}
+			if s.cors.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", s.cors.maxAge)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
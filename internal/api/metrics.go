@@ -79,12 +79,38 @@ func statusLabel(status int) string {
 
 func routeLabel(path string) string {
 	switch {
+	case path == "/v1/jobs/batch":
+		return "/v1/jobs/batch"
 	case strings.HasPrefix(path, "/v1/jobs/") && strings.HasSuffix(path, "/start"):
 		return "/v1/jobs/{id}/start"
+	case strings.HasPrefix(path, "/v1/jobs/") && strings.HasSuffix(path, "/retry"):
+		return "/v1/jobs/{id}/retry"
+	case strings.HasPrefix(path, "/v1/jobs/") && strings.HasSuffix(path, "/cancel"):
+		return "/v1/jobs/{id}/cancel"
+	case strings.HasPrefix(path, "/v1/jobs/") && strings.HasSuffix(path, "/upload/complete"):
+		return "/v1/jobs/{id}/upload/complete"
+	case strings.HasPrefix(path, "/v1/jobs/") && strings.HasSuffix(path, "/upload-url"):
+		return "/v1/jobs/{id}/upload-url"
+	case strings.HasPrefix(path, "/v1/jobs/"):
+		return "/v1/jobs/{id}"
 	case strings.HasPrefix(path, "/v1/jobs"):
 		return "/v1/jobs"
+	case strings.HasPrefix(path, "/v1/storage/"):
+		return "/v1/storage"
+	case path == "/v1/usage/logs":
+		return "/v1/usage/logs"
+	case path == "/v1/webhooks/test":
+		return "/v1/webhooks/test"
+	case strings.HasPrefix(path, "/v1/usage"):
+		return "/v1/usage"
 	case strings.HasPrefix(path, "/healthz"):
 		return "/healthz"
+	case strings.HasPrefix(path, "/readyz"):
+		return "/readyz"
+	case strings.HasPrefix(path, "/version"):
+		return "/version"
+	case path == "/openapi.json":
+		return "/openapi.json"
 	case strings.HasPrefix(path, "/metrics"):
 		return "/metrics"
 	default:
@@ -101,3 +127,8 @@ func (r *statusRecorder) WriteHeader(statusCode int) {
 	r.status = statusCode
 	r.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer's Flush.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
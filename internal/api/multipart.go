@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/requestid"
+	"github.com/dunamismax/pixelflow/internal/storage"
+)
+
+const (
+	defaultMultipartThreshold = 100 << 20
+	defaultMultipartPartSize  = 64 << 20
+	minMultipartPartSize      = 5 << 20
+	maxMultipartParts         = 10_000
+)
+
+type completeUploadRequest struct {
+	UploadID string               `json:"upload_id"`
+	Parts    []completeUploadPart `json:"parts"`
+}
+
+type completeUploadPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+func WithMultipartUpload(threshold, partSize int64) Option {
+	return func(s *Server) {
+		if threshold > 0 {
+			s.multipartThreshold = threshold
+		}
+		if partSize > 0 {
+			s.multipartPartSize = max(partSize, minMultipartPartSize)
+		}
+	}
+}
+
+func (s *Server) useMultipart(contentLength int64) bool {
+	return contentLength > 0 && contentLength >= s.multipartThreshold
+}
+
+func (s *Server) presignMultipartUpload(ctx context.Context, jobID, objectKey string, contentLength int64) (map[string]any, error) {
+	partSize, partCount := multipartPlan(contentLength, s.multipartPartSize)
+
+	uploadID, err := s.storage.CreateMultipartUpload(ctx, objectKey, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]map[string]any, 0, partCount)
+	for partNumber := 1; partNumber <= partCount; partNumber++ {
+		url, err := s.storage.PresignedUploadPart(ctx, objectKey, uploadID, partNumber, s.presignTTL)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, map[string]any{
+			"part_number": partNumber,
+			"url":         url,
+		})
+	}
+
+	return map[string]any{
+		"upload_id":    uploadID,
+		"part_size":    partSize,
+		"parts":        parts,
+		"complete_url": fmt.Sprintf("/v1/jobs/%s/upload/complete", jobID),
+	}, nil
+}
+
+func multipartPlan(contentLength, partSize int64) (int64, int) {
+	if partSize < minMultipartPartSize {
+		partSize = minMultipartPartSize
+	}
+	if minSize := (contentLength + maxMultipartParts - 1) / maxMultipartParts; partSize < minSize {
+		const mib = 1 << 20
+		partSize = (minSize + mib - 1) / mib * mib
+	}
+	parts := int((contentLength + partSize - 1) / partSize)
+	return partSize, max(parts, 1)
+}
+
+func (s *Server) handleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
+	if jobID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected path format /v1/jobs/{id}/upload/complete"})
+		return
+	}
+
+	var req completeUploadRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	parts, err := req.validate()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	// Other source types have no upload, and their object key is not a
+	// storage key, so they never reach the object store.
+	if job.SourceType != domain.SourceTypeS3Presigned && job.SourceType != domain.SourceTypeVideo {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("source_type=%s has no upload to complete", job.SourceType)})
+		return
+	}
+
+	uploadID := strings.TrimSpace(req.UploadID)
+	if job.Status != domain.JobStatusCreated {
+		s.abortUpload(r.Context(), job, uploadID)
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("upload can only be completed before the job starts (status=%s)", job.Status)})
+		return
+	}
+	if err := s.storage.CompleteMultipartUpload(r.Context(), job.ObjectKey, uploadID, parts); err != nil {
+		requestid.Logf(r.Context(), s.logger, "complete multipart upload failed for job %s: %v", job.ID, err)
+		s.abortUpload(r.Context(), job, uploadID)
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "failed to complete multipart upload; its parts were discarded"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"job_id":     job.ID,
+		"object_key": job.ObjectKey,
+		"parts":      len(parts),
+		"start_url":  fmt.Sprintf("/v1/jobs/%s/start", job.ID),
+	})
+}
+
+// abortUpload discards the parts of an upload that can no longer be
+// completed so they stop accruing storage. Failures are only logged.
+func (s *Server) abortUpload(ctx context.Context, job domain.Job, uploadID string) {
+	if err := s.storage.AbortMultipartUpload(ctx, job.ObjectKey, uploadID); err != nil {
+		requestid.Logf(ctx, s.logger, "abort multipart upload failed for job %s: %v", job.ID, err)
+	}
+}
+
+func (r completeUploadRequest) validate() ([]storage.CompletedPart, error) {
+	if strings.TrimSpace(r.UploadID) == "" {
+		return nil, errors.New("upload_id is required")
+	}
+	if len(r.Parts) == 0 {
+		return nil, errors.New("parts must contain at least one part")
+	}
+
+	parts := make([]storage.CompletedPart, 0, len(r.Parts))
+	for i, part := range r.Parts {
+		if part.PartNumber < 1 || part.PartNumber > maxMultipartParts {
+			return nil, fmt.Errorf("parts[%d].part_number must be between 1 and %d", i, maxMultipartParts)
+		}
+		if strings.TrimSpace(part.ETag) == "" {
+			return nil, fmt.Errorf("parts[%d].etag is required", i)
+		}
+		parts = append(parts, storage.CompletedPart{PartNumber: part.PartNumber, ETag: strings.TrimSpace(part.ETag)})
+	}
+	return parts, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/store"
+)
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestOpenAPIEndpointServesSpec(t *testing.T) {
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+}
+
+// TestOpenAPICoversRoutes fails when a route registered in (*Server).routes is
+// missing from openapi.json.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+
+	patterns := registeredRoutes(t)
+	if len(patterns) == 0 {
+		t.Fatal("found no registered routes")
+	}
+	for _, pattern := range patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Fatalf("route %q has no method", pattern)
+		}
+		// The start handler is mounted on the subtree and parses the id itself.
+		if path == "/v1/jobs/" {
+			path = "/v1/jobs/{id}/start"
+		}
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s %s is missing from openapi.json", method, path)
+		}
+	}
+}
+
+// registeredRoutes returns the literal patterns passed to s.mux.Handle and
+// s.mux.HandleFunc in (*Server).routes. The storage routes, built from a
+// prefix constant, are internal and intentionally skipped.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse server.go: %v", err)
+	}
+	var patterns []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "routes" || fn.Recv == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			pattern, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatalf("unquote %s: %v", lit.Value, err)
+			}
+			patterns = append(patterns, pattern)
+			return true
+		})
+	}
+	return patterns
+}
@@ -8,14 +8,16 @@ import (
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/ratelimit"
+	"github.com/dunamismax/pixelflow/internal/requestid"
 )
 
 type RateLimiter interface {
 	Allow(ctx context.Context, subject string) (ratelimit.Decision, error)
+	AllowN(ctx context.Context, subject string, n int) (ratelimit.Decision, error)
 }
 
 func (s *Server) withRateLimit(next http.Handler) http.Handler {
-	if s.rateLimiter == nil {
+	if s.rateLimiter == nil && len(s.routeRateLimiters) == 0 {
 		return next
 	}
 
@@ -25,40 +27,69 @@ func (s *Server) withRateLimit(next http.Handler) http.Handler {
 			return
 		}
 
-		subject := strings.TrimSpace(r.Header.Get(s.rateLimitUserIDHeader))
-		if subject == "" {
-			subject = "anonymous"
-		}
-		subject = subject + ":" + routeLabel(r.URL.Path)
-
-		decision, err := s.rateLimiter.Allow(r.Context(), subject)
-		if err != nil {
-			s.logger.Printf("rate limiter check failed for subject=%s err=%v", subject, err)
+		if s.allowRequest(w, r, routeLabel(r.URL.Path), 1) {
 			next.ServeHTTP(w, r)
-			return
 		}
+	})
+}
 
-		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
-		if decision.Allowed {
-			next.ServeHTTP(w, r)
-			return
-		}
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, route string, cost int) bool {
+	limiter := s.rateLimiterFor(route)
+	if limiter == nil {
+		return true
+	}
 
-		retryAfter := int(decision.RetryAfter.Round(time.Second).Seconds())
-		if retryAfter < 1 {
-			retryAfter = 1
-		}
-		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-		s.metrics.rateLimitRejected.WithLabelValues(routeLabel(r.URL.Path)).Inc()
-		writeJSON(w, http.StatusTooManyRequests, map[string]string{
-			"error": "rate limit exceeded",
-		})
+	subject := strings.TrimSpace(r.Header.Get(s.rateLimitUserIDHeader))
+	if subject == "" {
+		subject = "anonymous"
+	}
+	subject = subject + ":" + route
+
+	var (
+		decision ratelimit.Decision
+		err      error
+	)
+	if cost == 1 {
+		decision, err = limiter.Allow(r.Context(), subject)
+	} else {
+		decision, err = limiter.AllowN(r.Context(), subject, cost)
+	}
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "rate limiter check failed for subject=%s err=%v", subject, err)
+		return true
+	}
+
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := int(decision.RetryAfter.Round(time.Second).Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	s.metrics.rateLimitRejected.WithLabelValues(route).Inc()
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{
+		"error": "rate limit exceeded",
 	})
+	return false
+}
+
+func (s *Server) rateLimiterFor(route string) RateLimiter {
+	if limiter, ok := s.routeRateLimiters[route]; ok {
+		return limiter
+	}
+	return s.rateLimiter
 }
 
 func shouldRateLimit(r *http.Request) bool {
 	if r.Method == http.MethodGet {
 		return false
 	}
-	return strings.HasPrefix(r.URL.Path, "/v1/jobs")
+	// Batches are charged per item by handleCreateJobBatch once the body is decoded.
+	if r.URL.Path == "/v1/jobs/batch" {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, "/v1/jobs") || strings.HasPrefix(r.URL.Path, "/v1/webhooks/")
 }
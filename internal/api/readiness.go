@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/requestid"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func readinessChecks(deps map[string]any) map[string]pinger {
+	checks := make(map[string]pinger, len(deps))
+	for name, dep := range deps {
+		if p, ok := dep.(pinger); ok {
+			checks[name] = p
+		}
+	}
+	return checks
+}
+
+// handleReadyz reports each dependency as ok, timeout, or error. The endpoint
+// is unauthenticated, so failure details (which can carry DSNs and host:port)
+// go to the log, not the response.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		ready  = true
+		checks = make(map[string]string, len(s.readinessChecks))
+	)
+	for name, check := range s.readinessChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := "ok"
+			if err := check.Ping(ctx); err != nil {
+				requestid.Logf(ctx, s.logger, "readiness check failed dependency=%s err=%v", name, err)
+				status = "error"
+				if errors.Is(err, context.DeadlineExceeded) {
+					status = "timeout"
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			checks[name] = status
+			if status != "ok" {
+				ready = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "checks": checks})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "checks": checks})
+}
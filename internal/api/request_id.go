@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dunamismax/pixelflow/internal/requestid"
+)
+
+func (s *Server) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := requestid.Sanitize(r.Header.Get(requestid.Header))
+		if requestID == "" {
+			requestID = requestid.New()
+		}
+		w.Header().Set(requestid.Header, requestID)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), requestID)))
+	})
+}
@@ -7,45 +7,137 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/dunamismax/pixelflow/internal/buildinfo"
 	"github.com/dunamismax/pixelflow/internal/domain"
 	"github.com/dunamismax/pixelflow/internal/id"
+	"github.com/dunamismax/pixelflow/internal/pipeline"
 	"github.com/dunamismax/pixelflow/internal/queue"
+	"github.com/dunamismax/pixelflow/internal/requestid"
+	"github.com/dunamismax/pixelflow/internal/storage"
 	"github.com/dunamismax/pixelflow/internal/store"
+	"github.com/dunamismax/pixelflow/internal/video"
 	"github.com/hibiken/asynq"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const maxBatchJobs = 100
+
+const defaultMaxBodyBytes = 1 << 20
+
+// Signed filesystem-storage uploads carry whole source images, so they get
+// their own size cap (the worker's default max input) and deadline instead of
+// the JSON body limit and the server's read/write timeouts.
+const (
+	defaultMaxUploadBytes = 256 << 20
+	defaultUploadTimeout  = 10 * time.Minute
+)
+
+// sniffLen is the number of leading bytes read to sniff a source: enough
+// for pipeline.SniffInputFormat, video.Container, and http.DetectContentType.
+const sniffLen = 512
+
+var (
+	errUploadURL             = errors.New("failed to generate upload URL")
+	errUnsupportedSourceType = errors.New("unsupported source content type")
+	errHTTPSourceDisabled    = errors.New("source_type=http_url is not enabled")
+	errVideoSourceDisabled   = errors.New("source_type=video is not supported by this build (requires -tags ffmpeg)")
+
+	defaultAllowedSourceTypes = []string{"jpeg", "png", "gif", "webp"}
+)
+
 type Server struct {
 	logger                *log.Logger
 	queueClient           queueEnqueuer
 	jobStore              store.JobStore
+	usageStore            usageSummarizer
+	webhookDeliveries     webhookDeliveryLister
 	storage               objectStorage
 	presignTTL            time.Duration
+	multipartThreshold    int64
+	multipartPartSize     int64
+	terminalCacheMaxAge   time.Duration
+	maxJobRetries         int
+	maxTaskTimeout        time.Duration
+	maxPipelineSteps      int
+	maxBodyBytes          int64
+	maxUploadBytes        int64
+	uploadTimeout         time.Duration
+	overlayAssetsPrefix   string
+	allowedSourceTypes    map[string]bool
+	httpSource            sourceReader
+	videoSources          bool
+	webhookProber         webhookProber
+	jobCanceller          jobCanceller
 	mux                   *http.ServeMux
 	handler               http.Handler
 	metrics               *metrics
 	rateLimiter           RateLimiter
+	routeRateLimiters     map[string]RateLimiter
 	rateLimitUserIDHeader string
+	tierHeader            string
+	tierQueues            map[string]string
 	tracer                trace.Tracer
+	readinessChecks       map[string]pinger
+	cors                  *cors
 }
 
 type queueEnqueuer interface {
-	EnqueueProcessImage(ctx context.Context, payload queue.ProcessImagePayload) (*asynq.TaskInfo, error)
+	EnqueueProcessImage(ctx context.Context, queueName string, payload queue.ProcessImagePayload) (*asynq.TaskInfo, error)
+	ProcessImageTaskInfo(ctx context.Context, queueName, jobID string, retryCount int) (*asynq.TaskInfo, error)
+}
+
+type usageSummarizer interface {
+	Summary(ctx context.Context, userID string, from, to time.Time) (domain.UsageSummary, error)
+}
+
+type webhookDeliveryLister interface {
+	ListWebhookDeliveries(ctx context.Context, jobID string) ([]domain.WebhookDelivery, error)
 }
 
 type objectStorage interface {
 	PresignedPutURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
 	ObjectExists(ctx context.Context, objectKey string) (bool, error)
+	ReadObjectHead(ctx context.Context, objectKey string, n int64) ([]byte, error)
+	CreateMultipartUpload(ctx context.Context, objectKey, contentType string) (string, error)
+	PresignedUploadPart(ctx context.Context, objectKey, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, objectKey, uploadID string, parts []storage.CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, objectKey, uploadID string) error
+	DeleteObject(ctx context.Context, objectKey string) error
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// sourceReader is the read-only view of a job source backend used to verify
+// sources before enqueueing.
+type sourceReader interface {
+	ObjectExists(ctx context.Context, objectKey string) (bool, error)
+	ReadObjectHead(ctx context.Context, objectKey string, n int64) ([]byte, error)
 }
 
 type Option func(*Server)
 
+// WithVideoSources enables source_type=video jobs; cmd/api passes
+// video.Supported so builds without ffmpeg reject them at creation.
+func WithVideoSources(enabled bool) Option {
+	return func(s *Server) {
+		s.videoSources = enabled
+	}
+}
+
+// WithHTTPSource enables source_type=http_url jobs, verified through reader
+// (normally an SSRF-guarded *httpsource.Client).
+func WithHTTPSource(reader sourceReader) Option {
+	return func(s *Server) {
+		s.httpSource = reader
+	}
+}
+
 func WithRateLimiter(limiter RateLimiter, userIDHeader string) Option {
 	return func(s *Server) {
 		s.rateLimiter = limiter
@@ -55,6 +147,105 @@ func WithRateLimiter(limiter RateLimiter, userIDHeader string) Option {
 	}
 }
 
+func WithTerminalCacheMaxAge(maxAge time.Duration) Option {
+	return func(s *Server) {
+		s.terminalCacheMaxAge = maxAge
+	}
+}
+
+// WithAllowedSourceTypes sets the image formats start accepts, named like
+// the worker's WORKER_ALLOWED_INPUT_FORMATS ("jpeg", "tiff", ...); media
+// types such as image/jpeg are accepted as aliases.
+func WithAllowedSourceTypes(formats []string) Option {
+	return func(s *Server) {
+		if len(formats) > 0 {
+			s.allowedSourceTypes = inputFormatSet(formats)
+		}
+	}
+}
+
+func WithMaxBodyBytes(maxBytes int64) Option {
+	return func(s *Server) {
+		if maxBytes > 0 {
+			s.maxBodyBytes = maxBytes
+		}
+	}
+}
+
+// WithMaxUploadBytes caps PUT bodies on the filesystem storage routes; larger
+// uploads get 413.
+func WithMaxUploadBytes(maxBytes int64) Option {
+	return func(s *Server) {
+		if maxBytes > 0 {
+			s.maxUploadBytes = maxBytes
+		}
+	}
+}
+
+// WithUploadTimeout replaces the server's read and write timeouts for each
+// request on the filesystem storage routes.
+func WithUploadTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		if timeout > 0 {
+			s.uploadTimeout = timeout
+		}
+	}
+}
+
+// WithOverlayAssetsPrefix sets the object-store prefix watermark images must
+// live under; it should match the worker's.
+func WithOverlayAssetsPrefix(prefix string) Option {
+	return func(s *Server) {
+		if strings.TrimSpace(prefix) != "" {
+			s.overlayAssetsPrefix = prefix
+		}
+	}
+}
+
+func WithMaxJobRetries(maxRetries int) Option {
+	return func(s *Server) {
+		s.maxJobRetries = maxRetries
+	}
+}
+
+func WithMaxTaskTimeout(limit time.Duration) Option {
+	return func(s *Server) {
+		s.maxTaskTimeout = limit
+	}
+}
+
+// WithMaxPipelineSteps caps how many steps a job may define; <= 0 disables
+// the cap.
+func WithMaxPipelineSteps(limit int) Option {
+	return func(s *Server) {
+		s.maxPipelineSteps = limit
+	}
+}
+
+func WithQueueTiers(header string, tierQueues map[string]string) Option {
+	return func(s *Server) {
+		if strings.TrimSpace(header) != "" {
+			s.tierHeader = header
+		}
+		s.tierQueues = make(map[string]string, len(tierQueues))
+		for tier, queueName := range tierQueues {
+			s.tierQueues[strings.ToLower(strings.TrimSpace(tier))] = queueName
+		}
+	}
+}
+
+func WithRouteRateLimiter(route string, limiter RateLimiter) Option {
+	return func(s *Server) {
+		if limiter == nil {
+			return
+		}
+		if s.routeRateLimiters == nil {
+			s.routeRateLimiters = make(map[string]RateLimiter)
+		}
+		s.routeRateLimiters[route] = limiter
+	}
+}
+
 func NewServer(logger *log.Logger, queueClient queueEnqueuer, jobStore store.JobStore, storage objectStorage, presignTTL time.Duration, opts ...Option) *Server {
 	if presignTTL <= 0 {
 		presignTTL = 15 * time.Minute
@@ -69,21 +260,48 @@ func NewServer(logger *log.Logger, queueClient queueEnqueuer, jobStore store.Job
 		jobStore:              jobStore,
 		storage:               storage,
 		presignTTL:            presignTTL,
+		multipartThreshold:    defaultMultipartThreshold,
+		multipartPartSize:     defaultMultipartPartSize,
+		terminalCacheMaxAge:   5 * time.Minute,
+		maxJobRetries:         3,
+		maxTaskTimeout:        30 * time.Minute,
+		maxPipelineSteps:      domain.DefaultMaxPipelineSteps,
+		maxBodyBytes:          defaultMaxBodyBytes,
+		maxUploadBytes:        defaultMaxUploadBytes,
+		uploadTimeout:         defaultUploadTimeout,
+		overlayAssetsPrefix:   domain.DefaultOverlayAssetsPrefix,
+		allowedSourceTypes:    inputFormatSet(defaultAllowedSourceTypes),
 		mux:                   http.NewServeMux(),
 		metrics:               newMetrics(),
 		tracer:                otel.Tracer("pixelflow/api"),
 		rateLimitUserIDHeader: "X-User-ID",
+		tierHeader:            "X-User-Tier",
+	}
+	if usageStore, ok := jobStore.(usageSummarizer); ok {
+		s.usageStore = usageStore
+	}
+	if deliveries, ok := jobStore.(webhookDeliveryLister); ok {
+		s.webhookDeliveries = deliveries
 	}
+	s.readinessChecks = readinessChecks(map[string]any{
+		"job_store": jobStore,
+		"queue":     queueClient,
+		"storage":   storage,
+	})
 	for _, opt := range opts {
 		opt(s)
 	}
 	s.routes()
-	s.handler = s.metrics.withHTTPMetrics(s.withTracing(s.withRateLimit(s.mux)))
+	s.handler = s.withRequestID(s.metrics.withHTTPMetrics(s.withTracing(s.withCORS(s.withRateLimit(s.mux)))))
 	return s
 }
 
 type unavailableObjectStorage struct{}
 
+func (unavailableObjectStorage) Ping(_ context.Context) error {
+	return errors.New("object storage is unavailable")
+}
+
 func (unavailableObjectStorage) PresignedPutURL(_ context.Context, _ string, _ time.Duration) (string, error) {
 	return "", errors.New("object storage is unavailable")
 }
@@ -92,6 +310,34 @@ func (unavailableObjectStorage) ObjectExists(_ context.Context, _ string) (bool,
 	return false, errors.New("object storage is unavailable")
 }
 
+func (unavailableObjectStorage) ReadObjectHead(_ context.Context, _ string, _ int64) ([]byte, error) {
+	return nil, errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) CreateMultipartUpload(_ context.Context, _, _ string) (string, error) {
+	return "", errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) PresignedUploadPart(_ context.Context, _, _ string, _ int, _ time.Duration) (string, error) {
+	return "", errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) CompleteMultipartUpload(_ context.Context, _, _ string, _ []storage.CompletedPart) error {
+	return errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) AbortMultipartUpload(_ context.Context, _, _ string) error {
+	return errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) DeleteObject(_ context.Context, _ string) error {
+	return errors.New("object storage is unavailable")
+}
+
+func (unavailableObjectStorage) DeletePrefix(_ context.Context, _ string) (int, error) {
+	return 0, errors.New("object storage is unavailable")
+}
+
 func (s *Server) Handler() http.Handler {
 	return s.handler
 }
@@ -102,8 +348,45 @@ func (s *Server) MetricsHandler() http.Handler {
 
 func (s *Server) routes() {
 	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.Handle("GET /version", buildinfo.Handler())
+	s.mux.HandleFunc("GET /openapi.json", handleOpenAPI)
 	s.mux.HandleFunc("POST /v1/jobs", s.handleCreateJob)
+	s.mux.HandleFunc("POST /v1/jobs/batch", s.handleCreateJobBatch)
+	s.mux.HandleFunc("GET /v1/jobs/{id}", s.handleGetJob)
+	s.mux.HandleFunc("DELETE /v1/jobs/{id}", s.handleDeleteJob)
+	s.mux.HandleFunc("POST /v1/jobs/{id}/upload/complete", s.handleCompleteUpload)
+	s.mux.HandleFunc("POST /v1/jobs/{id}/upload-url", s.handleRenewUploadURL)
+	s.mux.HandleFunc("POST /v1/jobs/{id}/retry", s.handleRetryJob)
+	s.mux.HandleFunc("POST /v1/jobs/{id}/cancel", s.handleCancelJob)
 	s.mux.HandleFunc("POST /v1/jobs/", s.handleStartJob)
+	s.mux.HandleFunc("GET /v1/usage", s.handleUsageSummary)
+	s.mux.HandleFunc("GET /v1/usage/logs", s.handleUsageLogs)
+	s.mux.HandleFunc("POST /v1/webhooks/test", s.handleTestWebhook)
+	if local, ok := s.storage.(interface{ Handler() http.Handler }); ok {
+		handler := s.withUploadLimits(local.Handler())
+		s.mux.Handle("GET "+storage.FilesystemRoutePrefix+"{key...}", handler)
+		s.mux.Handle("PUT "+storage.FilesystemRoutePrefix+"{key...}", handler)
+	}
+}
+
+// withUploadLimits gives each storage request uploadTimeout to finish, in
+// place of the server-wide timeouts, and caps PUT bodies at maxUploadBytes.
+func (s *Server) withUploadLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Now().Add(s.uploadTimeout)
+		controller := http.NewResponseController(w)
+		if err := controller.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			requestid.Logf(r.Context(), s.logger, "set storage read deadline: %v", err)
+		}
+		if err := controller.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			requestid.Logf(r.Context(), s.logger, "set storage write deadline: %v", err)
+		}
+		if r.Method == http.MethodPut {
+			r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
@@ -112,70 +395,200 @@ func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
 
 func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 	var req domain.CreateJobRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
-	if err := req.Validate(); err != nil {
+	if err := s.validateCreateJob(req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	now := time.Now().UTC()
-	jobID := id.New()
-	userIDHeader := s.rateLimitUserIDHeader
-	if strings.TrimSpace(userIDHeader) == "" {
-		userIDHeader = "X-User-ID"
+	job, upload, err := s.prepareJob(r.Context(), s.requestUserID(r), req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
-	userID := strings.TrimSpace(r.Header.Get(userIDHeader))
-	if userID == "" {
-		userID = "anonymous"
+
+	if err := s.jobStore.Create(r.Context(), job); err != nil {
+		requestid.Logf(r.Context(), s.logger, "create job failed for job %s: %v", job.ID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create job"})
+		return
 	}
+
+	writeJSON(w, http.StatusAccepted, createJobResponse(job, upload))
+}
+
+func (s *Server) handleCreateJobBatch(w http.ResponseWriter, r *http.Request) {
+	var reqs []domain.CreateJobRequest
+	if !s.decodeJSON(w, r, &reqs) {
+		return
+	}
+	if len(reqs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "batch must contain at least one job"})
+		return
+	}
+	if len(reqs) > maxBatchJobs {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("batch must contain at most %d jobs", maxBatchJobs)})
+		return
+	}
+	if !s.allowRequest(w, r, "/v1/jobs", len(reqs)) {
+		return
+	}
+
+	userID := s.requestUserID(r)
+	results := make([]map[string]any, len(reqs))
+	jobs := make([]domain.Job, 0, len(reqs))
+	for i, req := range reqs {
+		if err := s.validateCreateJob(req); err != nil {
+			results[i] = map[string]any{"index": i, "error": err.Error()}
+			continue
+		}
+		job, upload, err := s.prepareJob(r.Context(), userID, req)
+		if err != nil {
+			results[i] = map[string]any{"index": i, "error": err.Error()}
+			continue
+		}
+		jobs = append(jobs, job)
+		results[i] = createJobResponse(job, upload)
+		results[i]["index"] = i
+	}
+
+	if len(jobs) > 0 {
+		if err := s.jobStore.CreateBatch(r.Context(), jobs); err != nil {
+			requestid.Logf(r.Context(), s.logger, "create job batch failed for %d jobs: %v", len(jobs), err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create jobs"})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"jobs": results})
+}
+
+func (s *Server) validateCreateJob(req domain.CreateJobRequest) error {
+	if err := req.ValidateWithMaxSteps(s.maxPipelineSteps); err != nil {
+		return err
+	}
+	if err := req.ValidateOverlayKeys(s.overlayAssetsPrefix); err != nil {
+		return err
+	}
+	if s.httpSource == nil && strings.EqualFold(strings.TrimSpace(req.SourceType), domain.SourceTypeHTTPURL) {
+		return errHTTPSourceDisabled
+	}
+	if !s.videoSources && strings.EqualFold(strings.TrimSpace(req.SourceType), domain.SourceTypeVideo) {
+		return errVideoSourceDisabled
+	}
+	if timeout := time.Duration(req.TimeoutSeconds) * time.Second; s.maxTaskTimeout > 0 && timeout > s.maxTaskTimeout {
+		return fmt.Errorf("timeout_seconds must be at most %d", int(s.maxTaskTimeout.Seconds()))
+	}
+	return nil
+}
+
+func (s *Server) prepareJob(ctx context.Context, userID string, req domain.CreateJobRequest) (domain.Job, map[string]any, error) {
+	now := time.Now().UTC()
+	jobID := id.New()
 	sourceType := strings.ToLower(strings.TrimSpace(req.SourceType))
 	objectKey := strings.TrimSpace(req.ObjectKey)
 	uploadState := "not_required"
 	presignedPutURL := ""
+	var multipart map[string]any
+	// Already validated; store the sanitized form the worker will use.
+	outputSubdir, _ := domain.SanitizeSubdir(req.OutputSubdir)
 
-	if sourceType == domain.SourceTypeS3Presigned {
+	if sourceType == domain.SourceTypeS3Presigned || sourceType == domain.SourceTypeVideo {
 		objectKey = fmt.Sprintf("uploads/%s/source", jobID)
-		url, err := s.storage.PresignedPutURL(r.Context(), objectKey, s.presignTTL)
-		if err != nil {
-			s.logger.Printf("generate presigned url failed for job %s: %v", jobID, err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate upload URL"})
-			return
+		if s.useMultipart(req.ContentLength) {
+			plan, err := s.presignMultipartUpload(ctx, jobID, objectKey, req.ContentLength)
+			if err != nil {
+				requestid.Logf(ctx, s.logger, "generate multipart upload failed for job %s: %v", jobID, err)
+				return domain.Job{}, nil, errUploadURL
+			}
+			multipart = plan
+			uploadState = "multipart_ready"
+		} else {
+			url, err := s.storage.PresignedPutURL(ctx, objectKey, s.presignTTL)
+			if err != nil {
+				requestid.Logf(ctx, s.logger, "generate presigned url failed for job %s: %v", jobID, err)
+				return domain.Job{}, nil, errUploadURL
+			}
+			presignedPutURL = url
+			uploadState = "ready"
 		}
-		presignedPutURL = url
-		uploadState = "ready"
 	}
 
 	job := domain.Job{
-		ID:         jobID,
-		UserID:     userID,
-		Status:     domain.JobStatusCreated,
-		SourceType: sourceType,
-		WebhookURL: req.WebhookURL,
-		Pipeline:   req.Pipeline,
-		ObjectKey:  objectKey,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:              jobID,
+		UserID:          userID,
+		Status:          domain.JobStatusCreated,
+		SourceType:      sourceType,
+		WebhookURL:      req.WebhookURL,
+		WebhookHeaders:  req.WebhookHeaders,
+		Pipeline:        req.Pipeline,
+		ObjectKey:       objectKey,
+		DeadlineSeconds: req.DeadlineSeconds,
+		MaxRetry:        req.MaxRetry,
+		TimeoutSeconds:  req.TimeoutSeconds,
+		EmitSidecar:     req.EmitSidecar,
+		EmitManifest:    req.EmitManifest,
+		DeleteSource:    req.DeleteSourceOnSuccess,
+		OutputSubdir:    outputSubdir,
+		FrameAtSeconds:  req.FrameAtSeconds,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
-	if err := s.jobStore.Create(r.Context(), job); err != nil {
-		s.logger.Printf("create job failed for job %s: %v", job.ID, err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create job"})
-		return
+	upload := map[string]any{
+		"object_key":          job.ObjectKey,
+		"presigned_put_url":   presignedPutURL,
+		"presigned_url_state": uploadState,
+	}
+	if multipart != nil {
+		upload["multipart"] = multipart
+	}
+	if presignedPutURL != "" {
+		if headers := s.presignedPutHeaders(); len(headers) > 0 {
+			upload["presigned_put_headers"] = headers
+		}
 	}
 
-	writeJSON(w, http.StatusAccepted, map[string]any{
-		"job_id": job.ID,
-		"status": job.Status,
-		"upload": map[string]string{
-			"object_key":          job.ObjectKey,
-			"presigned_put_url":   presignedPutURL,
-			"presigned_url_state": uploadState,
-		},
+	return job, upload, nil
+}
+
+// presignedPutHeaders returns the headers uploads must send when the storage
+// backend signs extra headers into presigned PUTs.
+func (s *Server) presignedPutHeaders() map[string]string {
+	if signed, ok := s.storage.(interface{ PresignedPutHeaders() map[string]string }); ok {
+		return signed.PresignedPutHeaders()
+	}
+	return nil
+}
+
+func createJobResponse(job domain.Job, upload map[string]any) map[string]any {
+	return map[string]any{
+		"job_id":    job.ID,
+		"status":    job.Status,
+		"upload":    upload,
 		"start_url": fmt.Sprintf("/v1/jobs/%s/start", job.ID),
-	})
+	}
+}
+
+func (s *Server) requestUserID(r *http.Request) string {
+	userIDHeader := s.rateLimitUserIDHeader
+	if strings.TrimSpace(userIDHeader) == "" {
+		userIDHeader = "X-User-ID"
+	}
+	userID := strings.TrimSpace(r.Header.Get(userIDHeader))
+	if userID == "" {
+		userID = "anonymous"
+	}
+	return userID
+}
+
+func (s *Server) queueForRequest(r *http.Request) string {
+	tier := strings.ToLower(strings.TrimSpace(r.Header.Get(s.tierHeader)))
+	if tier == "" {
+		return ""
+	}
+	return s.tierQueues[tier]
 }
 
 func (s *Server) handleStartJob(w http.ResponseWriter, r *http.Request) {
@@ -187,7 +600,7 @@ func (s *Server) handleStartJob(w http.ResponseWriter, r *http.Request) {
 
 	job, ok, err := s.jobStore.Get(r.Context(), jobID)
 	if err != nil {
-		s.logger.Printf("fetch job failed for job %s: %v", jobID, err)
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
 		return
 	}
@@ -195,43 +608,480 @@ func (s *Server) handleStartJob(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
 		return
 	}
+	if job.Status != domain.JobStatusCreated {
+		s.writeStartedJob(w, r, job)
+		return
+	}
 
 	if err := s.verifySourceExists(r.Context(), job); err != nil {
 		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
 		return
 	}
+	if err := s.verifySourceContentType(r.Context(), job); err != nil {
+		writeSourceContentTypeError(w, err)
+		return
+	}
 
-	payload := queue.ProcessImagePayload{
-		JobID:       job.ID,
-		SourceType:  job.SourceType,
-		WebhookURL:  job.WebhookURL,
-		ObjectKey:   job.ObjectKey,
-		Pipeline:    job.Pipeline,
-		RequestedAt: time.Now().UTC(),
+	// Claiming the start before enqueueing pins the job to one queue: asynq
+	// only dedups task ids within a queue, so two concurrent starts routed to
+	// different tiers would otherwise both enqueue.
+	claimed, err := s.jobStore.ClaimStart(r.Context(), job.ID, s.queueForRequest(r))
+	if errors.Is(err, store.ErrStartNotAllowed) {
+		if current, ok, err := s.jobStore.Get(r.Context(), jobID); err == nil && ok {
+			s.writeStartedJob(w, r, current)
+			return
+		}
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "job was already started"})
+		return
+	}
+	if errors.Is(err, store.ErrJobNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "claim start failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to start job"})
+		return
+	}
+	job = claimed
+
+	payload, taskInfo, err := s.enqueueJob(r, job)
+	if errors.Is(err, queue.ErrDuplicateTask) {
+		s.writeExistingTask(w, r, job, taskInfo)
+		return
+	}
+	if err != nil {
+		if _, err := s.jobStore.UpdateStatus(r.Context(), job.ID, domain.JobStatusCreated); err != nil {
+			requestid.Logf(r.Context(), s.logger, "revert status failed for job %s: %v", job.ID, err)
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to enqueue job"})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, enqueueResponse(job, payload, taskInfo))
+}
+
+// writeStartedJob answers a start for a job that has already left the created
+// state.
+func (s *Server) writeStartedJob(w http.ResponseWriter, r *http.Request, job domain.Job) {
+	switch job.Status {
+	case domain.JobStatusQueued, domain.JobStatusProcessing, domain.JobStatusOrphaned:
+		s.writeExistingTask(w, r, job, nil)
+	case domain.JobStatusCancelled:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "job was cancelled"})
+	default:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("job has already run (status=%s)", job.Status)})
 	}
+}
+
+func (s *Server) handleRetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
 
-	taskInfo, err := s.queueClient.EnqueueProcessImage(r.Context(), payload)
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
 	if err != nil {
-		s.logger.Printf("enqueue failed for job %s: %v", job.ID, err)
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if job.Status != domain.JobStatusFailed {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("only failed jobs can be retried (status=%s)", job.Status)})
+		return
+	}
+	if job.RetryCount >= s.maxJobRetries {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("job has reached the retry limit of %d", s.maxJobRetries)})
+		return
+	}
+	if err := s.verifySourceExists(r.Context(), job); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.verifySourceContentType(r.Context(), job); err != nil {
+		writeSourceContentTypeError(w, err)
+		return
+	}
+
+	job, err = s.jobStore.ClaimRetry(r.Context(), job.ID, s.maxJobRetries)
+	if err != nil {
+		if errors.Is(err, store.ErrRetryNotAllowed) || errors.Is(err, store.ErrJobNotFound) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "job is no longer retryable"})
+			return
+		}
+		requestid.Logf(r.Context(), s.logger, "claim retry failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to retry job"})
+		return
+	}
+
+	payload, taskInfo, err := s.enqueueJob(r, job)
+	if errors.Is(err, queue.ErrDuplicateTask) {
+		s.writeExistingTask(w, r, job, taskInfo)
+		return
+	}
+	if err != nil {
+		if _, err := s.jobStore.UpdateStatus(r.Context(), job.ID, domain.JobStatusFailed); err != nil {
+			requestid.Logf(r.Context(), s.logger, "revert status failed for job %s: %v", job.ID, err)
+		}
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to enqueue job"})
 		return
 	}
+
+	response := enqueueResponse(job, payload, taskInfo)
+	response["retry_count"] = job.RetryCount
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// handleRenewUploadURL re-presigns the source upload of an s3_presigned job
+// that has not been uploaded yet, for clients whose original URL expired.
+func (s *Server) handleRenewUploadURL(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
+
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if job.SourceType != domain.SourceTypeS3Presigned && job.SourceType != domain.SourceTypeVideo {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("source_type=%s has no upload url", job.SourceType)})
+		return
+	}
+	if job.Status != domain.JobStatusCreated {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("upload url can only be renewed before the job starts (status=%s)", job.Status)})
+		return
+	}
+	exists, err := s.storage.ObjectExists(r.Context(), job.ObjectKey)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "check source object failed for job %s: %v", job.ID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to verify source object"})
+		return
+	}
+	if exists {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "source object already uploaded"})
+		return
+	}
+
+	url, err := s.storage.PresignedPutURL(r.Context(), job.ObjectKey, s.presignTTL)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "generate presigned url failed for job %s: %v", job.ID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": errUploadURL.Error()})
+		return
+	}
+
+	response := map[string]any{
+		"job_id":            job.ID,
+		"object_key":        job.ObjectKey,
+		"presigned_put_url": url,
+		"expires_at":        time.Now().UTC().Add(s.presignTTL),
+		"start_url":         fmt.Sprintf("/v1/jobs/%s/start", job.ID),
+	}
+	if headers := s.presignedPutHeaders(); len(headers) > 0 {
+		response["presigned_put_headers"] = headers
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) enqueueJob(r *http.Request, job domain.Job) (queue.ProcessImagePayload, *asynq.TaskInfo, error) {
+	requestedAt := time.Now().UTC()
+	payload := queue.ProcessImagePayload{
+		JobID:           job.ID,
+		SourceType:      job.SourceType,
+		WebhookURL:      job.WebhookURL,
+		WebhookHeaders:  job.WebhookHeaders,
+		ObjectKey:       job.ObjectKey,
+		Pipeline:        job.Pipeline,
+		RequestedAt:     requestedAt,
+		DeadlineSeconds: job.DeadlineSeconds,
+		MaxRetry:        job.MaxRetry,
+		TimeoutSeconds:  job.TimeoutSeconds,
+		EmitSidecar:     job.EmitSidecar,
+		EmitManifest:    job.EmitManifest,
+		DeleteSource:    job.DeleteSource,
+		OutputSubdir:    job.OutputSubdir,
+		FrameAtSeconds:  job.FrameAtSeconds,
+		RetryCount:      job.RetryCount,
+		RequestID:       requestid.FromContext(r.Context()),
+		UserID:          job.UserID,
+	}
+	if deadline := job.Deadline(); deadline > 0 {
+		payload.DeadlineAt = requestedAt.Add(deadline)
+	}
+
+	taskInfo, err := s.queueClient.EnqueueProcessImage(r.Context(), job.Queue, payload)
+	if errors.Is(err, queue.ErrDuplicateTask) {
+		requestid.Logf(r.Context(), s.logger, "duplicate enqueue ignored for job %s", job.ID)
+		return payload, taskInfo, err
+	}
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "enqueue failed for job %s: %v", job.ID, err)
+		return payload, nil, err
+	}
 	s.metrics.queueEnqueued.WithLabelValues(taskInfo.Queue).Inc()
+	return payload, taskInfo, nil
+}
 
-	if _, err := s.jobStore.UpdateStatus(r.Context(), job.ID, domain.JobStatusQueued); err != nil {
-		s.logger.Printf("update status failed for job %s: %v", job.ID, err)
+// writeExistingTask answers a start or retry for a job that already has a task
+// in the queue, without enqueueing it again. taskInfo may be nil, in which case
+// the task is looked up best-effort in the queue the job was started on.
+func (s *Server) writeExistingTask(w http.ResponseWriter, r *http.Request, job domain.Job, taskInfo *asynq.TaskInfo) {
+	if taskInfo == nil {
+		taskInfo, _ = s.queueClient.ProcessImageTaskInfo(r.Context(), job.Queue, job.ID, job.RetryCount)
 	}
 
-	writeJSON(w, http.StatusAccepted, map[string]any{
+	status := job.Status
+	if status == domain.JobStatusCreated {
+		status = domain.JobStatusQueued
+	}
+	response := map[string]any{
+		"job_id":    job.ID,
+		"status":    status,
+		"task_id":   queue.ProcessImageTaskID(job.ID, job.RetryCount),
+		"duplicate": true,
+	}
+	if taskInfo != nil {
+		response["queue"] = taskInfo.Queue
+		response["state"] = taskInfo.State.String()
+		response["enqueued_at"] = taskInfo.NextProcessAt
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func enqueueResponse(job domain.Job, payload queue.ProcessImagePayload, taskInfo *asynq.TaskInfo) map[string]any {
+	response := map[string]any{
 		"job_id":      job.ID,
 		"status":      domain.JobStatusQueued,
 		"queue":       taskInfo.Queue,
 		"task_id":     taskInfo.ID,
 		"state":       taskInfo.State.String(),
 		"enqueued_at": taskInfo.NextProcessAt,
+	}
+	if !payload.DeadlineAt.IsZero() {
+		response["deadline_at"] = payload.DeadlineAt
+	}
+	return response
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
+	if jobID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected path format /v1/jobs/{id}"})
+		return
+	}
+
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+
+	var deliveries []domain.WebhookDelivery
+	if s.webhookDeliveries != nil && job.WebhookURL != "" {
+		deliveries, err = s.webhookDeliveries.ListWebhookDeliveries(r.Context(), job.ID)
+		if err != nil {
+			requestid.Logf(r.Context(), s.logger, "fetch webhook deliveries failed for job %s: %v", job.ID, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+			return
+		}
+	}
+
+	etag := jobETag(job, deliveries)
+	w.Header().Set("Cache-Control", s.jobCacheControl(job, deliveries))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	response := jobStatusResponse(job)
+	if len(deliveries) > 0 {
+		response["webhook_deliveries"] = webhookDeliveriesResponse(deliveries)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// jobETag is a weak validator for the status response; every status change
+// bumps updated_at, so the pair identifies the representation. Webhook
+// deliveries are tracked separately, so their latest update is folded in.
+func jobETag(job domain.Job, deliveries []domain.WebhookDelivery) string {
+	if len(deliveries) == 0 {
+		return fmt.Sprintf(`W/"%x-%s"`, job.UpdatedAt.UnixNano(), job.Status)
+	}
+	var latest time.Time
+	attempts := 0
+	for _, delivery := range deliveries {
+		if delivery.UpdatedAt.After(latest) {
+			latest = delivery.UpdatedAt
+		}
+		attempts += delivery.Attempts
+	}
+	return fmt.Sprintf(`W/"%x-%s-%x-%d-%d"`, job.UpdatedAt.UnixNano(), job.Status, latest.UnixNano(), len(deliveries), attempts)
+}
+
+func webhookDeliveriesResponse(deliveries []domain.WebhookDelivery) []map[string]any {
+	items := make([]map[string]any, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		item := map[string]any{
+			"event":      delivery.Event,
+			"status":     delivery.Status,
+			"attempts":   delivery.Attempts,
+			"updated_at": delivery.UpdatedAt,
+		}
+		if delivery.LastStatusCode != 0 {
+			item["last_status_code"] = delivery.LastStatusCode
+		}
+		if delivery.LastError != "" {
+			item["last_error"] = delivery.LastError
+		}
+		if !delivery.DeliveredAt.IsZero() {
+			item["delivered_at"] = delivery.DeliveredAt
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	jobID := strings.TrimSpace(r.PathValue("id"))
+	if jobID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected path format /v1/jobs/{id}"})
+		return
+	}
+
+	job, ok, err := s.jobStore.Get(r.Context(), jobID)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "fetch job failed for job %s: %v", jobID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load job"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	// A job with a task in flight would write its outputs again after the
+	// delete, so it has to be cancelled first.
+	if job.Status != domain.JobStatusCreated && !domain.IsTerminalStatus(job.Status) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("job is still %s; cancel it before deleting", job.Status)})
+		return
+	}
+
+	s.deleteJobObjects(r.Context(), job)
+
+	if err := s.jobStore.Delete(r.Context(), job.ID); err != nil {
+		if errors.Is(err, store.ErrJobNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+			return
+		}
+		requestid.Logf(r.Context(), s.logger, "delete job failed for job %s: %v", job.ID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete job"})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"job_id": job.ID,
+		"status": "deleted",
 	})
 }
 
+func (s *Server) deleteJobObjects(ctx context.Context, job domain.Job) {
+	if job.SourceType != domain.SourceTypeLocalFile && job.SourceType != domain.SourceTypeHTTPURL && strings.TrimSpace(job.ObjectKey) != "" {
+		if err := s.storage.DeleteObject(ctx, job.ObjectKey); err != nil {
+			requestid.Logf(ctx, s.logger, "delete source object failed for job %s: %v", job.ID, err)
+		}
+	}
+
+	for _, outputPath := range job.OutputPaths {
+		var err error
+		if job.SourceType == domain.SourceTypeLocalFile {
+			if err = os.Remove(outputPath); errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = s.storage.DeleteObject(ctx, outputPath)
+		}
+		if err != nil {
+			requestid.Logf(ctx, s.logger, "delete output %s failed for job %s: %v", outputPath, job.ID, err)
+		}
+	}
+
+	// Outputs of a run that failed part way are never recorded; for object
+	// jobs they still sit under the default prefix.
+	if job.SourceType != domain.SourceTypeLocalFile {
+		outputPrefix := "outputs/" + domain.SanitizePathToken(job.ID) + "/"
+		if _, err := s.storage.DeletePrefix(ctx, outputPrefix); err != nil {
+			requestid.Logf(ctx, s.logger, "delete output objects failed for job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// jobCacheControl lets clients cache a job only once its response can no
+// longer change: failed jobs may still be retried, and pending webhook
+// deliveries keep updating. Responses are per-user, so never shared caches.
+func (s *Server) jobCacheControl(job domain.Job, deliveries []domain.WebhookDelivery) string {
+	if s.terminalCacheMaxAge <= 0 {
+		return "no-store"
+	}
+	switch job.Status {
+	case domain.JobStatusSucceeded, domain.JobStatusCancelled, domain.JobStatusDeadlineExceeded:
+	default:
+		return "no-store"
+	}
+	for _, delivery := range deliveries {
+		if delivery.Status == domain.WebhookDeliveryPending {
+			return "no-store"
+		}
+	}
+	return fmt.Sprintf("private, max-age=%d", int(s.terminalCacheMaxAge.Seconds()))
+}
+
+func jobStatusResponse(job domain.Job) map[string]any {
+	response := map[string]any{
+		"job_id":             job.ID,
+		"status":             job.Status,
+		"source_type":        job.SourceType,
+		"object_key":         job.ObjectKey,
+		"deadline_seconds":   job.DeadlineSeconds,
+		"processing_time_ms": job.ProcessingTimeMS,
+		"retry_count":        job.RetryCount,
+		"timeout_seconds":    job.TimeoutSeconds,
+		"created_at":         job.CreatedAt,
+		"updated_at":         job.UpdatedAt,
+	}
+	if job.ErrorMessage != "" && job.Status != domain.JobStatusSucceeded {
+		response["error_message"] = job.ErrorMessage
+	}
+	if job.MaxRetry != nil {
+		response["max_retry"] = *job.MaxRetry
+	}
+	if job.OutputSubdir != "" {
+		response["output_subdir"] = job.OutputSubdir
+	}
+	if job.SourceType == domain.SourceTypeVideo {
+		response["frame_at_seconds"] = job.FrameAtSeconds
+	}
+	return response
+}
+
 func (s *Server) verifySourceExists(ctx context.Context, job domain.Job) error {
 	switch job.SourceType {
 	case domain.SourceTypeLocalFile:
@@ -243,7 +1093,11 @@ func (s *Server) verifySourceExists(ctx context.Context, job domain.Job) error {
 		}
 		return nil
 	default:
-		exists, err := s.storage.ObjectExists(ctx, job.ObjectKey)
+		source, err := s.sourceFor(job)
+		if err != nil {
+			return err
+		}
+		exists, err := source.ObjectExists(ctx, job.ObjectKey)
 		if err != nil {
 			return fmt.Errorf("source object check failed: %w", err)
 		}
@@ -254,6 +1108,82 @@ func (s *Server) verifySourceExists(ctx context.Context, job domain.Job) error {
 	}
 }
 
+func (s *Server) sourceFor(job domain.Job) (sourceReader, error) {
+	if job.SourceType != domain.SourceTypeHTTPURL {
+		return s.storage, nil
+	}
+	if s.httpSource == nil {
+		return nil, errHTTPSourceDisabled
+	}
+	return s.httpSource, nil
+}
+
+func (s *Server) verifySourceContentType(ctx context.Context, job domain.Job) error {
+	var (
+		head []byte
+		err  error
+	)
+	switch job.SourceType {
+	case domain.SourceTypeLocalFile:
+		head, err = readFileHead(job.ObjectKey, sniffLen)
+	default:
+		var source sourceReader
+		if source, err = s.sourceFor(job); err == nil {
+			head, err = source.ReadObjectHead(ctx, job.ObjectKey, sniffLen)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("source content check failed: %w", err)
+	}
+
+	if job.SourceType == domain.SourceTypeVideo {
+		if _, err := video.Container(head); err != nil {
+			return fmt.Errorf("%w: %s", errUnsupportedSourceType, detectedType(head))
+		}
+		return nil
+	}
+	format := pipeline.SniffInputFormat(head)
+	if format == "" {
+		return fmt.Errorf("%w: %s", errUnsupportedSourceType, detectedType(head))
+	}
+	if !s.allowedSourceTypes[format] {
+		return fmt.Errorf("%w: %s", errUnsupportedSourceType, format)
+	}
+	return nil
+}
+
+// detectedType names a source that is not an accepted image or video by its
+// sniffed media type.
+func detectedType(head []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+func writeSourceContentTypeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnsupportedSourceType) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+func readFileHead(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
+
+func inputFormatSet(formats []string) map[string]bool {
+	set := make(map[string]bool, len(formats))
+	for _, format := range formats {
+		set[pipeline.NormalizeInputFormat(format)] = true
+	}
+	return set
+}
+
 func extractJobIDFromStartPath(path string) (string, error) {
 	trimmed := strings.TrimPrefix(path, "/v1/jobs/")
 	parts := strings.Split(strings.Trim(trimmed, "/"), "/")
@@ -263,15 +1193,34 @@ func extractJobIDFromStartPath(path string) (string, error) {
 	return parts[0], nil
 }
 
-func decodeJSON(r *http.Request, into any) error {
-	const maxBodyBytes = 1 << 20
-	limited := io.LimitReader(r.Body, maxBodyBytes)
-	decoder := json.NewDecoder(limited)
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, into any) bool {
+	err := decodeJSON(http.MaxBytesReader(w, r.Body, s.maxBodyBytes), into)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+		})
+		return false
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	return false
+}
+
+func decodeJSON(body io.Reader, into any) error {
+	decoder := json.NewDecoder(body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(into); err != nil {
 		return fmt.Errorf("invalid JSON body: %w", err)
 	}
 	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
 		return errors.New("invalid JSON body: multiple JSON values are not allowed")
 	}
 	return nil
@@ -4,16 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
 	"github.com/dunamismax/pixelflow/internal/queue"
 	"github.com/dunamismax/pixelflow/internal/ratelimit"
+	"github.com/dunamismax/pixelflow/internal/storage"
 	"github.com/dunamismax/pixelflow/internal/store"
 	"github.com/hibiken/asynq"
 )
@@ -32,6 +39,47 @@ func TestExtractJobIDFromStartPath(t *testing.T) {
 	}
 }
 
+func TestReadyzReportsDependencyStatus(t *testing.T) {
+	queueClient := &fakeQueueClient{pingErr: errors.New("redis down")}
+	server := NewServer(testLogger(t), queueClient, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if body.Checks["queue"] != "error" {
+		t.Fatalf("expected queue failure, got %v", body.Checks)
+	}
+	if strings.Contains(rec.Body.String(), "redis down") {
+		t.Fatalf("expected dependency error details to stay out of the response: %s", rec.Body.String())
+	}
+	if body.Checks["storage"] != "ok" {
+		t.Fatalf("expected storage ok, got %v", body.Checks)
+	}
+
+	queueClient.pingErr = nil
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d once dependencies recover, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected liveness to stay ok, got %d", rec.Code)
+	}
+}
+
 func TestCreateJobReturnsPresignedURLForS3Source(t *testing.T) {
 	jobStore := store.NewMemoryJobStore()
 	storageClient := &fakeStorage{
@@ -75,155 +123,1967 @@ func TestCreateJobReturnsPresignedURLForS3Source(t *testing.T) {
 	if got := upload["presigned_put_url"]; got != "http://minio.local/presigned-put" {
 		t.Fatalf("expected presigned URL in response, got %v", got)
 	}
+	if _, ok := upload["presigned_put_headers"]; ok {
+		t.Fatalf("expected no upload headers without encryption, got %v", upload["presigned_put_headers"])
+	}
 }
 
-func TestStartJobRejectsMissingSourceObject(t *testing.T) {
-	jobStore := store.NewMemoryJobStore()
-	if err := jobStore.Create(context.Background(), domain.Job{
-		ID:         "job-1",
-		Status:     domain.JobStatusCreated,
-		SourceType: domain.SourceTypeS3Presigned,
-		ObjectKey:  "uploads/job-1/source",
-		Pipeline: []domain.PipelineStep{
-			{ID: "thumb", Action: "resize", Width: 100},
-		},
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-	}); err != nil {
-		t.Fatalf("create seed job: %v", err)
-	}
+type encryptedFakeStorage struct {
+	fakeStorage
+}
 
-	queueClient := &fakeQueueClient{}
-	server := NewServer(
-		testLogger(t),
-		queueClient,
-		jobStore,
-		&fakeStorage{exists: false},
-		15*time.Minute,
-	)
+func (e *encryptedFakeStorage) PresignedPutHeaders() map[string]string {
+	return map[string]string{"X-Amz-Server-Side-Encryption": "AES256"}
+}
 
-	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+func TestCreateJobReturnsEncryptionUploadHeaders(t *testing.T) {
+	storageClient := &encryptedFakeStorage{fakeStorage{presignedURL: "http://minio.local/presigned-put"}}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), storageClient, 15*time.Minute)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{"source_type":"s3_presigned","pipeline":[{"id":"thumb","action":"resize","width":120}]}`))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
 
-	if rec.Code != http.StatusConflict {
-		t.Fatalf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	var body struct {
+		Upload struct {
+			Headers map[string]string `json:"presigned_put_headers"`
+		} `json:"upload"`
 	}
-	if queueClient.called {
-		t.Fatal("expected enqueue to be skipped when source object is missing")
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if body.Upload.Headers["X-Amz-Server-Side-Encryption"] != "AES256" {
+		t.Fatalf("expected sse upload header, got %v", body.Upload.Headers)
 	}
 }
 
-func TestCreateJobPersistsAnonymousUserIDByDefault(t *testing.T) {
+func TestRenewUploadURL(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	storageClient := &fakeStorage{presignedURL: "http://minio.local/renewed-put"}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, storageClient, 15*time.Minute)
+
+	now := time.Now().UTC()
+	for _, job := range []domain.Job{
+		{ID: "job-created", Status: domain.JobStatusCreated, SourceType: domain.SourceTypeS3Presigned, ObjectKey: "uploads/job-created/source", CreatedAt: now, UpdatedAt: now},
+		{ID: "job-queued", Status: domain.JobStatusQueued, SourceType: domain.SourceTypeS3Presigned, ObjectKey: "uploads/job-queued/source", CreatedAt: now, UpdatedAt: now},
+		{ID: "job-local", Status: domain.JobStatusCreated, SourceType: domain.SourceTypeLocalFile, ObjectKey: "/tmp/in.png", CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := jobStore.Create(context.Background(), job); err != nil {
+			t.Fatalf("seed job: %v", err)
+		}
+	}
+
+	renew := func(jobID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+jobID+"/upload-url", nil))
+		return rec
+	}
+
+	rec := renew("job-created")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if body["presigned_put_url"] != "http://minio.local/renewed-put" || body["object_key"] != "uploads/job-created/source" {
+		t.Fatalf("unexpected renew response %v", body)
+	}
+
+	for jobID, want := range map[string]int{
+		"job-queued":  http.StatusConflict,
+		"job-local":   http.StatusConflict,
+		"job-missing": http.StatusNotFound,
+	} {
+		if rec := renew(jobID); rec.Code != want {
+			t.Fatalf("%s: expected status %d, got %d", jobID, want, rec.Code)
+		}
+	}
+
+	storageClient.exists = true
+	if rec := renew("job-created"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected conflict once the source exists, got %d", rec.Code)
+	}
+}
+
+func TestCreateJobReturnsMultipartPlanForLargeUpload(t *testing.T) {
 	jobStore := store.NewMemoryJobStore()
+	storageClient := &fakeStorage{uploadID: "upload-1"}
 	server := NewServer(
 		testLogger(t),
 		&fakeQueueClient{},
 		jobStore,
-		&fakeStorage{presignedURL: "http://minio.local/presigned-put"},
+		storageClient,
 		15*time.Minute,
+		WithMultipartUpload(100<<20, 64<<20),
 	)
 
-	reqBody := `{
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{
 		"source_type":"s3_presigned",
+		"content_length":209715200,
 		"pipeline":[{"id":"thumb","action":"resize","width":120}]
-	}`
-	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(reqBody))
-	req.Header.Set("Content-Type", "application/json")
-
+	}`))
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
 
 	if rec.Code != http.StatusAccepted {
-		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
 	}
 
-	var body map[string]any
+	var body struct {
+		JobID  string `json:"job_id"`
+		Upload struct {
+			State     string `json:"presigned_url_state"`
+			Multipart struct {
+				UploadID    string `json:"upload_id"`
+				PartSize    int64  `json:"part_size"`
+				CompleteURL string `json:"complete_url"`
+				Parts       []struct {
+					PartNumber int    `json:"part_number"`
+					URL        string `json:"url"`
+				} `json:"parts"`
+			} `json:"multipart"`
+		} `json:"upload"`
+	}
 	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
 		t.Fatalf("unmarshal response: %v", err)
 	}
-	jobID, ok := body["job_id"].(string)
-	if !ok || jobID == "" {
-		t.Fatalf("expected job_id string, got %v", body["job_id"])
+	if body.Upload.State != "multipart_ready" {
+		t.Fatalf("expected presigned_url_state=multipart_ready, got %s", body.Upload.State)
+	}
+	if body.Upload.Multipart.UploadID != "upload-1" || body.Upload.Multipart.PartSize != 64<<20 {
+		t.Fatalf("unexpected multipart plan: %+v", body.Upload.Multipart)
+	}
+	if len(body.Upload.Multipart.Parts) != 4 {
+		t.Fatalf("expected 4 parts for 200MiB at 64MiB, got %d", len(body.Upload.Multipart.Parts))
 	}
 
-	job, found, err := jobStore.Get(context.Background(), jobID)
-	if err != nil {
-		t.Fatalf("fetch job: %v", err)
+	completeReq := httptest.NewRequest(http.MethodPost, body.Upload.Multipart.CompleteURL, bytes.NewBufferString(`{
+		"upload_id":"upload-1",
+		"parts":[{"part_number":1,"etag":"a"},{"part_number":2,"etag":"b"},{"part_number":3,"etag":"c"},{"part_number":4,"etag":"d"}]
+	}`))
+	completeRec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(completeRec, completeReq)
+
+	if completeRec.Code != http.StatusOK {
+		t.Fatalf("expected complete status %d, got %d: %s", http.StatusOK, completeRec.Code, completeRec.Body.String())
 	}
-	if !found {
-		t.Fatal("expected job to be persisted")
+	if storageClient.completedKey != "uploads/"+body.JobID+"/source" || len(storageClient.completedParts) != 4 {
+		t.Fatalf("unexpected completion key=%s parts=%v", storageClient.completedKey, storageClient.completedParts)
 	}
-	if job.UserID != "anonymous" {
-		t.Fatalf("expected user_id=anonymous, got %s", job.UserID)
+}
+
+func TestCompleteUploadChecksJobFirst(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	for id, job := range map[string]domain.Job{
+		"job-url":     {SourceType: domain.SourceTypeHTTPURL, Status: domain.JobStatusCreated, ObjectKey: "https://images.example.com/cat.png"},
+		"job-started": {SourceType: domain.SourceTypeS3Presigned, Status: domain.JobStatusQueued, ObjectKey: "uploads/job-started/source"},
+		"job-created": {SourceType: domain.SourceTypeS3Presigned, Status: domain.JobStatusCreated, ObjectKey: "uploads/job-created/source"},
+	} {
+		job.ID = id
+		job.Pipeline = []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}}
+		job.CreatedAt = time.Now().UTC()
+		job.UpdatedAt = job.CreatedAt
+		if err := jobStore.Create(context.Background(), job); err != nil {
+			t.Fatalf("seed job: %v", err)
+		}
+	}
+	storageClient := &fakeStorage{}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, storageClient, 15*time.Minute)
+
+	complete := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+id+"/upload/complete", bytes.NewBufferString(`{
+			"upload_id":"upload-1",
+			"parts":[{"part_number":1,"etag":"a"}]
+		}`)))
+		return rec
+	}
+
+	if rec := complete("job-url"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for an http_url job, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	if len(storageClient.aborted) != 0 || storageClient.completedKey != "" {
+		t.Fatalf("expected an http_url job to never reach storage, got aborted=%v completed=%q", storageClient.aborted, storageClient.completedKey)
+	}
+
+	if rec := complete("job-started"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a started job, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	if storageClient.completedKey != "" {
+		t.Fatalf("expected a started job's upload not to be completed, got %q", storageClient.completedKey)
+	}
+	if len(storageClient.aborted) != 1 || storageClient.aborted[0] != "uploads/job-started/source#upload-1" {
+		t.Fatalf("expected the started job's upload to be aborted, got %v", storageClient.aborted)
+	}
+
+	storageClient.completeErr = errors.New("InvalidPart")
+	if rec := complete("job-created"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d when completion fails, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	if len(storageClient.aborted) != 2 || storageClient.aborted[1] != "uploads/job-created/source#upload-1" {
+		t.Fatalf("expected the failed upload to be aborted, got %v", storageClient.aborted)
 	}
 }
 
-func TestRateLimitMiddlewareRejectsWhenBucketDenied(t *testing.T) {
+func TestCreateJobBatchReturnsPerItemResults(t *testing.T) {
 	jobStore := store.NewMemoryJobStore()
+	limiter := &fakeRateLimiter{decision: ratelimit.Decision{Allowed: true, Remaining: 10}}
 	server := NewServer(
 		testLogger(t),
 		&fakeQueueClient{},
 		jobStore,
 		&fakeStorage{presignedURL: "http://minio.local/presigned-put"},
 		15*time.Minute,
-		WithRateLimiter(&fakeRateLimiter{
-			decision: ratelimit.Decision{Allowed: false, Remaining: 0, RetryAfter: 2 * time.Second},
-		}, "X-User-ID"),
+		WithRateLimiter(limiter, "X-User-ID"),
 	)
 
-	reqBody := `{
-		"source_type":"s3_presigned",
-		"pipeline":[{"id":"thumb","action":"resize","width":120}]
-	}`
-	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(reqBody))
-	req.Header.Set("Content-Type", "application/json")
+	reqBody := `[
+		{"source_type":"s3_presigned","pipeline":[{"id":"thumb","action":"resize","width":120}]},
+		{"source_type":"s3_presigned","pipeline":[]},
+		{"source_type":"s3_presigned","pipeline":[{"id":"small","action":"resize","width":64}]}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/batch", bytes.NewBufferString(reqBody))
 	req.Header.Set("X-User-ID", "alice")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if len(limiter.costs) != 1 || limiter.costs[0] != 3 || limiter.subjects[0] != "alice:/v1/jobs" {
+		t.Fatalf("expected one create-limiter charge of 3, got subjects=%v costs=%v", limiter.subjects, limiter.costs)
+	}
+
+	var body struct {
+		Jobs []map[string]any `json:"jobs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if len(body.Jobs) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(body.Jobs))
+	}
+	if _, ok := body.Jobs[1]["error"].(string); !ok {
+		t.Fatalf("expected validation error for item 1, got %v", body.Jobs[1])
+	}
+	for _, i := range []int{0, 2} {
+		jobID, _ := body.Jobs[i]["job_id"].(string)
+		if jobID == "" {
+			t.Fatalf("expected job_id for item %d, got %v", i, body.Jobs[i])
+		}
+		job, ok, err := jobStore.Get(context.Background(), jobID)
+		if err != nil || !ok {
+			t.Fatalf("expected job %s to be persisted, ok=%v err=%v", jobID, ok, err)
+		}
+		if job.UserID != "alice" {
+			t.Fatalf("expected user_id=alice, got %s", job.UserID)
+		}
+		upload, _ := body.Jobs[i]["upload"].(map[string]any)
+		if upload["presigned_put_url"] != "http://minio.local/presigned-put" {
+			t.Fatalf("expected presigned URL for item %d, got %v", i, upload)
+		}
+	}
+}
 
+func TestCreateJobBatchRejectsOversizedBatch(t *testing.T) {
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute)
+
+	items := make([]string, maxBatchJobs+1)
+	for i := range items {
+		items[i] = `{"source_type":"local_file","object_key":"in.png","pipeline":[{"id":"thumb","action":"resize","width":10}]}`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/batch", bytes.NewBufferString("["+strings.Join(items, ",")+"]"))
 	rec := httptest.NewRecorder()
 	server.Handler().ServeHTTP(rec, req)
 
-	if rec.Code != http.StatusTooManyRequests {
-		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
 	}
-	if got := rec.Header().Get("Retry-After"); got != "2" {
-		t.Fatalf("expected retry-after=2, got %s", got)
+}
+
+func TestMultipartPlanRespectsPartLimit(t *testing.T) {
+	partSize, parts := multipartPlan(1<<40, 64<<20)
+	if parts > maxMultipartParts {
+		t.Fatalf("expected at most %d parts, got %d", maxMultipartParts, parts)
+	}
+	if partSize*int64(parts) < 1<<40 {
+		t.Fatalf("expected plan to cover content length, got %d x %d", partSize, parts)
 	}
 }
 
-type fakeQueueClient struct {
-	called bool
+func TestGetJobDisablesCachingWhileInProgress(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-running",
+		Status:     domain.JobStatusProcessing,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-running/source",
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/jobs/job-running", nil))
+
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Fatalf("expected Cache-Control=no-store for in-progress job, got %q", got)
+	}
 }
 
-func (f *fakeQueueClient) EnqueueProcessImage(_ context.Context, _ queue.ProcessImagePayload) (*asynq.TaskInfo, error) {
-	f.called = true
-	return &asynq.TaskInfo{
-		ID:            "task-1",
-		Queue:         "default",
-		State:         asynq.TaskStateActive,
-		NextProcessAt: time.Now().UTC(),
-	}, nil
+func TestGetJobHonorsIfNoneMatch(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	created := time.Now().UTC().Add(-time.Minute)
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-poll",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-poll/source",
+		CreatedAt:  created,
+		UpdatedAt:  created,
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/jobs/job-poll", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected 200 with weak ETag, got %d %q", first.Code, etag)
+	}
+
+	unchanged := get(`"other", ` + etag)
+	if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d %q", unchanged.Code, unchanged.Body.String())
+	}
+	if unchanged.Header().Get("ETag") != etag {
+		t.Fatalf("expected 304 to repeat ETag %q, got %q", etag, unchanged.Header().Get("ETag"))
+	}
+
+	if _, err := jobStore.UpdateStatus(context.Background(), "job-poll", domain.JobStatusProcessing); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	changed := get(etag)
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Fatalf("expected 200 with a new ETag after a status change, got %d %q", changed.Code, changed.Header().Get("ETag"))
+	}
 }
 
-type fakeStorage struct {
-	presignedURL string
-	exists       bool
+func TestGetJobReportsWebhookDeliveries(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	created := time.Now().UTC().Add(-time.Minute)
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-hooked",
+		Status:     domain.JobStatusSucceeded,
+		SourceType: domain.SourceTypeS3Presigned,
+		WebhookURL: "https://hooks.example.com",
+		ObjectKey:  "uploads/job-hooked/source",
+		CreatedAt:  created,
+		UpdatedAt:  created,
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+	if err := jobStore.RecordWebhookDelivery(context.Background(), domain.WebhookDelivery{
+		JobID:          "job-hooked",
+		Event:          "job.completed",
+		Status:         domain.WebhookDeliveryPending,
+		Attempts:       5,
+		LastStatusCode: http.StatusBadGateway,
+		LastError:      "webhook returned status=502",
+		UpdatedAt:      created.Add(time.Second),
+	}); err != nil {
+		t.Fatalf("record delivery: %v", err)
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/jobs/job-hooked", nil))
+		return rec
+	}
+
+	first := get()
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", first.Code)
+	}
+	var body struct {
+		WebhookDeliveries []struct {
+			Event          string `json:"event"`
+			Status         string `json:"status"`
+			Attempts       int    `json:"attempts"`
+			LastStatusCode int    `json:"last_status_code"`
+			LastError      string `json:"last_error"`
+		} `json:"webhook_deliveries"`
+	}
+	if err := json.Unmarshal(first.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(body.WebhookDeliveries) != 1 {
+		t.Fatalf("expected one webhook delivery, got %+v", body.WebhookDeliveries)
+	}
+	delivery := body.WebhookDeliveries[0]
+	if delivery.Event != "job.completed" || delivery.Status != domain.WebhookDeliveryPending || delivery.Attempts != 5 || delivery.LastStatusCode != http.StatusBadGateway || delivery.LastError == "" {
+		t.Fatalf("unexpected webhook delivery: %+v", delivery)
+	}
+
+	if err := jobStore.RecordWebhookDelivery(context.Background(), domain.WebhookDelivery{
+		JobID:          "job-hooked",
+		Event:          "job.completed",
+		Status:         domain.WebhookDeliveryDelivered,
+		Attempts:       1,
+		LastStatusCode: http.StatusOK,
+		UpdatedAt:      created.Add(2 * time.Second),
+		DeliveredAt:    created.Add(2 * time.Second),
+	}); err != nil {
+		t.Fatalf("record delivery: %v", err)
+	}
+	if second := get(); second.Header().Get("ETag") == first.Header().Get("ETag") {
+		t.Fatalf("expected ETag to change after a delivery update, got %q", second.Header().Get("ETag"))
+	}
 }
 
-func (f *fakeStorage) PresignedPutURL(_ context.Context, _ string, _ time.Duration) (string, error) {
-	return f.presignedURL, nil
+func TestDeleteJobRemovesRowAndObjects(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-delete",
+		Status:     domain.JobStatusSucceeded,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-delete/source",
+		OutputPaths: []string{
+			"outputs/job-delete/thumb.png",
+			"outputs/job-delete/thumb-1a2b3c4d.png",
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+	if err := jobStore.CreateUsageLog(context.Background(), domain.UsageLog{JobID: "job-delete", UserID: "alice"}); err != nil {
+		t.Fatalf("create seed usage: %v", err)
+	}
+
+	storageClient := &fakeStorage{}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, storageClient, 15*time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/jobs/job-delete", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	if _, ok, _ := jobStore.Get(context.Background(), "job-delete"); ok {
+		t.Fatal("expected job row to be deleted")
+	}
+	if _, ok, _ := jobStore.GetUsageLog(context.Background(), "job-delete"); ok {
+		t.Fatal("expected usage log to be deleted")
+	}
+	if strings.Join(storageClient.deleted, ",") != "uploads/job-delete/source,outputs/job-delete/thumb.png,outputs/job-delete/thumb-1a2b3c4d.png,outputs/job-delete/" {
+		t.Fatalf("unexpected deleted objects: %v", storageClient.deleted)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/jobs/job-delete", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for deleted job, got %d", http.StatusNotFound, rec.Code)
+	}
 }
 
-func (f *fakeStorage) ObjectExists(_ context.Context, _ string) (bool, error) {
-	return f.exists, nil
+func TestDeleteJobRemovesRecordedLocalOutputs(t *testing.T) {
+	// A custom output_subdir puts local outputs outside any job-id directory.
+	outputDir := filepath.Join(t.TempDir(), "batch-7")
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		t.Fatalf("create output dir: %v", err)
+	}
+	thumb := filepath.Join(outputDir, "thumb.png")
+	other := filepath.Join(outputDir, "other-job.png")
+	for _, path := range []string{thumb, other} {
+		if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+			t.Fatalf("write output: %v", err)
+		}
+	}
+
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:           "job-local",
+		Status:       domain.JobStatusSucceeded,
+		SourceType:   domain.SourceTypeLocalFile,
+		ObjectKey:    "/srv/in/photo.jpg",
+		OutputSubdir: "batch-7",
+		OutputPaths:  []string{thumb, filepath.Join(outputDir, "already-gone.png")},
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	storageClient := &fakeStorage{}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, storageClient, 15*time.Minute)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/jobs/job-local", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(thumb); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected recorded output to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("expected unrelated file to survive: %v", err)
+	}
+	if len(storageClient.deleted) != 0 {
+		t.Fatalf("expected no storage deletes for a local job, got %v", storageClient.deleted)
+	}
 }
 
-type fakeRateLimiter struct {
-	decision ratelimit.Decision
-	err      error
+func TestDeleteJobRefusesJobsWithTasksInFlight(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	for _, status := range []string{domain.JobStatusQueued, domain.JobStatusProcessing, domain.JobStatusOrphaned} {
+		if err := jobStore.Create(context.Background(), domain.Job{
+			ID:         "job-" + status,
+			Status:     status,
+			SourceType: domain.SourceTypeS3Presigned,
+			ObjectKey:  "uploads/job-" + status + "/source",
+			CreatedAt:  time.Now().UTC(),
+			UpdatedAt:  time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("create seed job: %v", err)
+		}
+	}
+
+	storageClient := &fakeStorage{}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, storageClient, 15*time.Minute)
+	for _, status := range []string{domain.JobStatusQueued, domain.JobStatusProcessing, domain.JobStatusOrphaned} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/jobs/job-"+status, nil))
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("%s: expected status %d, got %d", status, http.StatusConflict, rec.Code)
+		}
+		if _, ok, _ := jobStore.Get(context.Background(), "job-"+status); !ok {
+			t.Fatalf("%s: expected job row to be kept", status)
+		}
+	}
+	if len(storageClient.deleted) != 0 {
+		t.Fatalf("expected no objects deleted, got %v", storageClient.deleted)
+	}
+}
+
+func TestStorageUploadsGetSizeCapAndOwnDeadline(t *testing.T) {
+	fsStorage, err := storage.NewFilesystemStorage(storage.Config{
+		FilesystemRoot:          t.TempDir(),
+		FilesystemPublicURL:     "http://pixelflow.invalid",
+		FilesystemSigningSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("new filesystem storage: %v", err)
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), fsStorage, 15*time.Minute,
+		WithMaxUploadBytes(64),
+		WithUploadTimeout(5*time.Second),
+	)
+	srv := httptest.NewUnstartedServer(server.Handler())
+	// Far shorter than the upload below takes to arrive.
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	signedPut := func(objectKey string) string {
+		t.Helper()
+		rawURL, err := fsStorage.PresignedPutURL(context.Background(), objectKey, time.Minute)
+		if err != nil {
+			t.Fatalf("presign put: %v", err)
+		}
+		return srv.URL + strings.TrimPrefix(rawURL, "http://pixelflow.invalid")
+	}
+
+	// Trickle a body that fits the cap over longer than the read timeout.
+	body, writer := io.Pipe()
+	go func() {
+		for range 4 {
+			time.Sleep(75 * time.Millisecond)
+			_, _ = writer.Write([]byte("0123456789"))
+		}
+		_ = writer.Close()
+	}()
+	req, _ := http.NewRequest(http.MethodPut, signedPut("uploads/job-slow/source"), body)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("slow upload: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected slow upload to succeed, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, signedPut("uploads/job-big/source"), bytes.NewReader(make([]byte, 65)))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("oversized upload: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d for an upload over the cap, got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+	if exists, _ := fsStorage.ObjectExists(context.Background(), "uploads/job-big/source"); exists {
+		t.Fatal("expected the oversized upload not to be stored")
+	}
+}
+
+func TestUsageSummaryAggregatesPerUser(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, usage := range []domain.UsageLog{
+		{JobID: "a", UserID: "alice", PixelsProcessed: 100, BytesSaved: 10, ComputeTimeMS: 5, CreatedAt: day},
+		{JobID: "b", UserID: "alice", PixelsProcessed: 200, BytesSaved: 20, ComputeTimeMS: 7, CreatedAt: day.AddDate(0, 0, 2)},
+		{JobID: "c", UserID: "bob", PixelsProcessed: 999, BytesSaved: 99, ComputeTimeMS: 9, CreatedAt: day},
+	} {
+		if err := jobStore.CreateUsageLog(context.Background(), usage); err != nil {
+			t.Fatalf("seed usage: %v", err)
+		}
+	}
+
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	for _, tc := range []struct {
+		name   string
+		user   string
+		query  string
+		jobs   float64
+		pixels float64
+	}{
+		{name: "all time", user: "alice", jobs: 2, pixels: 300},
+		{name: "date range", user: "alice", query: "?from=2026-03-10&to=2026-03-10", jobs: 1, pixels: 100},
+		{name: "no usage", user: "carol", jobs: 0, pixels: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/usage"+tc.query, nil)
+			req.Header.Set("X-User-ID", tc.user)
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("unmarshal response: %v", err)
+			}
+			if body["user_id"] != tc.user || body["jobs"] != tc.jobs || body["pixels_processed"] != tc.pixels {
+				t.Fatalf("unexpected summary: %v", body)
+			}
+		})
+	}
+
+	bad := httptest.NewRecorder()
+	server.Handler().ServeHTTP(bad, httptest.NewRequest(http.MethodGet, "/v1/usage?from=yesterday", nil))
+	if bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for invalid range, got %d", http.StatusBadRequest, bad.Code)
+	}
+}
+
+func TestUsageLogsPaginatesNewestFirst(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		if err := jobStore.CreateUsageLog(context.Background(), domain.UsageLog{JobID: id, UserID: "alice", PixelsProcessed: 100, CreatedAt: day.AddDate(0, 0, i)}); err != nil {
+			t.Fatalf("seed usage: %v", err)
+		}
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	get := func(user, query string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/usage/logs"+query, nil)
+		req.Header.Set("X-User-ID", user)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		var body map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+	jobIDs := func(body map[string]any) []string {
+		var ids []string
+		for _, item := range body["logs"].([]any) {
+			ids = append(ids, item.(map[string]any)["job_id"].(string))
+		}
+		return ids
+	}
+
+	code, body := get("alice", "?limit=2")
+	if code != http.StatusOK || fmt.Sprint(jobIDs(body)) != "[c b]" || body["next_offset"] != float64(2) {
+		t.Fatalf("unexpected first page: %d %v", code, body)
+	}
+	code, body = get("alice", "?limit=2&offset=2")
+	if code != http.StatusOK || fmt.Sprint(jobIDs(body)) != "[a]" || body["next_offset"] != nil {
+		t.Fatalf("unexpected last page: %d %v", code, body)
+	}
+	code, body = get("alice", "?from=2026-03-11&to=2026-03-11")
+	if code != http.StatusOK || fmt.Sprint(jobIDs(body)) != "[b]" {
+		t.Fatalf("unexpected filtered page: %d %v", code, body)
+	}
+	code, body = get("carol", "")
+	if code != http.StatusOK || body["logs"] == nil || len(body["logs"].([]any)) != 0 {
+		t.Fatalf("expected empty page for user without usage, got %d %v", code, body)
+	}
+	if code, _ = get("alice", "?limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for limit=0, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestUsageLogsExportsCSV(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b"} {
+		if err := jobStore.CreateUsageLog(context.Background(), domain.UsageLog{JobID: id, UserID: "alice", PixelsProcessed: 100, BytesSaved: 5, ComputeTimeMS: 7, CreatedAt: day.AddDate(0, 0, i)}); err != nil {
+			t.Fatalf("seed usage: %v", err)
+		}
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	for name, req := range map[string]*http.Request{
+		"format param":  httptest.NewRequest(http.MethodGet, "/v1/usage/logs?format=csv", nil),
+		"accept header": httptest.NewRequest(http.MethodGet, "/v1/usage/logs", nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req.Header.Set("X-User-ID", "alice")
+			if name == "accept header" {
+				req.Header.Set("Accept", "text/csv, application/json;q=0.5")
+			}
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+				t.Fatalf("expected text/csv, got %q", ct)
+			}
+			if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") || !strings.Contains(cd, ".csv") {
+				t.Fatalf("expected csv attachment disposition, got %q", cd)
+			}
+			want := "job_id,user_id,pixels_processed,bytes_saved,compute_time_ms,created_at\n" +
+				"b,alice,100,5,7,2026-03-11T12:00:00Z\n" +
+				"a,alice,100,5,7,2026-03-10T12:00:00Z\n"
+			if rec.Body.String() != want {
+				t.Fatalf("unexpected csv:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
+
+// slowUsageStore delays each usage page so a CSV export outlasts a short
+// server WriteTimeout.
+type slowUsageStore struct {
+	*store.MemoryJobStore
+	delay time.Duration
+}
+
+func (s slowUsageStore) ListUsage(ctx context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error) {
+	time.Sleep(s.delay)
+	return s.MemoryJobStore.ListUsage(ctx, userID, from, to, limit, offset)
+}
+
+func TestUsageCSVExportOutlivesWriteTimeout(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	rows := 2*maxUsageLogLimit + 500
+	for i := 0; i < rows; i++ {
+		if err := jobStore.CreateUsageLog(context.Background(), domain.UsageLog{JobID: fmt.Sprintf("job-%d", i), UserID: "alice", CreatedAt: day.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("seed usage: %v", err)
+		}
+	}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, slowUsageStore{MemoryJobStore: jobStore, delay: 150 * time.Millisecond}, &fakeStorage{}, 15*time.Minute)
+
+	httpServer := httptest.NewUnstartedServer(server.Handler())
+	httpServer.Config.WriteTimeout = 250 * time.Millisecond
+	httpServer.Start()
+	defer httpServer.Close()
+
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/v1/usage/logs?format=csv", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("X-User-ID", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read export after %d bytes: %v", len(body), err)
+	}
+	if got := strings.Count(string(body), "\n"); got != rows+1 {
+		t.Fatalf("expected header and %d rows, got %d lines", rows, got)
+	}
+}
+
+func TestStartJobPropagatesRequestID(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusCreated,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-1/source",
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(testLogger(t), queueClient, jobStore, &fakeStorage{exists: true}, 15*time.Minute)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Fatalf("expected request id to be echoed, got %q", got)
+	}
+	if queueClient.payload.RequestID != "req-42" {
+		t.Fatalf("expected payload request_id=req-42, got %q", queueClient.payload.RequestID)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Header().Get("X-Request-ID"); len(got) != 32 {
+		t.Fatalf("expected a generated request id, got %q", got)
+	}
+}
+
+func TestStartJobRejectsMissingSourceObject(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusCreated,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-1/source",
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 100},
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(
+		testLogger(t),
+		queueClient,
+		jobStore,
+		&fakeStorage{exists: false},
+		15*time.Minute,
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if queueClient.called {
+		t.Fatal("expected enqueue to be skipped when source object is missing")
+	}
+}
+
+func TestHTTPURLSourceRequiresHTTPSourceAndChecksIt(t *testing.T) {
+	body := `{"source_type":"http_url","object_key":"https://images.example.com/cat.png","pipeline":[{"id":"thumb","action":"resize","width":100}]}`
+
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{exists: true}, 15*time.Minute)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected http_url to be rejected without an http source, got %d", rec.Code)
+	}
+
+	queueClient := &fakeQueueClient{}
+	objects := &fakeStorage{exists: false}
+	remote := &fakeStorage{exists: true}
+	server = NewServer(testLogger(t), queueClient, store.NewMemoryJobStore(), objects, 15*time.Minute, WithHTTPSource(remote))
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected create to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+created.JobID+"/start", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected start to check the url source, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if queueClient.payload.ObjectKey != "https://images.example.com/cat.png" {
+		t.Fatalf("expected url to be enqueued as the object key, got %q", queueClient.payload.ObjectKey)
+	}
+}
+
+func TestVideoSourceRequiresSupportAndVideoContent(t *testing.T) {
+	body := `{"source_type":"video","frame_at_seconds":2.5,"pipeline":[{"id":"poster","action":"resize","width":640}]}`
+
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{exists: true}, 15*time.Minute)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "ffmpeg") {
+		t.Fatalf("expected video to be rejected without support, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	queueClient := &fakeQueueClient{}
+	objects := &fakeStorage{
+		exists:       true,
+		presignedURL: "http://minio.local/presigned-put",
+		head:         []byte("<html><body>not a video</body></html>"),
+	}
+	server = NewServer(testLogger(t), queueClient, store.NewMemoryJobStore(), objects, 15*time.Minute, WithVideoSources(true))
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected create to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		JobID  string `json:"job_id"`
+		Upload struct {
+			PresignedPutURL string `json:"presigned_put_url"`
+		} `json:"upload"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+	if created.Upload.PresignedPutURL == "" {
+		t.Fatalf("expected a presigned upload url for the video, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+created.JobID+"/start", nil))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected non-video upload to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	objects.head = []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+created.JobID+"/start", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected QuickTime upload to start, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if queueClient.payload.SourceType != domain.SourceTypeVideo || queueClient.payload.FrameAtSeconds != 2.5 {
+		t.Fatalf("expected video payload with frame_at_seconds=2.5, got %+v", queueClient.payload)
+	}
+}
+
+func TestStartJobRejectsNonImageSource(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusCreated,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-1/source",
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 100},
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	queueClient := &fakeQueueClient{}
+	storageClient := &fakeStorage{exists: true, head: []byte("<html><body>not an image</body></html>")}
+	server := NewServer(testLogger(t), queueClient, jobStore, storageClient, 15*time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil))
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "text/html") {
+		t.Fatalf("expected detected type in error, got %s", rec.Body.String())
+	}
+	if queueClient.called {
+		t.Fatal("expected enqueue to be skipped for a non-image source")
+	}
+
+	// TIFF is sniffed by its header even though http.DetectContentType
+	// does not know it, and is only accepted once configured.
+	storageClient.head = []byte("II*\x00\x08\x00\x00\x00")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil))
+	if rec.Code != http.StatusUnsupportedMediaType || !strings.Contains(rec.Body.String(), "tiff") {
+		t.Fatalf("expected tiff to be rejected by default, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	server = NewServer(testLogger(t), queueClient, jobStore, storageClient, 15*time.Minute, WithAllowedSourceTypes([]string{"jpeg", "image/tiff"}))
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected configured format to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCreateJobRejectsOversizedBody(t *testing.T) {
+	server := NewServer(
+		testLogger(t),
+		&fakeQueueClient{},
+		store.NewMemoryJobStore(),
+		&fakeStorage{presignedURL: "http://minio.local/presigned-put"},
+		15*time.Minute,
+		WithMaxBodyBytes(64),
+	)
+
+	reqBody := `{
+		"source_type":"s3_presigned",
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(reqBody)))
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "request body exceeds 64 bytes") {
+		t.Fatalf("expected size limit message, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{"source_type":`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected malformed small body to stay a %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCreateJobRejectsTooManySteps(t *testing.T) {
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute, WithMaxPipelineSteps(2))
+
+	steps := make([]domain.PipelineStep, 3)
+	for i := range steps {
+		steps[i] = domain.PipelineStep{ID: fmt.Sprintf("step-%d", i), Action: "resize", Width: 10}
+	}
+	body, err := json.Marshal(domain.CreateJobRequest{SourceType: domain.SourceTypeS3Presigned, Pipeline: steps})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at most 2 are allowed") {
+		t.Fatalf("expected step cap rejection, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCreateJobCarriesRetryAndTimeoutOverrides(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	queueClient := &fakeQueueClient{}
+	server := NewServer(
+		testLogger(t),
+		queueClient,
+		jobStore,
+		&fakeStorage{exists: true},
+		15*time.Minute,
+		WithMaxTaskTimeout(10*time.Minute),
+	)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{
+		"source_type":"s3_presigned",
+		"timeout_seconds":900,
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "timeout_seconds must be at most 600") {
+		t.Fatalf("expected timeout ceiling rejection, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{
+		"source_type":"s3_presigned",
+		"max_retry":0,
+		"timeout_seconds":600,
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("unmarshal create response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+created.JobID+"/start", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected start status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if queueClient.payload.MaxRetry == nil || *queueClient.payload.MaxRetry != 0 {
+		t.Fatalf("expected max_retry=0 in payload, got %v", queueClient.payload.MaxRetry)
+	}
+	if queueClient.payload.TimeoutSeconds != 600 {
+		t.Fatalf("expected timeout_seconds=600 in payload, got %d", queueClient.payload.TimeoutSeconds)
+	}
+}
+
+func TestCreateJobPersistsAnonymousUserIDByDefault(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	server := NewServer(
+		testLogger(t),
+		&fakeQueueClient{},
+		jobStore,
+		&fakeStorage{presignedURL: "http://minio.local/presigned-put"},
+		15*time.Minute,
+	)
+
+	reqBody := `{
+		"source_type":"s3_presigned",
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	jobID, ok := body["job_id"].(string)
+	if !ok || jobID == "" {
+		t.Fatalf("expected job_id string, got %v", body["job_id"])
+	}
+
+	job, found, err := jobStore.Get(context.Background(), jobID)
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if !found {
+		t.Fatal("expected job to be persisted")
+	}
+	if job.UserID != "anonymous" {
+		t.Fatalf("expected user_id=anonymous, got %s", job.UserID)
+	}
+}
+
+func TestRateLimitMiddlewareRejectsWhenBucketDenied(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	server := NewServer(
+		testLogger(t),
+		&fakeQueueClient{},
+		jobStore,
+		&fakeStorage{presignedURL: "http://minio.local/presigned-put"},
+		15*time.Minute,
+		WithRateLimiter(&fakeRateLimiter{
+			decision: ratelimit.Decision{Allowed: false, Remaining: 0, RetryAfter: 2 * time.Second},
+		}, "X-User-ID"),
+	)
+
+	reqBody := `{
+		"source_type":"s3_presigned",
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "alice")
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected retry-after=2, got %s", got)
+	}
+}
+
+func TestCORSPreflightBypassesRateLimit(t *testing.T) {
+	limiter := &fakeRateLimiter{decision: ratelimit.Decision{Allowed: false, RetryAfter: time.Second}}
+	server := NewServer(
+		testLogger(t),
+		&fakeQueueClient{},
+		store.NewMemoryJobStore(),
+		&fakeStorage{},
+		15*time.Minute,
+		WithRateLimiter(limiter, "X-User-ID"),
+		WithCORS(CORSConfig{
+			AllowedOrigins: []string{"https://app.example.com", "*.pixelflow.dev"},
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Content-Type", "X-User-ID"},
+			MaxAge:         10 * time.Minute,
+		}),
+	)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/jobs", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, preflight)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("expected allowed origin to be echoed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Fatalf("expected allowed methods, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Fatalf("expected max-age=600, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-User-ID, X-Request-ID" {
+		t.Fatalf("expected allowed headers to include X-Request-ID, got %q", got)
+	}
+	if len(limiter.subjects) != 0 {
+		t.Fatalf("expected preflight to skip the rate limiter, got %v", limiter.subjects)
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	get.Header.Set("Origin", "https://preview.pixelflow.dev")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, get)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://preview.pixelflow.dev" {
+		t.Fatalf("expected wildcard subdomain to be allowed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Request-ID") {
+		t.Fatalf("expected X-Request-ID to be exposed, got %q", got)
+	}
+
+	get = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	get.Header.Set("Origin", "http://preview.pixelflow.dev:3000")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, get)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://preview.pixelflow.dev:3000" {
+		t.Fatalf("expected wildcard subdomain with a port to be allowed, got %q", got)
+	}
+
+	get = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	get.Header.Set("Origin", "https://pixelflow.dev.evil.com:8443")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, get)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected lookalike host to be refused, got %q", got)
+	}
+
+	get = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	get.Header.Set("Origin", "https://evil.example.org")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, get)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected unknown origin to be refused, got %q", got)
+	}
+}
+
+func TestRouteRateLimiterAppliesOnlyToItsRoute(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	defaultLimiter := &fakeRateLimiter{decision: ratelimit.Decision{Allowed: true, Remaining: 59}}
+	createLimiter := &fakeRateLimiter{decision: ratelimit.Decision{Allowed: false, RetryAfter: 30 * time.Second}}
+	server := NewServer(
+		testLogger(t),
+		&fakeQueueClient{},
+		jobStore,
+		&fakeStorage{presignedURL: "http://minio.local/presigned-put", exists: true},
+		15*time.Minute,
+		WithRateLimiter(defaultLimiter, "X-User-ID"),
+		WithRouteRateLimiter("/v1/jobs", createLimiter),
+	)
+
+	createReq := httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewBufferString(`{
+		"source_type":"s3_presigned",
+		"pipeline":[{"id":"thumb","action":"resize","width":120}]
+	}`))
+	createReq.Header.Set("X-User-ID", "alice")
+	createRec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(createRec, createReq)
+
+	if createRec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected create status %d, got %d", http.StatusTooManyRequests, createRec.Code)
+	}
+	if len(createLimiter.subjects) != 1 || createLimiter.subjects[0] != "alice:/v1/jobs" {
+		t.Fatalf("expected create limiter subject alice:/v1/jobs, got %v", createLimiter.subjects)
+	}
+
+	startReq := httptest.NewRequest(http.MethodPost, "/v1/jobs/missing/start", nil)
+	startReq.Header.Set("X-User-ID", "alice")
+	server.Handler().ServeHTTP(httptest.NewRecorder(), startReq)
+
+	if len(createLimiter.subjects) != 1 {
+		t.Fatalf("expected start route to bypass create limiter, got %v", createLimiter.subjects)
+	}
+	if len(defaultLimiter.subjects) != 1 || defaultLimiter.subjects[0] != "alice:/v1/jobs/{id}/start" {
+		t.Fatalf("expected default limiter subject alice:/v1/jobs/{id}/start, got %v", defaultLimiter.subjects)
+	}
+}
+
+func TestStartJobDerivesDeadlineFromJob(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:              "job-1",
+		Status:          domain.JobStatusCreated,
+		SourceType:      domain.SourceTypeS3Presigned,
+		ObjectKey:       "uploads/job-1/source",
+		DeadlineSeconds: 60,
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 100},
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(
+		testLogger(t),
+		queueClient,
+		jobStore,
+		&fakeStorage{exists: true},
+		15*time.Minute,
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if queueClient.payload.DeadlineSeconds != 60 {
+		t.Fatalf("expected deadline_seconds=60 in payload, got %d", queueClient.payload.DeadlineSeconds)
+	}
+	if got := queueClient.payload.DeadlineAt.Sub(queueClient.payload.RequestedAt); got != 60*time.Second {
+		t.Fatalf("expected deadline_at 60s after requested_at, got %s", got)
+	}
+}
+
+func TestStartJobIsIdempotent(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	for _, id := range []string{"job-1", "job-2"} {
+		if err := jobStore.Create(context.Background(), domain.Job{
+			ID:         id,
+			Status:     domain.JobStatusCreated,
+			SourceType: domain.SourceTypeS3Presigned,
+			ObjectKey:  "uploads/" + id + "/source",
+			Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+			CreatedAt:  time.Now().UTC(),
+			UpdatedAt:  time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("create seed job: %v", err)
+		}
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(testLogger(t), queueClient, jobStore, &fakeStorage{exists: true}, 15*time.Minute)
+
+	start := func(jobID string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+jobID+"/start", nil))
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		return rec.Code, body
+	}
+
+	if code, _ := start("job-1"); code != http.StatusAccepted {
+		t.Fatalf("expected first start status %d, got %d", http.StatusAccepted, code)
+	}
+	code, body := start("job-1")
+	if code != http.StatusOK || body["duplicate"] != true || body["status"] != domain.JobStatusQueued {
+		t.Fatalf("expected idempotent 200 for queued job, got %d %v", code, body)
+	}
+	if body["task_id"] != queue.ProcessImageTaskID("job-1", 0) {
+		t.Fatalf("unexpected task id %v", body["task_id"])
+	}
+	if queueClient.calls != 1 {
+		t.Fatalf("expected a single enqueue, got %d", queueClient.calls)
+	}
+
+	// A concurrent start that raced past the status check hits the task id conflict.
+	queueClient.taskIDs["default/"+queue.ProcessImageTaskID("job-2", 0)] = true
+	code, body = start("job-2")
+	if code != http.StatusOK || body["duplicate"] != true || body["queue"] != "default" {
+		t.Fatalf("expected duplicate task to be reported, got %d %v", code, body)
+	}
+}
+
+func TestStartJobRoutesTierToQueue(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	for _, id := range []string{"job-paid", "job-none"} {
+		if err := jobStore.Create(context.Background(), domain.Job{
+			ID:         id,
+			Status:     domain.JobStatusCreated,
+			SourceType: domain.SourceTypeS3Presigned,
+			ObjectKey:  "uploads/" + id + "/source",
+			Pipeline: []domain.PipelineStep{
+				{ID: "thumb", Action: "resize", Width: 100},
+			},
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("create seed job: %v", err)
+		}
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(
+		testLogger(t),
+		queueClient,
+		jobStore,
+		&fakeStorage{exists: true},
+		15*time.Minute,
+		WithQueueTiers("X-User-Tier", map[string]string{"Paid": "critical"}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-paid/start", nil)
+	req.Header.Set("X-User-Tier", "paid")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if queueClient.queueName != "critical" {
+		t.Fatalf("expected paid tier to enqueue on critical, got %q", queueClient.queueName)
+	}
+	if !strings.Contains(rec.Body.String(), `"queue":"critical"`) {
+		t.Fatalf("expected response to report critical queue, got %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/jobs/job-none/start", nil)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if queueClient.queueName != "" {
+		t.Fatalf("expected request without tier to use the default queue, got %q", queueClient.queueName)
+	}
+}
+
+func TestConcurrentStartsKeepJobOnOneQueue(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusCreated,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-1/source",
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+
+	queueClient := &fakeQueueClient{}
+	server := NewServer(
+		testLogger(t),
+		queueClient,
+		jobStore,
+		&fakeStorage{exists: true},
+		15*time.Minute,
+		WithQueueTiers("X-User-Tier", map[string]string{"paid": "critical", "free": "low"}),
+	)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+			req.Header.Set("X-User-Tier", []string{"paid", "free"}[i%2])
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, req)
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	if queueClient.calls != 1 {
+		t.Fatalf("expected exactly one enqueue across tiers, got %d", queueClient.calls)
+	}
+	accepted := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusAccepted:
+			accepted++
+		case http.StatusOK:
+		default:
+			t.Fatalf("unexpected start status %d", code)
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("expected one start to be accepted, got %d", accepted)
+	}
+
+	job, _, _ := jobStore.Get(context.Background(), "job-1")
+	if job.Queue != queueClient.queueName {
+		t.Fatalf("expected stored queue %q to match the enqueue, got %q", queueClient.queueName, job.Queue)
+	}
+
+	// A later start from the other tier reports the task on the stored queue.
+	other := map[string]string{"critical": "free", "low": "paid"}[job.Queue]
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/start", nil)
+	req.Header.Set("X-User-Tier", other)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queue":"`+job.Queue+`"`) {
+		t.Fatalf("expected duplicate start on queue %s, got %d: %s", job.Queue, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/cancel", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected cancel status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	want := job.Queue + "/" + queue.ProcessImageTaskID("job-1", 0)
+	if len(queueClient.deleted) != 1 || queueClient.deleted[0] != want {
+		t.Fatalf("expected cancel to delete %s, got %v", want, queueClient.deleted)
+	}
+}
+
+func TestRetryJobRequeuesFailedJob(t *testing.T) {
+	seed := func(t *testing.T, jobStore *store.MemoryJobStore, id, status string) {
+		t.Helper()
+		if err := jobStore.Create(context.Background(), domain.Job{
+			ID:         id,
+			Status:     status,
+			SourceType: domain.SourceTypeS3Presigned,
+			ObjectKey:  "uploads/" + id + "/source",
+			Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+			CreatedAt:  time.Now().UTC(),
+			UpdatedAt:  time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("seed job: %v", err)
+		}
+	}
+
+	jobStore := store.NewMemoryJobStore()
+	seed(t, jobStore, "job-failed", domain.JobStatusFailed)
+	seed(t, jobStore, "job-succeeded", domain.JobStatusSucceeded)
+	queueClient := &fakeQueueClient{}
+	storageClient := &fakeStorage{exists: true}
+	server := NewServer(testLogger(t), queueClient, jobStore, storageClient, 15*time.Minute, WithMaxJobRetries(1))
+
+	retry := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+id+"/retry", nil))
+		return rec
+	}
+
+	if rec := retry("job-succeeded"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for non-failed job, got %d", http.StatusConflict, rec.Code)
+	}
+
+	storageClient.exists = false
+	if rec := retry("job-failed"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for missing source, got %d", http.StatusConflict, rec.Code)
+	}
+	if queueClient.called {
+		t.Fatal("expected no enqueue while the source is missing")
+	}
+
+	storageClient.exists = true
+	rec := retry("job-failed")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if !queueClient.called || queueClient.payload.JobID != "job-failed" {
+		t.Fatalf("expected job-failed to be re-enqueued, got %+v", queueClient.payload)
+	}
+	job, _, err := jobStore.Get(context.Background(), "job-failed")
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if job.Status != domain.JobStatusQueued || job.RetryCount != 1 {
+		t.Fatalf("expected queued job with retry_count=1, got status=%s retry_count=%d", job.Status, job.RetryCount)
+	}
+
+	if _, err := jobStore.UpdateStatus(context.Background(), "job-failed", domain.JobStatusFailed); err != nil {
+		t.Fatalf("fail job again: %v", err)
+	}
+	if rec := retry("job-failed"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d once the retry limit is reached, got %d", http.StatusConflict, rec.Code)
+	}
+
+	if rec := retry("unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for unknown job, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+type fakeCanceller struct {
+	published []string
+}
+
+func (c *fakeCanceller) PublishCancel(_ context.Context, jobID string) (int64, error) {
+	c.published = append(c.published, jobID)
+	return 1, nil
+}
+
+func TestCancelJob(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	for id, status := range map[string]string{
+		"job-created":    domain.JobStatusCreated,
+		"job-processing": domain.JobStatusProcessing,
+		"job-succeeded":  domain.JobStatusSucceeded,
+	} {
+		if err := jobStore.Create(context.Background(), domain.Job{
+			ID:         id,
+			Status:     status,
+			SourceType: domain.SourceTypeS3Presigned,
+			ObjectKey:  "uploads/" + id + "/source",
+			Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+			CreatedAt:  time.Now().UTC(),
+			UpdatedAt:  time.Now().UTC(),
+		}); err != nil {
+			t.Fatalf("seed job: %v", err)
+		}
+	}
+	canceller := &fakeCanceller{}
+	withoutCanceller := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{exists: true}, 15*time.Minute)
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{exists: true}, 15*time.Minute, WithJobCanceller(canceller))
+
+	cancel := func(s *Server, id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/"+id+"/cancel", nil))
+		return rec
+	}
+
+	if rec := cancel(withoutCanceller, "job-processing"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d without a canceller, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := cancel(server, "job-succeeded"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for finished job, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := cancel(server, "unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for unknown job, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if rec := cancel(server, "job-created"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if len(canceller.published) != 0 {
+		t.Fatalf("expected no signal for a job that never started, got %v", canceller.published)
+	}
+	rec := cancel(server, "job-processing")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if len(canceller.published) != 1 || canceller.published[0] != "job-processing" {
+		t.Fatalf("expected job-processing to be signalled, got %v", canceller.published)
+	}
+	for _, id := range []string{"job-created", "job-processing"} {
+		job, _, _ := jobStore.Get(context.Background(), id)
+		if job.Status != domain.JobStatusCancelled {
+			t.Fatalf("expected %s status=%s, got %s", id, domain.JobStatusCancelled, job.Status)
+		}
+	}
+
+	startRec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(startRec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-created/start", nil))
+	if startRec.Code != http.StatusConflict {
+		t.Fatalf("expected cancelled job start to return %d, got %d", http.StatusConflict, startRec.Code)
+	}
+}
+
+// finishBeforeCancelStore lets the worker finish a job between the cancel
+// handler loading it and claiming the cancel.
+type finishBeforeCancelStore struct {
+	*store.MemoryJobStore
+}
+
+func (s finishBeforeCancelStore) ClaimCancel(ctx context.Context, id, errMsg string) (domain.Job, error) {
+	if _, err := s.FinishProcessing(ctx, id, domain.JobStatusSucceeded, "", time.Second); err != nil {
+		return domain.Job{}, err
+	}
+	return s.MemoryJobStore.ClaimCancel(ctx, id, errMsg)
+}
+
+func TestCancelJobLosesRaceToFinishingWorker(t *testing.T) {
+	jobStore := finishBeforeCancelStore{store.NewMemoryJobStore()}
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusProcessing,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-1/source",
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+	canceller := &fakeCanceller{}
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{exists: true}, 15*time.Minute, WithJobCanceller(canceller))
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/cancel", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	if len(canceller.published) != 0 {
+		t.Fatalf("expected no signal for a finished job, got %v", canceller.published)
+	}
+	job, _, _ := jobStore.Get(context.Background(), "job-1")
+	if job.Status != domain.JobStatusSucceeded || job.ErrorMessage != "" {
+		t.Fatalf("expected the finished job to keep its result, got status=%s error_message=%q", job.Status, job.ErrorMessage)
+	}
+}
+
+func TestGetJobReturnsStatusAndTiming(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:              "job-1",
+		Status:          domain.JobStatusProcessing,
+		SourceType:      domain.SourceTypeS3Presigned,
+		ObjectKey:       "uploads/job-1/source",
+		DeadlineSeconds: 30,
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 100},
+		},
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create seed job: %v", err)
+	}
+	if _, err := jobStore.FinishProcessing(context.Background(), "job-1", domain.JobStatusDeadlineExceeded, "job deadline exceeded", 30*time.Second); err != nil {
+		t.Fatalf("finish seed job: %v", err)
+	}
+
+	server := NewServer(testLogger(t), &fakeQueueClient{}, jobStore, &fakeStorage{}, 15*time.Minute)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/jobs/job-1", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if got := body["status"]; got != domain.JobStatusDeadlineExceeded {
+		t.Fatalf("expected status=%s, got %v", domain.JobStatusDeadlineExceeded, got)
+	}
+	if got := body["deadline_seconds"]; got != float64(30) {
+		t.Fatalf("expected deadline_seconds=30, got %v", got)
+	}
+	if got := body["processing_time_ms"]; got != float64(30_000) {
+		t.Fatalf("expected processing_time_ms=30000, got %v", got)
+	}
+	if got := body["error_message"]; got != "job deadline exceeded" {
+		t.Fatalf("expected error_message to be surfaced, got %v", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "private, max-age=300" {
+		t.Fatalf("expected terminal job to be privately cacheable, got Cache-Control=%q", got)
+	}
+
+	// A failed job can still be retried, so its status must not be cached.
+	if err := jobStore.RecordFailure(context.Background(), "job-1", "boom"); err != nil {
+		t.Fatalf("fail seed job: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/jobs/job-1", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Fatalf("expected failed job to use no-store, got Cache-Control=%q", got)
+	}
+
+	missing := httptest.NewRequest(http.MethodGet, "/v1/jobs/unknown", nil)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, missing)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for unknown job, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestJobCacheControlWaitsForWebhookDeliveries(t *testing.T) {
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute)
+	job := domain.Job{ID: "job-1", Status: domain.JobStatusSucceeded}
+
+	pending := []domain.WebhookDelivery{{Event: "job.completed", Status: domain.WebhookDeliveryPending}}
+	if got := server.jobCacheControl(job, pending); got != "no-store" {
+		t.Fatalf("expected no-store while a delivery is pending, got %q", got)
+	}
+	settled := []domain.WebhookDelivery{{Event: "job.completed", Status: domain.WebhookDeliveryDelivered}}
+	if got := server.jobCacheControl(job, settled); got != "private, max-age=300" {
+		t.Fatalf("expected settled deliveries to allow caching, got %q", got)
+	}
+}
+
+type fakeQueueClient struct {
+	mu        sync.Mutex
+	called    bool
+	calls     int
+	queueName string
+	payload   queue.ProcessImagePayload
+	pingErr   error
+	// taskIDs holds "queue/task id" keys, since asynq only dedups task ids
+	// within a queue.
+	taskIDs map[string]bool
+	deleted []string
+}
+
+func (f *fakeQueueClient) ProcessImageTaskInfo(_ context.Context, queueName, jobID string, retryCount int) (*asynq.TaskInfo, error) {
+	if queueName == "" {
+		queueName = "default"
+	}
+	return &asynq.TaskInfo{
+		ID:    queue.ProcessImageTaskID(jobID, retryCount),
+		Queue: queueName,
+		State: asynq.TaskStatePending,
+	}, nil
+}
+
+func (f *fakeQueueClient) Ping(_ context.Context) error {
+	return f.pingErr
+}
+
+func (f *fakeQueueClient) DeleteProcessImageTask(_ context.Context, queueName, jobID string, retryCount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if queueName == "" {
+		queueName = "default"
+	}
+	key := queueName + "/" + queue.ProcessImageTaskID(jobID, retryCount)
+	delete(f.taskIDs, key)
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func (f *fakeQueueClient) EnqueueProcessImage(_ context.Context, queueName string, payload queue.ProcessImagePayload) (*asynq.TaskInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called = true
+	f.calls++
+	f.queueName = queueName
+	f.payload = payload
+	if queueName == "" {
+		queueName = "default"
+	}
+	taskID := queueName + "/" + queue.ProcessImageTaskID(payload.JobID, payload.RetryCount)
+	if f.taskIDs[taskID] {
+		info, _ := f.ProcessImageTaskInfo(context.Background(), queueName, payload.JobID, payload.RetryCount)
+		return info, queue.ErrDuplicateTask
+	}
+	if f.taskIDs == nil {
+		f.taskIDs = make(map[string]bool)
+	}
+	f.taskIDs[taskID] = true
+	return &asynq.TaskInfo{
+		ID:            "task-1",
+		Queue:         queueName,
+		State:         asynq.TaskStateActive,
+		NextProcessAt: time.Now().UTC(),
+	}, nil
+}
+
+type fakeStorage struct {
+	presignedURL   string
+	exists         bool
+	head           []byte
+	uploadID       string
+	completedKey   string
+	completedParts []storage.CompletedPart
+	completeErr    error
+	aborted        []string
+	deleted        []string
+}
+
+func (f *fakeStorage) Ping(_ context.Context) error {
+	return nil
+}
+
+func (f *fakeStorage) PresignedPutURL(_ context.Context, _ string, _ time.Duration) (string, error) {
+	return f.presignedURL, nil
+}
+
+func (f *fakeStorage) ObjectExists(_ context.Context, _ string) (bool, error) {
+	return f.exists, nil
+}
+
+func (f *fakeStorage) ReadObjectHead(_ context.Context, _ string, _ int64) ([]byte, error) {
+	if f.head == nil {
+		return []byte("\x89PNG\r\n\x1a\n"), nil
+	}
+	return f.head, nil
+}
+
+func (f *fakeStorage) CreateMultipartUpload(_ context.Context, _, _ string) (string, error) {
+	return f.uploadID, nil
+}
+
+func (f *fakeStorage) PresignedUploadPart(_ context.Context, objectKey, uploadID string, partNumber int, _ time.Duration) (string, error) {
+	return fmt.Sprintf("http://minio.local/%s?uploadId=%s&partNumber=%d", objectKey, uploadID, partNumber), nil
+}
+
+func (f *fakeStorage) DeleteObject(_ context.Context, objectKey string) error {
+	f.deleted = append(f.deleted, objectKey)
+	return nil
+}
+
+func (f *fakeStorage) DeletePrefix(_ context.Context, prefix string) (int, error) {
+	f.deleted = append(f.deleted, prefix)
+	return 1, nil
+}
+
+func (f *fakeStorage) CompleteMultipartUpload(_ context.Context, objectKey, _ string, parts []storage.CompletedPart) error {
+	if f.completeErr != nil {
+		return f.completeErr
+	}
+	f.completedKey = objectKey
+	f.completedParts = parts
+	f.exists = true
+	return nil
+}
+
+func (f *fakeStorage) AbortMultipartUpload(_ context.Context, objectKey, uploadID string) error {
+	f.aborted = append(f.aborted, objectKey+"#"+uploadID)
+	return nil
+}
+
+type fakeRateLimiter struct {
+	decision ratelimit.Decision
+	err      error
+	subjects []string
+	costs    []int
+}
+
+func (f *fakeRateLimiter) Allow(ctx context.Context, subject string) (ratelimit.Decision, error) {
+	return f.AllowN(ctx, subject, 1)
 }
 
-func (f *fakeRateLimiter) Allow(_ context.Context, _ string) (ratelimit.Decision, error) {
+func (f *fakeRateLimiter) AllowN(_ context.Context, subject string, n int) (ratelimit.Decision, error) {
+	f.subjects = append(f.subjects, subject)
+	f.costs = append(f.costs, n)
 	return f.decision, f.err
 }
 
@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/dunamismax/pixelflow/internal/requestid"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -19,6 +20,7 @@ func (s *Server) withTracing(next http.Handler) http.Handler {
 			attribute.String("http.method", r.Method),
 			attribute.String("http.route", routeLabel(r.URL.Path)),
 			attribute.String("http.target", r.URL.Path),
+			attribute.String("http.request_id", requestid.FromContext(r.Context())),
 		)
 		defer span.End()
 
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/requestid"
+)
+
+const (
+	defaultUsageLogLimit = 100
+	maxUsageLogLimit     = 1000
+	// usageCSVPageTimeout bounds fetching and writing one page of a CSV
+	// export; the write deadline moves forward by this much per page.
+	usageCSVPageTimeout = 30 * time.Second
+)
+
+type usageLister interface {
+	ListUsage(ctx context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error)
+}
+
+func (s *Server) handleUsageSummary(w http.ResponseWriter, r *http.Request) {
+	if s.usageStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "usage store is unavailable"})
+		return
+	}
+
+	from, to, err := parseUsageRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	userID := s.requestUserID(r)
+	summary, err := s.usageStore.Summary(r.Context(), userID, from, to)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "usage summary failed for user %s: %v", userID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load usage"})
+		return
+	}
+
+	response := map[string]any{
+		"user_id":          summary.UserID,
+		"jobs":             summary.Jobs,
+		"pixels_processed": summary.PixelsProcessed,
+		"bytes_saved":      summary.BytesSaved,
+		"compute_time_ms":  summary.ComputeTimeMS,
+	}
+	if !from.IsZero() {
+		response["from"] = from
+	}
+	if !to.IsZero() {
+		response["to"] = to
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleUsageLogs pages through the caller's per-job usage rows, newest first.
+func (s *Server) handleUsageLogs(w http.ResponseWriter, r *http.Request) {
+	lister, ok := s.usageStore.(usageLister)
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "usage store is unavailable"})
+		return
+	}
+
+	query := r.URL.Query()
+	from, to, err := parseUsageRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	limit, err := parsePageParam("limit", query.Get("limit"), defaultUsageLogLimit, 1, maxUsageLogLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	offset, err := parsePageParam("offset", query.Get("offset"), 0, 0, -1)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	userID := s.requestUserID(r)
+	if wantsCSV(r) {
+		s.writeUsageCSV(w, r, lister, userID, from, to)
+		return
+	}
+	logs, err := lister.ListUsage(r.Context(), userID, from, to, limit, offset)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "usage logs failed for user %s: %v", userID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load usage"})
+		return
+	}
+
+	items := make([]map[string]any, 0, len(logs))
+	for _, usage := range logs {
+		items = append(items, map[string]any{
+			"job_id":           usage.JobID,
+			"pixels_processed": usage.PixelsProcessed,
+			"bytes_saved":      usage.BytesSaved,
+			"compute_time_ms":  usage.ComputeTimeMS,
+			"created_at":       usage.CreatedAt,
+		})
+	}
+	response := map[string]any{
+		"user_id": userID,
+		"logs":    items,
+		"limit":   limit,
+		"offset":  offset,
+	}
+	if len(logs) == limit {
+		response["next_offset"] = offset + limit
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format := strings.TrimSpace(r.URL.Query().Get("format")); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "text/csv") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeUsageCSV streams every usage row in [from, to) as CSV, fetching and
+// flushing maxUsageLogLimit rows at a time so exports never sit in memory.
+// The first page is loaded before any header is written so store failures
+// still surface as a 500. The write deadline is extended before each page, so
+// a long export outlives the server's WriteTimeout while a client that stops
+// reading is still cut off.
+func (s *Server) writeUsageCSV(w http.ResponseWriter, r *http.Request, lister usageLister, userID string, from, to time.Time) {
+	logs, err := lister.ListUsage(r.Context(), userID, from, to, maxUsageLogLimit, 0)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "usage logs failed for user %s: %v", userID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load usage"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="pixelflow-usage.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	rc := http.NewResponseController(w)
+	_ = out.Write([]string{"job_id", "user_id", "pixels_processed", "bytes_saved", "compute_time_ms", "created_at"})
+	for offset := 0; ; {
+		if err := rc.SetWriteDeadline(time.Now().Add(usageCSVPageTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			requestid.Logf(r.Context(), s.logger, "usage csv write deadline for user %s: %v", userID, err)
+		}
+		for _, usage := range logs {
+			_ = out.Write([]string{
+				usage.JobID,
+				usage.UserID,
+				strconv.FormatInt(usage.PixelsProcessed, 10),
+				strconv.FormatInt(usage.BytesSaved, 10),
+				strconv.FormatInt(usage.ComputeTimeMS, 10),
+				usage.CreatedAt.UTC().Format(time.RFC3339Nano),
+			})
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			requestid.Logf(r.Context(), s.logger, "usage csv write failed for user %s: %v", userID, err)
+			return
+		}
+		_ = rc.Flush()
+		if len(logs) < maxUsageLogLimit {
+			return
+		}
+
+		offset += len(logs)
+		logs, err = lister.ListUsage(r.Context(), userID, from, to, maxUsageLogLimit, offset)
+		if err != nil {
+			requestid.Logf(r.Context(), s.logger, "usage csv page failed for user %s offset=%d: %v", userID, offset, err)
+			return
+		}
+	}
+}
+
+// parsePageParam parses a non-negative integer query parameter, applying def
+// when it is absent and rejecting values outside [min, max] (max < 0 means
+// unbounded).
+func parsePageParam(name, raw string, def, min, max int) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < min || (max >= 0 && v > max) {
+		if max < 0 {
+			return 0, fmt.Errorf("%s must be an integer >= %d", name, min)
+		}
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+	}
+	return v, nil
+}
+
+func parseUsageRange(fromRaw, toRaw string) (time.Time, time.Time, error) {
+	from, _, err := parseUsageTime("from", fromRaw)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, dateOnly, err := parseUsageTime("to", toRaw)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+func parseUsageTime(name, raw string) (time.Time, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), false, nil
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%s must be an RFC3339 timestamp or YYYY-MM-DD date", name)
+}
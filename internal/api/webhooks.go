@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/requestid"
+	"github.com/dunamismax/pixelflow/internal/webhook"
+)
+
+const webhookTestEvent = "webhook.test"
+
+// webhookProber sends a single signed delivery through the same signing path
+// the worker uses for job callbacks.
+type webhookProber interface {
+	Probe(ctx context.Context, endpoint, event string, payload any) (webhook.Attempt, error)
+}
+
+type webhookTestRequest struct {
+	URL string `json:"url"`
+	// SecretHint is still accepted so existing clients keep decoding under
+	// DisallowUnknownFields, but it is ignored and never echoed back.
+	SecretHint string `json:"secret_hint,omitempty"`
+}
+
+// WithWebhookTester enables POST /v1/webhooks/test, which sends a signed
+// sample payload so users can check that their receiver verifies callbacks.
+func WithWebhookTester(prober webhookProber) Option {
+	return func(s *Server) {
+		s.webhookProber = prober
+	}
+}
+
+func (s *Server) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhookProber == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "webhook delivery is unavailable"})
+		return
+	}
+
+	var req webhookTestRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	endpoint := strings.TrimSpace(req.URL)
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url must be an absolute http or https URL"})
+		return
+	}
+
+	payload := map[string]any{
+		"event":   webhookTestEvent,
+		"job_id":  "webhook-test",
+		"status":  "test",
+		"sent_at": time.Now().UTC(),
+	}
+	attempt, err := s.webhookProber.Probe(r.Context(), endpoint, webhookTestEvent, payload)
+	if err != nil {
+		requestid.Logf(r.Context(), s.logger, "webhook test failed for url=%s: %v", endpoint, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := map[string]any{
+		"url":           endpoint,
+		"event":         webhookTestEvent,
+		"delivered":     attempt.Outcome() == webhook.OutcomeSuccess,
+		"round_trip_ms": attempt.Duration.Milliseconds(),
+		"outcome":       attempt.Outcome(),
+	}
+	if attempt.StatusCode != 0 {
+		response["status_code"] = attempt.StatusCode
+	}
+	if attempt.Err != nil {
+		response["error"] = attempt.Err.Error()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/store"
+	"github.com/dunamismax/pixelflow/internal/webhook"
+	signature "github.com/dunamismax/pixelflow/pkg/webhook"
+)
+
+func TestWebhookTestDeliversSignedSample(t *testing.T) {
+	var (
+		gotEvent  string
+		verifyErr error
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEvent = r.Header.Get(webhook.HeaderEvent)
+		_, verifyErr = signature.VerifyRequest(r, "test-secret", 0)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	client := webhook.NewClient(webhook.Config{SigningSecret: "test-secret"})
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute, WithWebhookTester(client))
+
+	body := `{"url":"` + receiver.URL + `","secret_hint":"abcd1234"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/test", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if verifyErr != nil || gotEvent != webhookTestEvent {
+		t.Fatalf("expected verifiable %s delivery, event=%q err=%v", webhookTestEvent, gotEvent, verifyErr)
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["status_code"] != float64(http.StatusNoContent) || resp["delivered"] != true {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if _, ok := resp["round_trip_ms"]; !ok {
+		t.Fatalf("expected round_trip_ms in response: %+v", resp)
+	}
+	if _, ok := resp["secret_hint"]; ok || strings.Contains(rec.Body.String(), "abcd1234") {
+		t.Fatalf("expected no fingerprint of the signing secret in the response: %+v", resp)
+	}
+}
+
+func TestWebhookTestRejectsInvalidURL(t *testing.T) {
+	client := webhook.NewClient(webhook.Config{SigningSecret: "test-secret"})
+	server := NewServer(testLogger(t), &fakeQueueClient{}, store.NewMemoryJobStore(), &fakeStorage{}, 15*time.Minute, WithWebhookTester(client))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/test", strings.NewReader(`{"url":"ftp://example.com/hook"}`))
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,81 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/dunamismax/pixelflow/pkg/version"
+)
+
+// Commit and BuildTime are set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/dunamismax/pixelflow/internal/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// When unset they fall back to the VCS stamp recorded by the Go toolchain.
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+type Info struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"`
+	// Backend is the worker's image transformer (stdlib or govips); the API
+	// leaves it empty.
+	Backend string `json:"backend,omitempty"`
+}
+
+func Get() Info {
+	info := Info{
+		Name:      version.Name,
+		Version:   version.Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+func Handler() http.Handler {
+	return HandlerWithBackend("")
+}
+
+// HandlerWithBackend serves Get with Backend set to backend.
+func HandlerWithBackend(backend string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		info := Get()
+		info.Backend = backend
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(info)
+	})
+}
@@ -0,0 +1,36 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestGetPrefersLinkTimeValues(t *testing.T) {
+	prevCommit, prevBuildTime := Commit, BuildTime
+	t.Cleanup(func() { Commit, BuildTime = prevCommit, prevBuildTime })
+	Commit, BuildTime = "abc123", "2026-01-01T00:00:00Z"
+
+	info := Get()
+	if info.Commit != "abc123" || info.BuildTime != "2026-01-01T00:00:00Z" {
+		t.Fatalf("expected link-time values, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Fatalf("go_version = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestHandlerServesJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	var info Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if info.Commit == "" || info.BuildTime == "" || info.GoVersion == "" {
+		t.Fatalf("expected populated build info, got %+v", info)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRouteRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]RouteRateLimit
+		wantErr string
+	}{
+		{name: "empty", value: "", want: map[string]RouteRateLimit{}},
+		{
+			name:  "several routes",
+			value: " /v1/jobs=100/1m , /v1/jobs/{id}/start=10/30s,",
+			want: map[string]RouteRateLimit{
+				"/v1/jobs":            {Capacity: 100, Window: time.Minute},
+				"/v1/jobs/{id}/start": {Capacity: 10, Window: 30 * time.Second},
+			},
+		},
+		{name: "missing equals", value: "/v1/jobs", wantErr: "expected route=capacity/window"},
+		{name: "missing route", value: "=5/1m", wantErr: "expected route=capacity/window"},
+		{name: "missing window", value: "/v1/jobs=5", wantErr: "expected route=capacity/window"},
+		{name: "unknown route", value: "/v1/job=5/1m", wantErr: `unknown route "/v1/job"`},
+		{name: "raw path", value: "/v1/jobs/abc/start=5/1m", wantErr: "unknown route"},
+		{name: "duplicate route", value: "/v1/jobs=5/1m,/v1/jobs=6/1m", wantErr: "listed twice"},
+		{name: "zero capacity", value: "/v1/jobs=0/1m", wantErr: "capacity must be a positive integer"},
+		{name: "bad capacity", value: "/v1/jobs=many/1m", wantErr: "capacity must be a positive integer"},
+		{name: "bad window", value: "/v1/jobs=5/soon", wantErr: "window must be a positive duration"},
+		{name: "negative window", value: "/v1/jobs=5/-1m", wantErr: "window must be a positive duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRouteRateLimits(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsMalformedRouteRateLimits(t *testing.T) {
+	t.Setenv("PIXELFLOW_API_ROUTE_RATE_LIMITS", "/v1/jobs=10/1m,/v1/jobz=5/1m")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "PIXELFLOW_API_ROUTE_RATE_LIMITS") {
+		t.Fatalf("expected route rate limit error, got %v", err)
+	}
+
+	t.Setenv("PIXELFLOW_API_ROUTE_RATE_LIMITS", "/v1/jobs/{id}/retry=2/1m")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.API.RouteRateLimits["/v1/jobs/{id}/retry"] != (RouteRateLimit{Capacity: 2, Window: time.Minute}) {
+		t.Fatalf("unexpected route limits: %v", cfg.API.RouteRateLimits)
+	}
+	if _, ok := cfg.API.RouteRateLimits["/v1/jobs"]; !ok {
+		t.Fatalf("expected the create limit to keep its default bucket: %v", cfg.API.RouteRateLimits)
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable pointing at an optional config file.
+const FileEnv = "PIXELFLOW_CONFIG"
+
+// Resolve loads the file named by PIXELFLOW_CONFIG when it is set and falls
+// back to Load otherwise.
+func Resolve() (Config, error) {
+	path := strings.TrimSpace(os.Getenv(FileEnv))
+	if path == "" {
+		return Load()
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads a YAML (or JSON) file shaped like Config, using the yaml
+// tag names:
+//
+//	queue:
+//	  redis_addr: redis:6379
+//	worker:
+//	  object_ttl: 72h
+//
+// Unknown keys are an error. Each setting keeps its environment variable
+// (the env tag on the field), and a variable that is set, even to an empty
+// string, takes precedence over the file.
+func LoadFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	// Decoding into Config reports unknown keys and mistyped values with
+	// their line numbers; the node walk then hands each key that is present
+	// to load under its environment variable name.
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var shape Config
+	if err := decoder.Decode(&shape); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	values := make(map[string]string)
+	if len(doc.Content) > 0 {
+		if err := fileValues(values, doc.Content[0], reflect.TypeOf(Config{})); err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+	cfg, err := load(source{file: values})
+	if err != nil {
+		return Config{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func fileValues(out map[string]string, node *yaml.Node, typ reflect.Type) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field, ok := fieldByYAMLName(typ, key.Value)
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %q", key.Line, key.Value)
+		}
+		if name, ok := field.Tag.Lookup("env"); ok {
+			text, err := fileValue(value, field.Type)
+			if err != nil {
+				return fmt.Errorf("line %d: %s: %w", value.Line, key.Value, err)
+			}
+			out[name] = text
+			continue
+		}
+		if err := fileValues(out, value, field.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldByYAMLName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fileValue renders a node in the text form the matching environment
+// variable uses: lists are comma-separated and maps become name=value pairs.
+func fileValue(node *yaml.Node, typ reflect.Type) (string, error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", errors.New("list items must be scalars")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	case yaml.MappingNode:
+		if typ.Kind() != reflect.Map {
+			return "", errors.New("expected a scalar or list")
+		}
+		items := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i].Value, node.Content[i+1]
+			if typ.Elem() == reflect.TypeOf(RouteRateLimit{}) {
+				var limit RouteRateLimit
+				if err := value.Decode(&limit); err != nil {
+					return "", err
+				}
+				items = append(items, fmt.Sprintf("%s=%d/%s", name, limit.Capacity, limit.Window))
+				continue
+			}
+			items = append(items, name+"="+value.Value)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", errors.New("unsupported value")
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadFileAppliesFileValuesUnderEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixelflow.yaml")
+	if err := os.WriteFile(path, []byte(`
+queue:
+  redis_addr: redis.internal:6379
+  redis_db: 3
+  weights:
+    critical: 4
+    default: 1
+worker:
+  concurrency: 12
+  object_ttl: 72h
+  metrics_addr: ":9999"
+webhook:
+  events:
+    - job.completed
+    - job.failed
+api:
+  route_rate_limits:
+    /v1/jobs:
+      capacity: 5
+      window: 30s
+database:
+  dsn: postgres://file
+`), 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	t.Setenv("POSTGRES_DSN", "postgres://env")
+	t.Setenv("WORKER_METRICS_ADDR", "")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+	if cfg.Queue.RedisAddr != "redis.internal:6379" || cfg.Queue.RedisDB != 3 {
+		t.Fatalf("unexpected redis config: %+v", cfg.Queue)
+	}
+	if cfg.Worker.Concurrency != 12 || cfg.Worker.ObjectTTL != 72*time.Hour {
+		t.Fatalf("unexpected worker config: %+v", cfg.Worker)
+	}
+	if !slices.Equal(cfg.Webhook.Events, []string{"job.completed", "job.failed"}) {
+		t.Fatalf("unexpected webhook events: %v", cfg.Webhook.Events)
+	}
+	if cfg.Database.DSN != "postgres://env" {
+		t.Fatalf("expected environment to override file DSN, got %q", cfg.Database.DSN)
+	}
+	if cfg.Worker.MetricsAddr != ":9091" {
+		t.Fatalf("expected a set but empty variable to override the file, got %q", cfg.Worker.MetricsAddr)
+	}
+	if cfg.Queue.Weights["critical"] != 4 || cfg.Queue.Weights["default"] != 1 || len(cfg.Queue.Weights) != 2 {
+		t.Fatalf("unexpected queue weights: %v", cfg.Queue.Weights)
+	}
+	if limit := cfg.API.RouteRateLimits["/v1/jobs"]; limit.Capacity != 5 || limit.Window != 30*time.Second {
+		t.Fatalf("unexpected route rate limit: %+v", limit)
+	}
+	if cfg.API.Addr != ":8080" {
+		t.Fatalf("expected unset keys to keep defaults, got %q", cfg.API.Addr)
+	}
+}
+
+func TestResolveReadsJSONFromPixelflowConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixelflow.json")
+	if err := os.WriteFile(path, []byte(`{"api": {"addr": ":9999"}, "storage": {"use_ssl": true}}`), 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	t.Setenv(FileEnv, path)
+
+	cfg, err := Resolve()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if cfg.API.Addr != ":9999" || !cfg.Storage.UseSSL {
+		t.Fatalf("expected JSON file values, got addr=%q use_ssl=%v", cfg.API.Addr, cfg.Storage.UseSSL)
+	}
+
+	t.Setenv(FileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Resolve(); err == nil {
+		t.Fatal("expected error for a missing config file")
+	}
+}
+
+func TestLoadFileRejectsUnknownKeys(t *testing.T) {
+	for name, body := range map[string]string{
+		"misspelled field": "worker:\n  concurency: 4\n",
+		"unknown section":  "redis:\n  addr: redis:6379\n",
+		"env var name":     "REDIS_ADDR: redis:6379\n",
+		"wrong type":       "worker:\n  concurrency: lots\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pixelflow.yaml")
+			if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+				t.Fatalf("write config file: %v", err)
+			}
+			if _, err := LoadFile(path); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParseHexColor parses #rgb, #rrggbb, or #rrggbbaa (the # is optional). The
+// alpha byte is straight, not premultiplied, hence color.NRGBA; a missing
+// alpha is opaque.
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]}) + "ff"
+	case 6:
+		hex += "ff"
+	case 8:
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid hex color %q: expected #rgb, #rrggbb, or #rrggbbaa", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid hex color %q: expected #rgb, #rrggbb, or #rrggbbaa", s)
+	}
+
+	return color.NRGBA{
+		R: uint8(value >> 24),
+		G: uint8(value >> 16),
+		B: uint8(value >> 8),
+		A: uint8(value),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	valid := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{in: "#fff", want: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{in: "#0a8", want: color.NRGBA{R: 0x00, G: 0xaa, B: 0x88, A: 0xff}},
+		{in: "#1e90ff", want: color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff}},
+		{in: "#1E90FF", want: color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff}},
+		{in: "#00000080", want: color.NRGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x80}},
+		{in: "336699", want: color.NRGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff}},
+		{in: "  #abc  ", want: color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}},
+	}
+	for _, tc := range valid {
+		got, err := ParseHexColor(tc.in)
+		if err != nil {
+			t.Fatalf("ParseHexColor(%q) returned error: %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseHexColor(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+
+	invalid := []string{"", "#", "#ff", "#ffff", "#fffff", "#fffffff", "#fffffffff", "#ggg", "#12345z", "#-12345", "#+12345", "red"}
+	for _, in := range invalid {
+		if _, err := ParseHexColor(in); err == nil {
+			t.Fatalf("ParseHexColor(%q) expected error", in)
+		}
+	}
+}
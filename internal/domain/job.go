@@ -3,8 +3,11 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"path"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -14,58 +17,228 @@ const (
 	JobStatusSucceeded  = "succeeded"
 	JobStatusFailed     = "failed"
 
+	JobStatusDeadlineExceeded = "deadline_exceeded"
+	JobStatusOrphaned         = "orphaned"
+	JobStatusCancelled        = "cancelled"
+
 	SourceTypeLocalFile   = "local_file"
 	SourceTypeS3Presigned = "s3_presigned"
+	SourceTypeHTTPURL     = "http_url"
+	// SourceTypeVideo is uploaded like s3_presigned; the frame at
+	// frame_at_seconds becomes the pipeline's source image.
+	SourceTypeVideo = "video"
+
+	MaxDeadlineSeconds = 3600
+	MaxTaskRetry       = 25
+
+	DefaultMaxPipelineSteps = 50
+
+	MaxWebhookHeaders = 10
+
+	MaxFrameAtSeconds = 3600
+
+	MaxOutputSubdirLength = 255
+	MaxOutputSubdirDepth  = 8
+
+	MaxWatermarkFontSize = 512
+	MaxWatermarkSpacing  = 1000
+	MaxWatermarkRotation = 180
+	MaxFilenameLength    = 255
+
+	// DefaultOverlayAssetsPrefix is the object-store prefix watermark images
+	// are read from unless configured otherwise.
+	DefaultOverlayAssetsPrefix = "assets/"
+
+	MinBrightness = -100
+	MaxBrightness = 100
+	MaxContrast   = 2
+
+	MaxBorderWidth = 1000
+	MaxDensity     = 1200
+
+	JPEGSubsample420 = "4:2:0"
+	JPEGSubsample444 = "4:4:4"
 )
 
 type CreateJobRequest struct {
-	SourceType string         `json:"source_type"`
-	WebhookURL string         `json:"webhook_url,omitempty"`
-	ObjectKey  string         `json:"object_key,omitempty"`
-	Pipeline   []PipelineStep `json:"pipeline"`
+	SourceType            string            `json:"source_type"`
+	WebhookURL            string            `json:"webhook_url,omitempty"`
+	WebhookHeaders        map[string]string `json:"webhook_headers,omitempty"`
+	ObjectKey             string            `json:"object_key,omitempty"`
+	DeadlineSeconds       int               `json:"deadline_seconds,omitempty"`
+	MaxRetry              *int              `json:"max_retry,omitempty"`
+	TimeoutSeconds        int               `json:"timeout_seconds,omitempty"`
+	ContentLength         int64             `json:"content_length,omitempty"`
+	EmitSidecar           bool              `json:"emit_sidecar,omitempty"`
+	EmitManifest          bool              `json:"emit_manifest,omitempty"`
+	DeleteSourceOnSuccess bool              `json:"delete_source_on_success,omitempty"`
+	OutputSubdir          string            `json:"output_subdir,omitempty"`
+	FrameAtSeconds        float64           `json:"frame_at_seconds,omitempty"`
+	Pipeline              []PipelineStep    `json:"pipeline"`
 }
 
 type PipelineStep struct {
 	ID        string     `json:"id"`
 	Action    string     `json:"action"`
 	Width     int        `json:"width,omitempty"`
+	MaxWidth  int        `json:"max_width,omitempty"`
+	MaxHeight int        `json:"max_height,omitempty"`
 	Format    string     `json:"format,omitempty"`
+	Filename  string     `json:"filename,omitempty"`
 	Quality   int        `json:"quality,omitempty"`
 	Watermark *Watermark `json:"watermark,omitempty"`
+	Chain     bool       `json:"chain,omitempty"`
+	BlockSize int        `json:"block_size,omitempty"`
+	Region    *Region    `json:"region,omitempty"`
+
+	Brightness float64  `json:"brightness,omitempty"`
+	Contrast   *float64 `json:"contrast,omitempty"`
+	Background string   `json:"background,omitempty"`
+
+	BorderWidth int    `json:"border_width,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Radius      int    `json:"radius,omitempty"`
+	Density     int    `json:"density,omitempty"`
+	TargetBytes int    `json:"target_bytes,omitempty"`
+
+	NormalizeSRGB bool `json:"normalize_srgb,omitempty"`
+
+	Progressive bool   `json:"progressive,omitempty"`
+	Subsample   string `json:"subsample,omitempty"`
+	Lossless    bool   `json:"lossless,omitempty"`
 }
 
 type Watermark struct {
-	Text    string  `json:"text"`
-	Opacity float64 `json:"opacity"`
-	Gravity string  `json:"gravity"`
+	Text           string  `json:"text"`
+	ImageObjectKey string  `json:"image_object_key,omitempty"`
+	Scale          float64 `json:"scale,omitempty"`
+	Opacity        float64 `json:"opacity"`
+	Gravity        string  `json:"gravity"`
+	FontSize       int     `json:"font_size,omitempty"`
+	Color          string  `json:"color,omitempty"`
+	// Tile repeats the watermark across the whole image, Spacing pixels
+	// apart (0 uses a default gap); Gravity is ignored when tiling.
+	Tile    bool `json:"tile,omitempty"`
+	Spacing int  `json:"spacing,omitempty"`
+	// Rotation turns the watermark clockwise by this many degrees
+	// (-180..180) about its centre; -45 runs bottom-left to top-right.
+	Rotation float64 `json:"rotation,omitempty"`
+}
+
+// Region is a rectangle in source pixel coordinates.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
 }
 
 type Job struct {
-	ID         string
-	UserID     string
-	Status     string
-	SourceType string
-	WebhookURL string
-	Pipeline   []PipelineStep
-	ObjectKey  string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID              string
+	UserID          string
+	Status          string
+	SourceType      string
+	WebhookURL      string
+	WebhookHeaders  map[string]string
+	Pipeline        []PipelineStep
+	ObjectKey       string
+	DeadlineSeconds int
+	MaxRetry        *int
+	TimeoutSeconds  int
+	EmitSidecar     bool
+	EmitManifest    bool
+	DeleteSource    bool
+	OutputSubdir    string
+	// OutputPaths lists the files or object keys a successful run wrote, so
+	// deleting the job removes exactly those.
+	OutputPaths      []string
+	FrameAtSeconds   float64
+	ProcessingTimeMS int64
+	ErrorMessage     string
+	RetryCount       int
+	// Queue is the asynq queue the job was started on; empty means the
+	// client's default queue. Retries and cancels reuse it.
+	Queue     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func IsTerminalStatus(status string) bool {
+	switch status {
+	case JobStatusSucceeded, JobStatusFailed, JobStatusDeadlineExceeded, JobStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j Job) Deadline() time.Duration {
+	return time.Duration(j.DeadlineSeconds) * time.Second
+}
+
+func (j Job) Timeout() time.Duration {
+	return time.Duration(j.TimeoutSeconds) * time.Second
 }
 
 func (r CreateJobRequest) Validate() error {
+	return r.ValidateWithMaxSteps(DefaultMaxPipelineSteps)
+}
+
+// ValidateWithMaxSteps is Validate with a caller-chosen cap on pipeline
+// length; maxSteps <= 0 disables the cap.
+func (r CreateJobRequest) ValidateWithMaxSteps(maxSteps int) error {
 	sourceType := strings.ToLower(strings.TrimSpace(r.SourceType))
 	if sourceType == "" {
 		return errors.New("source_type is required")
 	}
-	if sourceType != SourceTypeLocalFile && sourceType != SourceTypeS3Presigned {
+	if sourceType != SourceTypeLocalFile && sourceType != SourceTypeS3Presigned && sourceType != SourceTypeHTTPURL && sourceType != SourceTypeVideo {
 		return fmt.Errorf("unsupported source_type: %s", r.SourceType)
 	}
 	if sourceType == SourceTypeLocalFile && strings.TrimSpace(r.ObjectKey) == "" {
 		return errors.New("object_key is required for source_type=local_file")
 	}
+	if sourceType == SourceTypeHTTPURL {
+		u, err := url.Parse(strings.TrimSpace(r.ObjectKey))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("object_key must be an absolute http or https url for source_type=http_url")
+		}
+	}
+	if r.FrameAtSeconds != 0 && sourceType != SourceTypeVideo {
+		return errors.New("frame_at_seconds is only supported for source_type=video")
+	}
+	if r.FrameAtSeconds < 0 || r.FrameAtSeconds > MaxFrameAtSeconds {
+		return fmt.Errorf("frame_at_seconds must be between 0 and %d", MaxFrameAtSeconds)
+	}
+	if r.DeadlineSeconds < 0 || r.DeadlineSeconds > MaxDeadlineSeconds {
+		return fmt.Errorf("deadline_seconds must be between 0 and %d", MaxDeadlineSeconds)
+	}
+	if r.MaxRetry != nil && (*r.MaxRetry < 0 || *r.MaxRetry > MaxTaskRetry) {
+		return fmt.Errorf("max_retry must be between 0 and %d", MaxTaskRetry)
+	}
+	if r.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must be >= 0")
+	}
+	if r.ContentLength < 0 {
+		return errors.New("content_length must be >= 0")
+	}
+	if err := validateWebhookHeaders(r.WebhookURL, r.WebhookHeaders); err != nil {
+		return err
+	}
+	if r.OutputSubdir != "" {
+		if sourceType != SourceTypeLocalFile {
+			return errors.New("output_subdir is only supported for source_type=local_file")
+		}
+		if _, err := SanitizeSubdir(r.OutputSubdir); err != nil {
+			return err
+		}
+	}
 	if len(r.Pipeline) == 0 {
 		return errors.New("pipeline must contain at least one step")
 	}
+	if maxSteps > 0 && len(r.Pipeline) > maxSteps {
+		return fmt.Errorf("pipeline has %d steps; at most %d are allowed", len(r.Pipeline), maxSteps)
+	}
+	outputNames := make(map[string]int, len(r.Pipeline))
 	for i, step := range r.Pipeline {
 		if strings.TrimSpace(step.ID) == "" {
 			return fmt.Errorf("pipeline[%d].id is required", i)
@@ -73,6 +246,231 @@ func (r CreateJobRequest) Validate() error {
 		if strings.TrimSpace(step.Action) == "" {
 			return fmt.Errorf("pipeline[%d].action is required", i)
 		}
+		if step.Chain && i == 0 {
+			return errors.New("pipeline[0].chain requires a previous step")
+		}
+		switch strings.TrimSpace(step.Subsample) {
+		case "", JPEGSubsample420, JPEGSubsample444:
+		default:
+			return fmt.Errorf("pipeline[%d].subsample must be %q or %q", i, JPEGSubsample420, JPEGSubsample444)
+		}
+		if strings.EqualFold(strings.TrimSpace(step.Action), "pixelate") {
+			if step.BlockSize <= 1 {
+				return fmt.Errorf("pipeline[%d].block_size must be greater than 1", i)
+			}
+			if rg := step.Region; rg != nil && (rg.X < 0 || rg.Y < 0 || rg.Width <= 0 || rg.Height <= 0) {
+				return fmt.Errorf("pipeline[%d].region must have non-negative x/y and positive width/height", i)
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(step.Action), "thumbnail") {
+			if step.MaxWidth < 0 || step.MaxHeight < 0 || step.MaxWidth+step.MaxHeight == 0 {
+				return fmt.Errorf("pipeline[%d].thumbnail requires max_width and/or max_height > 0", i)
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(step.Action), "adjust") {
+			if step.Brightness < MinBrightness || step.Brightness > MaxBrightness {
+				return fmt.Errorf("pipeline[%d].brightness must be between %d and %d", i, MinBrightness, MaxBrightness)
+			}
+			if step.Contrast != nil && (*step.Contrast < 0 || *step.Contrast > MaxContrast) {
+				return fmt.Errorf("pipeline[%d].contrast must be between 0 and %d", i, MaxContrast)
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(step.Action), "border") {
+			if step.BorderWidth < 1 || step.BorderWidth > MaxBorderWidth {
+				return fmt.Errorf("pipeline[%d].border_width must be between 1 and %d", i, MaxBorderWidth)
+			}
+			if _, err := ParseHexColor(step.Color); err != nil {
+				return fmt.Errorf("pipeline[%d].color: %w", i, err)
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(step.Action), "rounded_corners") {
+			if step.Radius < 1 {
+				return fmt.Errorf("pipeline[%d].radius must be at least 1", i)
+			}
+			if format := strings.ToLower(strings.TrimSpace(step.Format)); (format == "jpeg" || format == "jpg") && strings.TrimSpace(step.Background) == "" {
+				return fmt.Errorf("pipeline[%d].rounded_corners with jpeg output requires a background", i)
+			}
+		}
+		if step.TargetBytes < 0 {
+			return fmt.Errorf("pipeline[%d].target_bytes must be positive", i)
+		}
+		if step.TargetBytes > 0 && step.Quality > 0 {
+			return fmt.Errorf("pipeline[%d] cannot set both target_bytes and quality", i)
+		}
+		if step.Density < 0 || step.Density > MaxDensity {
+			return fmt.Errorf("pipeline[%d].density must be between 1 and %d", i, MaxDensity)
+		}
+		if name := step.Filename; name != "" {
+			if len(name) > MaxFilenameLength || strings.TrimSpace(name) == "" || strings.ContainsAny(name, `/\`) || strings.ContainsFunc(name, unicode.IsControl) {
+				return fmt.Errorf("pipeline[%d].filename must be a non-empty name of at most %d bytes without path separators or control characters", i, MaxFilenameLength)
+			}
+		}
+		if strings.TrimSpace(step.Background) != "" {
+			if _, err := ParseHexColor(step.Background); err != nil {
+				return fmt.Errorf("pipeline[%d].background: %w", i, err)
+			}
+		}
+		if wm := step.Watermark; wm != nil {
+			if strings.TrimSpace(wm.Text) != "" && strings.TrimSpace(wm.ImageObjectKey) != "" {
+				return fmt.Errorf("pipeline[%d].watermark must set text or image_object_key, not both", i)
+			}
+			if wm.Scale < 0 || wm.Scale > 1 {
+				return fmt.Errorf("pipeline[%d].watermark.scale must be between 0 and 1", i)
+			}
+			if wm.FontSize < 0 || wm.FontSize > MaxWatermarkFontSize {
+				return fmt.Errorf("pipeline[%d].watermark.font_size must be between 0 and %d", i, MaxWatermarkFontSize)
+			}
+			if wm.Spacing < 0 || wm.Spacing > MaxWatermarkSpacing {
+				return fmt.Errorf("pipeline[%d].watermark.spacing must be between 0 and %d", i, MaxWatermarkSpacing)
+			}
+			if wm.Rotation < -MaxWatermarkRotation || wm.Rotation > MaxWatermarkRotation {
+				return fmt.Errorf("pipeline[%d].watermark.rotation must be between -%d and %d degrees", i, MaxWatermarkRotation, MaxWatermarkRotation)
+			}
+			if wm.Spacing > 0 && !wm.Tile {
+				return fmt.Errorf("pipeline[%d].watermark.spacing requires tile", i)
+			}
+			if strings.TrimSpace(wm.Color) != "" {
+				if _, err := ParseHexColor(wm.Color); err != nil {
+					return fmt.Errorf("pipeline[%d].watermark.color: %w", i, err)
+				}
+			}
+		}
+		name := SanitizePathToken(step.ID)
+		if r.EmitManifest && r.EmitSidecar && name == "manifest" {
+			return fmt.Errorf("pipeline[%d].id %q would write a sidecar over manifest.json", i, step.ID)
+		}
+		if prev, ok := outputNames[name]; ok {
+			return fmt.Errorf("pipeline[%d].id %q collides with pipeline[%d].id %q: both write output %q", i, step.ID, prev, r.Pipeline[prev].ID, name)
+		}
+		outputNames[name] = i
+	}
+	return nil
+}
+
+// ValidateOverlayKeys rejects watermark images outside prefix, the only part
+// of the object store overlays are read from.
+func (r CreateJobRequest) ValidateOverlayKeys(prefix string) error {
+	for i, step := range r.Pipeline {
+		if step.Watermark == nil {
+			continue
+		}
+		if key := strings.TrimSpace(step.Watermark.ImageObjectKey); key != "" && !ValidOverlayKey(key, prefix) {
+			return fmt.Errorf("pipeline[%d].watermark.image_object_key must name an object under %q", i, prefix)
+		}
 	}
 	return nil
 }
+
+// ValidOverlayKey reports whether key names an object under prefix, without
+// ".." segments or other spellings that would resolve outside it.
+func ValidOverlayKey(key, prefix string) bool {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/") + "/"
+	if prefix == "/" || len(key) <= len(prefix) || !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	return path.Clean(key) == key && !strings.Contains(key, `\`)
+}
+
+func SanitizePathToken(in string) string {
+	in = strings.TrimSpace(in)
+	if in == "" {
+		return "unknown"
+	}
+
+	var b strings.Builder
+	b.Grow(len(in))
+	for _, r := range in {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' || r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// SanitizeSubdir turns a caller-supplied relative directory into a
+// slash-separated path whose segments pass SanitizePathToken. Absolute paths
+// and ".." segments are rejected rather than rewritten; "" stays "".
+func SanitizeSubdir(in string) (string, error) {
+	in = strings.TrimSpace(in)
+	if in == "" {
+		return "", nil
+	}
+	if len(in) > MaxOutputSubdirLength {
+		return "", fmt.Errorf("output_subdir must be at most %d bytes", MaxOutputSubdirLength)
+	}
+	if in[0] == '/' || in[0] == '\\' || (len(in) >= 2 && in[1] == ':') {
+		return "", errors.New("output_subdir must be a relative path")
+	}
+
+	var segments []string
+	for _, segment := range strings.FieldsFunc(in, func(r rune) bool { return r == '/' || r == '\\' }) {
+		segment = strings.TrimSpace(segment)
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return "", errors.New("output_subdir must not contain '..'")
+		}
+		segments = append(segments, SanitizePathToken(segment))
+	}
+	if len(segments) == 0 {
+		return "", errors.New("output_subdir must name a directory")
+	}
+	if len(segments) > MaxOutputSubdirDepth {
+		return "", fmt.Errorf("output_subdir must be at most %d directories deep", MaxOutputSubdirDepth)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// validateWebhookHeaders checks caller-supplied callback headers. Framing
+// headers and the X-Pixelflow- namespace (signature, timestamp, event) are
+// reserved so a job cannot spoof or break the signed delivery.
+func validateWebhookHeaders(webhookURL string, headers map[string]string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(webhookURL) == "" {
+		return errors.New("webhook_headers requires webhook_url")
+	}
+	if len(headers) > MaxWebhookHeaders {
+		return fmt.Errorf("webhook_headers may contain at most %d headers", MaxWebhookHeaders)
+	}
+	for name, value := range headers {
+		if !isHeaderToken(name) {
+			return fmt.Errorf("webhook_headers: invalid header name %q", name)
+		}
+		switch lower := strings.ToLower(name); {
+		case lower == "host", lower == "content-type", lower == "content-length", lower == "transfer-encoding", strings.HasPrefix(lower, "x-pixelflow-"):
+			return fmt.Errorf("webhook_headers: %s is reserved", name)
+		}
+		if strings.ContainsFunc(value, func(r rune) bool { return r != '\t' && unicode.IsControl(r) }) {
+			return fmt.Errorf("webhook_headers: value for %s contains control characters", name)
+		}
+	}
+	return nil
+}
+
+// isHeaderToken reports whether s is an RFC 9110 field-name token.
+func isHeaderToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
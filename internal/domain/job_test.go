@@ -1,6 +1,69 @@
 package domain
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSubdir(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                  "",
+		"batch-1":           "batch-1",
+		" batch 1/thumbs/ ": "batch_1/thumbs",
+		`a\b/./c`:           "a/b/c",
+	} {
+		got, err := SanitizeSubdir(in)
+		if err != nil || got != want {
+			t.Fatalf("SanitizeSubdir(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"/etc", `\\share\x`, "C:/out", "a/../b", "..", "./", strings.Repeat("a/", MaxOutputSubdirDepth+1)} {
+		if _, err := SanitizeSubdir(in); err == nil {
+			t.Fatalf("expected SanitizeSubdir(%q) to be rejected", in)
+		}
+	}
+
+	req := CreateJobRequest{SourceType: SourceTypeS3Presigned, OutputSubdir: "batch-1", Pipeline: []PipelineStep{{ID: "thumb", Action: "resize", Width: 10}}}
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected output_subdir to be rejected for s3_presigned sources")
+	}
+	req.SourceType = SourceTypeLocalFile
+	req.ObjectKey = "/data/in.png"
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expected output_subdir to be valid for local_file, got %v", err)
+	}
+}
+
+func TestValidOverlayKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"assets/logo.png":                true,
+		"assets/brand/logo.png":          true,
+		"assets/":                        false,
+		"assets":                         false,
+		"assetsx/logo.png":               false,
+		"uploads/job-1/source":           false,
+		"/assets/logo.png":               false,
+		"assets/../uploads/job-1/source": false,
+		"assets//logo.png":               false,
+		`assets\..\uploads`:              false,
+	} {
+		if got := ValidOverlayKey(key, "assets"); got != want {
+			t.Fatalf("ValidOverlayKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+
+	req := CreateJobRequest{SourceType: SourceTypeS3Presigned, Pipeline: []PipelineStep{
+		{ID: "branded", Action: "watermark", Watermark: &Watermark{ImageObjectKey: "uploads/other-job/source"}},
+	}}
+	if err := req.ValidateOverlayKeys(DefaultOverlayAssetsPrefix); err == nil {
+		t.Fatal("expected an overlay outside the assets prefix to be rejected")
+	}
+	req.Pipeline[0].Watermark.ImageObjectKey = "assets/logo.png"
+	if err := req.ValidateOverlayKeys(DefaultOverlayAssetsPrefix); err != nil {
+		t.Fatalf("expected an asset overlay to validate, got %v", err)
+	}
+}
 
 func TestCreateJobRequestValidate(t *testing.T) {
 	valid := CreateJobRequest{
@@ -34,6 +97,17 @@ func TestCreateJobRequestValidate(t *testing.T) {
 		t.Fatal("expected validation error for local_file object_key")
 	}
 
+	for _, objectKey := range []string{"", "ftp://example.com/a.png", "/etc/passwd"} {
+		req := CreateJobRequest{SourceType: SourceTypeHTTPURL, ObjectKey: objectKey, Pipeline: valid.Pipeline}
+		if err := req.Validate(); err == nil {
+			t.Fatalf("expected validation error for http_url object_key %q", objectKey)
+		}
+	}
+	httpURL := CreateJobRequest{SourceType: SourceTypeHTTPURL, ObjectKey: "https://example.com/a.png", Pipeline: valid.Pipeline}
+	if err := httpURL.Validate(); err != nil {
+		t.Fatalf("expected http_url request to validate, got %v", err)
+	}
+
 	unsupportedSourceType := CreateJobRequest{
 		SourceType: "http_url",
 		Pipeline: []PipelineStep{
@@ -46,4 +120,182 @@ func TestCreateJobRequestValidate(t *testing.T) {
 	if err := unsupportedSourceType.Validate(); err == nil {
 		t.Fatal("expected validation error for unsupported source_type")
 	}
+
+	excessiveDeadline := CreateJobRequest{
+		SourceType:      SourceTypeS3Presigned,
+		DeadlineSeconds: MaxDeadlineSeconds + 1,
+		Pipeline: []PipelineStep{
+			{
+				ID:     "thumb_small",
+				Action: "resize",
+			},
+		},
+	}
+	if err := excessiveDeadline.Validate(); err == nil {
+		t.Fatal("expected validation error for deadline_seconds above maximum")
+	}
+
+	collidingOutputs := CreateJobRequest{
+		SourceType: SourceTypeS3Presigned,
+		Pipeline: []PipelineStep{
+			{ID: "thumb!", Action: "resize"},
+			{ID: "thumb?", Action: "resize"},
+		},
+	}
+	err := collidingOutputs.Validate()
+	if err == nil {
+		t.Fatal("expected validation error for colliding output names")
+	}
+	if !strings.Contains(err.Error(), `pipeline[1].id "thumb?" collides with pipeline[0].id "thumb!"`) {
+		t.Fatalf("expected error to identify colliding steps, got %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		sourceType string
+		frameAt    float64
+		ok         bool
+	}{
+		"video frame":          {SourceTypeVideo, 2.5, true},
+		"video first frame":    {SourceTypeVideo, 0, true},
+		"negative frame":       {SourceTypeVideo, -1, false},
+		"frame past cap":       {SourceTypeVideo, MaxFrameAtSeconds + 1, false},
+		"frame on image input": {SourceTypeS3Presigned, 1, false},
+	} {
+		req := CreateJobRequest{
+			SourceType:     tc.sourceType,
+			FrameAtSeconds: tc.frameAt,
+			Pipeline:       []PipelineStep{{ID: "poster", Action: "resize"}},
+		}
+		if err := req.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("%s: expected ok=%v, got err=%v", name, tc.ok, err)
+		}
+	}
+
+	sidecarOverManifest := CreateJobRequest{
+		SourceType:   SourceTypeS3Presigned,
+		EmitSidecar:  true,
+		EmitManifest: true,
+		Pipeline:     []PipelineStep{{ID: "manifest", Action: "resize"}},
+	}
+	if err := sidecarOverManifest.Validate(); err == nil {
+		t.Fatal("expected validation error for a sidecar named manifest.json")
+	}
+	sidecarOverManifest.EmitSidecar = false
+	if err := sidecarOverManifest.Validate(); err != nil {
+		t.Fatalf("expected step id manifest without sidecars to validate, got %v", err)
+	}
+
+	textAndImageWatermark := CreateJobRequest{
+		SourceType: SourceTypeS3Presigned,
+		Pipeline: []PipelineStep{
+			{
+				ID:        "branded",
+				Action:    "watermark",
+				Watermark: &Watermark{Text: "PixelFlow", ImageObjectKey: "assets/logo.png"},
+			},
+		},
+	}
+	if err := textAndImageWatermark.Validate(); err == nil {
+		t.Fatal("expected validation error when watermark sets both text and image_object_key")
+	}
+
+	badWatermarkColor := CreateJobRequest{
+		SourceType: SourceTypeS3Presigned,
+		Pipeline: []PipelineStep{
+			{
+				ID:        "dark",
+				Action:    "watermark",
+				Watermark: &Watermark{Text: "PixelFlow", Color: "#12345"},
+			},
+		},
+	}
+	if err := badWatermarkColor.Validate(); err == nil {
+		t.Fatal("expected validation error for malformed watermark color")
+	}
+
+	for _, wm := range []Watermark{
+		{Text: "PixelFlow", Spacing: 20},
+		{Text: "PixelFlow", Tile: true, Spacing: MaxWatermarkSpacing + 1},
+		{Text: "PixelFlow", Rotation: MaxWatermarkRotation + 1},
+		{Text: "PixelFlow", Rotation: -MaxWatermarkRotation - 0.5},
+	} {
+		req := CreateJobRequest{
+			SourceType: SourceTypeS3Presigned,
+			Pipeline:   []PipelineStep{{ID: "tiled", Action: "watermark", Watermark: &wm}},
+		}
+		if err := req.Validate(); err == nil {
+			t.Fatalf("expected validation error for watermark %+v", wm)
+		}
+	}
+
+	badSubsample := CreateJobRequest{
+		SourceType: SourceTypeS3Presigned,
+		Pipeline: []PipelineStep{
+			{ID: "hero", Action: "resize", Format: "jpeg", Subsample: "4:1:1"},
+		},
+	}
+	if err := badSubsample.Validate(); err == nil {
+		t.Fatal("expected validation error for unsupported subsample")
+	}
+
+	tooLong := CreateJobRequest{SourceType: SourceTypeS3Presigned}
+	for i := 0; i <= DefaultMaxPipelineSteps; i++ {
+		tooLong.Pipeline = append(tooLong.Pipeline, PipelineStep{ID: fmt.Sprintf("step-%d", i), Action: "resize", Width: 10})
+	}
+	if err := tooLong.Validate(); err == nil {
+		t.Fatalf("expected validation error for more than %d steps", DefaultMaxPipelineSteps)
+	}
+	if err := tooLong.ValidateWithMaxSteps(0); err != nil {
+		t.Fatalf("expected disabled step cap to accept long pipeline: %v", err)
+	}
+
+	for name, headers := range map[string]map[string]string{
+		"bad name":      {"Bad Header": "x"},
+		"reserved":      {"X-Pixelflow-Signature": "x"},
+		"control chars": {"X-Route": "a\r\nInjected: 1"},
+	} {
+		req := CreateJobRequest{SourceType: SourceTypeS3Presigned, WebhookURL: "https://example.com/hook", WebhookHeaders: headers, Pipeline: []PipelineStep{{ID: "thumb", Action: "resize", Width: 10}}}
+		if err := req.Validate(); err == nil {
+			t.Fatalf("expected webhook_headers validation error for %s", name)
+		}
+	}
+	tooManyHeaders := make(map[string]string, MaxWebhookHeaders+1)
+	for i := range MaxWebhookHeaders + 1 {
+		tooManyHeaders[fmt.Sprintf("X-Header-%d", i)] = "v"
+	}
+	withHeaders := CreateJobRequest{SourceType: SourceTypeS3Presigned, WebhookURL: "https://example.com/hook", WebhookHeaders: tooManyHeaders, Pipeline: []PipelineStep{{ID: "thumb", Action: "resize", Width: 10}}}
+	if err := withHeaders.Validate(); err == nil {
+		t.Fatalf("expected error for more than %d webhook headers", MaxWebhookHeaders)
+	}
+	withHeaders.WebhookHeaders = map[string]string{"Authorization": "Bearer token"}
+	if err := withHeaders.Validate(); err != nil {
+		t.Fatalf("expected valid webhook headers, got %v", err)
+	}
+	withHeaders.WebhookURL = ""
+	if err := withHeaders.Validate(); err == nil {
+		t.Fatal("expected webhook_headers without webhook_url to be rejected")
+	}
+
+	tooMuchContrast := 2.5
+	for name, step := range map[string]PipelineStep{
+		"block size":   {ID: "redact", Action: "pixelate", BlockSize: 1},
+		"empty region": {ID: "redact", Action: "pixelate", BlockSize: 8, Region: &Region{X: 0, Y: 0, Width: 0, Height: 10}},
+		"negative":     {ID: "redact", Action: "pixelate", BlockSize: 8, Region: &Region{X: -1, Y: 0, Width: 10, Height: 10}},
+		"brightness":   {ID: "exposure", Action: "adjust", Brightness: 101},
+		"contrast":     {ID: "exposure", Action: "adjust", Contrast: &tooMuchContrast},
+		"background":   {ID: "flat", Action: "flatten", Background: "white"},
+		"thumbnail":    {ID: "thumb", Action: "thumbnail"},
+		"border width": {ID: "frame", Action: "border", Color: "#000000"},
+		"border color": {ID: "frame", Action: "border", BorderWidth: 4},
+		"radius":       {ID: "avatar", Action: "rounded_corners"},
+		"jpeg corners": {ID: "avatar", Action: "rounded_corners", Radius: 8, Format: "jpg"},
+		"density":      {ID: "print", Action: "resize", Width: 10, Density: 2400},
+		"target bytes": {ID: "cdn", Action: "resize", Width: 10, Format: "jpeg", TargetBytes: 200 << 10, Quality: 80},
+		"filename":     {ID: "hero", Action: "resize", Width: 10, Filename: "../hero.png"},
+	} {
+		req := CreateJobRequest{SourceType: SourceTypeS3Presigned, Pipeline: []PipelineStep{step}}
+		if err := req.Validate(); err == nil {
+			t.Fatalf("expected validation error for %s", name)
+		}
+	}
 }
@@ -10,3 +10,11 @@ type UsageLog struct {
 	ComputeTimeMS   int64
 	CreatedAt       time.Time
 }
+
+type UsageSummary struct {
+	UserID          string
+	Jobs            int64
+	PixelsProcessed int64
+	BytesSaved      int64
+	ComputeTimeMS   int64
+}
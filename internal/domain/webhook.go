@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the callback state of one job event. Attempts counts
+// every HTTP attempt across task retries; DeliveredAt is zero until a 2xx.
+type WebhookDelivery struct {
+	JobID          string
+	Event          string
+	Status         string
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	UpdatedAt      time.Time
+	DeliveredAt    time.Time
+}
@@ -0,0 +1,53 @@
+//go:build kafka
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaSink publishes events to cfg.Topic keyed by job_id, waiting for all
+// in-sync replicas to acknowledge each message.
+func NewKafkaSink(cfg KafkaConfig) (ClosableSink, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka brokers are required")
+	}
+	if strings.TrimSpace(cfg.Topic) == "" {
+		return nil, errors.New("kafka topic is required")
+	}
+	return &kafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}, nil
+}
+
+func (k *kafkaSink) Publish(ctx context.Context, event string, payload any) error {
+	body, err := encodeEnvelope(event, payload)
+	if err != nil {
+		return err
+	}
+	err = k.writer.WriteMessages(ctx, kafka.Message{
+		Key:     jobKey(payload),
+		Value:   body,
+		Headers: []kafka.Header{{Key: "event", Value: []byte(event)}},
+	})
+	if err != nil {
+		return fmt.Errorf("publish %s to kafka: %w", event, err)
+	}
+	return nil
+}
+
+func (k *kafkaSink) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,6 @@
+package events
+
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
@@ -0,0 +1,9 @@
+//go:build !kafka
+
+package events
+
+import "errors"
+
+func NewKafkaSink(KafkaConfig) (ClosableSink, error) {
+	return nil, errors.New("kafka events require building with -tags kafka")
+}
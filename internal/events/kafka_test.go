@@ -0,0 +1,42 @@
+//go:build kafka
+
+package events
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewKafkaSinkValidatesConfig(t *testing.T) {
+	if _, err := NewKafkaSink(KafkaConfig{Topic: "pixelflow.jobs"}); err == nil {
+		t.Fatal("expected an error without brokers")
+	}
+	if _, err := NewKafkaSink(KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: " "}); err == nil {
+		t.Fatal("expected an error without a topic")
+	}
+}
+
+func TestKafkaSinkPublishReportsUnreachableBroker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	sink, err := NewKafkaSink(KafkaConfig{Brokers: []string{addr}, Topic: "pixelflow.jobs"})
+	if err != nil {
+		t.Fatalf("new kafka sink: %v", err)
+	}
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = sink.Publish(ctx, "job.completed", map[string]any{"job_id": "job-1"})
+	if err == nil || !strings.Contains(err.Error(), "publish job.completed to kafka") {
+		t.Fatalf("expected a wrapped publish error, got %v", err)
+	}
+}
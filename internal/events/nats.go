@@ -0,0 +1,72 @@
+//go:build nats
+
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type natsSink struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATSSink publishes events to cfg.Subject through JetStream. It fails if
+// no stream captures the subject, since core NATS would drop the messages.
+func NewNATSSink(ctx context.Context, cfg NATSConfig) (ClosableSink, error) {
+	subject := strings.TrimSpace(cfg.Subject)
+	if subject == "" {
+		return nil, errors.New("nats subject is required")
+	}
+	conn, err := nats.Connect(cfg.URL, nats.Name("pixelflow-worker"))
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("open jetstream: %w", err)
+	}
+	if _, err := js.StreamNameBySubject(ctx, subject); err != nil {
+		conn.Close()
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return nil, fmt.Errorf("no jetstream stream captures subject %q; create one first: %w", subject, err)
+		}
+		return nil, fmt.Errorf("look up jetstream stream for subject %q: %w", subject, err)
+	}
+	return &natsSink{conn: conn, js: js, subject: subject}, nil
+}
+
+// Publish waits for the stream's ack so delivery is at-least-once. The
+// Nats-Msg-Id header lets JetStream drop duplicates from task retries within
+// its dedup window.
+func (n *natsSink) Publish(ctx context.Context, event string, payload any) error {
+	body, err := encodeEnvelope(event, payload)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(n.subject)
+	msg.Data = body
+	msg.Header.Set("Pixelflow-Event", event)
+	if key := jobKey(payload); key != nil {
+		msg.Header.Set(jetstream.MsgIDHeader, string(key)+":"+event)
+	}
+	for k, v := range traceHeaders(ctx) {
+		msg.Header.Set(k, v)
+	}
+	if _, err := n.js.PublishMsg(ctx, msg); err != nil {
+		return fmt.Errorf("publish %s to nats: %w", event, err)
+	}
+	return nil
+}
+
+func (n *natsSink) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,6 @@
+package events
+
+type NATSConfig struct {
+	URL     string
+	Subject string
+}
@@ -0,0 +1,12 @@
+//go:build !nats
+
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+func NewNATSSink(context.Context, NATSConfig) (ClosableSink, error) {
+	return nil, errors.New("nats events require building with -tags nats")
+}
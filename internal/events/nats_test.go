@@ -0,0 +1,92 @@
+//go:build nats
+
+package events
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func runJetStreamServer(t *testing.T) string {
+	t.Helper()
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natsserver.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+	return srv.ClientURL()
+}
+
+func TestNewNATSSinkRequiresStream(t *testing.T) {
+	url := runJetStreamServer(t)
+
+	_, err := NewNATSSink(context.Background(), NATSConfig{URL: url, Subject: "pixelflow.jobs.events"})
+	if err == nil || !strings.Contains(err.Error(), "no jetstream stream captures subject") {
+		t.Fatalf("expected a missing-stream error, got %v", err)
+	}
+	if _, err := NewNATSSink(context.Background(), NATSConfig{URL: url}); err == nil {
+		t.Fatal("expected an error without a subject")
+	}
+}
+
+func TestNATSSinkPublishesDeduplicatedEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url := runJetStreamServer(t)
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("open jetstream: %v", err)
+	}
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "PIXELFLOW", Subjects: []string{"pixelflow.jobs.events"}})
+	if err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+
+	sink, err := NewNATSSink(ctx, NATSConfig{URL: url, Subject: "pixelflow.jobs.events"})
+	if err != nil {
+		t.Fatalf("new nats sink: %v", err)
+	}
+	defer sink.Close()
+
+	payload := map[string]any{"job_id": "job-1", "status": "succeeded"}
+	// A task retry republishes the same event; JetStream should keep one copy.
+	for range 2 {
+		if err := sink.Publish(ctx, "job.completed", payload); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("expected 1 deduplicated message, got %d", info.State.Msgs)
+	}
+	msg, err := stream.GetMsg(ctx, 1)
+	if err != nil {
+		t.Fatalf("get message: %v", err)
+	}
+	if got := msg.Header.Get("Pixelflow-Event"); got != "job.completed" {
+		t.Fatalf("expected Pixelflow-Event=job.completed, got %q", got)
+	}
+	if got := msg.Header.Get(jetstream.MsgIDHeader); got != "job-1:job.completed" {
+		t.Fatalf("expected Nats-Msg-Id=job-1:job.completed, got %q", got)
+	}
+	if !strings.Contains(string(msg.Data), `"job_id":"job-1"`) {
+		t.Fatalf("unexpected envelope %s", msg.Data)
+	}
+}
@@ -0,0 +1,65 @@
+// Package events fans terminal job events out to pluggable sinks such as
+// per-job webhooks and Kafka.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Sink delivers one job event. Implementations must be safe for concurrent use.
+type Sink interface {
+	Publish(ctx context.Context, event string, payload any) error
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, event string, payload any) error
+
+func (f SinkFunc) Publish(ctx context.Context, event string, payload any) error {
+	return f(ctx, event, payload)
+}
+
+// ClosableSink is a Sink that holds connections to release on shutdown.
+type ClosableSink interface {
+	Sink
+	Close() error
+}
+
+// Envelope is the message body written by broker sinks.
+type Envelope struct {
+	Event       string    `json:"event"`
+	PublishedAt time.Time `json:"published_at"`
+	Payload     any       `json:"payload"`
+}
+
+func encodeEnvelope(event string, payload any) ([]byte, error) {
+	body, err := json.Marshal(Envelope{Event: event, PublishedAt: time.Now().UTC(), Payload: payload})
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event: %w", event, err)
+	}
+	return body, nil
+}
+
+// jobKey returns the payload's job_id so all events for a job land on the
+// same partition.
+func jobKey(payload any) []byte {
+	if m, ok := payload.(map[string]any); ok {
+		if id, ok := m["job_id"].(string); ok {
+			return []byte(id)
+		}
+	}
+	return nil
+}
+
+// traceHeaders returns the W3C trace context carried by ctx so broker
+// consumers can continue the worker's trace.
+func traceHeaders(ctx context.Context) propagation.MapCarrier {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEncodeEnvelopeKeysByJob(t *testing.T) {
+	payload := map[string]any{"job_id": "job-1", "status": "succeeded"}
+	body, err := encodeEnvelope("job.completed", payload)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var got struct {
+		Event   string         `json:"event"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Event != "job.completed" || got.Payload["job_id"] != "job-1" {
+		t.Fatalf("unexpected envelope %s", body)
+	}
+	if key := string(jobKey(payload)); key != "job-1" {
+		t.Fatalf("expected job-1 key, got %q", key)
+	}
+	if key := jobKey("not a map"); key != nil {
+		t.Fatalf("expected nil key, got %q", key)
+	}
+}
+
+func TestTraceHeadersCarryTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	headers := traceHeaders(ctx)
+	if got := headers.Get("traceparent"); got != "00-01000000000000000000000000000000-0200000000000000-01" {
+		t.Fatalf("unexpected traceparent %q", got)
+	}
+}
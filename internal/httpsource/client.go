@@ -0,0 +1,225 @@
+// Package httpsource fetches job sources from arbitrary HTTP(S) URLs while
+// refusing to connect to private or otherwise denied networks.
+package httpsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlockedAddress = errors.New("destination address is not allowed")
+	ErrInvalidURL     = errors.New("source url must be an absolute http or https url")
+	ErrContentType    = errors.New("source url did not return an image")
+)
+
+// DefaultDenyCIDRs covers loopback, private, link-local (including cloud
+// metadata endpoints), CGNAT, benchmarking, multicast, reserved, and
+// unspecified ranges, plus the NAT64 and 6to4 prefixes that embed an IPv4
+// address a gateway would forward to.
+var DefaultDenyCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+type Config struct {
+	Timeout time.Duration
+	// AllowCIDRs are permitted even when they overlap DenyCIDRs.
+	AllowCIDRs []string
+	DenyCIDRs  []string
+}
+
+type Client struct {
+	httpClient *http.Client
+	allow      []netip.Prefix
+	deny       []netip.Prefix
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	allow, err := parsePrefixes(cfg.AllowCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("allow cidrs: %w", err)
+	}
+	deny, err := parsePrefixes(cfg.DenyCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("deny cidrs: %w", err)
+	}
+
+	c := &Client{allow: allow, deny: deny}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: c.control}
+	c.httpClient = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return c, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether the policy permits connecting to addr.
+func (c *Client) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range c.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after DNS resolution for every connection, including
+// redirects, so a hostname cannot rebind onto a denied address.
+func (c *Client) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !c.Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+func checkScheme(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), nil)
+}
+
+// ObjectExists issues a HEAD request and reports whether the URL answered 2xx.
+func (c *Client) ObjectExists(ctx context.Context, rawURL string) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("head %s: %w", rawURL, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, nil
+	default:
+		return false, fmt.Errorf("head %s: unexpected status %d", rawURL, resp.StatusCode)
+	}
+}
+
+// ReadObjectHead returns up to the first n bytes of the URL body for content
+// sniffing; the declared Content-Type is not checked.
+func (c *Client) ReadObjectHead(ctx context.Context, rawURL string, n int64) ([]byte, error) {
+	body, err := c.get(ctx, rawURL, fmt.Sprintf("bytes=0-%d", n-1), false)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, n))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rawURL, err)
+	}
+	return data, nil
+}
+
+// ReadObjectStream GETs the URL and returns its body once the response is
+// confirmed to be an image.
+func (c *Client) ReadObjectStream(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	return c.get(ctx, rawURL, "", true)
+}
+
+func (c *Client) get(ctx context.Context, rawURL, byteRange string, requireImage bool) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", rawURL, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get %s: unexpected status %d", rawURL, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); requireImage && !strings.HasPrefix(mediaType, "image/") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: content-type %q", ErrContentType, resp.Header.Get("Content-Type"))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,105 @@
+package httpsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientBlocksDeniedAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	denied, err := NewClient(Config{DenyCIDRs: DefaultDenyCIDRs})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := denied.ObjectExists(context.Background(), srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("expected loopback to be blocked, got %v", err)
+	}
+
+	allowed, err := NewClient(Config{AllowCIDRs: []string{"127.0.0.1/32"}, DenyCIDRs: DefaultDenyCIDRs})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	exists, err := allowed.ObjectExists(context.Background(), srv.URL)
+	if err != nil || !exists {
+		t.Fatalf("expected allow-listed HEAD to succeed, got exists=%v err=%v", exists, err)
+	}
+	body, err := allowed.ReadObjectStream(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "png" {
+		t.Fatalf("unexpected body %q", data)
+	}
+
+	if denied.Allowed(netip.MustParseAddr("169.254.169.254")) || !denied.Allowed(netip.MustParseAddr("93.184.216.34")) {
+		t.Fatal("unexpected default policy decisions")
+	}
+}
+
+func TestClientBlocksIPv4EmbeddedInIPv6(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+	port := netip.MustParseAddrPort(srv.Listener.Addr().String()).Port()
+
+	client, err := NewClient(Config{DenyCIDRs: DefaultDenyCIDRs})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	// Both addresses embed 127.0.0.1: NAT64 in the low 32 bits, 6to4 in bits
+	// 16-47. A gateway on either path would forward to the loopback server.
+	for _, host := range []string{"64:ff9b::7f00:1", "2002:7f00:1::1"} {
+		rawURL := fmt.Sprintf("http://[%s]:%d/image.png", host, port)
+		if _, err := client.ObjectExists(context.Background(), rawURL); !errors.Is(err, ErrBlockedAddress) {
+			t.Fatalf("expected %s to be blocked, got %v", host, err)
+		}
+		if _, err := client.ReadObjectStream(context.Background(), rawURL); !errors.Is(err, ErrBlockedAddress) {
+			t.Fatalf("expected GET via %s to be blocked, got %v", host, err)
+		}
+	}
+
+	for _, addr := range []string{"192.0.0.170", "198.18.0.1", "224.0.0.251", "240.0.0.1", "255.255.255.255", "ff02::1"} {
+		if client.Allowed(netip.MustParseAddr(addr)) {
+			t.Fatalf("expected %s to be denied by default", addr)
+		}
+	}
+	if !client.Allowed(netip.MustParseAddr("2606:4700:4700::1111")) {
+		t.Fatal("expected ordinary IPv6 addresses to stay allowed")
+	}
+}
+
+func TestClientRejectsNonImagesAndBadURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>"))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(Config{})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.ReadObjectStream(context.Background(), srv.URL); !errors.Is(err, ErrContentType) {
+		t.Fatalf("expected content-type rejection, got %v", err)
+	}
+	if _, err := client.ReadObjectStream(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("expected invalid url, got %v", err)
+	}
+	if _, err := NewClient(Config{DenyCIDRs: []string{"not-a-cidr"}}); err == nil {
+		t.Fatal("expected invalid cidr to fail")
+	}
+}
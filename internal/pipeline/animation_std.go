@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+func transformAnimatedGIF(anim *gif.GIF, step domain.PipelineStep, overlay []byte, maxDecodeBytes int64) ([]byte, string, int, int, error) {
+	frames, err := composeGIFFrames(anim, maxDecodeBytes)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+
+	out := &gif.GIF{
+		Image:     make([]*image.Paletted, 0, len(frames)),
+		Delay:     anim.Delay,
+		Disposal:  make([]byte, len(frames)),
+		LoopCount: anim.LoopCount,
+	}
+	var bounds image.Rectangle
+	for i, frame := range frames {
+		transformed, err := applyStep(frame, step, overlay)
+		if err != nil {
+			return nil, "", 0, 0, fmt.Errorf("frame %d: %w", i, err)
+		}
+		bounds = transformed.Bounds()
+
+		paletted := image.NewPaletted(bounds, framePalette(anim, i))
+		draw.Draw(paletted, bounds, transformed, bounds.Min, draw.Src)
+		out.Image = append(out.Image, paletted)
+		out.Disposal[i] = gif.DisposalNone
+	}
+	out.Config = image.Config{Width: bounds.Dx(), Height: bounds.Dy()}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return nil, "", 0, 0, fmt.Errorf("encode gif: %w", err)
+	}
+	return buf.Bytes(), "gif", bounds.Dx(), bounds.Dy(), nil
+}
+
+// composeGIFFrames renders each frame onto the full canvas. Every frame
+// becomes its own RGBA canvas, so the total is checked against maxDecodeBytes
+// before anything is allocated.
+func composeGIFFrames(anim *gif.GIF, maxDecodeBytes int64) ([]image.Image, error) {
+	canvasRect := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if canvasRect.Empty() {
+		canvasRect = anim.Image[0].Bounds()
+	}
+	if err := checkFrameBytes(len(anim.Image), canvasRect.Dx(), canvasRect.Dy(), maxDecodeBytes); err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(canvasRect)
+	frames := make([]image.Image, 0, len(anim.Image))
+	for i, frame := range anim.Image {
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneImage(canvas).(*image.RGBA)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneImage(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+func framePalette(anim *gif.GIF, i int) color.Palette {
+	if pal := anim.Image[i].Palette; len(pal) > 0 {
+		return pal
+	}
+	if pal, ok := anim.Config.ColorModel.(color.Palette); ok && len(pal) > 0 {
+		return pal
+	}
+	return color.Palette{color.Transparent, color.Black, color.White}
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Output conflict modes decide what an emitter does when an output's path or
+// object key already exists. Empty means OutputConflictOverwrite.
+const (
+	OutputConflictOverwrite = "overwrite"
+	OutputConflictError     = "error"
+	OutputConflictSuffix    = "suffix"
+)
+
+var ErrOutputExists = errors.New("output already exists")
+
+// ValidOutputConflict reports whether mode is a known conflict mode.
+func ValidOutputConflict(mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", OutputConflictOverwrite, OutputConflictError, OutputConflictSuffix:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveOutputTarget returns where to write data given mode. Overwrite skips
+// the existence check; error fails with ErrOutputExists; suffix inserts the
+// first 8 hex digits of the content's SHA-256 before the extension, so a
+// retry writing identical bytes lands on the same name.
+func resolveOutputTarget(mode, target string, data []byte, exists func(string) (bool, error)) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" || mode == OutputConflictOverwrite {
+		return target, nil
+	}
+	taken, err := exists(target)
+	if err != nil {
+		return "", fmt.Errorf("check output %s: %w", target, err)
+	}
+	if !taken {
+		return target, nil
+	}
+	if mode == OutputConflictError {
+		return "", fmt.Errorf("%w: %s", ErrOutputExists, target)
+	}
+	sum := sha256.Sum256(data)
+	ext := path.Ext(target)
+	return strings.TrimSuffix(target, ext) + "-" + hex.EncodeToString(sum[:4]) + ext, nil
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+)
+
+// withPNGDensity inserts a pHYs chunk recording dpi right after IHDR, the
+// position image/png never writes one itself.
+func withPNGDensity(data []byte, dpi int) ([]byte, error) {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return nil, errors.New("set png density: missing IHDR chunk")
+	}
+	ppm := uint32(math.Round(float64(dpi) / 0.0254))
+
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:4], 9)
+	copy(chunk[4:8], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:12], ppm)
+	binary.BigEndian.PutUint32(chunk[12:16], ppm)
+	chunk[16] = 1 // unit: metre
+	binary.BigEndian.PutUint32(chunk[17:21], crc32.ChecksumIEEE(chunk[4:17]))
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...), nil
+}
+
+// withJPEGDensity inserts a JFIF APP0 segment recording dpi after SOI;
+// image/jpeg writes no APP0, so readers otherwise assume 72 DPI.
+func withJPEGDensity(data []byte, dpi int) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errors.New("set jpeg density: missing SOI marker")
+	}
+	app0 := []byte{
+		0xff, 0xe0, 0x00, 0x10,
+		'J', 'F', 'I', 'F', 0x00,
+		0x01, 0x01, // version 1.01
+		0x01, // unit: dots per inch
+		byte(dpi >> 8), byte(dpi), byte(dpi >> 8), byte(dpi),
+		0x00, 0x00, // no thumbnail
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(app0))
+	buf.Write(data[:2])
+	buf.Write(app0)
+	buf.Write(data[2:])
+	return buf.Bytes(), nil
+}
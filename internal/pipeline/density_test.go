@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+func TestStdlibTransformer_WritesDensity(t *testing.T) {
+	input := buildTestPNG(t, 16, 8)
+
+	step := domain.PipelineStep{ID: "print", Action: "resize", Width: 16, Format: "png", Density: 300}
+	transformed, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+	if err != nil {
+		t.Fatalf("transform png: %v", err)
+	}
+	data := transformed.Data
+	idx := bytes.Index(data, []byte("pHYs"))
+	if idx < 0 {
+		t.Fatal("expected pHYs chunk in png output")
+	}
+	ppm := binary.BigEndian.Uint32(data[idx+4:])
+	if dpi := math.Round(float64(ppm) * 0.0254); dpi != 300 || data[idx+12] != 1 {
+		t.Fatalf("expected 300 dpi in metres, got %v dpi unit %d", dpi, data[idx+12])
+	}
+	transformed, err = (stdlibTransformer{}).Transform(context.Background(), input, domain.PipelineStep{ID: "print", Action: "resize", Width: 16, Format: "png"}, nil)
+	if err != nil {
+		t.Fatalf("transform plain png: %v", err)
+	}
+	plain := transformed.Data
+	assertSamePixels(t, plain, data)
+
+	step.Format = "jpeg"
+	transformed, err = (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+	if err != nil {
+		t.Fatalf("transform jpeg: %v", err)
+	}
+	data = transformed.Data
+	idx = bytes.Index(data, []byte("JFIF\x00"))
+	if idx < 0 {
+		t.Fatal("expected JFIF segment in jpeg output")
+	}
+	if unit, x, y := data[idx+7], binary.BigEndian.Uint16(data[idx+8:]), binary.BigEndian.Uint16(data[idx+10:]); unit != 1 || x != 300 || y != 300 {
+		t.Fatalf("expected 300x300 dpi, got unit %d %dx%d", unit, x, y)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("decode jpeg with density: %v", err)
+	}
+}
+
+func assertSamePixels(t *testing.T, want, got []byte) {
+	t.Helper()
+	a, _, err := image.Decode(bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("decode reference: %v", err)
+	}
+	b, _, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if a.Bounds() != b.Bounds() {
+		t.Fatalf("bounds changed: %v vs %v", a.Bounds(), b.Bounds())
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				t.Fatalf("pixel (%d,%d) changed: %v vs %v", x, y, a.At(x, y), b.At(x, y))
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/httpsource"
+)
+
+const SourceTypeHTTPURL = domain.SourceTypeHTTPURL
+
+// HTTPFetcher reads http_url sources; ObjectKey holds the URL.
+type HTTPFetcher struct {
+	Client   *httpsource.Client
+	MaxBytes int64
+}
+
+func (f HTTPFetcher) Fetch(ctx context.Context, req Request) ([]byte, error) {
+	if f.Client == nil {
+		return nil, errors.New("http source client is required")
+	}
+	if !strings.EqualFold(req.SourceType, SourceTypeHTTPURL) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSourceType, req.SourceType)
+	}
+
+	body, err := f.Client.ReadObjectStream(ctx, req.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := readLimited(body, f.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("read url %s: %w", req.ObjectKey, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var ErrInputFormat = errors.New("input format not allowed")
+
+// WithAllowedInputFormats limits sources and watermark overlays to formats as
+// named by their header ("jpeg", "png", "webp", ...). Empty allows every
+// format in SupportedInputFormats.
+func WithAllowedInputFormats(formats []string) ProcessorOption {
+	return func(p *Processor) {
+		p.allowedFormats = nil
+		for _, format := range formats {
+			if format = NormalizeInputFormat(format); format != "" {
+				p.allowedFormats = append(p.allowedFormats, format)
+			}
+		}
+	}
+}
+
+// NormalizeInputFormat lower-cases format and maps common aliases (jpg, tif,
+// heic) and image media types (image/jpeg) to the names image headers report.
+func NormalizeInputFormat(format string) string {
+	format = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(format)), "image/")
+	switch format {
+	case "jpg":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	case "heic":
+		return "heif"
+	default:
+		return format
+	}
+}
+
+// SniffInputFormat names the image format of a source from its first bytes,
+// using the names image headers report, or returns "" when it is not an
+// image this service decodes in any build. It only needs the first few dozen
+// bytes, so the API can check an upload without fetching it, and it knows
+// the libvips-only formats (tiff, heif, avif) in stdlib builds too.
+func SniffInputFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "gif"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "tiff"
+	}
+	return sniffHEIFBrand(head)
+}
+
+// sniffHEIFBrand reads the brands of an ISO BMFF ftyp box. AVIF files may
+// use the generic mif1 major brand, so compatible brands are checked too.
+func sniffHEIFBrand(head []byte) string {
+	if len(head) < 16 || string(head[4:8]) != "ftyp" {
+		return ""
+	}
+	size := int(binary.BigEndian.Uint32(head[:4]))
+	if size < 16 || size > len(head) {
+		size = len(head)
+	}
+	format := ""
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue // minor version
+		}
+		switch string(head[i : i+4]) {
+		case "avif", "avis":
+			return "avif"
+		case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+			format = "heif"
+		}
+	}
+	return format
+}
+
+// UnsupportedInputFormats returns the entries of formats this build's
+// transformer cannot decode.
+func UnsupportedInputFormats(formats []string) []string {
+	supported := SupportedInputFormats()
+	var unsupported []string
+	for _, format := range formats {
+		if normalized := NormalizeInputFormat(format); normalized != "" && !slices.Contains(supported, normalized) {
+			unsupported = append(unsupported, format)
+		}
+	}
+	return unsupported
+}
+
+func (p *Processor) inputFormatAllowed(format string) bool {
+	if len(p.allowedFormats) == 0 {
+		return slices.Contains(SupportedInputFormats(), format)
+	}
+	return slices.Contains(p.allowedFormats, format)
+}
+
+// checkInputFormat rejects data whose header names a format outside the
+// allowlist. Data without a readable header is named by its sniffed content
+// type (application/pdf, image/bmp, text/xml for SVG); empty data is left for
+// the transformer to report as ErrEmptyImage.
+func (p *Processor) checkInputFormat(format string, headerErr error, data []byte) error {
+	if headerErr != nil {
+		if len(data) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%w: unrecognized image header (detected %s)", ErrInputFormat, http.DetectContentType(data))
+	}
+	if !p.inputFormatAllowed(format) {
+		return fmt.Errorf("%w: %s", ErrInputFormat, format)
+	}
+	return nil
+}
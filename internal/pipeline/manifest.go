@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const manifestFilename = "manifest.json"
+
+// ManifestEmitter is implemented by emitters that can write a job manifest
+// next to its outputs. It returns the manifest's path or object key.
+type ManifestEmitter interface {
+	EmitManifest(ctx context.Context, req Request, data []byte) (string, error)
+}
+
+// OutputURLFunc returns a download URL for an emitted output path.
+type OutputURLFunc func(ctx context.Context, path string) (string, error)
+
+type manifest struct {
+	JobID       string          `json:"job_id"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Outputs     []manifestEntry `json:"outputs"`
+}
+
+type manifestEntry struct {
+	StepID      string `json:"step_id"`
+	Action      string `json:"action"`
+	Format      string `json:"format"`
+	Path        string `json:"path"`
+	Bytes       int    `json:"bytes"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Quality     int    `json:"quality,omitempty"`
+	SidecarPath string `json:"sidecar_path,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// emitManifest serializes outputs and writes them through the processor's
+// emitter. A URL that fails to presign is left out rather than failing the job.
+func (p *Processor) emitManifest(ctx context.Context, req Request, outputs []Output) (string, error) {
+	writer, ok := p.emitter.(ManifestEmitter)
+	if !ok {
+		return "", errors.New("emitter does not support manifests")
+	}
+
+	doc := manifest{
+		JobID:       req.JobID,
+		GeneratedAt: time.Now().UTC(),
+		Outputs:     make([]manifestEntry, 0, len(outputs)),
+	}
+	for _, out := range outputs {
+		entry := manifestEntry{
+			StepID:      out.StepID,
+			Action:      out.Action,
+			Format:      out.Format,
+			Path:        out.Path,
+			Bytes:       out.Bytes,
+			Width:       out.Width,
+			Height:      out.Height,
+			Quality:     out.Quality,
+			SidecarPath: out.SidecarPath,
+		}
+		if p.outputURL != nil {
+			if url, err := p.outputURL(ctx, out.Path); err == nil {
+				entry.URL = url
+			}
+		}
+		doc.Outputs = append(doc.Outputs, entry)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode manifest: %w", err)
+	}
+	return writer.EmitManifest(ctx, req, data)
+}
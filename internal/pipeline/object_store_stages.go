@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mime"
 	"path"
 	"strings"
 
@@ -16,7 +17,8 @@ const (
 )
 
 type ObjectStoreFetcher struct {
-	Storage *storage.Client
+	Storage  storage.Backend
+	MaxBytes int64
 }
 
 func (f ObjectStoreFetcher) Fetch(ctx context.Context, req Request) ([]byte, error) {
@@ -26,12 +28,31 @@ func (f ObjectStoreFetcher) Fetch(ctx context.Context, req Request) ([]byte, err
 	if strings.EqualFold(req.SourceType, SourceTypeLocalFile) {
 		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSourceType, req.SourceType)
 	}
-	return f.Storage.ReadObject(ctx, req.ObjectKey)
+
+	obj, err := f.Storage.ReadObjectStream(ctx, req.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := readLimited(obj, f.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("read object %s: %w", req.ObjectKey, err)
+	}
+	return data, nil
 }
 
+// ObjectStoreEmitter writes outputs under OutputPrefix. CacheControl is
+// stored verbatim on each output; Disposition ("inline" or "attachment")
+// adds a Content-Disposition naming the step's filename, or the output key's
+// base name when the step sets none. A step filename alone implies inline.
+// Conflict is one of the OutputConflict modes.
 type ObjectStoreEmitter struct {
-	Storage      *storage.Client
+	Storage      storage.Backend
 	OutputPrefix string
+	CacheControl string
+	Disposition  string
+	Conflict     string
 }
 
 func (e ObjectStoreEmitter) Emit(ctx context.Context, req Request, step domain.PipelineStep, data []byte, format string, width, height int) (Output, error) {
@@ -43,16 +64,21 @@ func (e ObjectStoreEmitter) Emit(ctx context.Context, req Request, step domain.P
 	}
 
 	objectKey := path.Join(
-		defaultOutputPrefix(e.OutputPrefix),
-		sanitizePathToken(req.JobID),
-		fmt.Sprintf("%s.%s", sanitizePathToken(step.ID), normalizeOutputFormat(format)),
+		e.jobPrefix(req),
+		fmt.Sprintf("%s.%s", domain.SanitizePathToken(step.ID), normalizeOutputFormat(format)),
 	)
+	objectKey, err := resolveOutputTarget(e.Conflict, objectKey, data, func(key string) (bool, error) {
+		return e.Storage.ObjectExists(ctx, key)
+	})
+	if err != nil {
+		return Output{}, err
+	}
 
-	if err := e.Storage.WriteObject(ctx, objectKey, data, contentTypeForFormat(format)); err != nil {
+	if err := e.Storage.WriteObject(ctx, objectKey, data, e.writeOptions(step, objectKey, format)); err != nil {
 		return Output{}, err
 	}
 
-	return Output{
+	out := Output{
 		StepID:  step.ID,
 		Action:  step.Action,
 		Format:  normalizeOutputFormat(format),
@@ -61,7 +87,54 @@ func (e ObjectStoreEmitter) Emit(ctx context.Context, req Request, step domain.P
 		Width:   width,
 		Height:  height,
 		Success: true,
-	}, nil
+	}
+	if req.EmitSidecar {
+		meta, err := encodeSidecar(out)
+		if err != nil {
+			return Output{}, err
+		}
+		out.SidecarPath = sidecarPath(objectKey)
+		if err := e.Storage.WriteObject(ctx, out.SidecarPath, meta, storage.WriteOptions{ContentType: "application/json"}); err != nil {
+			return Output{}, err
+		}
+	}
+	return out, nil
+}
+
+// EmitManifest writes manifest.json under the job's output prefix, replacing
+// any earlier manifest regardless of Conflict.
+func (e ObjectStoreEmitter) EmitManifest(ctx context.Context, req Request, data []byte) (string, error) {
+	if e.Storage == nil {
+		return "", errors.New("storage client is required")
+	}
+	objectKey := path.Join(e.jobPrefix(req), manifestFilename)
+	if err := e.Storage.WriteObject(ctx, objectKey, data, storage.WriteOptions{ContentType: "application/json"}); err != nil {
+		return "", err
+	}
+	return objectKey, nil
+}
+
+func (e ObjectStoreEmitter) jobPrefix(req Request) string {
+	return path.Join(defaultOutputPrefix(e.OutputPrefix), domain.SanitizePathToken(req.JobID))
+}
+
+func (e ObjectStoreEmitter) writeOptions(step domain.PipelineStep, objectKey, format string) storage.WriteOptions {
+	opts := storage.WriteOptions{
+		ContentType:  contentTypeForFormat(format),
+		CacheControl: strings.TrimSpace(e.CacheControl),
+	}
+	disposition := strings.ToLower(strings.TrimSpace(e.Disposition))
+	filename := step.Filename
+	if disposition == "" && filename != "" {
+		disposition = "inline"
+	}
+	if disposition != "" {
+		if filename == "" {
+			filename = path.Base(objectKey)
+		}
+		opts.ContentDisposition = mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+	}
+	return opts
 }
 
 func defaultOutputPrefix(prefix string) string {
@@ -78,6 +151,8 @@ func contentTypeForFormat(format string) string {
 		return "image/jpeg"
 	case "webp":
 		return "image/webp"
+	case "gif":
+		return "image/gif"
 	default:
 		return "image/png"
 	}
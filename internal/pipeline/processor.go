@@ -1,14 +1,31 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/httpsource"
+	"github.com/dunamismax/pixelflow/internal/video"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 const SourceTypeLocalFile = domain.SourceTypeLocalFile
@@ -16,13 +33,32 @@ const SourceTypeLocalFile = domain.SourceTypeLocalFile
 var (
 	ErrUnsupportedSourceType = errors.New("unsupported source_type")
 	ErrInvalidStepAction     = errors.New("invalid pipeline action")
+	ErrChainWithoutPrevious  = errors.New("chained step has no previous step")
+	ErrInputTooLarge         = errors.New("input exceeds maximum size")
+	ErrTooManyPixels         = errors.New("input exceeds maximum pixel count")
+	ErrDecompressionBomb     = errors.New("input would exceed maximum decoded size")
+	ErrEmptyImage            = errors.New("source image is empty or truncated")
+	// ErrUnsupportedOption marks step options the active transformer cannot
+	// honour, such as WebP quality in the stdlib build; retrying cannot help.
+	ErrUnsupportedOption = errors.New("step option not supported by this build")
+	// ErrOverlayKey marks a watermark image outside the assets prefix.
+	ErrOverlayKey = errors.New("watermark image is outside the assets prefix")
 )
 
 type Request struct {
-	JobID      string
-	SourceType string
-	ObjectKey  string
-	Pipeline   []domain.PipelineStep
+	JobID       string
+	SourceType  string
+	ObjectKey   string
+	Pipeline    []domain.PipelineStep
+	EmitSidecar bool
+	// EmitManifest writes a manifest.json describing every output next to
+	// them once all steps succeed.
+	EmitManifest bool
+	// FrameAt is the offset of the frame used as the source for video jobs.
+	FrameAt time.Duration
+	// OutputSubdir replaces the job-id directory for local outputs; it is
+	// sanitized with domain.SanitizeSubdir.
+	OutputSubdir string
 }
 
 type Output struct {
@@ -33,12 +69,17 @@ type Output struct {
 	Bytes   int
 	Width   int
 	Height  int
+	Quality int
 	Success bool
+
+	SidecarPath string
 }
 
 type Result struct {
 	SourceBytes int
 	Outputs     []Output
+	// ManifestPath is set when Request.EmitManifest was honored.
+	ManifestPath string
 }
 
 type Fetcher interface {
@@ -50,34 +91,143 @@ type Emitter interface {
 }
 
 type Processor struct {
-	fetcher     Fetcher
-	transformer Transformer
-	emitter     Emitter
+	fetcher       Fetcher
+	transformer   Transformer
+	emitter       Emitter
+	maxInputBytes int64
+	maxPixels     int64
+	// maxDecodeBytes caps width*height*decodedBytesPerPixel declared by
+	// the source header.
+	maxDecodeBytes int64
+	// allowedFormats restricts header formats; nil means
+	// SupportedInputFormats.
+	allowedFormats []string
+	tracer         trace.Tracer
+	observeStep    StepObserver
+	dedupSteps     bool
+	// stepConcurrency bounds how many independent step chains run at once;
+	// <= 1 runs the pipeline serially.
+	stepConcurrency int
+	// outputConflict is passed to the local-file emitter.
+	outputConflict string
+	outputURL      OutputURLFunc
+	// overlayFetcher reads watermark images, only under overlayPrefix.
+	overlayFetcher Fetcher
+	overlayPrefix  string
+}
+
+// StepObserver is called after each transform with the step action
+// (lowercased, or "unknown" for actions no transformer implements), how long
+// the transform took, and its error (nil on success).
+type StepObserver func(action string, duration time.Duration, err error)
+
+type ProcessorOption func(*Processor)
+
+func WithMaxInputBytes(limit int64) ProcessorOption {
+	return func(p *Processor) {
+		p.maxInputBytes = limit
+	}
+}
+
+func WithMaxPixels(limit int64) ProcessorOption {
+	return func(p *Processor) {
+		p.maxPixels = limit
+	}
+}
+
+// WithMaxDecodeBytes rejects sources whose header declares dimensions that
+// would need more than limit bytes as a decoded RGBA bitmap, before decoding.
+func WithMaxDecodeBytes(limit int64) ProcessorOption {
+	return func(p *Processor) {
+		p.maxDecodeBytes = limit
+	}
+}
+
+func WithTracer(tracer trace.Tracer) ProcessorOption {
+	return func(p *Processor) {
+		p.tracer = tracer
+	}
+}
+
+func WithStepObserver(observer StepObserver) ProcessorOption {
+	return func(p *Processor) {
+		p.observeStep = observer
+	}
+}
+
+// WithStepDedup reuses a transform result for later steps in the same job
+// whose input and parameters (ignoring id, chain, and filename) match an
+// earlier step. Each step is still emitted under its own id.
+func WithStepDedup(enabled bool) ProcessorOption {
+	return func(p *Processor) {
+		p.dedupSteps = enabled
+	}
 }
 
-func NewLocalProcessor(outputDir string) (*Processor, error) {
-	transformer, err := newTransformer()
+// WithStepConcurrency runs up to limit independent step chains (a non-chained
+// step plus the chained steps after it) in parallel. Output order is
+// unchanged.
+func WithStepConcurrency(limit int) ProcessorOption {
+	return func(p *Processor) {
+		p.stepConcurrency = limit
+	}
+}
+
+// WithOutputConflict sets the OutputConflict mode used by the local-file
+// emitter. Object-store processors take it as ObjectStoreEmitter.Conflict.
+func WithOutputConflict(mode string) ProcessorOption {
+	return func(p *Processor) {
+		p.outputConflict = mode
+	}
+}
+
+// WithOutputURLs adds a download URL for each output to emitted manifests.
+func WithOutputURLs(fn OutputURLFunc) ProcessorOption {
+	return func(p *Processor) {
+		p.outputURL = fn
+	}
+}
+
+// WithOverlayAssets reads watermark images through fetcher, which must read the
+// object store, and only for keys under prefix. Overlays never go through the
+// job's own fetcher, so a job cannot use one to read another tenant's upload
+// or a local file. Without this option image watermarks are unsupported.
+func WithOverlayAssets(fetcher Fetcher, prefix string) ProcessorOption {
+	return func(p *Processor) {
+		p.overlayFetcher = fetcher
+		p.overlayPrefix = prefix
+	}
+}
+
+func NewLocalProcessor(outputDir string, opts ...ProcessorOption) (*Processor, error) {
+	p := &Processor{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	transformer, err := newTransformer(p.maxDecodeBytes)
 	if err != nil {
 		return nil, fmt.Errorf("build transformer: %w", err)
 	}
-
-	return &Processor{
-		fetcher:     LocalFileFetcher{},
-		transformer: transformer,
-		emitter:     LocalFileEmitter{OutputDir: outputDir},
-	}, nil
+	p.transformer = transformer
+	p.fetcher = LocalFileFetcher{MaxBytes: p.maxInputBytes}
+	p.emitter = LocalFileEmitter{OutputDir: outputDir, Conflict: p.outputConflict}
+	return p, nil
 }
 
-func NewObjectStoreProcessor(fetcher Fetcher, emitter Emitter) (*Processor, error) {
-	transformer, err := newTransformer()
+func NewObjectStoreProcessor(fetcher Fetcher, emitter Emitter, opts ...ProcessorOption) (*Processor, error) {
+	p := &Processor{
+		fetcher: fetcher,
+		emitter: emitter,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	transformer, err := newTransformer(p.maxDecodeBytes)
 	if err != nil {
 		return nil, fmt.Errorf("build transformer: %w", err)
 	}
-	return &Processor{
-		fetcher:     fetcher,
-		transformer: transformer,
-		emitter:     emitter,
-	}, nil
+	p.transformer = transformer
+	return p, nil
 }
 
 func (p *Processor) Process(ctx context.Context, req Request) (Result, error) {
@@ -92,36 +242,381 @@ func (p *Processor) Process(ctx context.Context, req Request) (Result, error) {
 	if err != nil {
 		return Result{}, fmt.Errorf("fetch stage: %w", err)
 	}
+	if p.maxInputBytes > 0 && int64(len(sourceBytes)) > p.maxInputBytes {
+		return Result{}, fmt.Errorf("fetch stage: %w: %d bytes > %d", ErrInputTooLarge, len(sourceBytes), p.maxInputBytes)
+	}
+	if err := p.checkHeader(sourceBytes); err != nil {
+		return Result{}, fmt.Errorf("fetch stage: %w", err)
+	}
+
+	if req.Pipeline[0].Chain {
+		return Result{}, fmt.Errorf("%w: step=%s", ErrChainWithoutPrevious, req.Pipeline[0].ID)
+	}
 
-	out := Result{
-		SourceBytes: len(sourceBytes),
-		Outputs:     make([]Output, 0, len(req.Pipeline)),
+	var cache *stepCache
+	if p.dedupSteps {
+		cache = &stepCache{calls: make(map[string]*stepCall, len(req.Pipeline))}
 	}
-	for _, step := range req.Pipeline {
+	outputs := make([]Output, len(req.Pipeline))
+	if err := p.runChains(ctx, req, sourceBytes, stepChains(req.Pipeline), cache, outputs); err != nil {
+		return Result{}, err
+	}
+
+	result := Result{SourceBytes: len(sourceBytes), Outputs: outputs}
+	if req.EmitManifest {
+		manifestPath, err := p.emitManifest(ctx, req, outputs)
+		if err != nil {
+			return Result{}, fmt.Errorf("emit stage manifest: %w", err)
+		}
+		result.ManifestPath = manifestPath
+	}
+	return result, nil
+}
+
+// stepChains splits a pipeline into runs of step indices that must execute in
+// order: each non-chained step starts a new run reading the source, and
+// chained steps join the run of the step before them. Runs are independent.
+func stepChains(steps []domain.PipelineStep) [][]int {
+	var chains [][]int
+	for i, step := range steps {
+		if step.Chain && len(chains) > 0 {
+			chains[len(chains)-1] = append(chains[len(chains)-1], i)
+			continue
+		}
+		chains = append(chains, []int{i})
+	}
+	return chains
+}
+
+// runChains executes chains on up to stepConcurrency goroutines, writing each
+// step's output at its pipeline index. The first failure cancels the
+// remaining chains and is returned.
+func (p *Processor) runChains(ctx context.Context, req Request, source []byte, chains [][]int, cache *stepCache, outputs []Output) error {
+	workers := min(p.stepConcurrency, len(chains))
+	if workers <= 1 {
+		for _, chain := range chains {
+			if err := p.runChain(ctx, req, source, chain, cache, outputs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	next := make(chan []int)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chain := range next {
+				if err := p.runChain(runCtx, req, source, chain, cache, outputs); err != nil {
+					failOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, chain := range chains {
+		select {
+		case next <- chain:
+		case <-runCtx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (p *Processor) runChain(ctx context.Context, req Request, source []byte, chain []int, cache *stepCache, outputs []Output) error {
+	input := source
+	for _, i := range chain {
 		select {
 		case <-ctx.Done():
-			return Result{}, ctx.Err()
+			return ctx.Err()
 		default:
 		}
 
-		transformed, format, width, height, err := p.transformer.Transform(ctx, sourceBytes, step)
+		transformed, written, err := p.runStep(ctx, req, req.Pipeline[i], input, cache)
 		if err != nil {
-			return Result{}, fmt.Errorf("transform stage step=%s action=%s: %w", step.ID, step.Action, err)
+			return err
 		}
+		outputs[i] = written
+		input = transformed
+	}
+	return nil
+}
+
+// stepCache holds deduplicated transform results for one Process call; a nil
+// cache disables deduplication.
+type stepCache struct {
+	mu    sync.Mutex
+	calls map[string]*stepCall
+}
 
-		written, err := p.emitter.Emit(ctx, req, step, transformed, format, width, height)
+// stepCall is one transform, finished once done is closed.
+type stepCall struct {
+	done   chan struct{}
+	result Transformed
+	err    error
+}
+
+// do runs fn once per key. Later callers, including ones on other chains
+// while the first is still running, wait for and share its result; cached
+// reports that fn was not run by this caller. A nil cache always runs fn.
+func (c *stepCache) do(ctx context.Context, key string, fn func() (Transformed, error)) (result Transformed, cached bool, err error) {
+	if c == nil {
+		result, err = fn()
+		return result, false, err
+	}
+
+	c.mu.Lock()
+	call, ok := c.calls[key]
+	if !ok {
+		call = &stepCall{done: make(chan struct{})}
+		c.calls[key] = call
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		call.result, call.err = fn()
+		close(call.done)
+		return call.result, false, call.err
+	}
+	select {
+	case <-call.done:
+		return call.result, true, call.err
+	case <-ctx.Done():
+		return Transformed{}, false, ctx.Err()
+	}
+}
+
+func (p *Processor) runStep(ctx context.Context, req Request, step domain.PipelineStep, input []byte, cache *stepCache) ([]byte, Output, error) {
+	tracer := p.tracer
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer("")
+	}
+	ctx, span := tracer.Start(ctx, "pipeline.step")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("step.id", step.ID),
+		attribute.String("step.action", step.Action),
+	)
+
+	fail := func(err error) ([]byte, Output, error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "step failed")
+		return nil, Output{}, err
+	}
+
+	var cacheKey string
+	if cache != nil {
+		cacheKey = stepCacheKey(input, step)
+	}
+	result, cached, err := cache.do(ctx, cacheKey, func() (Transformed, error) {
+		overlay, err := p.fetchOverlay(ctx, req, step)
+		if err != nil {
+			return Transformed{}, fmt.Errorf("fetch stage step=%s overlay: %w", step.ID, err)
+		}
+
+		transformStarted := time.Now()
+		result, err := p.transformer.Transform(ctx, input, step, overlay)
+		if p.observeStep != nil {
+			p.observeStep(stepActionLabel(step.Action), time.Since(transformStarted), err)
+		}
 		if err != nil {
-			return Result{}, fmt.Errorf("emit stage step=%s action=%s: %w", step.ID, step.Action, err)
+			return Transformed{}, fmt.Errorf("transform stage step=%s action=%s: %w", step.ID, step.Action, err)
 		}
-		out.Outputs = append(out.Outputs, written)
+		return result, nil
+	})
+	span.SetAttributes(attribute.Bool("step.cache_hit", cached))
+	if err != nil {
+		return fail(err)
 	}
+	transformed, format, width, height := result.Data, result.Format, result.Width, result.Height
+	span.SetAttributes(
+		attribute.String("step.format", format),
+		attribute.Int("step.output_bytes", len(transformed)),
+	)
 
-	return out, nil
+	written, err := p.emitter.Emit(ctx, req, step, transformed, format, width, height)
+	if err != nil {
+		return fail(fmt.Errorf("emit stage step=%s action=%s: %w", step.ID, step.Action, err))
+	}
+	written.Quality = result.Quality
+	return transformed, written, nil
+}
+
+// stepCacheKey identifies a transform by its input digest and the step's
+// normalized parameters, so steps differing only in id reuse one result.
+func stepCacheKey(input []byte, step domain.PipelineStep) string {
+	step.ID = ""
+	step.Chain = false
+	step.Filename = ""
+	step.Action = strings.ToLower(strings.TrimSpace(step.Action))
+	params, _ := json.Marshal(step)
+
+	digest := sha256.Sum256(input)
+	h := sha256.New()
+	h.Write(digest[:])
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *Processor) fetchOverlay(ctx context.Context, req Request, step domain.PipelineStep) ([]byte, error) {
+	if step.Watermark == nil || strings.TrimSpace(step.Watermark.ImageObjectKey) == "" {
+		return nil, nil
+	}
+	if p.overlayFetcher == nil {
+		return nil, fmt.Errorf("%w: watermark.image_object_key needs an assets store", ErrUnsupportedOption)
+	}
+	key := strings.TrimSpace(step.Watermark.ImageObjectKey)
+	if !domain.ValidOverlayKey(key, p.overlayPrefix) {
+		return nil, fmt.Errorf("%w: %q is not under %q", ErrOverlayKey, key, p.overlayPrefix)
+	}
+	overlay, err := p.overlayFetcher.Fetch(ctx, Request{
+		JobID:      req.JobID,
+		SourceType: SourceTypeS3Presigned,
+		ObjectKey:  key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkHeader(overlay); err != nil {
+		return nil, err
+	}
+	return overlay, nil
 }
 
-type LocalFileFetcher struct{}
+// decodedBytesPerPixel is the RGBA footprint assumed for decode size checks.
+const decodedBytesPerPixel = 4
 
-func (LocalFileFetcher) Fetch(ctx context.Context, req Request) ([]byte, error) {
+// checkHeader reads only the image header and checks its format against the
+// allowlist and its dimensions against the pixel and decode limits.
+func (p *Processor) checkHeader(data []byte) error {
+	format, width, height, err := imageHeader(data)
+	if err := p.checkInputFormat(format, err, data); err != nil {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+	pixels := int64(width) * int64(height)
+	if p.maxPixels > 0 && pixels > p.maxPixels {
+		return fmt.Errorf("%w: %dx%d > %d pixels", ErrTooManyPixels, width, height, p.maxPixels)
+	}
+	// Compare in pixels so huge declared dimensions can't overflow.
+	if p.maxDecodeBytes > 0 && pixels > p.maxDecodeBytes/decodedBytesPerPixel {
+		return fmt.Errorf("%w: %dx%d needs %d MiB decoded > %d MiB", ErrDecompressionBomb, width, height, pixels*decodedBytesPerPixel>>20, p.maxDecodeBytes>>20)
+	}
+	return nil
+}
+
+func stdImageHeader(data []byte) (string, int, int, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return format, cfg.Width, cfg.Height, nil
+}
+
+func IsPoisonInput(err error) bool {
+	return errors.Is(err, ErrInputTooLarge) || errors.Is(err, ErrTooManyPixels) || errors.Is(err, ErrDecompressionBomb) || errors.Is(err, ErrEmptyImage) || errors.Is(err, ErrInputFormat) || errors.Is(err, ErrUnsupportedOption) || errors.Is(err, ErrOverlayKey) ||
+		errors.Is(err, httpsource.ErrBlockedAddress) || errors.Is(err, httpsource.ErrContentType) || errors.Is(err, httpsource.ErrInvalidURL) ||
+		errors.Is(err, video.ErrUnavailable) || errors.Is(err, video.ErrInvalidVideo) || errors.Is(err, video.ErrTooLong) || errors.Is(err, video.ErrFrameOutOfRange)
+}
+
+type LocalFileFetcher struct {
+	MaxBytes int64
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// EmitManifest writes manifest.json into the job's output directory,
+// replacing any earlier manifest regardless of Conflict.
+func (e LocalFileEmitter) EmitManifest(_ context.Context, req Request, data []byte) (string, error) {
+	if strings.TrimSpace(e.OutputDir) == "" {
+		return "", errors.New("output directory is required")
+	}
+	jobDir, err := e.jobDir(req)
+	if err != nil {
+		return "", err
+	}
+	fullPath := filepath.Join(jobDir, manifestFilename)
+	if err := writeFileAtomic(fullPath, data, 0o644); err != nil {
+		return "", fmt.Errorf("write manifest file: %w", err)
+	}
+	return fullPath, nil
+}
+
+// jobDir creates and returns the directory a job's outputs are written to.
+func (e LocalFileEmitter) jobDir(req Request) (string, error) {
+	dir := domain.SanitizePathToken(req.JobID)
+	if req.OutputSubdir != "" {
+		subdir, err := domain.SanitizeSubdir(req.OutputSubdir)
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.FromSlash(subdir)
+	}
+	jobDir := filepath.Join(e.OutputDir, dir)
+	if err := os.MkdirAll(jobDir, 0o755); err != nil {
+		return "", fmt.Errorf("create output dir: %w", err)
+	}
+	return jobDir, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place, so readers only ever see complete outputs.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l LocalFileFetcher) Fetch(ctx context.Context, req Request) ([]byte, error) {
 	if !strings.EqualFold(req.SourceType, SourceTypeLocalFile) {
 		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSourceType, req.SourceType)
 	}
@@ -132,15 +627,40 @@ func (LocalFileFetcher) Fetch(ctx context.Context, req Request) ([]byte, error)
 	default:
 	}
 
-	data, err := os.ReadFile(req.ObjectKey)
+	f, err := os.Open(req.ObjectKey)
 	if err != nil {
 		return nil, fmt.Errorf("read input file %s: %w", req.ObjectKey, err)
 	}
+	defer f.Close()
+
+	data, err := readLimited(f, l.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("read input file %s: %w", req.ObjectKey, err)
+	}
+	return data, nil
+}
+
+// readLimited buffers r, reading at most limit+1 bytes so an oversized
+// source fails with ErrInputTooLarge without being read to the end.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrInputTooLarge, limit)
+	}
 	return data, nil
 }
 
+// LocalFileEmitter writes outputs under OutputDir. Conflict is one of the
+// OutputConflict modes and applies when an output file already exists.
 type LocalFileEmitter struct {
 	OutputDir string
+	Conflict  string
 }
 
 func (e LocalFileEmitter) Emit(_ context.Context, req Request, step domain.PipelineStep, data []byte, format string, width, height int) (Output, error) {
@@ -151,18 +671,21 @@ func (e LocalFileEmitter) Emit(_ context.Context, req Request, step domain.Pipel
 		return Output{}, errors.New("pipeline step id is required")
 	}
 
-	jobDir := filepath.Join(e.OutputDir, sanitizePathToken(req.JobID))
-	if err := os.MkdirAll(jobDir, 0o755); err != nil {
-		return Output{}, fmt.Errorf("create output dir: %w", err)
+	jobDir, err := e.jobDir(req)
+	if err != nil {
+		return Output{}, err
 	}
 
-	filename := fmt.Sprintf("%s.%s", sanitizePathToken(step.ID), normalizeOutputFormat(format))
-	fullPath := filepath.Join(jobDir, filename)
-	if err := os.WriteFile(fullPath, data, 0o644); err != nil {
+	filename := fmt.Sprintf("%s.%s", domain.SanitizePathToken(step.ID), normalizeOutputFormat(format))
+	fullPath, err := resolveOutputTarget(e.Conflict, filepath.Join(jobDir, filename), data, fileExists)
+	if err != nil {
+		return Output{}, err
+	}
+	if err := writeFileAtomic(fullPath, data, 0o644); err != nil {
 		return Output{}, fmt.Errorf("write output file: %w", err)
 	}
 
-	return Output{
+	out := Output{
 		StepID:  step.ID,
 		Action:  step.Action,
 		Format:  normalizeOutputFormat(format),
@@ -171,30 +694,16 @@ func (e LocalFileEmitter) Emit(_ context.Context, req Request, step domain.Pipel
 		Width:   width,
 		Height:  height,
 		Success: true,
-	}, nil
-}
-
-func sanitizePathToken(in string) string {
-	in = strings.TrimSpace(in)
-	if in == "" {
-		return "unknown"
-	}
-
-	var b strings.Builder
-	b.Grow(len(in))
-	for _, r := range in {
-		switch {
-		case r >= 'a' && r <= 'z':
-			b.WriteRune(r)
-		case r >= 'A' && r <= 'Z':
-			b.WriteRune(r)
-		case r >= '0' && r <= '9':
-			b.WriteRune(r)
-		case r == '-' || r == '_':
-			b.WriteRune(r)
-		default:
-			b.WriteRune('_')
+	}
+	if req.EmitSidecar {
+		meta, err := encodeSidecar(out)
+		if err != nil {
+			return Output{}, err
+		}
+		out.SidecarPath = sidecarPath(fullPath)
+		if err := writeFileAtomic(out.SidecarPath, meta, 0o644); err != nil {
+			return Output{}, fmt.Errorf("write sidecar file: %w", err)
 		}
 	}
-	return b.String()
+	return out, nil
 }
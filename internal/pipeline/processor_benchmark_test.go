@@ -83,6 +83,36 @@ func BenchmarkProcessorWatermark(b *testing.B) {
 	}
 }
 
+// BenchmarkProcessorFanout compares serial and parallel execution of eight
+// independent resize variants of one source.
+func BenchmarkProcessorFanout(b *testing.B) {
+	source := benchmarkPNG(b, 1920, 1080)
+	steps := make([]domain.PipelineStep, 8)
+	for i := range steps {
+		steps[i] = domain.PipelineStep{ID: fmt.Sprintf("w%d", 160*(i+1)), Action: "resize", Width: 160 * (i + 1), Format: "jpeg", Quality: 82}
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			processor, err := NewLocalProcessor(b.TempDir(), WithStepConcurrency(concurrency))
+			if err != nil {
+				b.Fatalf("new local processor: %v", err)
+			}
+			processor.fetcher = staticFetcher{data: source}
+			processor.emitter = discardEmitter{}
+			req := Request{JobID: "bench-fanout", SourceType: SourceTypeLocalFile, ObjectKey: "ignored.png", Pipeline: steps}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := processor.Process(context.Background(), req); err != nil {
+					b.Fatalf("process: %v", err)
+				}
+			}
+		})
+	}
+}
+
 type staticFetcher struct {
 	data []byte
 }
@@ -3,14 +3,30 @@ package pipeline
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
+	"image/draw"
+	"image/gif"
 	"image/png"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/storage"
+	"github.com/dunamismax/pixelflow/internal/video"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestLocalProcessor_FileInTransformFileOut(t *testing.T) {
@@ -82,6 +98,483 @@ func TestLocalProcessor_FileInTransformFileOut(t *testing.T) {
 	}
 }
 
+func TestLocalProcessor_WritesUnderOutputSubdir(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 240, 120), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	request := Request{
+		JobID:        "job-subdir",
+		SourceType:   SourceTypeLocalFile,
+		ObjectKey:    inputPath,
+		OutputSubdir: "batch 7/./thumbs",
+		Pipeline:     []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 60, Format: "png"}},
+	}
+	result, err := processor.Process(context.Background(), request)
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+	if want := filepath.Join(tmp, "out", "batch_7", "thumbs", "thumb.png"); result.Outputs[0].Path != want {
+		t.Fatalf("expected output at %s, got %s", want, result.Outputs[0].Path)
+	}
+
+	request.OutputSubdir = "../escape"
+	if _, err := processor.Process(context.Background(), request); err == nil {
+		t.Fatal("expected traversal in output_subdir to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "escape")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside the output dir, stat err=%v", err)
+	}
+}
+
+func TestLocalProcessor_OutputConflictModes(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 240, 120), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	for _, mode := range []string{OutputConflictOverwrite, OutputConflictError, OutputConflictSuffix} {
+		t.Run(mode, func(t *testing.T) {
+			outDir := filepath.Join(tmp, mode)
+			processor, err := NewLocalProcessor(outDir, WithOutputConflict(mode))
+			if err != nil {
+				t.Fatalf("new local processor: %v", err)
+			}
+			request := Request{
+				JobID:      "job-1",
+				SourceType: SourceTypeLocalFile,
+				ObjectKey:  inputPath,
+				Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 60, Format: "png"}},
+			}
+			first, err := processor.Process(context.Background(), request)
+			if err != nil {
+				t.Fatalf("first process: %v", err)
+			}
+			target := filepath.Join(outDir, "job-1", "thumb.png")
+			if first.Outputs[0].Path != target {
+				t.Fatalf("expected first output at %s, got %s", target, first.Outputs[0].Path)
+			}
+
+			second, err := processor.Process(context.Background(), request)
+			switch mode {
+			case OutputConflictOverwrite:
+				if err != nil || second.Outputs[0].Path != target {
+					t.Fatalf("expected overwrite of %s, got %+v err=%v", target, second.Outputs, err)
+				}
+			case OutputConflictError:
+				if !errors.Is(err, ErrOutputExists) {
+					t.Fatalf("expected ErrOutputExists, got %v", err)
+				}
+			case OutputConflictSuffix:
+				if err != nil {
+					t.Fatalf("second process: %v", err)
+				}
+				got := second.Outputs[0].Path
+				if got == target || !strings.HasPrefix(filepath.Base(got), "thumb-") || filepath.Ext(got) != ".png" {
+					t.Fatalf("expected a content-hash suffixed name, got %s", got)
+				}
+				if _, err := os.Stat(target); err != nil {
+					t.Fatalf("expected original output kept: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "thumb.png")
+	if err := writeFileAtomic(target, []byte("complete"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("stat output: %v", err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected 0644 output, got %v", info.Mode().Perm())
+	}
+
+	// A directory at the target makes the rename fail after the temp file is
+	// written; the temp file must still be removed.
+	blocked := filepath.Join(dir, "blocked.png")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0o755); err != nil {
+		t.Fatalf("create blocking dir: %v", err)
+	}
+	if err := writeFileAtomic(blocked, []byte("partial"), 0o644); err == nil {
+		t.Fatal("expected rename onto a non-empty directory to fail")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			t.Fatalf("temp file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestLocalProcessor_EmitsManifest(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 240, 120), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithOutputURLs(func(_ context.Context, path string) (string, error) {
+		return "https://cdn.example.com/" + filepath.Base(path), nil
+	}))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	result, err := processor.Process(context.Background(), Request{
+		JobID:        "job-manifest",
+		SourceType:   SourceTypeLocalFile,
+		ObjectKey:    inputPath,
+		EmitManifest: true,
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 60, Format: "png"},
+			{ID: "small", Action: "resize", Width: 30, Format: "jpeg"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+	want := filepath.Join(tmp, "out", "job-manifest", "manifest.json")
+	if result.ManifestPath != want {
+		t.Fatalf("expected manifest at %s, got %q", want, result.ManifestPath)
+	}
+
+	data, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var doc manifest
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if doc.JobID != "job-manifest" || len(doc.Outputs) != 2 {
+		t.Fatalf("unexpected manifest: %s", data)
+	}
+	for i, entry := range doc.Outputs {
+		out := result.Outputs[i]
+		if entry.StepID != out.StepID || entry.Path != out.Path || entry.Bytes != out.Bytes || entry.Width != out.Width || entry.Height != out.Height || entry.Format != out.Format {
+			t.Fatalf("manifest entry %d = %+v does not match output %+v", i, entry, out)
+		}
+		if entry.URL != "https://cdn.example.com/"+filepath.Base(out.Path) {
+			t.Fatalf("expected manifest entry %d to carry the output URL, got %q", i, entry.URL)
+		}
+	}
+}
+
+type fakeFrameExtractor struct {
+	frame []byte
+	at    time.Duration
+	input []byte
+}
+
+func (f *fakeFrameExtractor) ExtractFrame(_ context.Context, data []byte, at time.Duration) ([]byte, error) {
+	f.input, f.at = data, at
+	return f.frame, nil
+}
+
+func TestVideoFrameFetcher_FeedsFrameIntoPipeline(t *testing.T) {
+	tmp := t.TempDir()
+	extractor := &fakeFrameExtractor{frame: buildTestPNG(t, 320, 180)}
+	mp4 := []byte("\x00\x00\x00\x18ftypisom fake-mp4")
+	processor, err := NewObjectStoreProcessor(
+		VideoFrameFetcher{Fetcher: staticFetcher{data: mp4}, Extractor: extractor},
+		LocalFileEmitter{OutputDir: tmp},
+	)
+	if err != nil {
+		t.Fatalf("new processor: %v", err)
+	}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-video",
+		SourceType: SourceTypeVideo,
+		ObjectKey:  "uploads/job-video/source",
+		FrameAt:    1500 * time.Millisecond,
+		Pipeline:   []domain.PipelineStep{{ID: "poster", Action: "resize", Width: 160, Format: "png"}},
+	})
+	if err != nil {
+		t.Fatalf("process video: %v", err)
+	}
+	if !bytes.Equal(extractor.input, mp4) || extractor.at != 1500*time.Millisecond {
+		t.Fatalf("expected the fetched video and frame offset to reach the extractor, got %q at %s", extractor.input, extractor.at)
+	}
+	if out := result.Outputs[0]; out.Width != 160 || out.Height != 90 {
+		t.Fatalf("expected 160x90 poster, got %dx%d", out.Width, out.Height)
+	}
+
+	if _, err := processor.Process(context.Background(), Request{
+		JobID:      "job-image",
+		SourceType: SourceTypeS3Presigned,
+		Pipeline:   []domain.PipelineStep{{ID: "poster", Action: "resize", Width: 160}},
+	}); !errors.Is(err, ErrUnsupportedSourceType) {
+		t.Fatalf("expected non-video sources to be rejected, got %v", err)
+	}
+}
+
+func TestVideoFrameFetcher_RejectsPlaylists(t *testing.T) {
+	for name, data := range map[string]string{
+		"hls":    "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXTINF:1,\nhttp://169.254.169.254/latest/meta-data\n",
+		"concat": "ffconcat version 1.0\nfile /etc/passwd\n",
+	} {
+		extractor := &fakeFrameExtractor{frame: buildTestPNG(t, 8, 8)}
+		fetcher := VideoFrameFetcher{Fetcher: staticFetcher{data: []byte(data)}, Extractor: extractor}
+		_, err := fetcher.Fetch(context.Background(), Request{JobID: "job-" + name, SourceType: SourceTypeVideo})
+		if !errors.Is(err, video.ErrInvalidVideo) || !IsPoisonInput(err) {
+			t.Fatalf("%s: expected poison ErrInvalidVideo, got %v", name, err)
+		}
+		if extractor.input != nil {
+			t.Fatalf("%s: playlist reached the frame extractor", name)
+		}
+	}
+}
+
+func TestLocalProcessor_EmitsSidecarWhenRequested(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 240, 120), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:       "job-sidecar",
+		SourceType:  SourceTypeLocalFile,
+		ObjectKey:   inputPath,
+		EmitSidecar: true,
+		Pipeline: []domain.PipelineStep{
+			{ID: "thumb", Action: "resize", Width: 60, Format: "png"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+
+	out := result.Outputs[0]
+	if want := filepath.Join(tmp, "out", "job-sidecar", "thumb.json"); out.SidecarPath != want {
+		t.Fatalf("expected sidecar at %s, got %s", want, out.SidecarPath)
+	}
+
+	data, err := os.ReadFile(out.SidecarPath)
+	if err != nil {
+		t.Fatalf("read sidecar: %v", err)
+	}
+	var meta struct {
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		Format string `json:"format"`
+		Bytes  int    `json:"bytes"`
+		File   string `json:"file"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("decode sidecar: %v", err)
+	}
+	if meta.Width != 60 || meta.Height != 30 || meta.Format != "png" || meta.Bytes != out.Bytes || meta.File != "thumb.png" {
+		t.Fatalf("unexpected sidecar contents: %+v", meta)
+	}
+}
+
+func TestLocalProcessor_RejectsInputAboveMaxBytes(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	srcBytes := buildTestPNG(t, 240, 120)
+	if err := os.WriteFile(inputPath, srcBytes, 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithMaxInputBytes(int64(len(srcBytes)-1)))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-too-large",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 60}},
+	})
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Fatalf("expected ErrInputTooLarge, got %v", err)
+	}
+}
+
+func TestLocalProcessor_RejectsInputAboveMaxPixels(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 240, 120), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithMaxPixels(240*120-1))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-too-many-pixels",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 60}},
+	})
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("expected ErrTooManyPixels, got %v", err)
+	}
+	if !IsPoisonInput(err) {
+		t.Fatal("expected pixel limit error to be classified as poison input")
+	}
+}
+
+// bombPNG is a PNG signature and IHDR chunk declaring a huge RGBA image; it
+// is only ~33 bytes, so the raw-size limit can't catch it.
+func bombPNG(width, height uint32) []byte {
+	ihdr := make([]byte, 0, 17)
+	ihdr = append(ihdr, "IHDR"...)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, deflate, no filter, no interlace
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestLocalProcessor_RejectsDecompressionBomb(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "bomb.png")
+	bomb := bombPNG(100_000, 100_000)
+	if err := os.WriteFile(inputPath, bomb, 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithMaxInputBytes(1<<20), WithMaxDecodeBytes(512<<20))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-bomb",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 60}},
+	})
+	if !errors.Is(err, ErrDecompressionBomb) {
+		t.Fatalf("expected ErrDecompressionBomb for a %d-byte file, got %v", len(bomb), err)
+	}
+	if !IsPoisonInput(err) {
+		t.Fatal("expected decompression bomb to be classified as poison input")
+	}
+
+	// Larger headers are still rejected; the size comparison can't overflow.
+	if err := processor.checkHeader(bombPNG(1<<28, 1<<28)); !errors.Is(err, ErrDecompressionBomb) {
+		t.Fatalf("expected ErrDecompressionBomb for maximal dimensions, got %v", err)
+	}
+}
+
+func TestLocalProcessor_EnforcesInputFormatAllowlist(t *testing.T) {
+	tmp := t.TempDir()
+	var gifBuf bytes.Buffer
+	if err := gif.Encode(&gifBuf, image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	inputs := map[string][]byte{
+		"input.png": buildTestPNG(t, 8, 8),
+		"input.gif": gifBuf.Bytes(),
+		"input.pdf": []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<<>>\nendobj\n"),
+	}
+	for name, data := range inputs {
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	process := func(processor *Processor, name string) error {
+		_, err := processor.Process(context.Background(), Request{
+			JobID:      "job-format",
+			SourceType: SourceTypeLocalFile,
+			ObjectKey:  filepath.Join(tmp, name),
+			Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 4}},
+		})
+		return err
+	}
+
+	restricted, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithAllowedInputFormats([]string{"jpg", "PNG", "webp"}))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	if err := process(restricted, "input.png"); err != nil {
+		t.Fatalf("expected allowed png to process, got %v", err)
+	}
+	err = process(restricted, "input.gif")
+	if !errors.Is(err, ErrInputFormat) || !strings.Contains(err.Error(), "gif") || !IsPoisonInput(err) {
+		t.Fatalf("expected non-retryable ErrInputFormat naming gif, got %v", err)
+	}
+
+	defaults, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	if err := process(defaults, "input.gif"); err != nil {
+		t.Fatalf("expected gif to be allowed by default, got %v", err)
+	}
+	err = process(defaults, "input.pdf")
+	if !errors.Is(err, ErrInputFormat) || !strings.Contains(err.Error(), "application/pdf") {
+		t.Fatalf("expected ErrInputFormat naming application/pdf, got %v", err)
+	}
+
+	if got := UnsupportedInputFormats([]string{"jpeg", "svg"}); len(got) != 1 || got[0] != "svg" {
+		t.Fatalf("expected svg to be unsupported, got %v", got)
+	}
+}
+
+func TestSniffInputFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", "jpeg"},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "png"},
+		{"gif", "GIF89a\x01\x00\x01\x00", "gif"},
+		{"webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", "webp"},
+		{"tiff little endian", "II*\x00\x08\x00\x00\x00", "tiff"},
+		{"tiff big endian", "MM\x00*\x00\x00\x00\x08", "tiff"},
+		{"heic", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", "heif"},
+		{"avif", "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf", "avif"},
+		{"avif with mif1 major brand", "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00avifmiaf", "avif"},
+		{"quicktime", "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ", ""},
+		{"mp4", "\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2", ""},
+		{"pdf", "%PDF-1.7\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffInputFormat([]byte(tt.head)); got != tt.want {
+				t.Fatalf("SniffInputFormat(%q) = %q, want %q", tt.head, got, tt.want)
+			}
+		})
+	}
+	if got := NormalizeInputFormat("image/JPEG"); got != "jpeg" {
+		t.Fatalf("expected image/JPEG to normalize to jpeg, got %q", got)
+	}
+}
+
 func TestLocalProcessor_UnsupportedSourceType(t *testing.T) {
 	processor, err := NewLocalProcessor(t.TempDir())
 	if err != nil {
@@ -89,20 +582,973 @@ func TestLocalProcessor_UnsupportedSourceType(t *testing.T) {
 	}
 
 	_, err = processor.Process(context.Background(), Request{
-		JobID:      "job-unsupported",
-		SourceType: "s3_presigned",
-		ObjectKey:  "uploads/job/source",
+		JobID:      "job-unsupported",
+		SourceType: "s3_presigned",
+		ObjectKey:  "uploads/job/source",
+		Pipeline: []domain.PipelineStep{
+			{
+				ID:     "thumb_small",
+				Action: "resize",
+				Width:  120,
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected unsupported source_type error")
+	}
+}
+
+func TestLocalProcessor_ChainedWatermarksStackOnOneOutput(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+
+	srcBytes := buildTestPNG(t, 240, 120)
+	if err := os.WriteFile(inputPath, srcBytes, 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-chain-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{
+				ID:        "logo",
+				Action:    "watermark",
+				Format:    "png",
+				Watermark: &domain.Watermark{Text: "LOGO", Opacity: 1, Gravity: "southeast"},
+			},
+			{
+				ID:        "copyright",
+				Action:    "watermark",
+				Format:    "png",
+				Chain:     true,
+				Watermark: &domain.Watermark{Text: "(c) PixelFlow", Opacity: 1, Gravity: "northwest"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+	if len(result.Outputs) != 2 {
+		t.Fatalf("expected 2 outputs, got %d", len(result.Outputs))
+	}
+
+	src := decodePNG(t, srcBytes)
+	stacked := decodeFile(t, result.Outputs[1].Path)
+
+	topLeft := image.Rect(0, 0, 120, 40)
+	bottomRight := image.Rect(120, 80, 240, 120)
+	if !regionDiffers(src, stacked, topLeft) {
+		t.Fatal("expected copyright mark in the top-left of the chained output")
+	}
+	if !regionDiffers(src, stacked, bottomRight) {
+		t.Fatal("expected logo mark to survive in the bottom-right of the chained output")
+	}
+}
+
+func TestLocalProcessor_ChainOnFirstStepFails(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 64), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-chain-2",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "first", Action: "resize", Width: 32, Chain: true},
+		},
+	})
+	if !errors.Is(err, ErrChainWithoutPrevious) {
+		t.Fatalf("expected ErrChainWithoutPrevious, got %v", err)
+	}
+}
+
+func TestLocalProcessor_ImageWatermarkComposited(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+
+	srcBytes := buildTestPNG(t, 240, 120)
+	if err := os.WriteFile(inputPath, srcBytes, 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+	logo := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	var logoBuf bytes.Buffer
+	if err := png.Encode(&logoBuf, logo); err != nil {
+		t.Fatalf("encode logo: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithOverlayAssets(assetFetcher{"assets/logo.png": logoBuf.Bytes()}, "assets/"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-logo-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{
+				ID:     "branded",
+				Action: "watermark",
+				Format: "png",
+				Watermark: &domain.Watermark{
+					ImageObjectKey: "assets/logo.png",
+					Scale:          0.25,
+					Opacity:        1,
+					Gravity:        "northwest",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+
+	src := decodePNG(t, srcBytes)
+	branded := decodeFile(t, result.Outputs[0].Path)
+
+	if got := branded.At(12+30, 12+7); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("expected scaled logo pixel at top-left, got %#v", got)
+	}
+	if regionDiffers(src, branded, image.Rect(120, 60, 240, 120)) {
+		t.Fatal("expected bottom-right region to be untouched by northwest logo")
+	}
+}
+
+func TestImageWatermarkOnlyReadsAssets(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 40, 20), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+	watermarked := func(key string) Request {
+		return Request{
+			JobID:      "job-overlay",
+			SourceType: SourceTypeLocalFile,
+			ObjectKey:  inputPath,
+			Pipeline: []domain.PipelineStep{
+				{ID: "branded", Action: "watermark", Format: "png", Watermark: &domain.Watermark{ImageObjectKey: key, Opacity: 1}},
+			},
+		}
+	}
+
+	// Without an assets store the job's local-file fetcher must not be used.
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	if _, err := processor.Process(context.Background(), watermarked(inputPath)); !errors.Is(err, ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption without an assets store, got %v", err)
+	}
+
+	assets := assetFetcher{"assets/logo.png": buildTestPNG(t, 4, 4), "uploads/other/source": buildTestPNG(t, 4, 4)}
+	processor, err = NewLocalProcessor(filepath.Join(tmp, "out"), WithOverlayAssets(assets, "assets/"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	for _, key := range []string{inputPath, "uploads/other/source", "assets/../uploads/other/source", "assets/"} {
+		_, err := processor.Process(context.Background(), watermarked(key))
+		if !errors.Is(err, ErrOverlayKey) || !IsPoisonInput(err) {
+			t.Fatalf("key %q: expected poison ErrOverlayKey, got %v", key, err)
+		}
+	}
+	if _, err := processor.Process(context.Background(), watermarked("assets/logo.png")); err != nil {
+		t.Fatalf("process with asset overlay: %v", err)
+	}
+}
+
+// assetFetcher serves overlay objects by key, like an assets prefix in the
+// object store.
+type assetFetcher map[string][]byte
+
+func (f assetFetcher) Fetch(_ context.Context, req Request) ([]byte, error) {
+	data, ok := f[req.ObjectKey]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", req.ObjectKey)
+	}
+	return data, nil
+}
+
+func TestWatermarkTilesCoverBounds(t *testing.T) {
+	tiles := watermarkTiles(image.Rect(0, 0, 100, 50), 20, 10, 10)
+	want := []image.Point{{0, 0}, {30, 0}, {60, 0}, {90, 0}, {0, 20}, {30, 20}, {60, 20}, {90, 20}, {0, 40}, {30, 40}, {60, 40}, {90, 40}}
+	if fmt.Sprint(tiles) != fmt.Sprint(want) {
+		t.Fatalf("tiles = %v, want %v", tiles, want)
+	}
+}
+
+func TestRotateWatermarkTurnsClockwise(t *testing.T) {
+	bar := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	draw.Draw(bar, bar.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	upright := rotateWatermark(bar, 90)
+	if got := upright.Bounds().Size(); got != image.Pt(10, 40) {
+		t.Fatalf("90 degree bounds = %v, want (10,40)", got)
+	}
+	if got := upright.RGBAAt(5, 20); got.A != 255 {
+		t.Fatalf("expected the rotated bar to cover the centre, got %#v", got)
+	}
+
+	// Clockwise in image coordinates runs the bar from top-left to bottom-right.
+	diagonal := rotateWatermark(bar, 45)
+	size := diagonal.Bounds().Size()
+	if size.X != size.Y || size.X < 35 || size.X > 36 {
+		t.Fatalf("45 degree bounds = %v, want a ~36px square", size)
+	}
+	if got := diagonal.RGBAAt(8, 8); got.A == 0 {
+		t.Fatalf("expected ink near the top-left corner, got %#v", got)
+	}
+	if got := diagonal.RGBAAt(8, size.Y-8); got.A != 0 {
+		t.Fatalf("expected the bottom-left corner to stay transparent, got %#v", got)
+	}
+}
+
+func TestLocalProcessor_TiledWatermarksRepeat(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+
+	white := image.NewRGBA(image.Rect(0, 0, 240, 120))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, white); err != nil {
+		t.Fatalf("encode input: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+	logo := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	var logoBuf bytes.Buffer
+	if err := png.Encode(&logoBuf, logo); err != nil {
+		t.Fatalf("encode logo: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithOverlayAssets(assetFetcher{"assets/logo.png": logoBuf.Bytes()}, "assets/"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-tile",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "logos", Action: "watermark", Format: "png", Watermark: &domain.Watermark{ImageObjectKey: "assets/logo.png", Opacity: 1, Tile: true, Spacing: 20}},
+			{ID: "text", Action: "watermark", Format: "png", Watermark: &domain.Watermark{Text: "PF", Opacity: 1, Color: "#000000", Tile: true, Spacing: 10}},
+			{ID: "diagonal", Action: "watermark", Format: "png", Watermark: &domain.Watermark{Text: "PF", Opacity: 1, Color: "#000000", Tile: true, Rotation: -45}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+
+	logos := decodeFile(t, result.Outputs[0].Path)
+	red := color.RGBA{R: 255, A: 255}
+	for _, p := range []image.Point{{5, 5}, {35, 5}, {5, 35}, {125, 65}, {215, 95}} {
+		if got := logos.At(p.X, p.Y); got != red {
+			t.Fatalf("expected a logo tile at %v, got %#v", p, got)
+		}
+	}
+	if got := logos.At(15, 15); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Fatalf("expected spacing between tiles to stay untouched, got %#v", got)
+	}
+
+	text := decodeFile(t, result.Outputs[1].Path)
+	mark, err := textWatermarkMark(&domain.Watermark{Text: "PF"})
+	if err != nil {
+		t.Fatalf("render text mark: %v", err)
+	}
+	size := mark.Bounds().Size()
+	tiles := watermarkTiles(text.Bounds(), size.X, size.Y, 10)
+	inked := 0
+	for _, at := range tiles {
+		if regionHasDarkPixel(text, image.Rectangle{Min: at, Max: at.Add(size)}.Intersect(text.Bounds())) {
+			inked++
+		}
+	}
+	if inked != len(tiles) || inked < 10 {
+		t.Fatalf("expected text in every one of the %d tiles, found %d", len(tiles), inked)
+	}
+
+	diagonal := decodeFile(t, result.Outputs[2].Path)
+	rotated := rotateWatermark(mark, -45).Bounds().Size()
+	if rotated.X <= size.X || rotated.Y <= size.Y {
+		t.Fatalf("expected the rotated mark %v to outgrow %v", rotated, size)
+	}
+	for _, at := range watermarkTiles(diagonal.Bounds(), rotated.X, rotated.Y, defaultWatermarkSpacing) {
+		if !regionHasDarkPixel(diagonal, image.Rectangle{Min: at, Max: at.Add(rotated)}.Intersect(diagonal.Bounds())) {
+			t.Fatalf("expected rotated text in the tile at %v", at)
+		}
+	}
+}
+
+func regionHasDarkPixel(img image.Image, rect image.Rectangle) bool {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r < 0x4000 && g < 0x4000 && b < 0x4000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestLocalProcessor_WatermarkFontSizeAndColor(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+
+	light := image.NewRGBA(image.Rect(0, 0, 240, 120))
+	draw.Draw(light, light.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, light); err != nil {
+		t.Fatalf("encode input: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	watermarkHeight := func(fontSize int) (int, bool) {
+		t.Helper()
+
+		result, err := processor.Process(context.Background(), Request{
+			JobID:      fmt.Sprintf("job-font-%d", fontSize),
+			SourceType: SourceTypeLocalFile,
+			ObjectKey:  inputPath,
+			Pipeline: []domain.PipelineStep{
+				{
+					ID:     "dark",
+					Action: "watermark",
+					Format: "png",
+					Watermark: &domain.Watermark{
+						Text:     "PixelFlow",
+						Opacity:  1,
+						Gravity:  "center",
+						FontSize: fontSize,
+						Color:    "#000000",
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("process request: %v", err)
+		}
+
+		out := decodeFile(t, result.Outputs[0].Path)
+		minY, maxY, dark := out.Bounds().Max.Y, -1, false
+		for y := out.Bounds().Min.Y; y < out.Bounds().Max.Y; y++ {
+			for x := out.Bounds().Min.X; x < out.Bounds().Max.X; x++ {
+				r, g, b, _ := out.At(x, y).RGBA()
+				if r < 0x4000 && g < 0x4000 && b < 0x4000 {
+					dark = true
+					minY = min(minY, y)
+					maxY = max(maxY, y)
+				}
+			}
+		}
+		return maxY - minY + 1, dark
+	}
+
+	smallHeight, dark := watermarkHeight(0)
+	if !dark {
+		t.Fatal("expected dark watermark pixels on light background")
+	}
+	largeHeight, _ := watermarkHeight(40)
+	if largeHeight <= smallHeight*2 {
+		t.Fatalf("expected font_size=40 to render taller than default face, got %d vs %d", largeHeight, smallHeight)
+	}
+}
+
+func TestStdlibTransformer_RejectsGovipsOnlyJPEGOptions(t *testing.T) {
+	src := buildTestPNG(t, 32, 32)
+
+	for _, step := range []domain.PipelineStep{
+		{ID: "progressive", Action: "resize", Width: 16, Format: "jpeg", Progressive: true},
+		{ID: "full_chroma", Action: "resize", Width: 16, Format: "jpeg", Subsample: domain.JPEGSubsample444},
+	} {
+		if _, err := (stdlibTransformer{}).Transform(context.Background(), src, step, nil); err == nil {
+			t.Fatalf("expected step %s to require govips", step.ID)
+		}
+	}
+
+	baseline := domain.PipelineStep{ID: "baseline", Action: "resize", Width: 16, Format: "jpeg", Subsample: domain.JPEGSubsample420}
+	if _, err := (stdlibTransformer{}).Transform(context.Background(), src, baseline, nil); err != nil {
+		t.Fatalf("expected baseline 4:2:0 jpeg to encode, got %v", err)
+	}
+}
+
+func TestStdlibTransformer_PixelatesRegion(t *testing.T) {
+	src := buildTestPNG(t, 32, 32)
+	step := domain.PipelineStep{
+		ID:        "redacted",
+		Action:    "pixelate",
+		Format:    "png",
+		BlockSize: 8,
+		Region:    &domain.Region{X: 8, Y: 8, Width: 16, Height: 16},
+	}
+
+	transformed, err := (stdlibTransformer{}).Transform(context.Background(), src, step, nil)
+	if err != nil {
+		t.Fatalf("pixelate: %v", err)
+	}
+	data, width, height := transformed.Data, transformed.Width, transformed.Height
+	if width != 32 || height != 32 {
+		t.Fatalf("expected 32x32 output, got %dx%d", width, height)
+	}
+
+	out, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	orig, err := png.Decode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("decode source: %v", err)
+	}
+	if out.At(8, 8) != out.At(15, 15) {
+		t.Fatalf("expected block at (8,8) to be one colour, got %v and %v", out.At(8, 8), out.At(15, 15))
+	}
+	if out.At(8, 8) == out.At(16, 8) {
+		t.Fatal("expected neighbouring blocks to differ")
+	}
+	if out.At(0, 0) != orig.At(0, 0) || out.At(31, 31) != orig.At(31, 31) {
+		t.Fatal("expected pixels outside the region to be untouched")
+	}
+
+	step.Region = &domain.Region{X: 24, Y: 24, Width: 16, Height: 16}
+	if _, err := (stdlibTransformer{}).Transform(context.Background(), src, step, nil); err == nil {
+		t.Fatal("expected out-of-bounds region to fail")
+	}
+}
+
+func TestStdlibTransformer_RejectsTruncatedSource(t *testing.T) {
+	src := buildTestPNG(t, 64, 64)
+	step := domain.PipelineStep{ID: "thumb", Action: "resize", Width: 16}
+
+	for name, input := range map[string][]byte{
+		"truncated": src[:len(src)/2],
+		"empty":     nil,
+	} {
+		_, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+		if !errors.Is(err, ErrEmptyImage) {
+			t.Fatalf("%s: expected ErrEmptyImage, got %v", name, err)
+		}
+		if !IsPoisonInput(err) {
+			t.Fatalf("%s: expected empty image to be poison input", name)
+		}
+	}
+}
+
+func TestStdlibTransformer_ThumbnailFitsBoxWithoutUpscaling(t *testing.T) {
+	src := buildTestPNG(t, 400, 100)
+
+	tests := []struct {
+		name                string
+		maxWidth, maxHeight int
+		wantW, wantH        int
+	}{
+		{"width binds", 200, 200, 200, 50},
+		{"height binds", 1000, 20, 80, 20},
+		{"width only", 100, 0, 100, 25},
+		{"never upscales", 800, 800, 400, 100},
+	}
+	for _, tc := range tests {
+		step := domain.PipelineStep{ID: "thumb", Action: "thumbnail", Format: "png", MaxWidth: tc.maxWidth, MaxHeight: tc.maxHeight}
+		transformed, err := (stdlibTransformer{}).Transform(context.Background(), src, step, nil)
+		if err != nil {
+			t.Fatalf("%s: thumbnail: %v", tc.name, err)
+		}
+		w, h := transformed.Width, transformed.Height
+		if w != tc.wantW || h != tc.wantH {
+			t.Fatalf("%s: expected %dx%d, got %dx%d", tc.name, tc.wantW, tc.wantH, w, h)
+		}
+	}
+}
+
+func TestStdlibTransformer_AdjustMidGray(t *testing.T) {
+	gray := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(gray, gray.Bounds(), image.NewUniform(color.RGBA{R: 100, G: 100, B: 100, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		t.Fatalf("encode source: %v", err)
+	}
+
+	contrast := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name string
+		step domain.PipelineStep
+		want uint8
+	}{
+		{"identity", domain.PipelineStep{}, 100},
+		{"brighter", domain.PipelineStep{Brightness: 20}, 151},
+		{"darker clamps", domain.PipelineStep{Brightness: -100}, 0},
+		{"more contrast", domain.PipelineStep{Contrast: contrast(2)}, 72},
+		{"flat", domain.PipelineStep{Contrast: contrast(0)}, 128},
+	}
+	for _, tc := range tests {
+		tc.step.ID, tc.step.Action, tc.step.Format = "adjusted", "adjust", "png"
+		transformed, err := (stdlibTransformer{}).Transform(context.Background(), buf.Bytes(), tc.step, nil)
+		if err != nil {
+			t.Fatalf("%s: adjust: %v", tc.name, err)
+		}
+		data := transformed.Data
+		out, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decode output: %v", tc.name, err)
+		}
+		r, g, b, a := out.At(1, 1).RGBA()
+		if uint8(r>>8) != tc.want || uint8(g>>8) != tc.want || uint8(b>>8) != tc.want || a != 0xffff {
+			t.Fatalf("%s: expected gray %d, got %v", tc.name, tc.want, out.At(1, 1))
+		}
+	}
+}
+
+func TestStdlibTransformer_FlattensTransparency(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode transparent source: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		step domain.PipelineStep
+		want color.RGBA
+	}{
+		{"jpeg defaults to white", domain.PipelineStep{Action: "resize", Width: 8, Format: "jpeg"}, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"jpeg honors background", domain.PipelineStep{Action: "resize", Width: 8, Format: "jpeg", Background: "#000080"}, color.RGBA{B: 128, A: 255}},
+		{"flatten action", domain.PipelineStep{Action: "flatten", Format: "png", Background: "#ff0000"}, color.RGBA{R: 255, A: 255}},
+	}
+	for _, tc := range tests {
+		tc.step.ID = "flat"
+		transformed, err := (stdlibTransformer{}).Transform(context.Background(), buf.Bytes(), tc.step, nil)
+		if err != nil {
+			t.Fatalf("%s: transform: %v", tc.name, err)
+		}
+		data := transformed.Data
+		out, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decode output: %v", tc.name, err)
+		}
+		got := color.RGBAModel.Convert(out.At(4, 4)).(color.RGBA)
+		if absDiff(got.R, tc.want.R) > 4 || absDiff(got.G, tc.want.G) > 4 || absDiff(got.B, tc.want.B) > 4 || got.A != 255 {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestObjectStoreEmitter_WriteOptions(t *testing.T) {
+	for name, tc := range map[string]struct {
+		emitter ObjectStoreEmitter
+		step    domain.PipelineStep
+		want    storage.WriteOptions
+	}{
+		"defaults": {
+			want: storage.WriteOptions{ContentType: "image/webp"},
+		},
+		"configured": {
+			emitter: ObjectStoreEmitter{CacheControl: "public, max-age=3600", Disposition: "attachment"},
+			want: storage.WriteOptions{
+				ContentType:        "image/webp",
+				CacheControl:       "public, max-age=3600",
+				ContentDisposition: "attachment; filename=hero.webp",
+			},
+		},
+		"step filename": {
+			step: domain.PipelineStep{Filename: "Summer Hero.webp"},
+			want: storage.WriteOptions{
+				ContentType:        "image/webp",
+				ContentDisposition: `inline; filename="Summer Hero.webp"`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			step := tc.step
+			step.ID = "hero"
+			if got := tc.emitter.writeOptions(step, "outputs/job-1/hero.webp", "webp"); got != tc.want {
+				t.Fatalf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLocalProcessor_AnimatedGIFResizeKeepsFrames(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.gif")
+
+	pal := color.Palette{color.Black, color.White, color.RGBA{R: 255, A: 255}}
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i < 4; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 64, 32), pal)
+		draw.Draw(frame, image.Rect(i*16, 0, i*16+16, 32), image.NewUniform(pal[i%2+1]), image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-gif-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "small", Action: "resize", Width: 32},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+
+	output := result.Outputs[0]
+	if output.Format != "gif" || output.Width != 32 || output.Height != 16 {
+		t.Fatalf("expected 32x16 gif output, got %s %dx%d", output.Format, output.Width, output.Height)
+	}
+	data, err := os.ReadFile(output.Path)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	resized, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode output gif: %v", err)
+	}
+	if len(resized.Image) != len(anim.Image) {
+		t.Fatalf("expected %d frames, got %d", len(anim.Image), len(resized.Image))
+	}
+	if resized.Config.Width != 32 || resized.Config.Height != 16 {
+		t.Fatalf("expected 32x16 canvas, got %dx%d", resized.Config.Width, resized.Config.Height)
+	}
+}
+
+func TestLocalProcessor_AnimatedGIFFramesCountTowardDecodeLimit(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.gif")
+
+	// One 64x64 frame decodes to 16 KiB, well under the limit; 64 of them
+	// composed to RGBA need 1 MiB.
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for range 64 {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 64, 64), pal))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithMaxDecodeBytes(256<<10))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-gif-bomb",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   []domain.PipelineStep{{ID: "small", Action: "resize", Width: 32}},
+	})
+	if !errors.Is(err, ErrDecompressionBomb) || !IsPoisonInput(err) {
+		t.Fatalf("expected poison ErrDecompressionBomb for the composed frames, got %v", err)
+	}
+}
+
+type countingTransformer struct {
+	Transformer
+	// delay holds each transform open so parallel chains overlap.
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep, overlay []byte) (Transformed, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	time.Sleep(c.delay)
+	return c.Transformer.Transform(ctx, input, step, overlay)
+}
+
+func TestLocalProcessor_DedupsIdenticalSteps(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 32), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	for _, tc := range []struct {
+		dedup bool
+		want  int
+	}{
+		{dedup: true, want: 1},
+		{dedup: false, want: 2},
+	} {
+		processor, err := NewLocalProcessor(filepath.Join(tmp, fmt.Sprintf("out-%v", tc.dedup)), WithStepDedup(tc.dedup))
+		if err != nil {
+			t.Fatalf("new local processor: %v", err)
+		}
+		counter := &countingTransformer{Transformer: processor.transformer}
+		processor.transformer = counter
+
+		result, err := processor.Process(context.Background(), Request{
+			JobID:      "job-dedup-1",
+			SourceType: SourceTypeLocalFile,
+			ObjectKey:  inputPath,
+			Pipeline: []domain.PipelineStep{
+				{ID: "thumb", Action: "resize", Width: 32, Format: "png"},
+				{ID: "thumb-copy", Action: "resize", Width: 32, Format: "png"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("process request: %v", err)
+		}
+		if counter.calls != tc.want {
+			t.Fatalf("dedup=%v: expected %d transforms, got %d", tc.dedup, tc.want, counter.calls)
+		}
+		if len(result.Outputs) != 2 || result.Outputs[0].Path == result.Outputs[1].Path {
+			t.Fatalf("expected one output per step, got %+v", result.Outputs)
+		}
+		for _, output := range result.Outputs {
+			verifyImageWidth(t, output.Path, 32)
+		}
+	}
+}
+
+func TestLocalProcessor_DedupsIdenticalStepsAcrossParallelChains(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 32), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithStepDedup(true), WithStepConcurrency(3))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	counter := &countingTransformer{Transformer: processor.transformer, delay: 50 * time.Millisecond}
+	processor.transformer = counter
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-dedup-parallel",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "a", Action: "resize", Width: 32, Format: "png"},
+			{ID: "b", Action: "resize", Width: 32, Format: "png"},
+			{ID: "c", Action: "resize", Width: 32, Format: "png"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+	if counter.calls != 1 {
+		t.Fatalf("expected one transform shared by the parallel chains, got %d", counter.calls)
+	}
+	for _, output := range result.Outputs {
+		verifyImageWidth(t, output.Path, 32)
+	}
+}
+
+func TestLocalProcessor_ConcurrentStepsKeepOrder(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 32), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithStepConcurrency(3))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	steps := []domain.PipelineStep{
+		{ID: "w48", Action: "resize", Width: 48},
+		{ID: "w48-small", Action: "resize", Width: 12, Chain: true},
+		{ID: "w32", Action: "resize", Width: 32},
+		{ID: "w16", Action: "resize", Width: 16},
+		{ID: "w8", Action: "resize", Width: 8},
+	}
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-parallel-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   steps,
+	})
+	if err != nil {
+		t.Fatalf("process request: %v", err)
+	}
+	for i, output := range result.Outputs {
+		if output.StepID != steps[i].ID || output.Width != steps[i].Width {
+			t.Fatalf("output %d: expected %s at width %d, got %s at %d", i, steps[i].ID, steps[i].Width, output.StepID, output.Width)
+		}
+	}
+
+	steps[3] = domain.PipelineStep{ID: "broken", Action: "unknown"}
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-parallel-2",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   steps,
+	})
+	if !errors.Is(err, ErrInvalidStepAction) {
+		t.Fatalf("expected ErrInvalidStepAction from the failing chain, got %v", err)
+	}
+}
+
+func TestLocalProcessor_RecordsSpanPerStep(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 32), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithTracer(provider.Tracer("test")))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-spans-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
 		Pipeline: []domain.PipelineStep{
-			{
-				ID:     "thumb_small",
-				Action: "resize",
-				Width:  120,
-			},
+			{ID: "small", Action: "resize", Width: 32, Format: "png"},
+			{ID: "broken", Action: "rotate"},
 		},
 	})
-	if err == nil {
-		t.Fatal("expected unsupported source_type error")
+	if !errors.Is(err, ErrInvalidStepAction) {
+		t.Fatalf("expected ErrInvalidStepAction, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 step spans, got %d", len(spans))
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if spans[0].Name() != "pipeline.step" || attrs["step.id"].AsString() != "small" || attrs["step.format"].AsString() != "png" {
+		t.Fatalf("unexpected first span %s attrs=%v", spans[0].Name(), attrs)
+	}
+	if attrs["step.output_bytes"].AsInt64() <= 0 {
+		t.Fatalf("expected output bytes attribute, got %v", attrs["step.output_bytes"])
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) == 0 {
+		t.Fatalf("expected failed step span to record the error, got status=%v", spans[1].Status())
+	}
+}
+
+func TestLocalProcessor_ObservesStepDurations(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 64, 32), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	type observation struct {
+		action string
+		failed bool
+	}
+	var observed []observation
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"), WithStepObserver(func(action string, duration time.Duration, err error) {
+		if duration < 0 {
+			t.Errorf("negative duration for %s: %s", action, duration)
+		}
+		observed = append(observed, observation{action: action, failed: err != nil})
+	}))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+
+	_, err = processor.Process(context.Background(), Request{
+		JobID:      "job-observe-1",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "small", Action: " Resize ", Width: 32, Format: "png"},
+			{ID: "broken", Action: "rotate-by-user-supplied-garbage"},
+		},
+	})
+	if !errors.Is(err, ErrInvalidStepAction) {
+		t.Fatalf("expected ErrInvalidStepAction, got %v", err)
+	}
+
+	want := []observation{{action: "resize"}, {action: "unknown", failed: true}}
+	if fmt.Sprint(observed) != fmt.Sprint(want) {
+		t.Fatalf("observed %v, want %v", observed, want)
+	}
+}
+
+func decodePNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	return img
+}
+
+func decodeFile(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read image %s: %v", path, err)
+	}
+	return decodePNG(t, data)
+}
+
+func regionDiffers(a, b image.Image, rect image.Rectangle) bool {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			ar, ag, ab, _ := a.At(x, y).RGBA()
+			br, bg, bb, _ := b.At(x, y).RGBA()
+			if ar != br || ag != bg || ab != bb {
+				return true
+			}
+		}
 	}
+	return false
 }
 
 func buildTestPNG(t *testing.T, w, h int) []byte {
@@ -145,3 +1591,199 @@ func verifyImageWidth(t *testing.T, path string, want int) {
 		t.Fatalf("expected width %d, got %d", want, got)
 	}
 }
+
+func TestStdlibTransformer_AddsBorder(t *testing.T) {
+	input := buildTestPNG(t, 20, 10)
+	step := domain.PipelineStep{ID: "frame", Action: "border", BorderWidth: 3, Color: "#ff0000", Format: "png"}
+
+	transformed, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	data, width, height := transformed.Data, transformed.Width, transformed.Height
+	if width != 26 || height != 16 {
+		t.Fatalf("expected 26x16, got %dx%d", width, height)
+	}
+	out, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if got := color.RGBAModel.Convert(out.At(0, 0)).(color.RGBA); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("expected red margin, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(out.At(25, 15)).(color.RGBA); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("expected red margin at far corner, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(out.At(3, 3)).(color.RGBA); got == (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("expected source pixel inside the border, got margin colour")
+	}
+}
+
+func TestStdlibTransformer_RoundsCorners(t *testing.T) {
+	input := buildTestPNG(t, 40, 30)
+	step := domain.PipelineStep{ID: "avatar", Action: "rounded_corners", Radius: 10, Format: "png"}
+
+	transformed, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	data, width, height := transformed.Data, transformed.Width, transformed.Height
+	if width != 40 || height != 30 {
+		t.Fatalf("expected 40x30, got %dx%d", width, height)
+	}
+	out, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	for _, p := range []image.Point{{0, 0}, {39, 0}, {0, 29}, {39, 29}} {
+		if _, _, _, a := out.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Fatalf("expected transparent corner at %v, got alpha %d", p, a)
+		}
+	}
+	if _, _, _, a := out.At(20, 15).RGBA(); a != 0xffff {
+		t.Fatalf("expected opaque centre, got alpha %d", a)
+	}
+
+	step.Format = "jpeg"
+	if _, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil); !errors.Is(err, ErrInvalidStepAction) {
+		t.Fatalf("expected jpeg output without background to be rejected, got %v", err)
+	}
+	step.Background = "#ffffff"
+	if _, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil); err != nil {
+		t.Fatalf("expected jpeg output with background to flatten corners: %v", err)
+	}
+}
+
+func TestStdlibTransformer_NormalizesCMYK(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "cmyk.jpeg"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	src, _, err := image.Decode(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	if _, ok := src.(*image.CMYK); !ok {
+		t.Fatalf("expected CMYK fixture, got %T", src)
+	}
+
+	step := domain.PipelineStep{ID: "srgb", Action: "flatten", Format: "png", NormalizeSRGB: true}
+	transformed, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	data, format := transformed.Data, transformed.Format
+	if format != "png" {
+		t.Fatalf("expected png output, got %q", format)
+	}
+	out, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	b := src.Bounds()
+	for _, p := range []image.Point{b.Min, {b.Dx() / 2, b.Dy() / 2}, {b.Max.X - 1, b.Max.Y - 1}} {
+		want := color.RGBAModel.Convert(src.At(p.X, p.Y)).(color.RGBA)
+		got := color.RGBAModel.Convert(out.At(p.X-b.Min.X, p.Y-b.Min.Y)).(color.RGBA)
+		if absDiff(got.R, want.R) > 1 || absDiff(got.G, want.G) > 1 || absDiff(got.B, want.B) > 1 {
+			t.Fatalf("pixel %v: expected %v, got %v", p, want, got)
+		}
+	}
+}
+
+func TestLocalProcessor_TargetBytesSearchesQuality(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*7919) ^ uint8(i>>5)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode source: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	const target = 12 << 10
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-budget",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline: []domain.PipelineStep{
+			{ID: "cdn", Action: "resize", Width: 128, Format: "jpeg", TargetBytes: target},
+			{ID: "fixed", Action: "resize", Width: 128, Format: "jpeg", Quality: 95},
+		},
+	})
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	budget, fixed := result.Outputs[0], result.Outputs[1]
+	if budget.Bytes > target {
+		t.Fatalf("expected output at most %d bytes, got %d", target, budget.Bytes)
+	}
+	if fixed.Bytes <= target {
+		t.Fatalf("fixture too small to exercise the search: quality 95 is %d bytes", fixed.Bytes)
+	}
+	if budget.Quality < 1 || budget.Quality >= 95 {
+		t.Fatalf("expected searched quality in 1..94, got %d", budget.Quality)
+	}
+	if fixed.Quality != 95 {
+		t.Fatalf("expected fixed quality 95 reported, got %d", fixed.Quality)
+	}
+}
+
+// fixedQualityTransformer stands in for a custom backend that reports the
+// quality it encoded at.
+type fixedQualityTransformer struct {
+	Transformer
+	quality int
+}
+
+func (f fixedQualityTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep, overlay []byte) (Transformed, error) {
+	out, err := f.Transformer.Transform(ctx, input, step, overlay)
+	out.Quality = f.quality
+	return out, err
+}
+
+func TestLocalProcessor_ReportsCustomTransformerQuality(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	if err := os.WriteFile(inputPath, buildTestPNG(t, 32, 16), 0o644); err != nil {
+		t.Fatalf("write input image: %v", err)
+	}
+	processor, err := NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	processor.transformer = fixedQualityTransformer{Transformer: processor.transformer, quality: 42}
+
+	result, err := processor.Process(context.Background(), Request{
+		JobID:      "job-custom",
+		SourceType: SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 16, Format: "jpeg"}},
+	})
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if got := result.Outputs[0].Quality; got != 42 {
+		t.Fatalf("expected the custom transformer's quality 42, got %d", got)
+	}
+}
+
+func TestStdlibTransformer_RejectsTargetBytesWithoutQuality(t *testing.T) {
+	input := buildTestPNG(t, 16, 8)
+	for _, format := range []string{"webp", "png", "gif"} {
+		step := domain.PipelineStep{ID: "cdn", Action: "resize", Width: 8, Format: format, TargetBytes: 1 << 10}
+		if _, err := (stdlibTransformer{}).Transform(context.Background(), input, step, nil); !errors.Is(err, ErrUnsupportedOption) {
+			t.Fatalf("expected ErrUnsupportedOption for target_bytes with %s, got %v", format, err)
+		}
+	}
+}
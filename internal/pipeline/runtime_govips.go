@@ -8,6 +8,11 @@ import (
 	"github.com/davidbyttow/govips/v2/vips"
 )
 
+// BackendName identifies the transformer this build uses.
+func BackendName() string {
+	return "govips"
+}
+
 var (
 	startupOnce sync.Once
 	shutdownMu  sync.Mutex
@@ -39,6 +44,36 @@ func Shutdown() {
 	started = false
 }
 
-func newTransformer() (Transformer, error) {
-	return govipsTransformer{}, nil
+// SupportedInputFormats lists the source formats this build can decode.
+// libvips may load more (PDF, SVG, BMP), but they are not accepted as sources.
+func SupportedInputFormats() []string {
+	return []string{"jpeg", "png", "gif", "webp", "tiff", "heif", "avif"}
+}
+
+// imageHeader falls back to libvips for formats the standard library can't
+// parse (AVIF, HEIF, TIFF). libvips loads lazily, so only the header is read
+// here.
+func imageHeader(data []byte) (string, int, int, error) {
+	if format, width, height, err := stdImageHeader(data); err == nil {
+		return format, width, height, nil
+	}
+	img, err := vips.NewImageFromBuffer(data)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer img.Close()
+	return vipsFormatName(img.Format()), img.Width(), img.Height(), nil
+}
+
+// vipsFormatName names t like the image package would; govips reports AVIF
+// as "heif".
+func vipsFormatName(t vips.ImageType) string {
+	if t == vips.ImageTypeAVIF {
+		return "avif"
+	}
+	return vips.ImageTypes[t]
+}
+
+func newTransformer(maxDecodeBytes int64) (Transformer, error) {
+	return govipsTransformer{maxDecodeBytes: maxDecodeBytes}, nil
 }
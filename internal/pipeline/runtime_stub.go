@@ -2,12 +2,27 @@
 
 package pipeline
 
+// BackendName identifies the transformer this build uses. The stdlib
+// transformer cannot decode AVIF, HEIF, or TIFF sources.
+func BackendName() string {
+	return "stdlib"
+}
+
 func Startup() error {
 	return nil
 }
 
 func Shutdown() {}
 
-func newTransformer() (Transformer, error) {
-	return stdlibTransformer{}, nil
+// SupportedInputFormats lists the source formats this build can decode.
+func SupportedInputFormats() []string {
+	return []string{"jpeg", "png", "gif", "webp"}
+}
+
+func imageHeader(data []byte) (string, int, int, error) {
+	return stdImageHeader(data)
+}
+
+func newTransformer(maxDecodeBytes int64) (Transformer, error) {
+	return stdlibTransformer{maxDecodeBytes: maxDecodeBytes}, nil
 }
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+// SelfTest resizes a tiny in-memory image through this build's transformer
+// and returns the transformer's name, so a worker with a broken backend (for
+// example a govips build without libvips) fails at startup rather than on its
+// first job. Startup must have been called first.
+func SelfTest(ctx context.Context) (string, error) {
+	backend := BackendName()
+	transformer, err := newTransformer(0)
+	if err != nil {
+		return backend, fmt.Errorf("build %s transformer: %w", backend, err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 32), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return backend, fmt.Errorf("encode self-test image: %w", err)
+	}
+
+	step := domain.PipelineStep{ID: "self-test", Action: "resize", Width: 4, Format: "png"}
+	transformed, err := transformer.Transform(ctx, buf.Bytes(), step, nil)
+	if err != nil {
+		return backend, fmt.Errorf("%s transformer: %w", backend, err)
+	}
+	data, width, height := transformed.Data, transformed.Width, transformed.Height
+	if len(data) == 0 || width != 4 || height != 4 {
+		return backend, fmt.Errorf("%s transformer: resized 8x8 to %dx%d (%d bytes), want 4x4", backend, width, height, len(data))
+	}
+	return backend, nil
+}
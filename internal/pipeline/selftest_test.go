@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	backend, err := SelfTest(context.Background())
+	if err != nil {
+		t.Fatalf("self-test: %v", err)
+	}
+	if backend != BackendName() {
+		t.Fatalf("expected backend %q, got %q", BackendName(), backend)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SelfTest(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled self-test to fail, got %v", err)
+	}
+}
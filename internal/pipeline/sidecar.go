@@ -0,0 +1,38 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+type sidecar struct {
+	StepID string `json:"step_id"`
+	Action string `json:"action"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int    `json:"bytes"`
+	File   string `json:"file"`
+}
+
+func sidecarPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, path.Ext(outputPath)) + ".json"
+}
+
+func encodeSidecar(out Output) ([]byte, error) {
+	data, err := json.MarshalIndent(sidecar{
+		StepID: out.StepID,
+		Action: out.Action,
+		Format: out.Format,
+		Width:  out.Width,
+		Height: out.Height,
+		Bytes:  out.Bytes,
+		File:   path.Base(out.Path),
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode sidecar: %w", err)
+	}
+	return data, nil
+}
@@ -2,25 +2,253 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
 )
 
 type Transformer interface {
-	Transform(ctx context.Context, input []byte, step domain.PipelineStep) (data []byte, format string, width, height int, err error)
+	Transform(ctx context.Context, input []byte, step domain.PipelineStep, overlay []byte) (Transformed, error)
+}
+
+// Transformed is one step's encoded output. Quality is the encoder quality
+// the transformer used (the one a target_bytes search settled on), or 0 for
+// formats without one.
+type Transformed struct {
+	Data          []byte
+	Format        string
+	Width, Height int
+	Quality       int
+}
+
+// stepActions are the actions both transformers implement.
+var stepActions = map[string]bool{
+	"resize":          true,
+	"thumbnail":       true,
+	"watermark":       true,
+	"pixelate":        true,
+	"adjust":          true,
+	"flatten":         true,
+	"border":          true,
+	"rounded_corners": true,
+}
+
+// stepActionLabel normalizes a client-supplied action for use as a metric
+// label, so arbitrary strings cannot grow the label set.
+func stepActionLabel(action string) string {
+	action = strings.ToLower(strings.TrimSpace(action))
+	if !stepActions[action] {
+		return "unknown"
+	}
+	return action
+}
+
+// checkFrameBytes applies the decoded-size cap to all frames of an animation
+// at once; limit <= 0 disables it.
+func checkFrameBytes(frames, width, height int, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	pixels := int64(frames) * int64(width) * int64(height)
+	if pixels > limit/decodedBytesPerPixel {
+		return fmt.Errorf("%w: %d frames of %dx%d need %d MiB decoded > %d MiB", ErrDecompressionBomb, frames, width, height, pixels*decodedBytesPerPixel>>20, limit>>20)
+	}
+	return nil
 }
 
 func normalizeOutputFormat(format string) string {
 	switch format {
 	case "jpg":
 		return "jpeg"
-	case "jpeg", "png", "webp":
+	case "jpeg", "png", "webp", "gif":
 		return format
 	default:
 		return "png"
 	}
 }
 
+// decodeError wraps a source decode failure, classifying empty input and
+// truncated streams as ErrEmptyImage so they are not retried.
+func decodeError(input []byte, err error) error {
+	var pngErr png.FormatError
+	truncatedPNG := errors.As(err, &pngErr) && pngErr == "not enough pixel data"
+	if len(input) == 0 || truncatedPNG || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode source image: %w: %v", ErrEmptyImage, err)
+	}
+	return fmt.Errorf("decode source image: %w", err)
+}
+
+func checkDimensions(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("%w: decoded to %dx%d", ErrEmptyImage, width, height)
+	}
+	return nil
+}
+
+// pixelateArea resolves the rectangle a pixelate step applies to, relative to
+// a width x height image. A nil region covers the whole image.
+func pixelateArea(width, height, blockSize int, region *domain.Region) (image.Rectangle, error) {
+	if blockSize <= 1 {
+		return image.Rectangle{}, errors.New("pixelate action requires block_size > 1")
+	}
+	bounds := image.Rect(0, 0, width, height)
+	if region == nil {
+		return bounds, nil
+	}
+	area := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+	if region.Width <= 0 || region.Height <= 0 || !area.In(bounds) {
+		return image.Rectangle{}, fmt.Errorf("pixelate region %v lies outside image bounds %dx%d", area, width, height)
+	}
+	return area, nil
+}
+
+// defaultWatermarkSpacing is the gap between tiled watermarks when the step
+// sets no spacing.
+const defaultWatermarkSpacing = 48
+
+func watermarkSpacing(wm *domain.Watermark) int {
+	if wm.Spacing > 0 {
+		return wm.Spacing
+	}
+	return defaultWatermarkSpacing
+}
+
+// watermarkTiles returns the top-left corners of markW x markH watermarks
+// repeated across bounds, spacing pixels apart, starting at its top-left.
+func watermarkTiles(bounds image.Rectangle, markW, markH, spacing int) []image.Point {
+	if markW <= 0 || markH <= 0 {
+		return nil
+	}
+	var tiles []image.Point
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += markH + spacing {
+		for x := bounds.Min.X; x < bounds.Max.X; x += markW + spacing {
+			tiles = append(tiles, image.Pt(x, y))
+		}
+	}
+	return tiles
+}
+
+// adjustCoefficients returns a, b for out = a*in + b on 8-bit channels:
+// brightness is a percentage of full scale and contrast a multiplier around
+// mid-gray (nil means unchanged).
+func adjustCoefficients(step domain.PipelineStep) (a, b float64) {
+	a = 1
+	if step.Contrast != nil {
+		a = *step.Contrast
+	}
+	return a, 128*(1-a) + step.Brightness*255/100
+}
+
+// backgroundColor is the opaque colour transparent pixels are flattened onto,
+// white unless the step sets background.
+func backgroundColor(step domain.PipelineStep) (color.NRGBA, error) {
+	if strings.TrimSpace(step.Background) == "" {
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}, nil
+	}
+	c, err := domain.ParseHexColor(step.Background)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("background: %w", err)
+	}
+	c.A = 255
+	return c, nil
+}
+
+// borderColor is the opaque colour a border step fills its margin with.
+func borderColor(step domain.PipelineStep) (color.NRGBA, error) {
+	c, err := domain.ParseHexColor(step.Color)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("border color: %w", err)
+	}
+	c.A = 255
+	return c, nil
+}
+
+// checkRoundedCorners rejects rounding into a format without alpha unless the
+// step names a background to flatten the corners onto.
+func checkRoundedCorners(format string, step domain.PipelineStep) error {
+	if !strings.EqualFold(strings.TrimSpace(step.Action), "rounded_corners") {
+		return nil
+	}
+	if format == "jpeg" && strings.TrimSpace(step.Background) == "" {
+		return fmt.Errorf("%w: rounded_corners needs png, webp, or gif output, or a background for jpeg", ErrInvalidStepAction)
+	}
+	return nil
+}
+
+// checkTargetBytes rejects target_bytes for outputs the transformer cannot
+// tune, rather than encoding at a default quality and reporting success.
+func checkTargetBytes(format string, step domain.PipelineStep, tunable bool) error {
+	if step.TargetBytes > 0 && !tunable {
+		return fmt.Errorf("%w: target_bytes cannot tune %s output in this build", ErrUnsupportedOption, format)
+	}
+	return nil
+}
+
+// cornerRadius clamps the step's radius to half the shorter side.
+func cornerRadius(width, height int, step domain.PipelineStep) int {
+	return min(step.Radius, width/2, height/2)
+}
+
+// maxQualitySearches bounds the encodes searchQuality tries; seven halvings
+// cover the whole 1..100 range.
+const maxQualitySearches = 7
+
+// searchQuality binary-searches encoder quality for the largest output of at
+// most target bytes. When even the lowest quality tried overshoots, that
+// smallest encode is returned rather than failing the step.
+func searchQuality(target int, encode func(quality int) ([]byte, error)) ([]byte, int, error) {
+	var (
+		best, smallest   []byte
+		bestQ, smallestQ int
+	)
+	lo, hi := 1, 100
+	for i := 0; i < maxQualitySearches && lo <= hi; i++ {
+		q := (lo + hi) / 2
+		data, err := encode(q)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) <= target {
+			best, bestQ = data, q
+			lo = q + 1
+			continue
+		}
+		smallest, smallestQ = data, q
+		hi = q - 1
+	}
+	if best == nil {
+		return smallest, smallestQ, nil
+	}
+	return best, bestQ, nil
+}
+
+// thumbnailSize fits width x height inside the step's max_width/max_height
+// box (an unset side is unbounded), preserving aspect ratio and never
+// upscaling.
+func thumbnailSize(width, height int, step domain.PipelineStep) (int, int, error) {
+	if step.MaxWidth < 0 || step.MaxHeight < 0 || step.MaxWidth+step.MaxHeight == 0 {
+		return 0, 0, errors.New("thumbnail action requires max_width and/or max_height > 0")
+	}
+	scale := 1.0
+	if step.MaxWidth > 0 && width > step.MaxWidth {
+		scale = float64(step.MaxWidth) / float64(width)
+	}
+	if step.MaxHeight > 0 && height > step.MaxHeight {
+		scale = math.Min(scale, float64(step.MaxHeight)/float64(height))
+	}
+	if scale == 1 {
+		return width, height, nil
+	}
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale))), nil
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -3,48 +3,85 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"image/png"
 	"strings"
 
 	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/dunamismax/pixelflow/internal/domain"
 )
 
-type govipsTransformer struct{}
+type govipsTransformer struct {
+	// maxDecodeBytes caps all pages of an animated source; <= 0 disables it.
+	maxDecodeBytes int64
+}
 
-func (t govipsTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep) ([]byte, string, int, int, error) {
+func (t govipsTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep, overlay []byte) (Transformed, error) {
 	select {
 	case <-ctx.Done():
-		return nil, "", 0, 0, ctx.Err()
+		return Transformed{}, ctx.Err()
 	default:
 	}
 
-	img, err := vips.NewImageFromBuffer(input)
+	format := formatForStep(step.Format, input)
+	if err := checkRoundedCorners(format, step); err != nil {
+		return Transformed{}, err
+	}
+	if err := checkTargetBytes(format, step, format == "jpeg" || (format == "webp" && !step.Lossless)); err != nil {
+		return Transformed{}, err
+	}
+	img, err := loadGovipsImage(input, format, step, t.maxDecodeBytes)
 	if err != nil {
-		return nil, "", 0, 0, fmt.Errorf("decode source image: %w", err)
+		return Transformed{}, err
 	}
 	defer img.Close()
+	if err := checkDimensions(img.Width(), img.Height()); err != nil {
+		return Transformed{}, err
+	}
+
+	if step.NormalizeSRGB {
+		if err := normalizeGovipsSRGB(img); err != nil {
+			return Transformed{}, err
+		}
+	}
 
 	switch strings.ToLower(strings.TrimSpace(step.Action)) {
 	case "resize":
 		err = applyGovipsResize(img, step.Width)
+	case "thumbnail":
+		err = applyGovipsThumbnail(img, step)
 	case "watermark":
-		err = applyGovipsWatermark(img, step.Watermark)
+		err = applyGovipsWatermark(img, step.Watermark, overlay)
+	case "pixelate":
+		err = applyGovipsPixelate(img, step.BlockSize, step.Region)
+	case "adjust":
+		err = applyGovipsAdjust(img, step)
+	case "flatten":
+		err = applyGovipsFlatten(img, step)
+	case "border":
+		err = applyGovipsBorder(img, step)
+	case "rounded_corners":
+		err = applyGovipsRoundedCorners(img, step)
 	default:
-		return nil, "", 0, 0, fmt.Errorf("%w: %q", ErrInvalidStepAction, step.Action)
+		return Transformed{}, fmt.Errorf("%w: %q", ErrInvalidStepAction, step.Action)
 	}
 	if err != nil {
-		return nil, "", 0, 0, err
+		return Transformed{}, err
 	}
 
-	format := formatForStep(step.Format, input)
-	data, err := exportGovipsImage(img, format, step.Quality)
+	data, quality, err := exportGovipsImage(img, format, step)
 	if err != nil {
-		return nil, "", 0, 0, err
+		return Transformed{}, err
 	}
 
-	return data, format, img.Width(), img.Height(), nil
+	height := img.Height()
+	if img.Pages() > 1 {
+		height = img.PageHeight()
+	}
+	return Transformed{Data: data, Format: format, Width: img.Width(), Height: height, Quality: quality}, nil
 }
 
 func applyGovipsResize(img *vips.ImageRef, targetWidth int) error {
@@ -66,29 +103,184 @@ func applyGovipsResize(img *vips.ImageRef, targetWidth int) error {
 	return nil
 }
 
-func applyGovipsWatermark(img *vips.ImageRef, wm *domain.Watermark) error {
+// applyGovipsPixelate shrinks the target area by blockSize and scales it back
+// up with nearest-neighbour sampling so each block becomes a single colour.
+func applyGovipsPixelate(img *vips.ImageRef, blockSize int, region *domain.Region) error {
+	area, err := pixelateArea(img.Width(), img.Height(), blockSize, region)
+	if err != nil {
+		return err
+	}
+	if region == nil {
+		return govipsMosaic(img, blockSize)
+	}
+
+	patch, err := img.Copy()
+	if err != nil {
+		return fmt.Errorf("copy pixelate region: %w", err)
+	}
+	defer patch.Close()
+	if err := patch.ExtractArea(area.Min.X, area.Min.Y, area.Dx(), area.Dy()); err != nil {
+		return fmt.Errorf("extract pixelate region: %w", err)
+	}
+	if err := govipsMosaic(patch, blockSize); err != nil {
+		return err
+	}
+	if err := img.Insert(patch, area.Min.X, area.Min.Y, false, nil); err != nil {
+		return fmt.Errorf("insert pixelate region: %w", err)
+	}
+	return nil
+}
+
+func govipsMosaic(img *vips.ImageRef, blockSize int) error {
+	width, height := img.Width(), img.Height()
+	if err := img.Resize(1/float64(blockSize), vips.KernelLinear); err != nil {
+		return fmt.Errorf("downscale pixelate area: %w", err)
+	}
+	if err := img.ResizeWithVScale(float64(width)/float64(img.Width()), float64(height)/float64(img.Height()), vips.KernelNearest); err != nil {
+		return fmt.Errorf("upscale pixelate area: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsAdjust(img *vips.ImageRef, step domain.PipelineStep) error {
+	a, b := adjustCoefficients(step)
+	bands := img.Bands()
+	colorBands := bands
+	if img.HasAlpha() {
+		colorBands--
+	}
+	as := make([]float64, bands)
+	bs := make([]float64, bands)
+	for i := range as {
+		as[i] = 1
+		if i < colorBands {
+			as[i], bs[i] = a, b
+		}
+	}
+	if err := img.Linear(as, bs); err != nil {
+		return fmt.Errorf("adjust image: %w", err)
+	}
+	if err := img.Cast(vips.BandFormatUchar); err != nil {
+		return fmt.Errorf("adjust image: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsFlatten(img *vips.ImageRef, step domain.PipelineStep) error {
+	if !img.HasAlpha() {
+		return nil
+	}
+	bg, err := backgroundColor(step)
+	if err != nil {
+		return err
+	}
+	if err := img.Flatten(&vips.Color{R: bg.R, G: bg.G, B: bg.B}); err != nil {
+		return fmt.Errorf("flatten image: %w", err)
+	}
+	return nil
+}
+
+// normalizeGovipsSRGB converts through the embedded ICC profile when there is
+// one and otherwise reinterprets non-sRGB images (e.g. CMYK) as sRGB.
+func normalizeGovipsSRGB(img *vips.ImageRef) error {
+	if img.HasICCProfile() {
+		if err := img.TransformICCProfile(vips.SRGBIEC6196621ICCProfilePath); err != nil {
+			return fmt.Errorf("convert icc profile to srgb: %w", err)
+		}
+		return nil
+	}
+	if img.Interpretation() == vips.InterpretationSRGB {
+		return nil
+	}
+	if err := img.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return fmt.Errorf("convert to srgb: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsBorder(img *vips.ImageRef, step domain.PipelineStep) error {
+	if step.BorderWidth <= 0 {
+		return fmt.Errorf("border action requires border_width > 0")
+	}
+	c, err := borderColor(step)
+	if err != nil {
+		return err
+	}
+	b := step.BorderWidth
+	if err := img.EmbedBackground(b, b, img.Width()+2*b, img.Height()+2*b, &vips.Color{R: c.R, G: c.G, B: c.B}); err != nil {
+		return fmt.Errorf("add border: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsRoundedCorners(img *vips.ImageRef, step domain.PipelineStep) error {
+	if step.Radius <= 0 {
+		return fmt.Errorf("rounded_corners action requires radius > 0")
+	}
+	w, h := img.Width(), img.Height()
+	r := cornerRadius(w, h, step)
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><rect x="0" y="0" width="%d" height="%d" rx="%d" ry="%d" fill="#fff"/></svg>`, w, h, w, h, r, r)
+	mask, err := vips.NewImageFromBuffer([]byte(svg))
+	if err != nil {
+		return fmt.Errorf("render corner mask: %w", err)
+	}
+	defer mask.Close()
+
+	if !img.HasAlpha() {
+		if err := img.AddAlpha(); err != nil {
+			return fmt.Errorf("add alpha channel: %w", err)
+		}
+	}
+	if err := img.Composite(mask, vips.BlendModeDestIn, 0, 0); err != nil {
+		return fmt.Errorf("mask corners: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsThumbnail(img *vips.ImageRef, step domain.PipelineStep) error {
+	width, height, err := thumbnailSize(img.Width(), img.Height(), step)
+	if err != nil {
+		return err
+	}
+	if width == img.Width() && height == img.Height() {
+		return nil
+	}
+	if err := img.ThumbnailWithSize(width, height, vips.InterestingNone, vips.SizeDown); err != nil {
+		return fmt.Errorf("thumbnail image: %w", err)
+	}
+	return nil
+}
+
+func applyGovipsWatermark(img *vips.ImageRef, wm *domain.Watermark, overlay []byte) error {
 	if wm == nil {
 		return fmt.Errorf("watermark action requires watermark settings")
 	}
+	if strings.TrimSpace(wm.ImageObjectKey) != "" {
+		return applyGovipsImageWatermark(img, wm, overlay)
+	}
 
 	text := strings.TrimSpace(wm.Text)
 	if text == "" {
-		return fmt.Errorf("watermark action requires watermark.text")
+		return fmt.Errorf("watermark action requires watermark.text or watermark.image_object_key")
+	}
+	if wm.Tile || wm.Rotation != 0 {
+		return layeredGovipsTextWatermark(img, wm)
 	}
 
-	opacity := wm.Opacity
-	if opacity <= 0 {
-		opacity = 0.65
+	textColor, err := watermarkColor(wm)
+	if err != nil {
+		return err
 	}
-	if opacity > 1 {
-		opacity = 1
+	fontSize := wm.FontSize
+	if fontSize <= 0 {
+		fontSize = 24
 	}
 
 	label := &vips.LabelParams{
 		Text:      text,
-		Font:      "sans 24",
-		Opacity:   float32(opacity),
-		Color:     vips.Color{R: 255, G: 255, B: 255},
+		Font:      fmt.Sprintf("sans %d", fontSize),
+		Opacity:   float32(watermarkOpacity(wm) * float64(textColor.A) / 255),
+		Color:     vips.Color{R: textColor.R, G: textColor.G, B: textColor.B},
 		Alignment: alignmentFromGravity(wm.Gravity),
 	}
 	label.Width.SetInt(max(1, img.Width()-24))
@@ -102,6 +294,111 @@ func applyGovipsWatermark(img *vips.ImageRef, wm *domain.Watermark) error {
 	return nil
 }
 
+func applyGovipsImageWatermark(img *vips.ImageRef, wm *domain.Watermark, overlay []byte) error {
+	if len(overlay) == 0 {
+		return fmt.Errorf("watermark image is empty")
+	}
+	mark, err := vips.NewImageFromBuffer(overlay)
+	if err != nil {
+		return fmt.Errorf("decode watermark image: %w", err)
+	}
+	defer mark.Close()
+
+	if wm.Scale > 0 && mark.Width() > 0 {
+		scale := float64(img.Width()) * wm.Scale / float64(mark.Width())
+		if err := mark.Resize(scale, vips.KernelLanczos3); err != nil {
+			return fmt.Errorf("resize watermark image: %w", err)
+		}
+	}
+	if !mark.HasAlpha() {
+		if err := mark.AddAlpha(); err != nil {
+			return fmt.Errorf("add watermark alpha: %w", err)
+		}
+	}
+
+	multipliers := make([]float64, mark.Bands())
+	offsets := make([]float64, mark.Bands())
+	for i := range multipliers {
+		multipliers[i] = 1
+	}
+	multipliers[len(multipliers)-1] = watermarkOpacity(wm)
+	if err := mark.Linear(multipliers, offsets); err != nil {
+		return fmt.Errorf("apply watermark opacity: %w", err)
+	}
+	return compositeGovipsWatermark(img, mark, wm)
+}
+
+// compositeGovipsWatermark rotates mark (which must have an alpha band) and
+// tiles it or places it at the watermark's gravity.
+func compositeGovipsWatermark(img, mark *vips.ImageRef, wm *domain.Watermark) error {
+	if wm.Rotation != 0 {
+		// Transparent fill for the corners the rotated bounding box exposes.
+		if err := mark.Similarity(1, wm.Rotation, &vips.ColorRGBA{}, 0, 0, 0, 0); err != nil {
+			return fmt.Errorf("rotate watermark: %w", err)
+		}
+	}
+	if wm.Tile {
+		return tileGovipsWatermark(img, mark, watermarkSpacing(wm))
+	}
+
+	bounds := image.Rect(0, 0, img.Width(), img.Height())
+	x, bottomY := watermarkPosition(bounds, mark.Width(), mark.Height(), mark.Height(), wm.Gravity)
+	if err := img.Composite(mark, vips.BlendModeOver, x, bottomY-mark.Height()); err != nil {
+		return fmt.Errorf("apply watermark: %w", err)
+	}
+	return nil
+}
+
+// layeredGovipsTextWatermark renders the text as a transparent layer (libvips'
+// label op only draws in place) so it can be rotated and tiled like an image
+// watermark.
+func layeredGovipsTextWatermark(img *vips.ImageRef, wm *domain.Watermark) error {
+	mark, err := govipsTextWatermarkMark(wm)
+	if err != nil {
+		return err
+	}
+	defer mark.Close()
+	return compositeGovipsWatermark(img, mark, wm)
+}
+
+func govipsTextWatermarkMark(wm *domain.Watermark) (*vips.ImageRef, error) {
+	layer, err := textWatermarkMark(wm)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, layer); err != nil {
+		return nil, fmt.Errorf("encode watermark layer: %w", err)
+	}
+	mark, err := vips.NewImageFromBuffer(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("load watermark layer: %w", err)
+	}
+	return mark, nil
+}
+
+// tileGovipsWatermark pads mark (which must have an alpha band) with
+// transparency to its tile size, replicates it over img, and composites the
+// result in one pass.
+func tileGovipsWatermark(img, mark *vips.ImageRef, spacing int) error {
+	tileW, tileH := mark.Width()+spacing, mark.Height()+spacing
+	if err := mark.Embed(0, 0, tileW, tileH, vips.ExtendBlack); err != nil {
+		return fmt.Errorf("pad watermark tile: %w", err)
+	}
+	across := (img.Width() + tileW - 1) / tileW
+	down := (img.Height() + tileH - 1) / tileH
+	if err := mark.Replicate(across, down); err != nil {
+		return fmt.Errorf("tile watermark: %w", err)
+	}
+	if err := mark.ExtractArea(0, 0, img.Width(), img.Height()); err != nil {
+		return fmt.Errorf("crop watermark tiles: %w", err)
+	}
+	if err := img.Composite(mark, vips.BlendModeOver, 0, 0); err != nil {
+		return fmt.Errorf("apply watermark: %w", err)
+	}
+	return nil
+}
+
 func alignmentFromGravity(gravity string) vips.Align {
 	gravity = strings.ToLower(strings.TrimSpace(gravity))
 	switch {
@@ -124,23 +421,97 @@ func formatForStep(stepFormat string, input []byte) string {
 		return "jpeg"
 	case vips.ImageTypeWEBP:
 		return "webp"
+	case vips.ImageTypeGIF:
+		return "gif"
 	default:
 		return "png"
 	}
 }
 
-func exportGovipsImage(img *vips.ImageRef, format string, quality int) ([]byte, error) {
+func isAnimatedFormat(format string) bool {
+	return format == "gif" || format == "webp"
+}
+
+// frameActions work on a multi-page image as a whole: libvips stacks the
+// frames vertically, so only per-pixel operations and resize (which rescales
+// the page height) treat every frame alike.
+var frameActions = map[string]bool{
+	"resize":  true,
+	"adjust":  true,
+	"flatten": true,
+}
+
+// loadGovipsImage loads the first frame of input, or every frame when the
+// source itself is animated and format can carry the animation. Steps that
+// cannot run on every frame fail with ErrUnsupportedOption instead of
+// silently dropping all but the first.
+func loadGovipsImage(input []byte, format string, step domain.PipelineStep, maxDecodeBytes int64) (*vips.ImageRef, error) {
+	img, err := vips.LoadImageFromBuffer(input, vips.NewImportParams())
+	if err != nil {
+		return nil, decodeError(input, err)
+	}
+	// n-pages reports the source's frame count even when only one is loaded.
+	if img.Pages() <= 1 || !isAnimatedFormat(format) {
+		return img, nil
+	}
+	pages, width, height := img.Pages(), img.Width(), img.Height()
+	img.Close()
+
+	action := strings.ToLower(strings.TrimSpace(step.Action))
+	if !frameActions[action] {
+		return nil, fmt.Errorf("%w: %s on an animated source needs a still output format such as png", ErrUnsupportedOption, action)
+	}
+	if err := checkFrameBytes(pages, width, height, maxDecodeBytes); err != nil {
+		return nil, err
+	}
+	params := vips.NewImportParams()
+	params.NumPages.Set(-1)
+	img, err = vips.LoadImageFromBuffer(input, params)
+	if err != nil {
+		return nil, decodeError(input, err)
+	}
+	return img, nil
+}
+
+func exportGovipsImage(img *vips.ImageRef, format string, step domain.PipelineStep) ([]byte, int, error) {
+	if step.Density > 0 && (format == "jpeg" || format == "png") {
+		// libvips stores resolution in pixels per millimetre.
+		res := float64(step.Density) / 25.4
+		dense, err := img.CopyChangingResolution(res, res)
+		if err != nil {
+			return nil, 0, fmt.Errorf("set density: %w", err)
+		}
+		defer dense.Close()
+		img = dense
+	}
+
+	quality := step.Quality
 	switch format {
 	case "jpeg":
-		params := vips.NewJpegExportParams()
-		if quality > 0 && quality <= 100 {
-			params.Quality = quality
+		if err := applyGovipsFlatten(img, step); err != nil {
+			return nil, 0, err
 		}
-		data, _, err := img.ExportJpeg(params)
-		if err != nil {
-			return nil, fmt.Errorf("encode jpeg: %w", err)
+		encode := func(quality int) ([]byte, error) {
+			params := vips.NewJpegExportParams()
+			if quality > 0 && quality <= 100 {
+				params.Quality = quality
+			}
+			params.Interlace = step.Progressive
+			params.SubsampleMode = vips.VipsForeignSubsampleOn
+			if strings.TrimSpace(step.Subsample) == domain.JPEGSubsample444 {
+				params.SubsampleMode = vips.VipsForeignSubsampleOff
+			}
+			data, _, err := img.ExportJpeg(params)
+			if err != nil {
+				return nil, fmt.Errorf("encode jpeg: %w", err)
+			}
+			return data, nil
 		}
-		return data, nil
+		if step.TargetBytes > 0 {
+			return searchQuality(step.TargetBytes, encode)
+		}
+		data, err := encode(quality)
+		return data, quality, err
 	case "png":
 		params := vips.NewPngExportParams()
 		if quality > 0 && quality <= 100 {
@@ -148,20 +519,34 @@ func exportGovipsImage(img *vips.ImageRef, format string, quality int) ([]byte,
 		}
 		data, _, err := img.ExportPng(params)
 		if err != nil {
-			return nil, fmt.Errorf("encode png: %w", err)
+			return nil, 0, fmt.Errorf("encode png: %w", err)
 		}
-		return data, nil
+		return data, quality, nil
 	case "webp":
-		params := vips.NewWebpExportParams()
-		if quality > 0 && quality <= 100 {
-			params.Quality = quality
+		encode := func(quality int) ([]byte, error) {
+			params := vips.NewWebpExportParams()
+			if quality > 0 && quality <= 100 {
+				params.Quality = quality
+			}
+			params.Lossless = step.Lossless
+			data, _, err := img.ExportWebp(params)
+			if err != nil {
+				return nil, fmt.Errorf("encode webp: %w", err)
+			}
+			return data, nil
+		}
+		if step.TargetBytes > 0 && !step.Lossless {
+			return searchQuality(step.TargetBytes, encode)
 		}
-		data, _, err := img.ExportWebp(params)
+		data, err := encode(quality)
+		return data, quality, err
+	case "gif":
+		data, _, err := img.ExportGIF(vips.NewGifExportParams())
 		if err != nil {
-			return nil, fmt.Errorf("encode webp: %w", err)
+			return nil, 0, fmt.Errorf("encode gif: %w", err)
 		}
-		return data, nil
+		return data, 0, nil
 	default:
-		return nil, fmt.Errorf("unsupported output format: %s", format)
+		return nil, 0, fmt.Errorf("unsupported output format: %s", format)
 	}
 }
@@ -8,46 +8,47 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"math"
 	"strings"
+	"sync"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
+	xdraw "golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
 	"golang.org/x/image/math/fixed"
 	_ "golang.org/x/image/webp"
 )
 
-type stdlibTransformer struct{}
+type stdlibTransformer struct {
+	// maxDecodeBytes caps the RGBA frames of an animated source, which the
+	// processor's header check only sized for one frame; <= 0 disables it.
+	maxDecodeBytes int64
+}
 
-func (t stdlibTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep) ([]byte, string, int, int, error) {
+func (t stdlibTransformer) Transform(ctx context.Context, input []byte, step domain.PipelineStep, overlay []byte) (Transformed, error) {
 	select {
 	case <-ctx.Done():
-		return nil, "", 0, 0, ctx.Err()
+		return Transformed{}, ctx.Err()
 	default:
 	}
 
 	src, srcFormat, err := image.Decode(bytes.NewReader(input))
 	if err != nil {
-		return nil, "", 0, 0, fmt.Errorf("decode source image: %w", err)
+		return Transformed{}, decodeError(input, err)
+	}
+	if err := checkDimensions(src.Bounds().Dx(), src.Bounds().Dy()); err != nil {
+		return Transformed{}, err
 	}
 
-	var out image.Image
-	switch strings.ToLower(strings.TrimSpace(step.Action)) {
-	case "resize":
-		out, err = resizeToWidth(src, step.Width)
-		if err != nil {
-			return nil, "", 0, 0, err
-		}
-	case "watermark":
-		out, err = watermarkText(src, step.Watermark)
-		if err != nil {
-			return nil, "", 0, 0, err
-		}
-	default:
-		return nil, "", 0, 0, fmt.Errorf("%w: %q", ErrInvalidStepAction, step.Action)
+	if step.NormalizeSRGB {
+		src = toSRGB(src)
 	}
 
 	format := normalizeOutputFormat(strings.ToLower(strings.TrimSpace(step.Format)))
@@ -55,13 +56,59 @@ func (t stdlibTransformer) Transform(ctx context.Context, input []byte, step dom
 		format = normalizeOutputFormat(strings.ToLower(srcFormat))
 	}
 
-	output, err := encodeImage(out, format, step.Quality)
+	if err := checkRoundedCorners(format, step); err != nil {
+		return Transformed{}, err
+	}
+	if err := checkTargetBytes(format, step, format == "jpeg"); err != nil {
+		return Transformed{}, err
+	}
+
+	if srcFormat == "gif" && format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(input))
+		if err != nil {
+			return Transformed{}, fmt.Errorf("decode source animation: %w", err)
+		}
+		if len(anim.Image) > 1 {
+			data, format, width, height, err := transformAnimatedGIF(anim, step, overlay, t.maxDecodeBytes)
+			return Transformed{Data: data, Format: format, Width: width, Height: height}, err
+		}
+	}
+
+	out, err := applyStep(src, step, overlay)
 	if err != nil {
-		return nil, "", 0, 0, err
+		return Transformed{}, err
+	}
+
+	output, quality, err := encodeImage(out, format, step)
+	if err != nil {
+		return Transformed{}, err
 	}
 
 	bounds := out.Bounds()
-	return output, format, bounds.Dx(), bounds.Dy(), nil
+	return Transformed{Data: output, Format: format, Width: bounds.Dx(), Height: bounds.Dy(), Quality: quality}, nil
+}
+
+func applyStep(src image.Image, step domain.PipelineStep, overlay []byte) (image.Image, error) {
+	switch strings.ToLower(strings.TrimSpace(step.Action)) {
+	case "resize":
+		return resizeToWidth(src, step.Width)
+	case "thumbnail":
+		return thumbnail(src, step)
+	case "watermark":
+		return watermark(src, step.Watermark, overlay)
+	case "pixelate":
+		return pixelate(src, step.BlockSize, step.Region)
+	case "adjust":
+		return adjust(src, step), nil
+	case "flatten":
+		return flatten(src, step)
+	case "border":
+		return border(src, step)
+	case "rounded_corners":
+		return roundedCorners(src, step)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStepAction, step.Action)
+	}
 }
 
 func resizeToWidth(src image.Image, width int) (image.Image, error) {
@@ -85,6 +132,28 @@ func resizeToWidth(src image.Image, width int) (image.Image, error) {
 	if height < 1 {
 		height = 1
 	}
+	return scaleImage(src, width, height), nil
+}
+
+func thumbnail(src image.Image, step domain.PipelineStep) (image.Image, error) {
+	srcBounds := src.Bounds()
+	if srcBounds.Dx() == 0 || srcBounds.Dy() == 0 {
+		return nil, errors.New("source image has invalid dimensions")
+	}
+	width, height, err := thumbnailSize(srcBounds.Dx(), srcBounds.Dy(), step)
+	if err != nil {
+		return nil, err
+	}
+	if width == srcBounds.Dx() && height == srcBounds.Dy() {
+		return cloneImage(src), nil
+	}
+	return scaleImage(src, width, height), nil
+}
+
+func scaleImage(src image.Image, width, height int) image.Image {
+	srcBounds := src.Bounds()
+	srcW := srcBounds.Dx()
+	srcH := srcBounds.Dy()
 
 	dst := image.NewRGBA(image.Rect(0, 0, width, height))
 	for y := 0; y < height; y++ {
@@ -95,18 +164,157 @@ func resizeToWidth(src image.Image, width int) (image.Image, error) {
 		}
 	}
 
+	return dst
+}
+
+func pixelate(src image.Image, blockSize int, region *domain.Region) (image.Image, error) {
+	bounds := src.Bounds()
+	area, err := pixelateArea(bounds.Dx(), bounds.Dy(), blockSize, region)
+	if err != nil {
+		return nil, err
+	}
+	area = area.Add(bounds.Min)
+
+	dst := cloneImage(src).(*image.RGBA)
+	for by := area.Min.Y; by < area.Max.Y; by += blockSize {
+		for bx := area.Min.X; bx < area.Max.X; bx += blockSize {
+			block := image.Rect(bx, by, bx+blockSize, by+blockSize).Intersect(area)
+
+			var r, g, b, a, n uint64
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					c := dst.RGBAAt(x, y)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			avg := color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+			draw.Draw(dst, block, image.NewUniform(avg), image.Point{}, draw.Src)
+		}
+	}
 	return dst, nil
 }
 
-func watermarkText(src image.Image, wm *domain.Watermark) (image.Image, error) {
+func adjust(src image.Image, step domain.PipelineStep) image.Image {
+	a, b := adjustCoefficients(step)
+	var lut [256]uint8
+	for i := range lut {
+		lut[i] = uint8(clamp(int(math.Round(a*float64(i)+b)), 0, 255))
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = lut[dst.Pix[i]]
+		dst.Pix[i+1] = lut[dst.Pix[i+1]]
+		dst.Pix[i+2] = lut[dst.Pix[i+2]]
+	}
+	return dst
+}
+
+func flatten(src image.Image, step domain.PipelineStep) (image.Image, error) {
+	bg, err := backgroundColor(step)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Over)
+	return dst, nil
+}
+
+func border(src image.Image, step domain.PipelineStep) (image.Image, error) {
+	if step.BorderWidth <= 0 {
+		return nil, errors.New("border action requires border_width > 0")
+	}
+	c, err := borderColor(step)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	b := step.BorderWidth
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*b, bounds.Dy()+2*b))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(b, b, b+bounds.Dx(), b+bounds.Dy()), src, bounds.Min, draw.Src)
+	return dst, nil
+}
+
+func roundedCorners(src image.Image, step domain.PipelineStep) (image.Image, error) {
+	if step.Radius <= 0 {
+		return nil, errors.New("rounded_corners action requires radius > 0")
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	r := cornerRadius(w, h, step)
+
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			mask.Pix[y*mask.Stride+x] = cornerCoverage(x, y, w, h, r)
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.DrawMask(dst, dst.Bounds(), src, bounds.Min, mask, image.Point{}, draw.Src)
+	return dst, nil
+}
+
+// cornerCoverage is the mask alpha for pixel (x, y): opaque outside the
+// corner squares and anti-aliased along each corner's arc.
+func cornerCoverage(x, y, w, h, r int) uint8 {
+	if r <= 0 {
+		return 255
+	}
+	cx, cy := -1.0, -1.0
+	switch {
+	case x < r:
+		cx = float64(r)
+	case x >= w-r:
+		cx = float64(w - r)
+	}
+	switch {
+	case y < r:
+		cy = float64(r)
+	case y >= h-r:
+		cy = float64(h - r)
+	}
+	if cx < 0 || cy < 0 {
+		return 255
+	}
+	d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+	coverage := math.Max(0, math.Min(1, float64(r)-d+0.5))
+	return uint8(math.Round(coverage * 255))
+}
+
+// toSRGB converts CMYK sources to RGBA. The stdlib path cannot apply
+// embedded ICC profiles, so other colour spaces pass through unchanged.
+func toSRGB(src image.Image) image.Image {
+	if _, ok := src.(*image.CMYK); !ok {
+		return src
+	}
+	return cloneImage(src)
+}
+
+func isOpaque(img image.Image) bool {
+	o, ok := img.(interface{ Opaque() bool })
+	return ok && o.Opaque()
+}
+
+func watermark(src image.Image, wm *domain.Watermark, overlay []byte) (image.Image, error) {
 	if wm == nil {
 		return nil, errors.New("watermark action requires watermark settings")
 	}
-	text := strings.TrimSpace(wm.Text)
-	if text == "" {
-		return nil, errors.New("watermark action requires watermark.text")
+	if strings.TrimSpace(wm.ImageObjectKey) != "" {
+		return watermarkImage(src, wm, overlay)
 	}
+	return watermarkText(src, wm)
+}
 
+func watermarkOpacity(wm *domain.Watermark) float64 {
 	opacity := wm.Opacity
 	if opacity <= 0 {
 		opacity = 0.65
@@ -114,11 +322,69 @@ func watermarkText(src image.Image, wm *domain.Watermark) (image.Image, error) {
 	if opacity > 1 {
 		opacity = 1
 	}
+	return opacity
+}
+
+func watermarkImage(src image.Image, wm *domain.Watermark, overlay []byte) (image.Image, error) {
+	if len(overlay) == 0 {
+		return nil, errors.New("watermark image is empty")
+	}
+	mark, _, err := image.Decode(bytes.NewReader(overlay))
+	if err != nil {
+		return nil, fmt.Errorf("decode watermark image: %w", err)
+	}
+	if wm.Scale > 0 {
+		width := max(1, int(math.Round(float64(src.Bounds().Dx())*wm.Scale)))
+		if mark, err = resizeToWidth(mark, width); err != nil {
+			return nil, err
+		}
+	}
+	if wm.Rotation != 0 {
+		mark = rotateWatermark(mark, wm.Rotation)
+	}
+
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	markW, markH := mark.Bounds().Dx(), mark.Bounds().Dy()
+	x, bottomY := watermarkPosition(dst.Bounds(), markW, markH, markH, wm.Gravity)
+	target := image.Rect(x, bottomY-markH, x+markW, bottomY)
+
+	alpha := uint8(math.Round(watermarkOpacity(wm) * 255))
+	mask := image.NewUniform(color.Alpha{A: alpha})
+	if wm.Tile {
+		for _, at := range watermarkTiles(dst.Bounds(), markW, markH, watermarkSpacing(wm)) {
+			draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(image.Pt(markW, markH))}, mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+		}
+		return dst, nil
+	}
+	draw.DrawMask(dst, target, mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst, nil
+}
+
+func watermarkText(src image.Image, wm *domain.Watermark) (image.Image, error) {
+	text := strings.TrimSpace(wm.Text)
+	if text == "" {
+		return nil, errors.New("watermark action requires watermark.text or watermark.image_object_key")
+	}
+	if wm.Tile || wm.Rotation != 0 {
+		return layeredTextWatermark(src, wm)
+	}
+	opacity := watermarkOpacity(wm)
+	textColor, err := watermarkColor(wm)
+	if err != nil {
+		return nil, err
+	}
+	face, err := watermarkFace(wm.FontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
 
 	dst := image.NewRGBA(src.Bounds())
 	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
 
-	face := basicfont.Face7x13
 	metrics := face.Metrics()
 	ascent := metrics.Ascent.Ceil()
 	height := metrics.Height.Ceil()
@@ -131,14 +397,134 @@ func watermarkText(src image.Image, wm *domain.Watermark) (image.Image, error) {
 
 	x, baselineY := watermarkPosition(dst.Bounds(), width, height, ascent, wm.Gravity)
 
-	alpha := uint8(math.Round(opacity * 255))
-	drawer.Src = image.NewUniform(color.RGBA{R: 255, G: 255, B: 255, A: alpha})
+	textColor.A = uint8(math.Round(opacity * float64(textColor.A)))
+	drawer.Src = image.NewUniform(textColor)
 	drawer.Dot = fixed.P(x, baselineY)
 	drawer.DrawString(text)
 
 	return dst, nil
 }
 
+// layeredTextWatermark composites text rendered by textWatermarkMark, which
+// tiled and rotated text need since font.Drawer can only draw it upright.
+func layeredTextWatermark(src image.Image, wm *domain.Watermark) (image.Image, error) {
+	mark, err := textWatermarkMark(wm)
+	if err != nil {
+		return nil, err
+	}
+	if wm.Rotation != 0 {
+		mark = rotateWatermark(mark, wm.Rotation)
+	}
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	size := mark.Bounds().Size()
+	if wm.Tile {
+		for _, at := range watermarkTiles(dst.Bounds(), size.X, size.Y, watermarkSpacing(wm)) {
+			draw.Draw(dst, image.Rectangle{Min: at, Max: at.Add(size)}, mark, image.Point{}, draw.Over)
+		}
+		return dst, nil
+	}
+	x, bottomY := watermarkPosition(dst.Bounds(), size.X, size.Y, size.Y, wm.Gravity)
+	draw.Draw(dst, image.Rect(x, bottomY-size.Y, x+size.X, bottomY), mark, image.Point{}, draw.Over)
+	return dst, nil
+}
+
+// rotateWatermark turns mark clockwise by degrees about its centre onto a
+// transparent canvas sized to the rotated bounding box.
+func rotateWatermark(mark image.Image, degrees float64) *image.RGBA {
+	bounds := mark.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	outW := max(1, int(math.Ceil(math.Abs(w*cos)+math.Abs(h*sin)-1e-9)))
+	outH := max(1, int(math.Ceil(math.Abs(w*sin)+math.Abs(h*cos)-1e-9)))
+	dst := image.NewRGBA(image.Rect(0, 0, outW, outH))
+
+	// Map the source centre onto the canvas centre.
+	cx := float64(bounds.Min.X) + w/2
+	cy := float64(bounds.Min.Y) + h/2
+	s2d := f64.Aff3{
+		cos, -sin, float64(outW)/2 - (cos*cx - sin*cy),
+		sin, cos, float64(outH)/2 - (sin*cx + cos*cy),
+	}
+	xdraw.BiLinear.Transform(dst, s2d, mark, bounds, xdraw.Over, nil)
+	return dst
+}
+
+// textWatermarkMark renders wm.Text in its colour and opacity onto a
+// transparent image just large enough to hold it, for watermarks that are
+// composited as a layer rather than drawn in place. The govips transformer
+// uses it too, so layered text renders with the embedded Go font in both
+// builds.
+func textWatermarkMark(wm *domain.Watermark) (*image.RGBA, error) {
+	text := strings.TrimSpace(wm.Text)
+	if text == "" {
+		return nil, errors.New("watermark action requires watermark.text or watermark.image_object_key")
+	}
+	textColor, err := watermarkColor(wm)
+	if err != nil {
+		return nil, err
+	}
+	face, err := watermarkFace(wm.FontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := max(1, font.MeasureString(face, text).Ceil())
+	height := max(1, metrics.Height.Ceil())
+	mark := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	textColor.A = uint8(math.Round(watermarkOpacity(wm) * float64(textColor.A)))
+	drawer := &font.Drawer{
+		Dst:  mark,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.P(0, metrics.Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+	return mark, nil
+}
+
+func watermarkColor(wm *domain.Watermark) (color.NRGBA, error) {
+	if strings.TrimSpace(wm.Color) == "" {
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}, nil
+	}
+	c, err := domain.ParseHexColor(wm.Color)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("watermark color: %w", err)
+	}
+	return c, nil
+}
+
+var (
+	watermarkFontOnce sync.Once
+	watermarkFont     *opentype.Font
+	watermarkFontErr  error
+)
+
+func watermarkFace(size int) (font.Face, error) {
+	if size <= 0 {
+		return basicfont.Face7x13, nil
+	}
+	watermarkFontOnce.Do(func() {
+		watermarkFont, watermarkFontErr = opentype.Parse(goregular.TTF)
+	})
+	if watermarkFontErr != nil {
+		return nil, fmt.Errorf("load watermark font: %w", watermarkFontErr)
+	}
+	face, err := opentype.NewFace(watermarkFont, &opentype.FaceOptions{
+		Size:    float64(size),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build watermark font face: %w", err)
+	}
+	return face, nil
+}
+
 func watermarkPosition(bounds image.Rectangle, textWidth, textHeight, ascent int, gravity string) (int, int) {
 	const pad = 12
 
@@ -178,29 +564,69 @@ func watermarkPosition(bounds image.Rectangle, textWidth, textHeight, ascent int
 	}
 }
 
-func encodeImage(img image.Image, format string, quality int) ([]byte, error) {
+func encodeImage(img image.Image, format string, step domain.PipelineStep) ([]byte, int, error) {
 	var buf bytes.Buffer
 
 	switch format {
 	case "jpeg":
+		if step.Progressive {
+			return nil, 0, fmt.Errorf("%w: progressive jpeg export requires govips build tag", ErrUnsupportedOption)
+		}
+		if strings.TrimSpace(step.Subsample) == domain.JPEGSubsample444 {
+			return nil, 0, fmt.Errorf("%w: 4:4:4 jpeg subsampling requires govips build tag", ErrUnsupportedOption)
+		}
+		if !isOpaque(img) {
+			flat, err := flatten(img, step)
+			if err != nil {
+				return nil, 0, err
+			}
+			img = flat
+		}
+		encode := func(quality int) ([]byte, error) {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, fmt.Errorf("encode jpeg: %w", err)
+			}
+			if step.Density > 0 {
+				return withJPEGDensity(buf.Bytes(), step.Density)
+			}
+			return buf.Bytes(), nil
+		}
+		if step.TargetBytes > 0 {
+			return searchQuality(step.TargetBytes, encode)
+		}
+		quality := step.Quality
 		if quality <= 0 || quality > 100 {
 			quality = 80
 		}
-		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
-			return nil, fmt.Errorf("encode jpeg: %w", err)
-		}
+		data, err := encode(quality)
+		return data, quality, err
 	case "png":
 		encoder := png.Encoder{CompressionLevel: png.DefaultCompression}
 		if err := encoder.Encode(&buf, img); err != nil {
-			return nil, fmt.Errorf("encode png: %w", err)
+			return nil, 0, fmt.Errorf("encode png: %w", err)
+		}
+		if step.Density > 0 {
+			data, err := withPNGDensity(buf.Bytes(), step.Density)
+			return data, 0, err
+		}
+	case "gif":
+		if err := gif.Encode(&buf, img, nil); err != nil {
+			return nil, 0, fmt.Errorf("encode gif: %w", err)
 		}
 	case "webp":
-		return nil, errors.New("webp export requires govips build tag")
+		// The in-tree encoder is lossless only, so a quality would be ignored.
+		if step.Quality > 0 {
+			return nil, 0, fmt.Errorf("%w: webp quality requires govips build tag (stdlib webp is lossless)", ErrUnsupportedOption)
+		}
+		if err := encodeWebPLossless(&buf, img); err != nil {
+			return nil, 0, fmt.Errorf("encode webp: %w", err)
+		}
 	default:
-		return nil, fmt.Errorf("unsupported output format: %s", format)
+		return nil, 0, fmt.Errorf("unsupported output format: %s", format)
 	}
 
-	return buf.Bytes(), nil
+	return buf.Bytes(), 0, nil
 }
 
 func cloneImage(src image.Image) image.Image {
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/video"
+)
+
+const SourceTypeVideo = domain.SourceTypeVideo
+
+// FrameExtractor decodes the frame at a video offset into an image the
+// transformer can read; *video.Extractor implements it.
+type FrameExtractor interface {
+	ExtractFrame(ctx context.Context, data []byte, at time.Duration) ([]byte, error)
+}
+
+// VideoFrameFetcher reads a video through Fetcher and hands the frame at
+// Request.FrameAt to the rest of the pipeline as the source image.
+type VideoFrameFetcher struct {
+	Fetcher   Fetcher
+	Extractor FrameExtractor
+}
+
+func (f VideoFrameFetcher) Fetch(ctx context.Context, req Request) ([]byte, error) {
+	if f.Fetcher == nil || f.Extractor == nil {
+		return nil, errors.New("video fetcher and frame extractor are required")
+	}
+	if !strings.EqualFold(req.SourceType, SourceTypeVideo) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSourceType, req.SourceType)
+	}
+
+	data, err := f.Fetcher.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// The upload was only sniffed by the API, if at all; refuse anything but
+	// a plain video container before ffmpeg sees it.
+	if _, err := video.Container(data); err != nil {
+		return nil, err
+	}
+	frame, err := f.Extractor.ExtractFrame(ctx, data, req.FrameAt)
+	if err != nil {
+		return nil, fmt.Errorf("extract frame at %s: %w", req.FrameAt, err)
+	}
+	return frame, nil
+}
@@ -0,0 +1,310 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+const (
+	vp8lSignature     = 0x2f
+	vp8lMaxDimension  = 1 << 14
+	vp8lMaxCodeLength = 15
+
+	vp8lNumLengthCodes   = 24
+	vp8lNumDistanceCodes = 40
+	vp8lCodeLengthCodes  = 19
+	vp8lMaxCLCodeLength  = 7
+)
+
+var vp8lCodeLengthCodeOrder = [vp8lCodeLengthCodes]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebPLossless writes img as a lossless (VP8L) WebP. It emits literal
+// pixels only, with no transforms, color cache, or backward references, so it
+// trades file size for a dependency-free encoder.
+func encodeWebPLossless(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return errors.New("webp: image has invalid dimensions")
+	}
+	if width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return fmt.Errorf("webp: image %dx%d exceeds %d pixel limit", width, height, vp8lMaxDimension)
+	}
+
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Rect.Min != (image.Point{}) {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	}
+
+	var histograms [4][256]int
+	alphaUsed := false
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			r, g, b, a := row[x], row[x+1], row[x+2], row[x+3]
+			histograms[0][g]++
+			histograms[1][r]++
+			histograms[2][b]++
+			histograms[3][a]++
+			if a != 0xff {
+				alphaUsed = true
+			}
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if alphaUsed {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // version
+	bw.writeBits(0, 1) // no transforms
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // no meta prefix codes
+
+	green := make([]int, 256+vp8lNumLengthCodes)
+	copy(green, histograms[0][:])
+	codes := [4]prefixCode{
+		buildPrefixCode(green),
+		buildPrefixCode(histograms[1][:]),
+		buildPrefixCode(histograms[2][:]),
+		buildPrefixCode(histograms[3][:]),
+	}
+	for _, code := range codes {
+		writePrefixCode(bw, code)
+	}
+	distance := make([]int, vp8lNumDistanceCodes)
+	distance[0] = 1
+	writePrefixCode(bw, buildPrefixCode(distance))
+
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			codes[0].write(bw, int(row[x+1]))
+			codes[1].write(bw, int(row[x]))
+			codes[2].write(bw, int(row[x+2]))
+			codes[3].write(bw, int(row[x+3]))
+		}
+	}
+	payload := bw.bytes()
+
+	var header [20]byte
+	chunkSize := len(payload)
+	padded := chunkSize + chunkSize&1
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+padded))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(chunkSize))
+
+	var buf bytes.Buffer
+	buf.Grow(len(header) + padded)
+	buf.Write(header[:])
+	buf.Write(payload)
+	if chunkSize&1 == 1 {
+		buf.WriteByte(0)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	trivial bool
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	if c.trivial {
+		return
+	}
+	bw.writeBits(c.codes[symbol], c.lengths[symbol])
+}
+
+// buildPrefixCode returns a canonical Huffman code limited to maxLength bits.
+// A code with a single used symbol is "trivial": VP8L decoders read zero bits
+// for it, so its one length is transmitted as 1 but never written per symbol.
+func buildPrefixCode(counts []int) prefixCode {
+	return buildLimitedPrefixCode(counts, vp8lMaxCodeLength)
+}
+
+func buildLimitedPrefixCode(counts []int, maxLength int) prefixCode {
+	used := 0
+	last := 0
+	for symbol, count := range counts {
+		if count > 0 {
+			used++
+			last = symbol
+		}
+	}
+	lengths := make([]int, len(counts))
+	if used <= 1 {
+		lengths[last] = 1
+		return prefixCode{lengths: lengths, codes: make([]uint32, len(counts)), trivial: true}
+	}
+
+	adjusted := append([]int(nil), counts...)
+	for minCount := 1; ; minCount *= 2 {
+		for symbol, count := range counts {
+			if count > 0 && count < minCount {
+				adjusted[symbol] = minCount
+			}
+		}
+		huffmanLengths(adjusted, lengths)
+		if maxOf(lengths) <= maxLength {
+			break
+		}
+	}
+	return prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+func huffmanLengths(counts []int, lengths []int) {
+	type node struct {
+		count       int
+		symbol      int
+		left, right int
+	}
+	nodes := make([]node, 0, 2*len(counts))
+	leaves := make([]int, 0, len(counts))
+	for symbol, count := range counts {
+		lengths[symbol] = 0
+		if count > 0 {
+			nodes = append(nodes, node{count: count, symbol: symbol, left: -1, right: -1})
+			leaves = append(leaves, len(nodes)-1)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool { return nodes[leaves[i]].count < nodes[leaves[j]].count })
+
+	var merged []int
+	pop := func() int {
+		if len(merged) == 0 || (len(leaves) > 0 && nodes[leaves[0]].count <= nodes[merged[0]].count) {
+			n := leaves[0]
+			leaves = leaves[1:]
+			return n
+		}
+		n := merged[0]
+		merged = merged[1:]
+		return n
+	}
+	for len(leaves)+len(merged) > 1 {
+		a, b := pop(), pop()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, symbol: -1, left: a, right: b})
+		merged = append(merged, len(nodes)-1)
+	}
+
+	var walk func(n, depth int)
+	walk = func(n, depth int) {
+		if nodes[n].symbol >= 0 {
+			lengths[nodes[n].symbol] = depth
+			return
+		}
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+	walk(len(nodes)-1, 0)
+}
+
+func canonicalCodes(lengths []int) []uint32 {
+	var counts [vp8lMaxCodeLength + 1]uint32
+	for _, l := range lengths {
+		counts[l]++
+	}
+	counts[0] = 0
+
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for l := 1; l <= vp8lMaxCodeLength; l++ {
+		code = (code + counts[l-1]) << 1
+		next[l] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[symbol] = reverseCode(next[l], l)
+		next[l]++
+	}
+	return codes
+}
+
+func reverseCode(code uint32, length int) uint32 {
+	var out uint32
+	for i := 0; i < length; i++ {
+		out = out<<1 | code&1
+		code >>= 1
+	}
+	return out
+}
+
+func writePrefixCode(bw *bitWriter, code prefixCode) {
+	bw.writeBits(0, 1) // normal (not simple) code
+
+	var clCounts [vp8lCodeLengthCodes]int
+	for _, l := range code.lengths {
+		clCounts[l]++
+	}
+	clCode := buildLimitedPrefixCode(clCounts[:], vp8lMaxCLCodeLength)
+
+	numCodes := 4
+	for i, symbol := range vp8lCodeLengthCodeOrder {
+		if clCode.lengths[symbol] > 0 && i+1 > numCodes {
+			numCodes = i + 1
+		}
+	}
+	bw.writeBits(uint32(numCodes-4), 4)
+	for _, symbol := range vp8lCodeLengthCodeOrder[:numCodes] {
+		bw.writeBits(uint32(clCode.lengths[symbol]), 3)
+	}
+
+	bw.writeBits(0, 1) // code lengths cover the whole alphabet
+	for _, l := range code.lengths {
+		clCode.write(bw, l)
+	}
+}
+
+func maxOf(values []int) int {
+	out := 0
+	for _, v := range values {
+		if v > out {
+			out = v
+		}
+	}
+	return out
+}
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (w *bitWriter) writeBits(value uint32, n int) {
+	w.acc |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
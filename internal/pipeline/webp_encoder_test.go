@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPLosslessRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{name: "single pixel", img: image.NewNRGBA(image.Rect(0, 0, 1, 1))},
+		{name: "solid opaque", img: fillNRGBA(17, 9, func(x, y int) color.NRGBA { return color.NRGBA{R: 10, G: 200, B: 30, A: 255} })},
+		{name: "gradient", img: fillNRGBA(240, 120, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8((x * 255) / 240), G: uint8((y * 255) / 120), B: 140, A: 255}
+		})},
+		{name: "translucent", img: fillNRGBA(33, 21, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 7), G: uint8(y * 11), B: 90, A: uint8(x * y)}
+		})},
+		{name: "noise", img: fillNRGBA(128, 96, func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(4)), A: 255}
+		})},
+		{name: "skewed histogram", img: fillNRGBA(300, 200, func(x, y int) color.NRGBA {
+			if (x+y*300)%4096 == 0 {
+				return color.NRGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+			}
+			return color.NRGBA{R: 1, G: 2, B: 3, A: 255}
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebPLossless(&buf, tt.img); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if decoded.Bounds() != tt.img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", decoded.Bounds(), tt.img.Bounds())
+			}
+			for y := 0; y < tt.img.Rect.Dy(); y++ {
+				for x := 0; x < tt.img.Rect.Dx(); x++ {
+					want := tt.img.NRGBAAt(x, y)
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want.A == 0 {
+						want, got = color.NRGBA{}, color.NRGBA{A: got.A}
+					}
+					if got != want {
+						t.Fatalf("pixel (%d,%d) = %#v, want %#v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuildPrefixCodeRespectsMaxLength(t *testing.T) {
+	counts := make([]int, 40)
+	for i := range counts {
+		counts[i] = 1 << min(i, 30)
+	}
+
+	code := buildPrefixCode(counts)
+	if got := maxOf(code.lengths); got > vp8lMaxCodeLength {
+		t.Fatalf("max code length = %d, want <= %d", got, vp8lMaxCodeLength)
+	}
+
+	kraft := 0.0
+	for _, l := range code.lengths {
+		if l > 0 {
+			kraft += 1 / float64(uint(1)<<l)
+		}
+	}
+	if kraft != 1 {
+		t.Fatalf("expected complete prefix code, kraft sum = %v", kraft)
+	}
+}
+
+func fillNRGBA(w, h int, at func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+func TestEncodeImageRejectsWebPQuality(t *testing.T) {
+	img := fillNRGBA(4, 4, func(x, y int) color.NRGBA { return color.NRGBA{R: 200, A: 255} })
+
+	if _, _, err := encodeImage(img, "webp", domain.PipelineStep{Format: "webp", Quality: 60}); !errors.Is(err, ErrUnsupportedOption) {
+		t.Fatalf("expected ErrUnsupportedOption for webp quality, got %v", err)
+	}
+	if !IsPoisonInput(ErrUnsupportedOption) {
+		t.Fatal("expected unsupported options to skip retries")
+	}
+	if _, _, err := encodeImage(img, "webp", domain.PipelineStep{Format: "webp"}); err != nil {
+		t.Fatalf("expected lossless webp without quality to encode, got %v", err)
+	}
+}
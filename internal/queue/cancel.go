@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+// CancelChannel is the Redis pub/sub channel carrying job ids to cancel.
+const CancelChannel = "pixelflow:jobs:cancel"
+
+// JobCancels broadcasts cancel requests for running jobs from the API to
+// every worker. Delivery is best-effort: pub/sub keeps no history, so a
+// worker that is disconnected or restarting when a message is published
+// never sees it.
+type JobCancels struct {
+	rdb redis.UniversalClient
+}
+
+func NewJobCancels(redisOpt asynq.RedisClientOpt) *JobCancels {
+	return &JobCancels{rdb: redis.NewClient(&redis.Options{
+		Addr:     redisOpt.Addr,
+		Username: redisOpt.Username,
+		Password: redisOpt.Password,
+		DB:       redisOpt.DB,
+	})}
+}
+
+// PublishCancel announces jobID and returns how many workers received it.
+func (c *JobCancels) PublishCancel(ctx context.Context, jobID string) (int64, error) {
+	return c.rdb.Publish(ctx, CancelChannel, jobID).Result()
+}
+
+// Subscribe calls fn with each announced job id until ctx is done. It returns
+// once the subscription is confirmed or fails, and keeps receiving in the
+// background; go-redis reconnects dropped subscriptions on its own.
+func (c *JobCancels) Subscribe(ctx context.Context, fn func(jobID string)) error {
+	sub := c.rdb.Subscribe(ctx, CancelChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if jobID := strings.TrimSpace(msg.Payload); jobID != "" {
+					fn(jobID)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *JobCancels) Close() error {
+	return c.rdb.Close()
+}
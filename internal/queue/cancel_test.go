@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestJobCancelsDeliversPublishedIDs(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cancels := NewJobCancels(asynq.RedisClientOpt{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = cancels.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 1)
+	if err := cancels.Subscribe(ctx, func(jobID string) { received <- jobID }); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	n, err := cancels.PublishCancel(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+	select {
+	case got := <-received:
+		if got != "job-1" {
+			t.Fatalf("expected job-1, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for cancel")
+	}
+}
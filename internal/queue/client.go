@@ -2,37 +2,175 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/hibiken/asynq"
 )
 
+const (
+	defaultMaxRetry = 5
+	defaultTimeout  = 3 * time.Minute
+)
+
+// ErrDuplicateTask reports that a task for the same job attempt is already
+// held by asynq, so nothing new was enqueued.
+var ErrDuplicateTask = errors.New("task already enqueued for job")
+
 type Client struct {
-	client *asynq.Client
-	queue  string
+	client    *asynq.Client
+	inspector *asynq.Inspector
+	queue     string
+	maxRetry  int
+	timeout   time.Duration
+}
+
+type ClientOption func(*Client)
+
+func WithMaxRetry(n int) ClientOption {
+	return func(c *Client) {
+		if n >= 0 {
+			c.maxRetry = n
+		}
+	}
+}
+
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
 }
 
-func NewClient(redisOpt asynq.RedisClientOpt, queueName string) *Client {
-	return &Client{
-		client: asynq.NewClient(redisOpt),
-		queue:  queueName,
+func NewClient(redisOpt asynq.RedisClientOpt, queueName string, opts ...ClientOption) *Client {
+	c := &Client{
+		client:    asynq.NewClient(redisOpt),
+		inspector: asynq.NewInspector(redisOpt),
+		queue:     queueName,
+		maxRetry:  defaultMaxRetry,
+		timeout:   defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-func (c *Client) EnqueueProcessImage(ctx context.Context, payload ProcessImagePayload) (*asynq.TaskInfo, error) {
+// ProcessImageTaskID derives the asynq task id for one attempt of a job, so a
+// job can only be enqueued once per retry_count.
+func ProcessImageTaskID(jobID string, retryCount int) string {
+	return fmt.Sprintf("%s:%s:%d", TypeProcessImage, jobID, retryCount)
+}
+
+// EnqueueProcessImage enqueues the job under its deterministic task id. If
+// that task already exists it returns the existing task's info (when it can
+// still be found) together with ErrDuplicateTask.
+func (c *Client) EnqueueProcessImage(ctx context.Context, queueName string, payload ProcessImagePayload) (*asynq.TaskInfo, error) {
 	task, err := NewProcessImageTask(payload)
 	if err != nil {
 		return nil, err
 	}
-	return c.client.EnqueueContext(
-		ctx,
-		task,
-		asynq.Queue(c.queue),
-		asynq.MaxRetry(5),
-		asynq.Timeout(3*time.Minute),
+	if queueName == "" {
+		queueName = c.queue
+	}
+
+	info, err := c.client.EnqueueContext(ctx, task, c.enqueueOptions(queueName, payload)...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		existing, _ := c.ProcessImageTaskInfo(ctx, queueName, payload.JobID, payload.RetryCount)
+		return existing, ErrDuplicateTask
+	}
+	return info, err
+}
+
+// DeliverWebhookTaskID derives the asynq task id for one event of a job
+// attempt, so a retried processing task does not enqueue the same callback
+// twice while the first is still held by asynq.
+func DeliverWebhookTaskID(jobID, event string, retryCount int) string {
+	return fmt.Sprintf("%s:%s:%s:%d", TypeDeliverWebhook, jobID, event, retryCount)
+}
+
+// EnqueueDeliverWebhook enqueues a webhook delivery with the client's
+// max_retry and timeout. A delivery already held by asynq is not an error.
+func (c *Client) EnqueueDeliverWebhook(ctx context.Context, queueName string, payload DeliverWebhookPayload) (*asynq.TaskInfo, error) {
+	task, err := NewDeliverWebhookTask(payload)
+	if err != nil {
+		return nil, err
+	}
+	if queueName == "" {
+		queueName = c.queue
+	}
+
+	info, err := c.client.EnqueueContext(ctx, task,
+		asynq.Queue(queueName),
+		asynq.TaskID(DeliverWebhookTaskID(payload.JobID, payload.Event, payload.RetryCount)),
+		asynq.MaxRetry(c.maxRetry),
+		asynq.Timeout(c.timeout),
 	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil, nil
+	}
+	return info, err
+}
+
+func (c *Client) ProcessImageTaskInfo(_ context.Context, queueName, jobID string, retryCount int) (*asynq.TaskInfo, error) {
+	if queueName == "" {
+		queueName = c.queue
+	}
+	return c.inspector.GetTaskInfo(queueName, ProcessImageTaskID(jobID, retryCount))
+}
+
+// DeleteProcessImageTask removes a job attempt's task that no worker has
+// picked up yet. A task that is already gone is not an error; one that is
+// running cannot be deleted and is left to the worker.
+func (c *Client) DeleteProcessImageTask(_ context.Context, queueName, jobID string, retryCount int) error {
+	if queueName == "" {
+		queueName = c.queue
+	}
+	err := c.inspector.DeleteTask(queueName, ProcessImageTaskID(jobID, retryCount))
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+	return err
+}
+
+// enqueueOptions applies per-job max_retry and timeout_seconds overrides on
+// top of the client defaults. A job deadline replaces the default timeout but
+// an explicit per-job timeout still applies alongside it.
+func (c *Client) enqueueOptions(queueName string, payload ProcessImagePayload) []asynq.Option {
+	maxRetry := c.maxRetry
+	if payload.MaxRetry != nil {
+		maxRetry = *payload.MaxRetry
+	}
+	opts := []asynq.Option{
+		asynq.Queue(queueName),
+		asynq.TaskID(ProcessImageTaskID(payload.JobID, payload.RetryCount)),
+		asynq.MaxRetry(maxRetry),
+	}
+
+	timeout := c.timeout
+	if payload.TimeoutSeconds > 0 {
+		timeout = time.Duration(payload.TimeoutSeconds) * time.Second
+	}
+	if payload.DeadlineAt.IsZero() {
+		return append(opts, asynq.Timeout(timeout))
+	}
+	opts = append(opts, asynq.Deadline(payload.DeadlineAt))
+	if payload.TimeoutSeconds > 0 {
+		opts = append(opts, asynq.Timeout(timeout))
+	}
+	return opts
+}
+
+func (c *Client) EnqueueDeadLetter(ctx context.Context, task *asynq.Task) (*asynq.TaskInfo, error) {
+	return c.client.EnqueueContext(ctx, asynq.NewTask(task.Type(), task.Payload()), asynq.Queue(c.queue), asynq.MaxRetry(0))
+}
+
+func (c *Client) Ping(_ context.Context) error {
+	return c.client.Ping()
 }
 
 func (c *Client) Close() error {
-	return c.client.Close()
+	return errors.Join(c.client.Close(), c.inspector.Close())
 }
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestEnqueueOptionsApplyPerJobOverrides(t *testing.T) {
+	c := &Client{queue: "default", maxRetry: defaultMaxRetry, timeout: defaultTimeout}
+	noRetry := 0
+	deadline := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		payload  ProcessImagePayload
+		retry    int
+		timeout  time.Duration
+		deadline bool
+	}{
+		{name: "defaults", payload: ProcessImagePayload{}, retry: defaultMaxRetry, timeout: defaultTimeout},
+		{name: "overrides", payload: ProcessImagePayload{MaxRetry: &noRetry, TimeoutSeconds: 900}, retry: 0, timeout: 15 * time.Minute},
+		{name: "deadline only", payload: ProcessImagePayload{DeadlineAt: deadline}, retry: defaultMaxRetry, deadline: true},
+		{name: "deadline with timeout", payload: ProcessImagePayload{DeadlineAt: deadline, TimeoutSeconds: 60}, retry: defaultMaxRetry, timeout: time.Minute, deadline: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				retry       = -1
+				timeout     time.Duration
+				hasDeadline bool
+			)
+			for _, opt := range c.enqueueOptions("default", tc.payload) {
+				switch opt.Type() {
+				case asynq.MaxRetryOpt:
+					retry = opt.Value().(int)
+				case asynq.TimeoutOpt:
+					timeout = opt.Value().(time.Duration)
+				case asynq.DeadlineOpt:
+					hasDeadline = true
+				}
+			}
+			if retry != tc.retry || timeout != tc.timeout || hasDeadline != tc.deadline {
+				t.Fatalf("got retry=%d timeout=%s deadline=%v, want retry=%d timeout=%s deadline=%v", retry, timeout, hasDeadline, tc.retry, tc.timeout, tc.deadline)
+			}
+		})
+	}
+}
+
+func TestEnqueueOptionsUseAttemptScopedTaskID(t *testing.T) {
+	c := &Client{queue: "default", maxRetry: defaultMaxRetry, timeout: defaultTimeout}
+
+	taskID := func(payload ProcessImagePayload) string {
+		for _, opt := range c.enqueueOptions("default", payload) {
+			if opt.Type() == asynq.TaskIDOpt {
+				return opt.Value().(string)
+			}
+		}
+		t.Fatal("expected a task id option")
+		return ""
+	}
+
+	first := taskID(ProcessImagePayload{JobID: "job-1"})
+	if again := taskID(ProcessImagePayload{JobID: "job-1", RequestedAt: time.Now()}); again != first {
+		t.Fatalf("expected repeat enqueue of the same attempt to reuse %q, got %q", first, again)
+	}
+	if retried := taskID(ProcessImagePayload{JobID: "job-1", RetryCount: 1}); retried == first {
+		t.Fatalf("expected a retried job to get a new task id, got %q", retried)
+	}
+}
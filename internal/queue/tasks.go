@@ -9,15 +9,31 @@ import (
 	"github.com/hibiken/asynq"
 )
 
-const TypeProcessImage = "image:process"
+const (
+	TypeProcessImage   = "image:process"
+	TypeDeliverWebhook = "webhook:deliver"
+)
 
 type ProcessImagePayload struct {
-	JobID       string                `json:"job_id"`
-	SourceType  string                `json:"source_type"`
-	WebhookURL  string                `json:"webhook_url,omitempty"`
-	ObjectKey   string                `json:"object_key"`
-	Pipeline    []domain.PipelineStep `json:"pipeline"`
-	RequestedAt time.Time             `json:"requested_at"`
+	JobID           string                `json:"job_id"`
+	SourceType      string                `json:"source_type"`
+	WebhookURL      string                `json:"webhook_url,omitempty"`
+	WebhookHeaders  map[string]string     `json:"webhook_headers,omitempty"`
+	ObjectKey       string                `json:"object_key"`
+	Pipeline        []domain.PipelineStep `json:"pipeline"`
+	RequestedAt     time.Time             `json:"requested_at"`
+	DeadlineSeconds int                   `json:"deadline_seconds,omitempty"`
+	DeadlineAt      time.Time             `json:"deadline_at,omitzero"`
+	MaxRetry        *int                  `json:"max_retry,omitempty"`
+	TimeoutSeconds  int                   `json:"timeout_seconds,omitempty"`
+	RetryCount      int                   `json:"retry_count,omitempty"`
+	EmitSidecar     bool                  `json:"emit_sidecar,omitempty"`
+	EmitManifest    bool                  `json:"emit_manifest,omitempty"`
+	DeleteSource    bool                  `json:"delete_source_on_success,omitempty"`
+	OutputSubdir    string                `json:"output_subdir,omitempty"`
+	FrameAtSeconds  float64               `json:"frame_at_seconds,omitempty"`
+	RequestID       string                `json:"request_id,omitempty"`
+	UserID          string                `json:"user_id,omitempty"`
 }
 
 func NewProcessImageTask(payload ProcessImagePayload) (*asynq.Task, error) {
@@ -35,3 +51,54 @@ func ParseProcessImagePayload(task *asynq.Task) (ProcessImagePayload, error) {
 	}
 	return payload, nil
 }
+
+// DeliverWebhookPayload carries one job event to the job's webhook so it can
+// be delivered and retried independently of image processing. Body is the
+// exact JSON document that is signed and POSTed.
+type DeliverWebhookPayload struct {
+	JobID      string            `json:"job_id"`
+	Event      string            `json:"event"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body"`
+	RetryCount int               `json:"retry_count,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+}
+
+func NewDeliverWebhookTask(payload DeliverWebhookPayload) (*asynq.Task, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook payload: %w", err)
+	}
+	return asynq.NewTask(TypeDeliverWebhook, body), nil
+}
+
+func ParseDeliverWebhookPayload(task *asynq.Task) (DeliverWebhookPayload, error) {
+	var payload DeliverWebhookPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return DeliverWebhookPayload{}, fmt.Errorf("unmarshal webhook payload: %w", err)
+	}
+	if payload.URL == "" || payload.Event == "" || len(payload.Body) == 0 {
+		return DeliverWebhookPayload{}, fmt.Errorf("webhook payload requires url, event, and body")
+	}
+	return payload, nil
+}
+
+func RecoverProcessImagePayload(task *asynq.Task) (ProcessImagePayload, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(task.Payload(), &fields); err != nil {
+		return ProcessImagePayload{}, false
+	}
+
+	var payload ProcessImagePayload
+	_ = json.Unmarshal(fields["job_id"], &payload.JobID)
+	_ = json.Unmarshal(fields["source_type"], &payload.SourceType)
+	_ = json.Unmarshal(fields["webhook_url"], &payload.WebhookURL)
+	_ = json.Unmarshal(fields["object_key"], &payload.ObjectKey)
+	_ = json.Unmarshal(fields["requested_at"], &payload.RequestedAt)
+	_ = json.Unmarshal(fields["request_id"], &payload.RequestID)
+	if payload.JobID == "" {
+		return ProcessImagePayload{}, false
+	}
+	return payload, true
+}
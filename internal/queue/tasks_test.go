@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/hibiken/asynq"
 )
 
 func TestProcessImageTaskRoundTrip(t *testing.T) {
@@ -38,3 +39,50 @@ func TestProcessImageTaskRoundTrip(t *testing.T) {
 		t.Fatalf("expected one pipeline step, got %d", len(parsed.Pipeline))
 	}
 }
+
+func TestRecoverProcessImagePayloadFromMalformedPipeline(t *testing.T) {
+	task := asynq.NewTask(TypeProcessImage, []byte(`{"job_id":"job-123","webhook_url":"http://hooks.local","pipeline":"resize"}`))
+
+	if _, err := ParseProcessImagePayload(task); err == nil {
+		t.Fatal("expected parse error for malformed pipeline")
+	}
+
+	recovered, ok := RecoverProcessImagePayload(task)
+	if !ok {
+		t.Fatal("expected job reference to be recovered")
+	}
+	if recovered.JobID != "job-123" || recovered.WebhookURL != "http://hooks.local" {
+		t.Fatalf("unexpected recovered payload: %+v", recovered)
+	}
+
+	if _, ok := RecoverProcessImagePayload(asynq.NewTask(TypeProcessImage, []byte(`{"job_id":`))); ok {
+		t.Fatal("expected truncated JSON to be unrecoverable")
+	}
+}
+
+func TestDeliverWebhookTaskRoundTrip(t *testing.T) {
+	task, err := NewDeliverWebhookTask(DeliverWebhookPayload{
+		JobID: "job-123",
+		Event: "job.completed",
+		URL:   "http://hooks.local",
+		Body:  []byte(`{"job_id":"job-123"}`),
+	})
+	if err != nil {
+		t.Fatalf("NewDeliverWebhookTask returned error: %v", err)
+	}
+	if task.Type() != TypeDeliverWebhook {
+		t.Fatalf("expected task type %q, got %q", TypeDeliverWebhook, task.Type())
+	}
+
+	parsed, err := ParseDeliverWebhookPayload(task)
+	if err != nil {
+		t.Fatalf("ParseDeliverWebhookPayload returned error: %v", err)
+	}
+	if parsed.JobID != "job-123" || string(parsed.Body) != `{"job_id":"job-123"}` {
+		t.Fatalf("unexpected parsed payload: %+v", parsed)
+	}
+
+	if _, err := ParseDeliverWebhookPayload(asynq.NewTask(TypeDeliverWebhook, []byte(`{"job_id":"job-123"}`))); err == nil {
+		t.Fatal("expected error for payload without url, event, and body")
+	}
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/id"
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisSlidingWindow struct {
+	client    redis.UniversalClient
+	limit     int64
+	window    time.Duration
+	keyPrefix string
+	now       func() time.Time
+	script    *redis.Script
+}
+
+func NewRedisSlidingWindow(client redis.UniversalClient, limit int, window time.Duration, keyPrefix string) (*RedisSlidingWindow, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client is required")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+
+	if strings.TrimSpace(keyPrefix) == "" {
+		keyPrefix = "pixelflow:ratelimit"
+	}
+
+	if window < time.Millisecond {
+		window = time.Millisecond
+	}
+
+	return &RedisSlidingWindow{
+		client:    client,
+		limit:     int64(limit),
+		window:    window,
+		keyPrefix: keyPrefix,
+		now:       time.Now,
+		script: redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window_ms = tonumber(ARGV[2])
+local now_ms = tonumber(ARGV[3])
+local member = ARGV[4]
+local requested = tonumber(ARGV[5])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now_ms - window_ms)
+local count = redis.call("ZCARD", key)
+
+local allowed = 0
+local retry_after_ms = 0
+if count + requested <= limit then
+  for i = 1, requested do
+    redis.call("ZADD", key, now_ms, member .. ":" .. i)
+  end
+  count = count + requested
+  allowed = 1
+elseif requested > limit then
+  retry_after_ms = window_ms
+else
+  local blocking = redis.call("ZRANGE", key, count + requested - limit - 1, count + requested - limit - 1, "WITHSCORES")
+  retry_after_ms = math.max(1, tonumber(blocking[2]) + window_ms - now_ms)
+end
+
+redis.call("PEXPIRE", key, window_ms)
+
+return {allowed, limit - count, retry_after_ms}
+`),
+	}, nil
+}
+
+func (l *RedisSlidingWindow) Allow(ctx context.Context, subject string) (Decision, error) {
+	return l.AllowN(ctx, subject, 1)
+}
+
+func (l *RedisSlidingWindow) AllowN(ctx context.Context, subject string, n int) (Decision, error) {
+	if n < 1 {
+		return Decision{}, fmt.Errorf("cost must be positive")
+	}
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = "anonymous"
+	}
+
+	key := fmt.Sprintf("%s:%s", l.keyPrefix, subject)
+	now := l.now().UTC().UnixMilli()
+	raw, err := l.script.Run(
+		ctx,
+		l.client,
+		[]string{key},
+		l.limit,
+		l.window.Milliseconds(),
+		now,
+		strconv.FormatInt(now, 10)+"-"+id.New(),
+		n,
+	).Result()
+	if err != nil {
+		return Decision{}, fmt.Errorf("run sliding window script: %w", err)
+	}
+
+	values, ok := raw.([]any)
+	if !ok || len(values) != 3 {
+		return Decision{}, fmt.Errorf("invalid sliding window response")
+	}
+
+	allowed, err := toInt64(values[0])
+	if err != nil {
+		return Decision{}, fmt.Errorf("parse allow value: %w", err)
+	}
+	remaining, err := toInt64(values[1])
+	if err != nil {
+		return Decision{}, fmt.Errorf("parse remaining value: %w", err)
+	}
+	retryAfterMS, err := toInt64(values[2])
+	if err != nil {
+		return Decision{}, fmt.Errorf("parse retry-after value: %w", err)
+	}
+
+	return Decision{
+		Allowed:    allowed == 1,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(retryAfterMS) * time.Millisecond,
+	}, nil
+}
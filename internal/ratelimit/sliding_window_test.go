@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisSlidingWindowRejectsBurstAcrossWindowEdge(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	limiter, err := NewRedisSlidingWindow(client, 3, time.Minute, "test:ratelimit")
+	if err != nil {
+		t.Fatalf("new sliding window: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := base.Add(59 * time.Second)
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		decision, err := limiter.Allow(ctx, "alice")
+		if err != nil {
+			t.Fatalf("allow request %d: %v", i, err)
+		}
+		if !decision.Allowed {
+			t.Fatalf("expected request %d at window end to be allowed", i)
+		}
+	}
+
+	now = base.Add(61 * time.Second)
+	decision, err := limiter.Allow(ctx, "alice")
+	if err != nil {
+		t.Fatalf("allow after window edge: %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("expected request just past the fixed window edge to be rejected")
+	}
+	if decision.Remaining != 0 {
+		t.Fatalf("expected remaining=0, got %d", decision.Remaining)
+	}
+	if decision.RetryAfter != 58*time.Second {
+		t.Fatalf("expected retry-after=58s, got %s", decision.RetryAfter)
+	}
+
+	now = base.Add(59*time.Second + time.Minute + time.Millisecond)
+	decision, err = limiter.Allow(ctx, "alice")
+	if err != nil {
+		t.Fatalf("allow after trailing window: %v", err)
+	}
+	if !decision.Allowed {
+		t.Fatal("expected request to be allowed once the burst leaves the trailing window")
+	}
+
+	decision, err = limiter.Allow(ctx, "bob")
+	if err != nil {
+		t.Fatalf("allow other subject: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 2 {
+		t.Fatalf("expected independent window for another subject, got %+v", decision)
+	}
+}
+
+func TestRedisSlidingWindowAllowNChargesFullCost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	limiter, err := NewRedisSlidingWindow(client, 5, time.Minute, "test:ratelimit")
+	if err != nil {
+		t.Fatalf("new sliding window: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := base
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	decision, err := limiter.AllowN(ctx, "alice", 3)
+	if err != nil {
+		t.Fatalf("allow batch: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 2 {
+		t.Fatalf("expected batch of 3 to be allowed with 2 remaining, got %+v", decision)
+	}
+
+	now = base.Add(10 * time.Second)
+	if _, err := limiter.Allow(ctx, "alice"); err != nil {
+		t.Fatalf("allow single: %v", err)
+	}
+
+	now = base.Add(20 * time.Second)
+	decision, err = limiter.AllowN(ctx, "alice", 3)
+	if err != nil {
+		t.Fatalf("allow second batch: %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("expected batch exceeding the remaining budget to be rejected")
+	}
+	if decision.RetryAfter != 40*time.Second {
+		t.Fatalf("expected retry-after=40s once the first batch expires, got %s", decision.RetryAfter)
+	}
+
+	decision, err = limiter.AllowN(ctx, "alice", 6)
+	if err != nil {
+		t.Fatalf("allow oversized batch: %v", err)
+	}
+	if decision.Allowed || decision.RetryAfter != time.Minute {
+		t.Fatalf("expected oversized batch to be rejected for a full window, got %+v", decision)
+	}
+}
@@ -93,6 +93,13 @@ return {allowed, math.floor(tokens), retry_after_ms}
 }
 
 func (l *RedisTokenBucket) Allow(ctx context.Context, subject string) (Decision, error) {
+	return l.AllowN(ctx, subject, 1)
+}
+
+func (l *RedisTokenBucket) AllowN(ctx context.Context, subject string, n int) (Decision, error) {
+	if n < 1 {
+		return Decision{}, fmt.Errorf("cost must be positive")
+	}
 	subject = strings.TrimSpace(subject)
 	if subject == "" {
 		subject = "anonymous"
@@ -107,7 +114,7 @@ func (l *RedisTokenBucket) Allow(ctx context.Context, subject string) (Decision,
 		l.capacity,
 		l.refillPerMS,
 		now,
-		1,
+		n,
 		l.ttl.Milliseconds(),
 	).Result()
 	if err != nil {
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisTokenBucketAllowNConsumesCost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	limiter, err := NewRedisTokenBucket(client, 10, 10*time.Second, "test:ratelimit")
+	if err != nil {
+		t.Fatalf("new token bucket: %v", err)
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	decision, err := limiter.AllowN(ctx, "alice", 8)
+	if err != nil {
+		t.Fatalf("allow batch: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 2 {
+		t.Fatalf("expected batch of 8 to be allowed with 2 remaining, got %+v", decision)
+	}
+
+	decision, err = limiter.AllowN(ctx, "alice", 4)
+	if err != nil {
+		t.Fatalf("allow second batch: %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("expected batch exceeding the remaining tokens to be rejected")
+	}
+	if decision.RetryAfter != 2*time.Second {
+		t.Fatalf("expected retry-after=2s to refill 2 tokens, got %s", decision.RetryAfter)
+	}
+
+	if _, err := limiter.AllowN(ctx, "alice", 0); err == nil {
+		t.Fatal("expected zero cost to be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+package requestid
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/dunamismax/pixelflow/internal/id"
+)
+
+const (
+	Header    = "X-Request-ID"
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+func New() string {
+	return id.New()
+}
+
+// Sanitize returns a caller-supplied ID if it is short printable ASCII, or ""
+// so the caller generates a fresh one instead of echoing untrusted input.
+func Sanitize(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > maxLength {
+		return ""
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x21 || value[i] > 0x7e {
+			return ""
+		}
+	}
+	return value
+}
+
+func WithID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// Logf logs through logger, appending request_id=<id> when ctx carries one so
+// API and worker lines for the same request can be joined.
+func Logf(ctx context.Context, logger *log.Logger, format string, args ...any) {
+	if requestID := FromContext(ctx); requestID != "" {
+		format += " request_id=%s"
+		args = append(args, requestID)
+	}
+	logger.Printf(format, args...)
+}
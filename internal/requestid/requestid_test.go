@@ -0,0 +1,53 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "keeps plain id", value: "req-123", want: "req-123"},
+		{name: "trims whitespace", value: "  abc  ", want: "abc"},
+		{name: "rejects empty", value: "", want: ""},
+		{name: "rejects control characters", value: "abc\ninjected=1", want: ""},
+		{name: "rejects spaces", value: "a b", want: ""},
+		{name: "rejects overlong", value: strings.Repeat("a", maxLength+1), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.value); got != tt.want {
+				t.Fatalf("Sanitize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	ctx := WithID(context.Background(), "req-1")
+	if got := FromContext(ctx); got != "req-1" {
+		t.Fatalf("FromContext = %q, want req-1", got)
+	}
+	if got := FromContext(context.Background()); got != "" {
+		t.Fatalf("expected empty id without value, got %q", got)
+	}
+}
+
+func TestLogfAppendsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	Logf(context.Background(), logger, "job=%s done", "a")
+	Logf(WithID(context.Background(), "req-1"), logger, "job=%s done", "b")
+
+	if got, want := buf.String(), "job=a done\njob=b done request_id=req-1\n"; got != want {
+		t.Fatalf("unexpected log output %q, want %q", got, want)
+	}
+}
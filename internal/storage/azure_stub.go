@@ -0,0 +1,9 @@
+//go:build !azure
+
+package storage
+
+import "errors"
+
+func newAzureBackend(Config) (Backend, error) {
+	return nil, errors.New("azure storage requires building with -tags azure")
+}
@@ -0,0 +1,301 @@
+//go:build azure
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBlobService implements the subset of the Azure Blob REST API the
+// backend uses, for one container, so the SDK calls can be exercised without
+// Azurite.
+type fakeBlobService struct {
+	mu        sync.Mutex
+	container bool
+	blobs     map[string]fakeBlob
+	blocks    map[string]map[string][]byte
+}
+
+type fakeBlob struct {
+	data         []byte
+	contentType  string
+	cacheControl string
+	modified     time.Time
+}
+
+func newFakeBlobService() *fakeBlobService {
+	return &fakeBlobService{blobs: map[string]fakeBlob{}, blocks: map[string]map[string][]byte{}}
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Paths are /<account>/<container>[/<blob>].
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	query := r.URL.Query()
+	if len(parts) < 3 || parts[2] == "" {
+		f.serveContainer(w, r, query)
+		return
+	}
+	name := parts[2]
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, _ := io.ReadAll(r.Body)
+		if f.blocks[name] == nil {
+			f.blocks[name] = map[string][]byte{}
+		}
+		f.blocks[name][query.Get("blockid")] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			data = append(data, f.blocks[name][id]...)
+		}
+		f.blobs[name] = fakeBlob{data: data, modified: time.Now().UTC()}
+		delete(f.blocks, name)
+		writeBlobHeaders(w, f.blobs[name])
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			http.Error(w, "missing x-ms-blob-type", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[name] = fakeBlob{
+			data:         data,
+			contentType:  r.Header.Get("x-ms-blob-content-type"),
+			cacheControl: r.Header.Get("x-ms-blob-cache-control"),
+			modified:     time.Now().UTC(),
+		}
+		writeBlobHeaders(w, f.blobs[name])
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		blob, ok := f.blobs[name]
+		if !ok {
+			blobError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		writeBlobHeaders(w, blob)
+		data := blob.data
+		status := http.StatusOK
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("x-ms-range"), "bytes=%d-%d", &start, &end); err == nil {
+			end = min(end, len(data)-1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		if _, ok := f.blobs[name]; !ok {
+			blobError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(f.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeBlobService) serveContainer(w http.ResponseWriter, r *http.Request, query map[string][]string) {
+	comp := ""
+	if v := query["comp"]; len(v) > 0 {
+		comp = v[0]
+	}
+	switch {
+	case r.Method == http.MethodPut:
+		if f.container {
+			blobError(w, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		f.container = true
+		w.WriteHeader(http.StatusCreated)
+	case !f.container:
+		blobError(w, http.StatusNotFound, "ContainerNotFound")
+	case comp == "list":
+		prefix := ""
+		if v := query["prefix"]; len(v) > 0 {
+			prefix = v[0]
+		}
+		names := make([]string, 0, len(f.blobs))
+		for name := range f.blobs {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var body bytes.Buffer
+		body.WriteString(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="pixelflow"><Blobs>`)
+		for _, name := range names {
+			fmt.Fprintf(&body, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType></Properties></Blob>`,
+				name, f.blobs[name].modified.Format(http.TimeFormat), len(f.blobs[name].data))
+		}
+		body.WriteString(`</Blobs><NextMarker /></EnumerationResults>`)
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(body.Bytes())
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func writeBlobHeaders(w http.ResponseWriter, blob fakeBlob) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, blob.modified.UnixNano()))
+	w.Header().Set("Last-Modified", blob.modified.Format(http.TimeFormat))
+	w.Header().Set("x-ms-blob-type", "BlockBlob")
+	if blob.contentType != "" {
+		w.Header().Set("Content-Type", blob.contentType)
+	}
+}
+
+func blobError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func newFakeAzureBackend(t *testing.T) (Backend, *fakeBlobService) {
+	t.Helper()
+	service := newFakeBlobService()
+	srv := httptest.NewServer(service)
+	t.Cleanup(srv.Close)
+
+	backend, err := newAzureBackend(Config{
+		AzureAccount:   "devaccount",
+		AzureKey:       base64.StdEncoding.EncodeToString([]byte("devkey")),
+		AzureContainer: "pixelflow",
+		AzureEndpoint:  srv.URL + "/devaccount/",
+	})
+	if err != nil {
+		t.Fatalf("new azure backend: %v", err)
+	}
+	return backend, service
+}
+
+func TestAzureBackendObjectLifecycle(t *testing.T) {
+	ctx := context.Background()
+	backend, service := newFakeAzureBackend(t)
+
+	if err := backend.Ping(ctx); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected a missing-container ping error, got %v", err)
+	}
+	if err := backend.EnsureBucket(ctx); err != nil {
+		t.Fatalf("ensure bucket: %v", err)
+	}
+	if err := backend.EnsureBucket(ctx); err != nil {
+		t.Fatalf("ensure existing bucket: %v", err)
+	}
+
+	key := "outputs/job-1/thumb.png"
+	if err := backend.WriteObject(ctx, key, []byte("pixels"), WriteOptions{ContentType: "image/png", CacheControl: "max-age=60"}); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	if stored := service.blobs[key]; stored.contentType != "image/png" || stored.cacheControl != "max-age=60" {
+		t.Fatalf("expected blob headers to be set, got %+v", stored)
+	}
+	if ok, err := backend.ObjectExists(ctx, key); err != nil || !ok {
+		t.Fatalf("expected object to exist: ok=%v err=%v", ok, err)
+	}
+	if ok, err := backend.ObjectExists(ctx, "outputs/job-1/missing.png"); err != nil || ok {
+		t.Fatalf("expected missing object: ok=%v err=%v", ok, err)
+	}
+	data, err := backend.ReadObject(ctx, key)
+	if err != nil || string(data) != "pixels" {
+		t.Fatalf("read object = %q, %v", data, err)
+	}
+	head, err := backend.ReadObjectHead(ctx, key, 3)
+	if err != nil || string(head) != "pix" {
+		t.Fatalf("read object head = %q, %v", head, err)
+	}
+
+	if err := backend.WriteObject(ctx, "outputs/job-1/large.png", []byte("more"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("write second object: %v", err)
+	}
+	if err := backend.WriteObject(ctx, "outputs/job-2/keep.png", []byte("keep"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("write other job object: %v", err)
+	}
+	deleted, err := backend.DeletePrefix(ctx, "outputs/job-1/")
+	if err != nil || deleted != 2 {
+		t.Fatalf("delete prefix = %d, %v; want 2", deleted, err)
+	}
+	if _, ok := service.blobs["outputs/job-2/keep.png"]; !ok {
+		t.Fatal("expected objects outside the prefix to survive")
+	}
+	if err := backend.DeleteObject(ctx, key); err != nil {
+		t.Fatalf("delete missing object should be a no-op: %v", err)
+	}
+}
+
+func TestAzureBackendMultipartCommitsBlocksInOrder(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newFakeAzureBackend(t)
+	if err := backend.EnsureBucket(ctx); err != nil {
+		t.Fatalf("ensure bucket: %v", err)
+	}
+
+	key := "uploads/job-1/source"
+	uploadID, err := backend.CreateMultipartUpload(ctx, key, "image/png")
+	if err != nil {
+		t.Fatalf("create multipart upload: %v", err)
+	}
+	// Upload the parts out of order; completion must still sort them.
+	for _, part := range []struct {
+		number int
+		data   string
+	}{{2, "world"}, {1, "hello "}} {
+		partURL, err := backend.PresignedUploadPart(ctx, key, uploadID, part.number, time.Minute)
+		if err != nil {
+			t.Fatalf("presign part %d: %v", part.number, err)
+		}
+		if !strings.Contains(partURL, "comp=block") || !strings.Contains(partURL, "sig=") {
+			t.Fatalf("expected a SAS-signed Put Block URL, got %s", partURL)
+		}
+		req, _ := http.NewRequest(http.MethodPut, partURL, strings.NewReader(part.data))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("upload part %d: %v", part.number, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("upload part %d status = %d", part.number, resp.StatusCode)
+		}
+	}
+
+	if err := backend.CompleteMultipartUpload(ctx, key, uploadID, []CompletedPart{{PartNumber: 2}, {PartNumber: 1}}); err != nil {
+		t.Fatalf("complete multipart upload: %v", err)
+	}
+	data, err := backend.ReadObject(ctx, key)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("read committed object = %q, %v", data, err)
+	}
+
+	if len(azureBlockID(1)) != len(azureBlockID(123456)) {
+		t.Fatal("expected fixed-width block ids")
+	}
+}
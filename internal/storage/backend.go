@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderMinio = "minio"
+	ProviderAzure = "azure"
+)
+
+// Backend is the object-store contract shared by the API, worker, and
+// pipeline. Keys follow the uploads/{job_id}/source and outputs/{job_id}/...
+// conventions regardless of provider.
+type Backend interface {
+	Ping(ctx context.Context) error
+	EnsureBucket(ctx context.Context) error
+
+	PresignedPutURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
+	PresignedGetURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
+
+	ObjectExists(ctx context.Context, objectKey string) (bool, error)
+	ReadObject(ctx context.Context, objectKey string) ([]byte, error)
+	ReadObjectHead(ctx context.Context, objectKey string, n int64) ([]byte, error)
+	ReadObjectStream(ctx context.Context, objectKey string) (io.ReadCloser, error)
+	WriteObject(ctx context.Context, objectKey string, data []byte, opts WriteOptions) error
+
+	DeleteObject(ctx context.Context, objectKey string) error
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	PruneExpired(ctx context.Context, prefix string, olderThan time.Time) (int, error)
+
+	CreateMultipartUpload(ctx context.Context, objectKey, contentType string) (string, error)
+	PresignedUploadPart(ctx context.Context, objectKey, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, objectKey, uploadID string, parts []CompletedPart) error
+	// AbortMultipartUpload discards an upload's parts; aborting an unknown
+	// upload is not an error.
+	AbortMultipartUpload(ctx context.Context, objectKey, uploadID string) error
+}
+
+// WriteOptions are the HTTP headers stored with an object and replayed on
+// GET. Empty fields are left unset.
+type WriteOptions struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+}
+
+var (
+	_ Backend = (*Client)(nil)
+	_ Backend = (*FilesystemStorage)(nil)
+)
+
+// Open builds the backend selected by cfg.Provider (default minio, which also
+// covers S3-compatible endpoints; azure; or filesystem).
+func Open(cfg Config) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", ProviderMinio, "s3":
+		client, err := NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case ProviderAzure:
+		return newAzureBackend(cfg)
+	case ProviderFilesystem:
+		fsStorage, err := NewFilesystemStorage(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return fsStorage, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage provider: %s", cfg.Provider)
+	}
+}
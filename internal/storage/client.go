@@ -5,30 +5,88 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+const (
+	CredentialsStatic = "static"
+	CredentialsIAM    = "iam"
+
+	SSEModeS3  = "sse-s3"
+	SSEModeKMS = "sse-kms"
 )
 
 type Config struct {
-	Endpoint string
-	Access   string
-	Secret   string
-	Bucket   string
-	UseSSL   bool
+	Provider    string
+	Endpoint    string
+	Access      string
+	Secret      string
+	Bucket      string
+	UseSSL      bool
+	Credentials string
+	Region      string
+
+	// SSEMode ("sse-s3" or "sse-kms") encrypts every object the service
+	// writes and every presigned upload; empty leaves encryption to the
+	// bucket default. SSEKMSKeyID is required for sse-kms.
+	SSEMode     string
+	SSEKMSKeyID string
+
+	// Retry* bound retries of transient (5xx, throttling, connection)
+	// failures on reads, writes, and stats. Attempts <= 1 disables retries;
+	// backoff doubles from RetryBackoff up to RetryMaxBackoff.
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	Logger          *log.Logger
+
+	// Azure* settings apply to Provider "azure". AzureEndpoint overrides the
+	// default https://{account}.blob.core.windows.net service URL (e.g. for
+	// Azurite).
+	AzureAccount   string
+	AzureKey       string
+	AzureContainer string
+	AzureEndpoint  string
+
+	// Filesystem* settings apply to Provider "filesystem". FilesystemPublicURL
+	// is the API base URL that presigned links point at.
+	FilesystemRoot          string
+	FilesystemPublicURL     string
+	FilesystemSigningSecret string
 }
 
 type Client struct {
 	minio  *minio.Client
 	bucket string
+	sse    encrypt.ServerSide
+	retry  retryPolicy
 }
 
 func NewClient(cfg Config) (*Client, error) {
+	return newClient(cfg, nil)
+}
+
+func newClient(cfg Config, transport http.RoundTripper) (*Client, error) {
+	creds, err := newCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// minio-go's own retries are disabled so retryPolicy alone decides, and
+	// logs, how often a request is repeated.
 	mc, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.Access, cfg.Secret, ""),
-		Secure: cfg.UseSSL,
+		Creds:      creds,
+		Secure:     cfg.UseSSL,
+		Region:     cfg.Region,
+		Transport:  transport,
+		MaxRetries: 1,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create minio client: %w", err)
@@ -38,16 +96,75 @@ func NewClient(cfg Config) (*Client, error) {
 		return nil, fmt.Errorf("bucket is required")
 	}
 
+	sse, err := newServerSide(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
 		minio:  mc,
 		bucket: cfg.Bucket,
+		sse:    sse,
+		retry: retryPolicy{
+			attempts:   cfg.RetryAttempts,
+			backoff:    cfg.RetryBackoff,
+			maxBackoff: cfg.RetryMaxBackoff,
+			logger:     cfg.Logger,
+		},
 	}, nil
 }
 
+func newServerSide(cfg Config) (encrypt.ServerSide, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.SSEMode)) {
+	case "":
+		return nil, nil
+	case SSEModeS3:
+		return encrypt.NewSSE(), nil
+	case SSEModeKMS:
+		keyID := strings.TrimSpace(cfg.SSEKMSKeyID)
+		if keyID == "" {
+			return nil, fmt.Errorf("sse-kms requires a kms key id")
+		}
+		sse, err := encrypt.NewSSEKMS(keyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("configure sse-kms: %w", err)
+		}
+		return sse, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage sse mode: %s", cfg.SSEMode)
+	}
+}
+
+func newCredentials(cfg Config) (*credentials.Credentials, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Credentials)) {
+	case "", CredentialsStatic:
+		return credentials.NewStaticV4(cfg.Access, cfg.Secret, ""), nil
+	case CredentialsIAM:
+		return credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage credentials source: %s", cfg.Credentials)
+	}
+}
+
 func (c *Client) Bucket() string {
 	return c.bucket
 }
 
+func (c *Client) Ping(ctx context.Context) error {
+	exists, err := c.minio.BucketExists(ctx, c.bucket)
+	if err != nil {
+		return fmt.Errorf("check bucket existence: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", c.bucket)
+	}
+	return nil
+}
+
 func (c *Client) EnsureBucket(ctx context.Context) error {
 	exists, err := c.minio.BucketExists(ctx, c.bucket)
 	if err != nil {
@@ -68,53 +185,186 @@ func (c *Client) EnsureBucket(ctx context.Context) error {
 	return nil
 }
 
+// PresignedPutURL signs the SSE headers into the URL when encryption is
+// configured; uploaders must send PresignedPutHeaders with the request.
 func (c *Client) PresignedPutURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error) {
-	u, err := c.minio.PresignedPutObject(ctx, c.bucket, objectKey, expiry)
+	u, err := c.minio.PresignHeader(ctx, http.MethodPut, c.bucket, objectKey, expiry, nil, c.sseHeaders())
 	if err != nil {
 		return "", fmt.Errorf("presign put object: %w", err)
 	}
 	return u.String(), nil
 }
 
+// PresignedPutHeaders lists the headers a presigned PUT must carry, or nil
+// when none are required.
+func (c *Client) PresignedPutHeaders() map[string]string {
+	headers := c.sseHeaders()
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for name := range headers {
+		out[name] = headers.Get(name)
+	}
+	return out
+}
+
+func (c *Client) sseHeaders() http.Header {
+	if c.sse == nil {
+		return nil
+	}
+	headers := http.Header{}
+	c.sse.Marshal(headers)
+	return headers
+}
+
+func (c *Client) PresignedGetURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error) {
+	u, err := c.minio.PresignedGetObject(ctx, c.bucket, objectKey, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("presign get object: %w", err)
+	}
+	return u.String(), nil
+}
+
 func (c *Client) ObjectExists(ctx context.Context, objectKey string) (bool, error) {
-	_, err := c.minio.StatObject(ctx, c.bucket, objectKey, minio.StatObjectOptions{})
+	err := c.retry.do(ctx, "stat", objectKey, func() error {
+		_, err := c.minio.StatObject(ctx, c.bucket, objectKey, minio.StatObjectOptions{})
+		return err
+	})
 	if err == nil {
 		return true, nil
 	}
-
-	resp := minio.ToErrorResponse(err)
-	if resp.Code == "NoSuchKey" || resp.Code == "NoSuchObject" {
+	if isNotFound(err) {
 		return false, nil
 	}
 	return false, fmt.Errorf("stat object %s: %w", objectKey, err)
 }
 
+func isNotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "NoSuchKey", "NoSuchObject", "NotFound":
+		return true
+	}
+	return resp.StatusCode == http.StatusNotFound && resp.Code != "NoSuchBucket"
+}
+
 func (c *Client) ReadObject(ctx context.Context, objectKey string) ([]byte, error) {
-	obj, err := c.minio.GetObject(ctx, c.bucket, objectKey, minio.GetObjectOptions{})
+	var data []byte
+	err := c.retry.do(ctx, "get", objectKey, func() error {
+		obj, err := c.getObject(ctx, objectKey, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		data, err = io.ReadAll(obj)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("get object %s: %w", objectKey, err)
+		return nil, fmt.Errorf("read object %s: %w", objectKey, err)
 	}
-	defer obj.Close()
+	return data, nil
+}
 
-	data, err := io.ReadAll(obj)
+func (c *Client) ReadObjectHead(ctx context.Context, objectKey string, n int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(0, n-1); err != nil {
+		return nil, fmt.Errorf("set object range: %w", err)
+	}
+	var data []byte
+	err := c.retry.do(ctx, "get", objectKey, func() error {
+		obj, err := c.getObject(ctx, objectKey, opts)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		data, err = io.ReadAll(io.LimitReader(obj, n))
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("read object %s: %w", objectKey, err)
 	}
 	return data, nil
 }
 
-func (c *Client) WriteObject(ctx context.Context, objectKey string, data []byte, contentType string) error {
-	reader := bytes.NewReader(data)
-	_, err := c.minio.PutObject(
-		ctx,
-		c.bucket,
-		objectKey,
-		reader,
-		int64(len(data)),
-		minio.PutObjectOptions{ContentType: contentType},
-	)
+// ReadObjectStream retries opening the object; failures after the caller
+// starts reading are not retried.
+func (c *Client) ReadObjectStream(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	var obj io.ReadCloser
+	err := c.retry.do(ctx, "get", objectKey, func() (err error) {
+		obj, err = c.getObject(ctx, objectKey, minio.GetObjectOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", objectKey, err)
+	}
+	return obj, nil
+}
+
+// getObject issues a single GET for objectKey so errors surface here. The
+// high-level minio.Object defers the request until first use and turns a
+// Stat into an extra HEAD, so this goes through minio.Core instead.
+func (c *Client) getObject(ctx context.Context, objectKey string, opts minio.GetObjectOptions) (io.ReadCloser, error) {
+	body, _, _, err := minio.Core{Client: c.minio}.GetObject(ctx, c.bucket, objectKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (c *Client) WriteObject(ctx context.Context, objectKey string, data []byte, opts WriteOptions) error {
+	err := c.retry.do(ctx, "put", objectKey, func() error {
+		_, err := c.minio.PutObject(
+			ctx,
+			c.bucket,
+			objectKey,
+			bytes.NewReader(data),
+			int64(len(data)),
+			minio.PutObjectOptions{
+				ContentType:          opts.ContentType,
+				CacheControl:         opts.CacheControl,
+				ContentDisposition:   opts.ContentDisposition,
+				ServerSideEncryption: c.sse,
+			},
+		)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("put object %s: %w", objectKey, err)
 	}
 	return nil
 }
+
+func (c *Client) DeleteObject(ctx context.Context, objectKey string) error {
+	if err := c.minio.RemoveObject(ctx, c.bucket, objectKey, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("delete object %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+func (c *Client) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return c.deleteMatching(ctx, prefix, func(minio.ObjectInfo) bool { return true })
+}
+
+func (c *Client) PruneExpired(ctx context.Context, prefix string, olderThan time.Time) (int, error) {
+	return c.deleteMatching(ctx, prefix, func(obj minio.ObjectInfo) bool {
+		return obj.LastModified.Before(olderThan)
+	})
+}
+
+func (c *Client) deleteMatching(ctx context.Context, prefix string, match func(minio.ObjectInfo) bool) (int, error) {
+	deleted := 0
+	for obj := range c.minio.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return deleted, fmt.Errorf("list objects %s: %w", prefix, obj.Err)
+		}
+		if !match(obj) {
+			continue
+		}
+		if err := c.DeleteObject(ctx, obj.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestIsNotFoundAcrossProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "minio no such key", err: minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}, want: true},
+		{name: "minio no such object", err: minio.ErrorResponse{Code: "NoSuchObject", StatusCode: http.StatusNotFound}, want: true},
+		{name: "aws head not found", err: minio.ErrorResponse{Code: "NotFound", StatusCode: http.StatusNotFound}, want: true},
+		{name: "aws head empty code", err: minio.ErrorResponse{StatusCode: http.StatusNotFound}, want: true},
+		{name: "gateway unknown code", err: minio.ErrorResponse{Code: "ResourceNotFound", StatusCode: http.StatusNotFound}, want: true},
+		{name: "missing bucket", err: minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}, want: false},
+		{name: "access denied", err: minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden}, want: false},
+		{name: "network error", err: errors.New("dial tcp: connection refused"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFound(tt.err); got != tt.want {
+				t.Fatalf("isNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCredentialsRejectsUnknownSource(t *testing.T) {
+	if _, err := newCredentials(Config{Credentials: "vault"}); err == nil {
+		t.Fatal("expected error for unsupported credentials source")
+	}
+	for _, source := range []string{"", CredentialsStatic, CredentialsIAM} {
+		if _, err := newCredentials(Config{Credentials: source, Access: "a", Secret: "b"}); err != nil {
+			t.Fatalf("newCredentials(%q) error = %v", source, err)
+		}
+	}
+}
+
+func TestOpenSelectsProvider(t *testing.T) {
+	if _, err := Open(Config{Provider: "gcs", Bucket: "b"}); err == nil {
+		t.Fatal("expected error for unsupported provider")
+	}
+	backend, err := Open(Config{Endpoint: "localhost:9000", Bucket: "b"})
+	if err != nil {
+		t.Fatalf("open default provider: %v", err)
+	}
+	if _, ok := backend.(*Client); !ok {
+		t.Fatalf("expected minio client for default provider, got %T", backend)
+	}
+}
+
+func TestServerSideEncryptionConfig(t *testing.T) {
+	base := Config{Endpoint: "localhost:9000", Access: "minioadmin", Secret: "minioadmin", Bucket: "pixelflow-jobs", Region: "us-east-1"}
+
+	kmsWithoutKey := base
+	kmsWithoutKey.SSEMode = SSEModeKMS
+	if _, err := NewClient(kmsWithoutKey); err == nil {
+		t.Fatal("expected sse-kms without a key id to be rejected")
+	}
+
+	plain, err := NewClient(base)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if headers := plain.PresignedPutHeaders(); headers != nil {
+		t.Fatalf("expected no upload headers without sse, got %v", headers)
+	}
+
+	kms := base
+	kms.SSEMode = SSEModeKMS
+	kms.SSEKMSKeyID = "alias/pixelflow"
+	client, err := NewClient(kms)
+	if err != nil {
+		t.Fatalf("new kms client: %v", err)
+	}
+	headers := client.PresignedPutHeaders()
+	if headers["X-Amz-Server-Side-Encryption"] != "aws:kms" || headers["X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"] != "alias/pixelflow" {
+		t.Fatalf("unexpected upload headers %v", headers)
+	}
+	u, err := client.PresignedPutURL(context.Background(), "uploads/job-1/source", time.Minute)
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	if !strings.Contains(u, "x-amz-server-side-encryption") {
+		t.Fatalf("expected sse headers to be signed, got %s", u)
+	}
+}
@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/id"
+)
+
+const (
+	ProviderFilesystem = "filesystem"
+
+	// FilesystemRoutePrefix is where FilesystemStorage.Handler must be mounted
+	// for its presigned URLs to resolve.
+	FilesystemRoutePrefix = "/v1/storage/"
+
+	multipartDir = ".multipart"
+)
+
+var errInvalidObjectKey = errors.New("invalid object key")
+
+// FilesystemStorage keeps objects as files under a root directory. Presigned
+// URLs are HMAC-signed links to Handler, which accepts uploads and serves
+// downloads, so the s3_presigned flow works without an object store.
+type FilesystemStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+func NewFilesystemStorage(cfg Config) (*FilesystemStorage, error) {
+	root := strings.TrimSpace(cfg.FilesystemRoot)
+	if root == "" {
+		return nil, errors.New("filesystem storage root is required")
+	}
+	if strings.TrimSpace(cfg.FilesystemSigningSecret) == "" {
+		return nil, errors.New("filesystem storage signing secret is required")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve filesystem storage root: %w", err)
+	}
+	return &FilesystemStorage{
+		root:    abs,
+		baseURL: strings.TrimRight(strings.TrimSpace(cfg.FilesystemPublicURL), "/"),
+		secret:  []byte(cfg.FilesystemSigningSecret),
+		now:     time.Now,
+	}, nil
+}
+
+// objectPath maps a slash-separated key to a file under root, rejecting keys
+// that would escape it.
+func (f *FilesystemStorage) objectPath(objectKey string) (string, error) {
+	clean := path.Clean("/" + objectKey)
+	if objectKey == "" || clean == "/" || strings.HasPrefix(clean, "/"+multipartDir) {
+		return "", fmt.Errorf("%w: %q", errInvalidObjectKey, objectKey)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(clean)), nil
+}
+
+func (f *FilesystemStorage) Ping(_ context.Context) error {
+	info, err := os.Stat(f.root)
+	if err != nil {
+		return fmt.Errorf("check storage root: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage root %s is not a directory", f.root)
+	}
+	return nil
+}
+
+func (f *FilesystemStorage) EnsureBucket(_ context.Context) error {
+	if err := os.MkdirAll(f.root, 0o755); err != nil {
+		return fmt.Errorf("create storage root %s: %w", f.root, err)
+	}
+	return nil
+}
+
+func (f *FilesystemStorage) PresignedPutURL(_ context.Context, objectKey string, expiry time.Duration) (string, error) {
+	return f.presign(http.MethodPut, objectKey, expiry, nil)
+}
+
+func (f *FilesystemStorage) PresignedGetURL(_ context.Context, objectKey string, expiry time.Duration) (string, error) {
+	return f.presign(http.MethodGet, objectKey, expiry, nil)
+}
+
+func (f *FilesystemStorage) presign(method, objectKey string, expiry time.Duration, extra url.Values) (string, error) {
+	if _, err := f.objectPath(objectKey); err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	for k, v := range extra {
+		params[k] = v
+	}
+	params.Set("expires", strconv.FormatInt(f.now().Add(expiry).Unix(), 10))
+	params.Set("signature", f.sign(method, objectKey, params))
+	return f.baseURL + FilesystemRoutePrefix + objectKey + "?" + params.Encode(), nil
+}
+
+func (f *FilesystemStorage) sign(method, objectKey string, params url.Values) string {
+	mac := hmac.New(sha256.New, f.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, objectKey, params.Get("expires"), params.Get("uploadId"), params.Get("partNumber"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *FilesystemStorage) verify(method, objectKey string, params url.Values) bool {
+	expires, err := strconv.ParseInt(params.Get("expires"), 10, 64)
+	if err != nil || f.now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(params.Get("signature")), []byte(f.sign(method, objectKey, params)))
+}
+
+func (f *FilesystemStorage) ObjectExists(_ context.Context, objectKey string) (bool, error) {
+	p, err := f.objectPath(objectKey)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat object %s: %w", objectKey, err)
+	}
+	return !info.IsDir(), nil
+}
+
+func (f *FilesystemStorage) ReadObject(ctx context.Context, objectKey string) ([]byte, error) {
+	obj, err := f.ReadObjectStream(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("read object %s: %w", objectKey, err)
+	}
+	return data, nil
+}
+
+func (f *FilesystemStorage) ReadObjectHead(ctx context.Context, objectKey string, n int64) ([]byte, error) {
+	obj, err := f.ReadObjectStream(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, n))
+	if err != nil {
+		return nil, fmt.Errorf("read object %s: %w", objectKey, err)
+	}
+	return data, nil
+}
+
+func (f *FilesystemStorage) ReadObjectStream(_ context.Context, objectKey string) (io.ReadCloser, error) {
+	p, err := f.objectPath(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("get object %s: %w", objectKey, err)
+	}
+	return obj, nil
+}
+
+func (f *FilesystemStorage) WriteObject(_ context.Context, objectKey string, data []byte, _ WriteOptions) error {
+	p, err := f.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(p, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("put object %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes through a temp file in the target directory and
+// renames it into place so readers never see partial objects.
+func writeFileAtomic(p string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (f *FilesystemStorage) DeleteObject(_ context.Context, objectKey string) error {
+	p, err := f.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete object %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+func (f *FilesystemStorage) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return f.deleteMatching(ctx, prefix, func(fs.FileInfo) bool { return true })
+}
+
+func (f *FilesystemStorage) PruneExpired(ctx context.Context, prefix string, olderThan time.Time) (int, error) {
+	return f.deleteMatching(ctx, prefix, func(info fs.FileInfo) bool {
+		return info.ModTime().Before(olderThan)
+	})
+}
+
+func (f *FilesystemStorage) deleteMatching(ctx context.Context, prefix string, match func(fs.FileInfo) bool) (int, error) {
+	deleted := 0
+	err := filepath.WalkDir(f.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if key == multipartDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !match(info) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("list objects %s: %w", prefix, err)
+	}
+	return deleted, nil
+}
+
+func (f *FilesystemStorage) CreateMultipartUpload(_ context.Context, objectKey, _ string) (string, error) {
+	if _, err := f.objectPath(objectKey); err != nil {
+		return "", err
+	}
+	uploadID := id.New()
+	if err := os.MkdirAll(f.partsDir(uploadID), 0o755); err != nil {
+		return "", fmt.Errorf("create multipart upload %s: %w", objectKey, err)
+	}
+	return uploadID, nil
+}
+
+func (f *FilesystemStorage) PresignedUploadPart(_ context.Context, objectKey, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	return f.presign(http.MethodPut, objectKey, expiry, url.Values{
+		"uploadId":   {uploadID},
+		"partNumber": {strconv.Itoa(partNumber)},
+	})
+}
+
+func (f *FilesystemStorage) CompleteMultipartUpload(_ context.Context, objectKey, uploadID string, parts []CompletedPart) error {
+	p, err := f.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+	sorted := append([]CompletedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+
+	err = writeFileAtomic(p, func(w io.Writer) error {
+		for _, part := range sorted {
+			if err := copyPart(w, f.partPath(uploadID, part.PartNumber)); err != nil {
+				return fmt.Errorf("part %d: %w", part.PartNumber, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("complete multipart upload %s: %w", objectKey, err)
+	}
+	return os.RemoveAll(f.partsDir(uploadID))
+}
+
+func (f *FilesystemStorage) AbortMultipartUpload(_ context.Context, objectKey, uploadID string) error {
+	if _, err := f.objectPath(objectKey); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(f.partsDir(uploadID)); err != nil {
+		return fmt.Errorf("abort multipart upload %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+func copyPart(w io.Writer, p string) error {
+	part, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	_, err = io.Copy(w, part)
+	return err
+}
+
+func (f *FilesystemStorage) partsDir(uploadID string) string {
+	return filepath.Join(f.root, multipartDir, filepath.Base(uploadID))
+}
+
+func (f *FilesystemStorage) partPath(uploadID string, partNumber int) string {
+	return filepath.Join(f.partsDir(uploadID), strconv.Itoa(partNumber))
+}
+
+// Handler serves presigned PUT (object and multipart part) and GET requests
+// under FilesystemRoutePrefix.
+func (f *FilesystemStorage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		objectKey := strings.TrimPrefix(r.URL.Path, FilesystemRoutePrefix)
+		method := r.Method
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		if !f.verify(method, objectKey, r.URL.Query()) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+		p, err := f.objectPath(objectKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			http.ServeFile(w, r, p)
+		case http.MethodPut:
+			target := p
+			if uploadID := r.URL.Query().Get("uploadId"); uploadID != "" {
+				partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+				if err != nil || partNumber < 1 {
+					http.Error(w, "invalid partNumber", http.StatusBadRequest)
+					return
+				}
+				target = f.partPath(uploadID, partNumber)
+			}
+			sum := md5.New()
+			if err := writeFileAtomic(target, func(out io.Writer) error {
+				_, err := io.Copy(io.MultiWriter(out, sum), r.Body)
+				return err
+			}); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, fmt.Sprintf("object exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "write object failed", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum.Sum(nil))+`"`)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFilesystemStorage(t *testing.T) (*FilesystemStorage, *httptest.Server) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	fsStorage, err := NewFilesystemStorage(Config{
+		FilesystemRoot:          t.TempDir(),
+		FilesystemPublicURL:     srv.URL,
+		FilesystemSigningSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("new filesystem storage: %v", err)
+	}
+	mux.Handle(FilesystemRoutePrefix, fsStorage.Handler())
+	return fsStorage, srv
+}
+
+func put(t *testing.T, rawURL string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, rawURL, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("build put: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestFilesystemStoragePresignedRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fsStorage, _ := newTestFilesystemStorage(t)
+	if err := fsStorage.EnsureBucket(ctx); err != nil {
+		t.Fatalf("ensure bucket: %v", err)
+	}
+
+	putURL, err := fsStorage.PresignedPutURL(ctx, "uploads/job-1/source", time.Minute)
+	if err != nil {
+		t.Fatalf("presign put: %v", err)
+	}
+	if resp := put(t, putURL+"x", []byte("tampered")); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected tampered signature to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := put(t, putURL, []byte("source-bytes")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d", resp.StatusCode)
+	}
+
+	if exists, err := fsStorage.ObjectExists(ctx, "uploads/job-1/source"); err != nil || !exists {
+		t.Fatalf("expected uploaded object to exist, got exists=%v err=%v", exists, err)
+	}
+	if head, err := fsStorage.ReadObjectHead(ctx, "uploads/job-1/source", 6); err != nil || string(head) != "source" {
+		t.Fatalf("unexpected head %q err=%v", head, err)
+	}
+
+	if err := fsStorage.WriteObject(ctx, "outputs/job-1/thumb.png", []byte("output"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	getURL, err := fsStorage.PresignedGetURL(ctx, "outputs/job-1/thumb.png", time.Minute)
+	if err != nil {
+		t.Fatalf("presign get: %v", err)
+	}
+	resp, err := http.Get(getURL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "output" {
+		t.Fatalf("unexpected download status=%d body=%q", resp.StatusCode, body)
+	}
+
+	fsStorage.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if resp := put(t, putURL, []byte("late")); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected expired url to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestFilesystemStorageMultipartAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	fsStorage, _ := newTestFilesystemStorage(t)
+
+	uploadID, err := fsStorage.CreateMultipartUpload(ctx, "uploads/job-2/source", "application/octet-stream")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	for part, data := range map[int]string{2: "world", 1: "hello "} {
+		partURL, err := fsStorage.PresignedUploadPart(ctx, "uploads/job-2/source", uploadID, part, time.Minute)
+		if err != nil {
+			t.Fatalf("presign part: %v", err)
+		}
+		if resp := put(t, partURL, []byte(data)); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+			t.Fatalf("part %d upload failed: status=%d", part, resp.StatusCode)
+		}
+	}
+	if err := fsStorage.CompleteMultipartUpload(ctx, "uploads/job-2/source", uploadID, []CompletedPart{{PartNumber: 2}, {PartNumber: 1}}); err != nil {
+		t.Fatalf("complete multipart: %v", err)
+	}
+	if data, err := fsStorage.ReadObject(ctx, "uploads/job-2/source"); err != nil || string(data) != "hello world" {
+		t.Fatalf("unexpected assembled object %q err=%v", data, err)
+	}
+
+	abandoned, err := fsStorage.CreateMultipartUpload(ctx, "uploads/job-3/source", "application/octet-stream")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	if err := fsStorage.AbortMultipartUpload(ctx, "uploads/job-3/source", abandoned); err != nil {
+		t.Fatalf("abort multipart: %v", err)
+	}
+	if _, err := os.Stat(fsStorage.partsDir(abandoned)); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected aborted parts to be removed, got %v", err)
+	}
+
+	if err := fsStorage.WriteObject(ctx, "outputs/job-2/a.png", []byte("a"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	p, _ := fsStorage.objectPath("outputs/job-2/a.png")
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := fsStorage.WriteObject(ctx, "outputs/job-2/b.png", []byte("b"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	if n, err := fsStorage.PruneExpired(ctx, "outputs/", time.Now().Add(-time.Minute)); err != nil || n != 1 {
+		t.Fatalf("expected one pruned object, got %d err=%v", n, err)
+	}
+	if n, err := fsStorage.DeletePrefix(ctx, "outputs/job-2/"); err != nil || n != 1 {
+		t.Fatalf("expected one deleted object, got %d err=%v", n, err)
+	}
+
+	if _, err := fsStorage.ObjectExists(ctx, ".multipart/x"); err == nil {
+		t.Fatal("expected internal multipart keys to be rejected")
+	}
+	if p, err := fsStorage.objectPath("../../etc/passwd"); err != nil || p != filepath.Join(fsStorage.root, "etc", "passwd") {
+		t.Fatalf("expected traversal to stay under root, got %q err=%v", p, err)
+	}
+}
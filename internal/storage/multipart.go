@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (c *Client) CreateMultipartUpload(ctx context.Context, objectKey, contentType string) (string, error) {
+	uploadID, err := c.core().NewMultipartUpload(ctx, c.bucket, objectKey, minio.PutObjectOptions{ContentType: contentType, ServerSideEncryption: c.sse})
+	if err != nil {
+		return "", fmt.Errorf("create multipart upload %s: %w", objectKey, err)
+	}
+	return uploadID, nil
+}
+
+func (c *Client) PresignedUploadPart(ctx context.Context, objectKey, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+
+	u, err := c.minio.Presign(ctx, http.MethodPut, c.bucket, objectKey, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("presign upload part %d: %w", partNumber, err)
+	}
+	return u.String(), nil
+}
+
+func (c *Client) CompleteMultipartUpload(ctx context.Context, objectKey, uploadID string, parts []CompletedPart) error {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	sort.Slice(completeParts, func(i, j int) bool {
+		return completeParts[i].PartNumber < completeParts[j].PartNumber
+	})
+
+	if _, err := c.core().CompleteMultipartUpload(ctx, c.bucket, objectKey, uploadID, completeParts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("complete multipart upload %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+func (c *Client) AbortMultipartUpload(ctx context.Context, objectKey, uploadID string) error {
+	if err := c.core().AbortMultipartUpload(ctx, c.bucket, objectKey, uploadID); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+			return nil
+		}
+		return fmt.Errorf("abort multipart upload %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+func (c *Client) core() minio.Core {
+	return minio.Core{Client: c.minio}
+}
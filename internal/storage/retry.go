@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     *log.Logger
+}
+
+// do runs fn until it succeeds, fails with a non-transient error, or the
+// attempts are used up, sleeping with doubling backoff between tries.
+func (p retryPolicy) do(ctx context.Context, op, objectKey string, fn func() error) error {
+	backoff := p.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				p.logf("storage %s %s succeeded after retries=%d", op, objectKey, attempt-1)
+			}
+			return nil
+		}
+		if attempt >= p.attempts || !isTransient(err) {
+			if attempt > 1 {
+				p.logf("storage %s %s failed after retries=%d err=%v", op, objectKey, attempt-1, err)
+			}
+			return err
+		}
+		p.logf("storage %s %s transient failure attempt=%d/%d retry_in=%s err=%v", op, objectKey, attempt, p.attempts, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if p.maxBackoff > 0 && backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+func (p retryPolicy) logf(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.Printf(format, args...)
+	}
+}
+
+// isTransient reports whether err is worth retrying: server errors,
+// throttling, and dropped connections. Client errors such as 404 and 403, and
+// cancellation, are final.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if status := minio.ToErrorResponse(err).StatusCode; status != 0 {
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails the first failures requests with status (or err when
+// status is 0) and then answers every request with 200 OK.
+type flakyTransport struct {
+	failures int
+	status   int
+	err      error
+	calls    int
+	methods  []string
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	f.methods = append(f.methods, req.Method)
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	header := http.Header{}
+	if f.calls <= f.failures {
+		if f.status == 0 {
+			return nil, f.err
+		}
+		header.Set("Content-Type", "application/xml")
+		body := "<Error><Code>InternalError</Code><Message>flaky</Message></Error>"
+		return &http.Response{StatusCode: f.status, Header: header, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	}
+	header.Set("ETag", `"etag"`)
+	header.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	header.Set("Content-Length", "4")
+	header.Set("Content-Type", "image/png")
+	return &http.Response{StatusCode: http.StatusOK, Header: header, ContentLength: 4, Body: io.NopCloser(bytes.NewReader([]byte("data"))), Request: req}, nil
+}
+
+func newFlakyClient(t *testing.T, transport *flakyTransport, logs *bytes.Buffer) *Client {
+	t.Helper()
+	client, err := newClient(Config{
+		Endpoint:      "localhost:9000",
+		Access:        "minioadmin",
+		Secret:        "minioadmin",
+		Bucket:        "pixelflow-jobs",
+		Region:        "us-east-1",
+		RetryAttempts: 3,
+		RetryBackoff:  time.Millisecond,
+		Logger:        log.New(logs, "", 0),
+	}, transport)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	var logs bytes.Buffer
+	transport := &flakyTransport{failures: 2, status: http.StatusServiceUnavailable}
+	client := newFlakyClient(t, transport, &logs)
+
+	exists, err := client.ObjectExists(context.Background(), "uploads/job-1/source")
+	if err != nil || !exists {
+		t.Fatalf("expected object after retries, got exists=%v err=%v", exists, err)
+	}
+	if transport.calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", transport.calls)
+	}
+	if !strings.Contains(logs.String(), "succeeded after retries=2") {
+		t.Fatalf("expected retry count in logs, got %q", logs.String())
+	}
+
+	transport = &flakyTransport{failures: 1, err: syscall.ECONNRESET}
+	client = newFlakyClient(t, transport, &logs)
+	if err := client.WriteObject(context.Background(), "outputs/job-1/thumb.png", []byte("data"), WriteOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("expected write to survive a connection reset: %v", err)
+	}
+	if transport.calls != 2 {
+		t.Fatalf("expected 2 requests, got %d", transport.calls)
+	}
+
+	transport = &flakyTransport{failures: 1, status: http.StatusInternalServerError}
+	client = newFlakyClient(t, transport, &logs)
+	data, err := client.ReadObject(context.Background(), "uploads/job-1/source")
+	if err != nil || string(data) != "data" {
+		t.Fatalf("expected read after retry, got %q err=%v", data, err)
+	}
+}
+
+func TestClientReadObjectStreamIssuesOneGet(t *testing.T) {
+	var logs bytes.Buffer
+	transport := &flakyTransport{}
+	client := newFlakyClient(t, transport, &logs)
+
+	body, err := client.ReadObjectStream(context.Background(), "uploads/job-1/source")
+	if err != nil {
+		t.Fatalf("read object stream: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil || string(data) != "data" {
+		t.Fatalf("expected object body, got %q err=%v", data, err)
+	}
+	if len(transport.methods) != 1 || transport.methods[0] != http.MethodGet {
+		t.Fatalf("expected a single GET, got %v", transport.methods)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		var logs bytes.Buffer
+		transport := &flakyTransport{failures: 5, status: status}
+		client := newFlakyClient(t, transport, &logs)
+
+		_, err := client.ObjectExists(context.Background(), "uploads/job-1/source")
+		if status == http.StatusForbidden && err == nil {
+			t.Fatal("expected forbidden stat to fail")
+		}
+		if transport.calls != 1 {
+			t.Fatalf("status %d: expected a single request, got %d", status, transport.calls)
+		}
+	}
+
+	var logs bytes.Buffer
+	transport := &flakyTransport{failures: 5, status: http.StatusBadGateway}
+	client := newFlakyClient(t, transport, &logs)
+	if _, err := client.ReadObject(context.Background(), "uploads/job-1/source"); err == nil {
+		t.Fatal("expected read to fail once attempts are exhausted")
+	}
+	if transport.calls != 3 {
+		t.Fatalf("expected attempts to stop at 3, got %d", transport.calls)
+	}
+	if isTransient(context.Canceled) {
+		t.Fatal("expected cancellation to be final")
+	}
+}
@@ -2,16 +2,111 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
 )
 
 type JobStore interface {
 	Create(ctx context.Context, job domain.Job) error
+	CreateBatch(ctx context.Context, jobs []domain.Job) error
 	Get(ctx context.Context, id string) (domain.Job, bool, error)
 	UpdateStatus(ctx context.Context, id, status string) (domain.Job, error)
+	// FinishProcessing records a job's final status, error message (empty on
+	// success) and processing time in one write. A cancelled job keeps its
+	// status and message: only another cancelled write applies, adding the
+	// processing time, and any other status gets ErrJobCancelled.
+	FinishProcessing(ctx context.Context, id, status, errMsg string, processingTime time.Duration) (domain.Job, error)
+	RecordFailure(ctx context.Context, id, errMsg string) error
+	// RecordOutputs replaces the output paths stored for a job.
+	RecordOutputs(ctx context.Context, id string, paths []string) error
+	// ClaimStart moves a created job to queued on queueName. Only one caller
+	// wins; the rest get ErrStartNotAllowed.
+	ClaimStart(ctx context.Context, id, queueName string) (domain.Job, error)
+	ClaimRetry(ctx context.Context, id string, maxRetries int) (domain.Job, error)
+	// ClaimCancel moves an unfinished job to cancelled with errMsg. A job
+	// that already reached a terminal status gets ErrCancelNotAllowed.
+	ClaimCancel(ctx context.Context, id, errMsg string) (domain.Job, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func nullableInt(v sql.NullInt32) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int32)
+	return &n
+}
+
+// marshalWebhookHeaders encodes a job's webhook headers for storage, always
+// as a JSON object so the column never holds null.
+func marshalWebhookHeaders(headers map[string]string) ([]byte, error) {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook headers: %w", err)
+	}
+	return data, nil
+}
+
+func unmarshalWebhookHeaders(data []byte) (map[string]string, error) {
+	var headers map[string]string
+	if err := json.Unmarshal(data, &headers); err != nil {
+		return nil, fmt.Errorf("unmarshal webhook headers: %w", err)
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	return headers, nil
+}
+
+// marshalOutputPaths encodes a job's output paths, always as a JSON array.
+func marshalOutputPaths(paths []string) ([]byte, error) {
+	if paths == nil {
+		paths = []string{}
+	}
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return nil, fmt.Errorf("marshal output paths: %w", err)
+	}
+	return data, nil
+}
+
+func unmarshalOutputPaths(data []byte) ([]string, error) {
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil, fmt.Errorf("unmarshal output paths: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return paths, nil
+}
+
+// WebhookDeliveryStore records the callback state of each (job, event) pair.
+type WebhookDeliveryStore interface {
+	// RecordWebhookDelivery upserts the row for delivery's job and event,
+	// adding delivery.Attempts to the stored count and keeping the first
+	// delivered_at.
+	RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	// ListWebhookDeliveries returns jobID's rows oldest update first; an empty
+	// result is a non-nil slice.
+	ListWebhookDeliveries(ctx context.Context, jobID string) ([]domain.WebhookDelivery, error)
 }
 
 type UsageStore interface {
 	CreateUsageLog(ctx context.Context, usage domain.UsageLog) error
+	Summary(ctx context.Context, userID string, from, to time.Time) (domain.UsageSummary, error)
+	// ListUsage returns a page of userID's usage rows in [from, to), newest
+	// first; zero times leave that side of the range open.
+	ListUsage(ctx context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error)
 }
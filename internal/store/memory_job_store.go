@@ -3,24 +3,34 @@ package store
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/dunamismax/pixelflow/internal/domain"
 )
 
-var ErrJobNotFound = errors.New("job not found")
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrRetryNotAllowed  = errors.New("job is not retryable")
+	ErrStartNotAllowed  = errors.New("job was already started")
+	ErrCancelNotAllowed = errors.New("job has already finished")
+	ErrJobCancelled     = errors.New("job was cancelled")
+)
 
 type MemoryJobStore struct {
 	mu        sync.RWMutex
 	jobs      map[string]domain.Job
 	usageLogs map[string]domain.UsageLog
+	webhooks  map[string]map[string]domain.WebhookDelivery
 }
 
 func NewMemoryJobStore() *MemoryJobStore {
 	return &MemoryJobStore{
 		jobs:      make(map[string]domain.Job),
 		usageLogs: make(map[string]domain.UsageLog),
+		webhooks:  make(map[string]map[string]domain.WebhookDelivery),
 	}
 }
 
@@ -31,6 +41,15 @@ func (s *MemoryJobStore) Create(_ context.Context, job domain.Job) error {
 	return nil
 }
 
+func (s *MemoryJobStore) CreateBatch(_ context.Context, jobs []domain.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+	return nil
+}
+
 func (s *MemoryJobStore) Get(_ context.Context, id string) (domain.Job, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -53,6 +72,172 @@ func (s *MemoryJobStore) UpdateStatus(_ context.Context, id, status string) (dom
 	return job, nil
 }
 
+func (s *MemoryJobStore) FinishProcessing(_ context.Context, id, status, errMsg string, processingTime time.Duration) (domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if job.Status == domain.JobStatusCancelled && status != domain.JobStatusCancelled {
+		return domain.Job{}, ErrJobCancelled
+	}
+
+	if job.Status != domain.JobStatusCancelled {
+		job.ErrorMessage = errMsg
+	}
+	job.Status = status
+	job.ProcessingTimeMS = processingTime.Milliseconds()
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return job, nil
+}
+
+func (s *MemoryJobStore) RecordOutputs(_ context.Context, id string, paths []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	job.OutputPaths = slices.Clone(paths)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return nil
+}
+
+func (s *MemoryJobStore) RecordFailure(_ context.Context, id, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	job.Status = domain.JobStatusFailed
+	job.ErrorMessage = errMsg
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return nil
+}
+
+func (s *MemoryJobStore) ClaimStart(_ context.Context, id, queueName string) (domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if job.Status != domain.JobStatusCreated {
+		return domain.Job{}, ErrStartNotAllowed
+	}
+
+	job.Status = domain.JobStatusQueued
+	job.Queue = queueName
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return job, nil
+}
+
+func (s *MemoryJobStore) ClaimRetry(_ context.Context, id string, maxRetries int) (domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if job.Status != domain.JobStatusFailed || job.RetryCount >= maxRetries {
+		return domain.Job{}, ErrRetryNotAllowed
+	}
+
+	job.Status = domain.JobStatusQueued
+	job.ErrorMessage = ""
+	job.RetryCount++
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return job, nil
+}
+
+func (s *MemoryJobStore) ClaimCancel(_ context.Context, id, errMsg string) (domain.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if domain.IsTerminalStatus(job.Status) {
+		return domain.Job{}, ErrCancelNotAllowed
+	}
+
+	job.Status = domain.JobStatusCancelled
+	job.ErrorMessage = errMsg
+	job.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = job
+	return job, nil
+}
+
+func (s *MemoryJobStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return ErrJobNotFound
+	}
+	delete(s.jobs, id)
+	delete(s.usageLogs, id)
+	delete(s.webhooks, id)
+	return nil
+}
+
+func (s *MemoryJobStore) RecordWebhookDelivery(_ context.Context, delivery domain.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[delivery.JobID]; !ok {
+		return ErrJobNotFound
+	}
+	if delivery.UpdatedAt.IsZero() {
+		delivery.UpdatedAt = time.Now().UTC()
+	}
+	events := s.webhooks[delivery.JobID]
+	if events == nil {
+		events = make(map[string]domain.WebhookDelivery)
+		s.webhooks[delivery.JobID] = events
+	}
+	if existing, ok := events[delivery.Event]; ok {
+		delivery.Attempts += existing.Attempts
+		if !existing.DeliveredAt.IsZero() {
+			delivery.DeliveredAt = existing.DeliveredAt
+		}
+	}
+	events[delivery.Event] = delivery
+	return nil
+}
+
+func (s *MemoryJobStore) ListWebhookDeliveries(_ context.Context, jobID string) ([]domain.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := make([]domain.WebhookDelivery, 0, len(s.webhooks[jobID]))
+	for _, delivery := range s.webhooks[jobID] {
+		deliveries = append(deliveries, delivery)
+	}
+	slices.SortFunc(deliveries, func(a, b domain.WebhookDelivery) int {
+		if c := a.UpdatedAt.Compare(b.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Event, b.Event)
+	})
+	return deliveries, nil
+}
+
 func (s *MemoryJobStore) CreateUsageLog(_ context.Context, usage domain.UsageLog) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -63,3 +248,67 @@ func (s *MemoryJobStore) CreateUsageLog(_ context.Context, usage domain.UsageLog
 	s.usageLogs[usage.JobID] = usage
 	return nil
 }
+
+func (s *MemoryJobStore) GetUsageLog(_ context.Context, jobID string) (domain.UsageLog, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	usage, ok := s.usageLogs[jobID]
+	return usage, ok, nil
+}
+
+func (s *MemoryJobStore) Summary(_ context.Context, userID string, from, to time.Time) (domain.UsageSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := domain.UsageSummary{UserID: userID}
+	for _, usage := range s.usageLogs {
+		if usage.UserID != userID {
+			continue
+		}
+		if !from.IsZero() && usage.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !usage.CreatedAt.Before(to) {
+			continue
+		}
+		summary.Jobs++
+		summary.PixelsProcessed += usage.PixelsProcessed
+		summary.BytesSaved += usage.BytesSaved
+		summary.ComputeTimeMS += usage.ComputeTimeMS
+	}
+	return summary, nil
+}
+
+func (s *MemoryJobStore) ListUsage(_ context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]domain.UsageLog, 0)
+	for _, usage := range s.usageLogs {
+		if usage.UserID != userID {
+			continue
+		}
+		if !from.IsZero() && usage.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !usage.CreatedAt.Before(to) {
+			continue
+		}
+		matched = append(matched, usage)
+	}
+	slices.SortFunc(matched, func(a, b domain.UsageLog) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.JobID, b.JobID)
+	})
+
+	if offset >= len(matched) {
+		return []domain.UsageLog{}, nil
+	}
+	matched = matched[offset:]
+	if limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched, nil
+}
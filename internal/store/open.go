@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+	"strings"
+)
+
+const sqliteDSNPrefix = "sqlite:"
+
+type Backend interface {
+	JobStore
+	UsageStore
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Open selects a backend from dsn: "sqlite:<path>" opens a SQLiteJobStore,
+// anything else is treated as a Postgres connection string.
+func Open(ctx context.Context, dsn string, pool PoolConfig) (Backend, error) {
+	if path, ok := strings.CutPrefix(dsn, sqliteDSNPrefix); ok {
+		return NewSQLiteJobStore(ctx, path)
+	}
+	return NewPostgresJobStore(ctx, dsn, pool)
+}
@@ -18,14 +18,70 @@ CREATE TABLE IF NOT EXISTS jobs (
 	status TEXT NOT NULL,
 	source_type TEXT NOT NULL,
 	webhook_url TEXT NOT NULL DEFAULT '',
+	webhook_headers JSONB NOT NULL DEFAULT '{}',
 	pipeline JSONB NOT NULL,
 	object_key TEXT NOT NULL,
+	deadline_seconds INTEGER NOT NULL DEFAULT 0,
+	emit_sidecar BOOLEAN NOT NULL DEFAULT FALSE,
+	emit_manifest BOOLEAN NOT NULL DEFAULT FALSE,
+	delete_source BOOLEAN NOT NULL DEFAULT FALSE,
+	output_subdir TEXT NOT NULL DEFAULT '',
+	output_paths JSONB NOT NULL DEFAULT '[]',
+	queue TEXT NOT NULL DEFAULT '',
+	frame_at_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+	processing_time_ms BIGINT NOT NULL DEFAULT 0,
+	error_message TEXT NOT NULL DEFAULT '',
+	retry_count INTEGER NOT NULL DEFAULT 0,
+	max_retry INTEGER,
+	timeout_seconds INTEGER NOT NULL DEFAULT 0,
 	created_at TIMESTAMPTZ NOT NULL,
 	updated_at TIMESTAMPTZ NOT NULL
 );
 
 ALTER TABLE jobs
 ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT 'anonymous';
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS deadline_seconds INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS processing_time_ms BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS emit_sidecar BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS delete_source BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS error_message TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS retry_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS max_retry INTEGER;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS timeout_seconds INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS webhook_headers JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS output_subdir TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS emit_manifest BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS frame_at_seconds DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS output_paths JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS queue TEXT NOT NULL DEFAULT '';
 `
 
 const usageLogSchemaSQL = `
@@ -42,15 +98,38 @@ CREATE INDEX IF NOT EXISTS usage_logs_user_id_created_at_idx
 ON usage_logs (user_id, created_at DESC);
 `
 
+const webhookDeliverySchemaSQL = `
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMPTZ NOT NULL,
+	delivered_at TIMESTAMPTZ,
+	PRIMARY KEY (job_id, event)
+);
+`
+
 type PostgresJobStore struct {
 	db *sql.DB
 }
 
-func NewPostgresJobStore(ctx context.Context, dsn string) (*PostgresJobStore, error) {
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func NewPostgresJobStore(ctx context.Context, dsn string, pool PoolConfig) (*PostgresJobStore, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open postgres connection: %w", err)
 	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 
 	if err := db.PingContext(ctx); err != nil {
 		_ = db.Close()
@@ -73,30 +152,72 @@ func (s *PostgresJobStore) EnsureSchema(ctx context.Context) error {
 	if _, err := s.db.ExecContext(ctx, usageLogSchemaSQL); err != nil {
 		return fmt.Errorf("ensure usage logs schema: %w", err)
 	}
+	if _, err := s.db.ExecContext(ctx, webhookDeliverySchemaSQL); err != nil {
+		return fmt.Errorf("ensure webhook deliveries schema: %w", err)
+	}
 	return nil
 }
 
+func (s *PostgresJobStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *PostgresJobStore) Close() error {
 	return s.db.Close()
 }
 
 func (s *PostgresJobStore) Create(ctx context.Context, job domain.Job) error {
+	return insertPostgresJob(ctx, s.db, job)
+}
+
+func (s *PostgresJobStore) CreateBatch(ctx context.Context, jobs []domain.Job) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin job batch: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, job := range jobs {
+		if err := insertPostgresJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit job batch: %w", err)
+	}
+	return nil
+}
+
+func insertPostgresJob(ctx context.Context, db execer, job domain.Job) error {
 	pipelineJSON, err := json.Marshal(job.Pipeline)
 	if err != nil {
 		return fmt.Errorf("marshal job pipeline: %w", err)
 	}
+	headersJSON, err := marshalWebhookHeaders(job.WebhookHeaders)
+	if err != nil {
+		return err
+	}
 
-	_, err = s.db.ExecContext(
+	_, err = db.ExecContext(
 		ctx,
-		`INSERT INTO jobs (id, user_id, status, source_type, webhook_url, pipeline, object_key, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		`INSERT INTO jobs (id, user_id, status, source_type, webhook_url, webhook_headers, pipeline, object_key, deadline_seconds, emit_sidecar, emit_manifest, delete_source, output_subdir, frame_at_seconds, max_retry, timeout_seconds, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
 		job.ID,
 		job.UserID,
 		job.Status,
 		job.SourceType,
 		job.WebhookURL,
+		headersJSON,
 		pipelineJSON,
 		job.ObjectKey,
+		job.DeadlineSeconds,
+		job.EmitSidecar,
+		job.EmitManifest,
+		job.DeleteSource,
+		job.OutputSubdir,
+		job.FrameAtSeconds,
+		job.MaxRetry,
+		job.TimeoutSeconds,
 		job.CreatedAt,
 		job.UpdatedAt,
 	)
@@ -110,7 +231,7 @@ func (s *PostgresJobStore) Create(ctx context.Context, job domain.Job) error {
 func (s *PostgresJobStore) Get(ctx context.Context, id string) (domain.Job, bool, error) {
 	row := s.db.QueryRowContext(
 		ctx,
-		`SELECT id, user_id, status, source_type, webhook_url, pipeline, object_key, created_at, updated_at
+		`SELECT id, user_id, status, source_type, webhook_url, webhook_headers, pipeline, object_key, deadline_seconds, emit_sidecar, emit_manifest, delete_source, output_subdir, output_paths, frame_at_seconds, processing_time_ms, error_message, retry_count, max_retry, timeout_seconds, queue, created_at, updated_at
 		 FROM jobs
 		 WHERE id = $1`,
 		id,
@@ -118,7 +239,10 @@ func (s *PostgresJobStore) Get(ctx context.Context, id string) (domain.Job, bool
 
 	var (
 		job          domain.Job
+		headersJSON  []byte
 		pipelineJSON []byte
+		outputsJSON  []byte
+		maxRetry     sql.NullInt32
 	)
 	if err := row.Scan(
 		&job.ID,
@@ -126,8 +250,22 @@ func (s *PostgresJobStore) Get(ctx context.Context, id string) (domain.Job, bool
 		&job.Status,
 		&job.SourceType,
 		&job.WebhookURL,
+		&headersJSON,
 		&pipelineJSON,
 		&job.ObjectKey,
+		&job.DeadlineSeconds,
+		&job.EmitSidecar,
+		&job.EmitManifest,
+		&job.DeleteSource,
+		&job.OutputSubdir,
+		&outputsJSON,
+		&job.FrameAtSeconds,
+		&job.ProcessingTimeMS,
+		&job.ErrorMessage,
+		&job.RetryCount,
+		&maxRetry,
+		&job.TimeoutSeconds,
+		&job.Queue,
 		&job.CreatedAt,
 		&job.UpdatedAt,
 	); err != nil {
@@ -140,6 +278,15 @@ func (s *PostgresJobStore) Get(ctx context.Context, id string) (domain.Job, bool
 	if err := json.Unmarshal(pipelineJSON, &job.Pipeline); err != nil {
 		return domain.Job{}, false, fmt.Errorf("unmarshal job pipeline: %w", err)
 	}
+	headers, err := unmarshalWebhookHeaders(headersJSON)
+	if err != nil {
+		return domain.Job{}, false, err
+	}
+	job.WebhookHeaders = headers
+	if job.OutputPaths, err = unmarshalOutputPaths(outputsJSON); err != nil {
+		return domain.Job{}, false, err
+	}
+	job.MaxRetry = nullableInt(maxRetry)
 
 	return job, true, nil
 }
@@ -170,6 +317,215 @@ func (s *PostgresJobStore) UpdateStatus(ctx context.Context, id, status string)
 	return job, nil
 }
 
+func (s *PostgresJobStore) FinishProcessing(ctx context.Context, id, status, errMsg string, processingTime time.Duration) (domain.Job, error) {
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = $1,
+		     error_message = CASE WHEN status = $5 THEN error_message ELSE $6 END,
+		     processing_time_ms = $2, updated_at = $3
+		 WHERE id = $4 AND (status <> $5 OR $1 = $5)`,
+		status,
+		processingTime.Milliseconds(),
+		now,
+		id,
+		domain.JobStatusCancelled,
+		errMsg,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("finish job processing: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("finish job processing rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrJobCancelled
+	}
+
+	return job, nil
+}
+
+func (s *PostgresJobStore) RecordOutputs(ctx context.Context, id string, paths []string) error {
+	outputsJSON, err := marshalOutputPaths(paths)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET output_paths = $1, updated_at = $2
+		 WHERE id = $3`,
+		outputsJSON,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("record job outputs: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("record job outputs rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *PostgresJobStore) RecordFailure(ctx context.Context, id, errMsg string) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = $1, error_message = $2, updated_at = $3
+		 WHERE id = $4`,
+		domain.JobStatusFailed,
+		errMsg,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("record job failure: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("record job failure rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *PostgresJobStore) ClaimStart(ctx context.Context, id, queueName string) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = $1, queue = $2, updated_at = $3
+		 WHERE id = $4 AND status = $5`,
+		domain.JobStatusQueued,
+		queueName,
+		time.Now().UTC(),
+		id,
+		domain.JobStatusCreated,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job start: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job start rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrStartNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *PostgresJobStore) ClaimCancel(ctx context.Context, id, errMsg string) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = $1, error_message = $2, updated_at = $3
+		 WHERE id = $4 AND status IN ($5, $6, $7, $8)`,
+		domain.JobStatusCancelled,
+		errMsg,
+		time.Now().UTC(),
+		id,
+		domain.JobStatusCreated,
+		domain.JobStatusQueued,
+		domain.JobStatusProcessing,
+		domain.JobStatusOrphaned,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job cancel: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job cancel rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrCancelNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *PostgresJobStore) ClaimRetry(ctx context.Context, id string, maxRetries int) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = $1, error_message = '', retry_count = retry_count + 1, updated_at = $2
+		 WHERE id = $3 AND status = $4 AND retry_count < $5`,
+		domain.JobStatusQueued,
+		time.Now().UTC(),
+		id,
+		domain.JobStatusFailed,
+		maxRetries,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job retry: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job retry rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrRetryNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *PostgresJobStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete job rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
 func (s *PostgresJobStore) CreateUsageLog(ctx context.Context, usage domain.UsageLog) error {
 	createdAt := usage.CreatedAt
 	if createdAt.IsZero() {
@@ -199,3 +555,178 @@ func (s *PostgresJobStore) CreateUsageLog(ctx context.Context, usage domain.Usag
 
 	return nil
 }
+
+func (s *PostgresJobStore) GetUsageLog(ctx context.Context, jobID string) (domain.UsageLog, bool, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT job_id, user_id, pixels_processed, bytes_saved, compute_time_ms, created_at
+		 FROM usage_logs
+		 WHERE job_id = $1`,
+		jobID,
+	)
+
+	var usage domain.UsageLog
+	if err := row.Scan(
+		&usage.JobID,
+		&usage.UserID,
+		&usage.PixelsProcessed,
+		&usage.BytesSaved,
+		&usage.ComputeTimeMS,
+		&usage.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.UsageLog{}, false, nil
+		}
+		return domain.UsageLog{}, false, fmt.Errorf("query usage log: %w", err)
+	}
+
+	return usage, true, nil
+}
+
+func (s *PostgresJobStore) Summary(ctx context.Context, userID string, from, to time.Time) (domain.UsageSummary, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), SUM(pixels_processed), SUM(bytes_saved), SUM(compute_time_ms)
+		 FROM usage_logs
+		 WHERE user_id = $1
+		   AND ($2::timestamptz IS NULL OR created_at >= $2)
+		   AND ($3::timestamptz IS NULL OR created_at < $3)
+		 GROUP BY user_id`,
+		userID,
+		nullTime(from),
+		nullTime(to),
+	)
+
+	summary := domain.UsageSummary{UserID: userID}
+	if err := row.Scan(
+		&summary.Jobs,
+		&summary.PixelsProcessed,
+		&summary.BytesSaved,
+		&summary.ComputeTimeMS,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return summary, nil
+		}
+		return domain.UsageSummary{}, fmt.Errorf("query usage summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (s *PostgresJobStore) ListUsage(ctx context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT job_id, user_id, pixels_processed, bytes_saved, compute_time_ms, created_at
+		 FROM usage_logs
+		 WHERE user_id = $1
+		   AND ($2::timestamptz IS NULL OR created_at >= $2)
+		   AND ($3::timestamptz IS NULL OR created_at < $3)
+		 ORDER BY created_at DESC, job_id
+		 LIMIT $4 OFFSET $5`,
+		userID,
+		nullTime(from),
+		nullTime(to),
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query usage logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := make([]domain.UsageLog, 0, limit)
+	for rows.Next() {
+		var usage domain.UsageLog
+		if err := rows.Scan(
+			&usage.JobID,
+			&usage.UserID,
+			&usage.PixelsProcessed,
+			&usage.BytesSaved,
+			&usage.ComputeTimeMS,
+			&usage.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan usage log: %w", err)
+		}
+		logs = append(logs, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate usage logs: %w", err)
+	}
+	return logs, nil
+}
+
+func (s *PostgresJobStore) RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	updatedAt := delivery.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now().UTC()
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (job_id, event, status, attempts, last_status_code, last_error, updated_at, delivered_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (job_id, event) DO UPDATE
+		 SET status = EXCLUDED.status,
+		     attempts = webhook_deliveries.attempts + EXCLUDED.attempts,
+		     last_status_code = EXCLUDED.last_status_code,
+		     last_error = EXCLUDED.last_error,
+		     updated_at = EXCLUDED.updated_at,
+		     delivered_at = COALESCE(webhook_deliveries.delivered_at, EXCLUDED.delivered_at)`,
+		delivery.JobID,
+		delivery.Event,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		updatedAt,
+		nullTime(delivery.DeliveredAt),
+	)
+	if err != nil {
+		return fmt.Errorf("record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresJobStore) ListWebhookDeliveries(ctx context.Context, jobID string) ([]domain.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT event, status, attempts, last_status_code, last_error, updated_at, delivered_at
+		 FROM webhook_deliveries
+		 WHERE job_id = $1
+		 ORDER BY updated_at, event`,
+		jobID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := domain.WebhookDelivery{JobID: jobID}
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(
+			&delivery.Event,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.UpdatedAt,
+			&deliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
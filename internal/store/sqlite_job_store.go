@@ -0,0 +1,752 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+const sqliteDriverName = "sqlite"
+
+const sqliteSchemaSQL = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL DEFAULT 'anonymous',
+	status TEXT NOT NULL,
+	source_type TEXT NOT NULL,
+	webhook_url TEXT NOT NULL DEFAULT '',
+	webhook_headers TEXT NOT NULL DEFAULT '{}',
+	pipeline TEXT NOT NULL,
+	object_key TEXT NOT NULL,
+	deadline_seconds INTEGER NOT NULL DEFAULT 0,
+	emit_sidecar INTEGER NOT NULL DEFAULT 0,
+	emit_manifest INTEGER NOT NULL DEFAULT 0,
+	delete_source INTEGER NOT NULL DEFAULT 0,
+	output_subdir TEXT NOT NULL DEFAULT '',
+	output_paths TEXT NOT NULL DEFAULT '[]',
+	queue TEXT NOT NULL DEFAULT '',
+	frame_at_seconds REAL NOT NULL DEFAULT 0,
+	processing_time_ms INTEGER NOT NULL DEFAULT 0,
+	error_message TEXT NOT NULL DEFAULT '',
+	retry_count INTEGER NOT NULL DEFAULT 0,
+	max_retry INTEGER,
+	timeout_seconds INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS usage_logs (
+	job_id TEXT PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	pixels_processed INTEGER NOT NULL,
+	bytes_saved INTEGER NOT NULL,
+	compute_time_ms INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS usage_logs_user_id_created_at_idx
+ON usage_logs (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at INTEGER NOT NULL,
+	delivered_at INTEGER,
+	PRIMARY KEY (job_id, event)
+);
+`
+
+// SQLiteJobStore keeps job and usage state in a single SQLite file. Timestamps
+// are stored as Unix nanoseconds so range queries compare integers.
+type SQLiteJobStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteJobStore(ctx context.Context, path string) (*SQLiteJobStore, error) {
+	db, err := sql.Open(sqliteDriverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY within
+	// the process and keeps per-connection pragmas in effect.
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteJobStore{db: db}
+	if err := store.EnsureSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *SQLiteJobStore) EnsureSchema(ctx context.Context) error {
+	for _, pragma := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA busy_timeout = 5000`,
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := s.db.ExecContext(ctx, pragma); err != nil {
+			return fmt.Errorf("configure sqlite: %w", err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, sqliteSchemaSQL); err != nil {
+		return fmt.Errorf("ensure sqlite schema: %w", err)
+	}
+	if err := s.ensureColumn(ctx, "jobs", "webhook_headers", `TEXT NOT NULL DEFAULT '{}'`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "output_subdir", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "emit_manifest", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "retry_count", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "max_retry", `INTEGER`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "timeout_seconds", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "frame_at_seconds", `REAL NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "jobs", "output_paths", `TEXT NOT NULL DEFAULT '[]'`); err != nil {
+		return err
+	}
+	return s.ensureColumn(ctx, "jobs", "queue", `TEXT NOT NULL DEFAULT ''`)
+}
+
+// ensureColumn adds a column missing from a database created by an older
+// schema; SQLite has no ADD COLUMN IF NOT EXISTS.
+func (s *SQLiteJobStore) ensureColumn(ctx context.Context, table, column, definition string) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("inspect sqlite table %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("inspect sqlite table %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspect sqlite table %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("add sqlite column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+func (s *SQLiteJobStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteJobStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteJobStore) Create(ctx context.Context, job domain.Job) error {
+	return insertSQLiteJob(ctx, s.db, job)
+}
+
+func (s *SQLiteJobStore) CreateBatch(ctx context.Context, jobs []domain.Job) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin job batch: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, job := range jobs {
+		if err := insertSQLiteJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit job batch: %w", err)
+	}
+	return nil
+}
+
+func insertSQLiteJob(ctx context.Context, db execer, job domain.Job) error {
+	pipelineJSON, err := json.Marshal(job.Pipeline)
+	if err != nil {
+		return fmt.Errorf("marshal job pipeline: %w", err)
+	}
+	headersJSON, err := marshalWebhookHeaders(job.WebhookHeaders)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO jobs (id, user_id, status, source_type, webhook_url, webhook_headers, pipeline, object_key, deadline_seconds, emit_sidecar, emit_manifest, delete_source, output_subdir, frame_at_seconds, error_message, max_retry, timeout_seconds, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID,
+		job.UserID,
+		job.Status,
+		job.SourceType,
+		job.WebhookURL,
+		string(headersJSON),
+		string(pipelineJSON),
+		job.ObjectKey,
+		job.DeadlineSeconds,
+		job.EmitSidecar,
+		job.EmitManifest,
+		job.DeleteSource,
+		job.OutputSubdir,
+		job.FrameAtSeconds,
+		job.ErrorMessage,
+		job.MaxRetry,
+		job.TimeoutSeconds,
+		unixNano(job.CreatedAt),
+		unixNano(job.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("insert job: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteJobStore) Get(ctx context.Context, id string) (domain.Job, bool, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, status, source_type, webhook_url, webhook_headers, pipeline, object_key, deadline_seconds, emit_sidecar, emit_manifest, delete_source, output_subdir, output_paths, frame_at_seconds, processing_time_ms, error_message, retry_count, max_retry, timeout_seconds, queue, created_at, updated_at
+		 FROM jobs
+		 WHERE id = ?`,
+		id,
+	)
+
+	var (
+		job          domain.Job
+		headersJSON  string
+		pipelineJSON string
+		outputsJSON  string
+		maxRetry     sql.NullInt32
+		createdAt    int64
+		updatedAt    int64
+	)
+	if err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.SourceType,
+		&job.WebhookURL,
+		&headersJSON,
+		&pipelineJSON,
+		&job.ObjectKey,
+		&job.DeadlineSeconds,
+		&job.EmitSidecar,
+		&job.EmitManifest,
+		&job.DeleteSource,
+		&job.OutputSubdir,
+		&outputsJSON,
+		&job.FrameAtSeconds,
+		&job.ProcessingTimeMS,
+		&job.ErrorMessage,
+		&job.RetryCount,
+		&maxRetry,
+		&job.TimeoutSeconds,
+		&job.Queue,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Job{}, false, nil
+		}
+		return domain.Job{}, false, fmt.Errorf("query job: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(pipelineJSON), &job.Pipeline); err != nil {
+		return domain.Job{}, false, fmt.Errorf("unmarshal job pipeline: %w", err)
+	}
+	headers, err := unmarshalWebhookHeaders([]byte(headersJSON))
+	if err != nil {
+		return domain.Job{}, false, err
+	}
+	job.WebhookHeaders = headers
+	if job.OutputPaths, err = unmarshalOutputPaths([]byte(outputsJSON)); err != nil {
+		return domain.Job{}, false, err
+	}
+	job.MaxRetry = nullableInt(maxRetry)
+	job.CreatedAt = fromUnixNano(createdAt)
+	job.UpdatedAt = fromUnixNano(updatedAt)
+
+	return job, true, nil
+}
+
+func (s *SQLiteJobStore) UpdateStatus(ctx context.Context, id, status string) (domain.Job, error) {
+	return s.updateAndGet(ctx, id, "update job status",
+		`UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?`,
+		status, unixNano(time.Now().UTC()), id,
+	)
+}
+
+func (s *SQLiteJobStore) FinishProcessing(ctx context.Context, id, status, errMsg string, processingTime time.Duration) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = ?,
+		     error_message = CASE WHEN status = ? THEN error_message ELSE ? END,
+		     processing_time_ms = ?, updated_at = ?
+		 WHERE id = ? AND (status <> ? OR ? = ?)`,
+		status,
+		domain.JobStatusCancelled,
+		errMsg,
+		processingTime.Milliseconds(),
+		unixNano(time.Now().UTC()),
+		id,
+		domain.JobStatusCancelled,
+		status,
+		domain.JobStatusCancelled,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("finish job processing: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("finish job processing rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrJobCancelled
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteJobStore) updateAndGet(ctx context.Context, id, op, query string, args ...any) (domain.Job, error) {
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return domain.Job{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteJobStore) RecordOutputs(ctx context.Context, id string, paths []string) error {
+	outputsJSON, err := marshalOutputPaths(paths)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs SET output_paths = ?, updated_at = ? WHERE id = ?`,
+		string(outputsJSON),
+		unixNano(time.Now().UTC()),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("record job outputs: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("record job outputs rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteJobStore) RecordFailure(ctx context.Context, id, errMsg string) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs SET status = ?, error_message = ?, updated_at = ? WHERE id = ?`,
+		domain.JobStatusFailed,
+		errMsg,
+		unixNano(time.Now().UTC()),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("record job failure: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("record job failure rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteJobStore) ClaimStart(ctx context.Context, id, queueName string) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = ?, queue = ?, updated_at = ?
+		 WHERE id = ? AND status = ?`,
+		domain.JobStatusQueued,
+		queueName,
+		unixNano(time.Now().UTC()),
+		id,
+		domain.JobStatusCreated,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job start: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job start rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrStartNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteJobStore) ClaimCancel(ctx context.Context, id, errMsg string) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = ?, error_message = ?, updated_at = ?
+		 WHERE id = ? AND status IN (?, ?, ?, ?)`,
+		domain.JobStatusCancelled,
+		errMsg,
+		unixNano(time.Now().UTC()),
+		id,
+		domain.JobStatusCreated,
+		domain.JobStatusQueued,
+		domain.JobStatusProcessing,
+		domain.JobStatusOrphaned,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job cancel: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job cancel rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrCancelNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteJobStore) ClaimRetry(ctx context.Context, id string, maxRetries int) (domain.Job, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE jobs
+		 SET status = ?, error_message = '', retry_count = retry_count + 1, updated_at = ?
+		 WHERE id = ? AND status = ? AND retry_count < ?`,
+		domain.JobStatusQueued,
+		unixNano(time.Now().UTC()),
+		id,
+		domain.JobStatusFailed,
+		maxRetries,
+	)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job retry: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("claim job retry rows affected: %w", err)
+	}
+
+	job, ok, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	if !ok {
+		return domain.Job{}, ErrJobNotFound
+	}
+	if affected == 0 {
+		return domain.Job{}, ErrRetryNotAllowed
+	}
+
+	return job, nil
+}
+
+func (s *SQLiteJobStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete job rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteJobStore) CreateUsageLog(ctx context.Context, usage domain.UsageLog) error {
+	createdAt := usage.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO usage_logs (job_id, user_id, pixels_processed, bytes_saved, compute_time_ms, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (job_id) DO UPDATE
+		 SET user_id = excluded.user_id,
+		     pixels_processed = excluded.pixels_processed,
+		     bytes_saved = excluded.bytes_saved,
+		     compute_time_ms = excluded.compute_time_ms,
+		     created_at = excluded.created_at`,
+		usage.JobID,
+		usage.UserID,
+		usage.PixelsProcessed,
+		usage.BytesSaved,
+		usage.ComputeTimeMS,
+		unixNano(createdAt),
+	)
+	if err != nil {
+		return fmt.Errorf("insert usage log: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteJobStore) GetUsageLog(ctx context.Context, jobID string) (domain.UsageLog, bool, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT job_id, user_id, pixels_processed, bytes_saved, compute_time_ms, created_at
+		 FROM usage_logs
+		 WHERE job_id = ?`,
+		jobID,
+	)
+
+	var (
+		usage     domain.UsageLog
+		createdAt int64
+	)
+	if err := row.Scan(
+		&usage.JobID,
+		&usage.UserID,
+		&usage.PixelsProcessed,
+		&usage.BytesSaved,
+		&usage.ComputeTimeMS,
+		&createdAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.UsageLog{}, false, nil
+		}
+		return domain.UsageLog{}, false, fmt.Errorf("query usage log: %w", err)
+	}
+	usage.CreatedAt = fromUnixNano(createdAt)
+
+	return usage, true, nil
+}
+
+func (s *SQLiteJobStore) Summary(ctx context.Context, userID string, from, to time.Time) (domain.UsageSummary, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), COALESCE(SUM(pixels_processed), 0), COALESCE(SUM(bytes_saved), 0), COALESCE(SUM(compute_time_ms), 0)
+		 FROM usage_logs
+		 WHERE user_id = ?1
+		   AND (?2 IS NULL OR created_at >= ?2)
+		   AND (?3 IS NULL OR created_at < ?3)`,
+		userID,
+		nullUnixNano(from),
+		nullUnixNano(to),
+	)
+
+	summary := domain.UsageSummary{UserID: userID}
+	if err := row.Scan(
+		&summary.Jobs,
+		&summary.PixelsProcessed,
+		&summary.BytesSaved,
+		&summary.ComputeTimeMS,
+	); err != nil {
+		return domain.UsageSummary{}, fmt.Errorf("query usage summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (s *SQLiteJobStore) ListUsage(ctx context.Context, userID string, from, to time.Time, limit, offset int) ([]domain.UsageLog, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT job_id, user_id, pixels_processed, bytes_saved, compute_time_ms, created_at
+		 FROM usage_logs
+		 WHERE user_id = ?1
+		   AND (?2 IS NULL OR created_at >= ?2)
+		   AND (?3 IS NULL OR created_at < ?3)
+		 ORDER BY created_at DESC, job_id
+		 LIMIT ?4 OFFSET ?5`,
+		userID,
+		nullUnixNano(from),
+		nullUnixNano(to),
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query usage logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := make([]domain.UsageLog, 0, limit)
+	for rows.Next() {
+		var (
+			usage     domain.UsageLog
+			createdAt int64
+		)
+		if err := rows.Scan(
+			&usage.JobID,
+			&usage.UserID,
+			&usage.PixelsProcessed,
+			&usage.BytesSaved,
+			&usage.ComputeTimeMS,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan usage log: %w", err)
+		}
+		usage.CreatedAt = fromUnixNano(createdAt)
+		logs = append(logs, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate usage logs: %w", err)
+	}
+	return logs, nil
+}
+
+func (s *SQLiteJobStore) RecordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	updatedAt := delivery.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now().UTC()
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (job_id, event, status, attempts, last_status_code, last_error, updated_at, delivered_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (job_id, event) DO UPDATE
+		 SET status = excluded.status,
+		     attempts = webhook_deliveries.attempts + excluded.attempts,
+		     last_status_code = excluded.last_status_code,
+		     last_error = excluded.last_error,
+		     updated_at = excluded.updated_at,
+		     delivered_at = COALESCE(webhook_deliveries.delivered_at, excluded.delivered_at)`,
+		delivery.JobID,
+		delivery.Event,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		unixNano(updatedAt),
+		nullUnixNano(delivery.DeliveredAt),
+	)
+	if err != nil {
+		return fmt.Errorf("record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteJobStore) ListWebhookDeliveries(ctx context.Context, jobID string) ([]domain.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT event, status, attempts, last_status_code, last_error, updated_at, delivered_at
+		 FROM webhook_deliveries
+		 WHERE job_id = ?
+		 ORDER BY updated_at, event`,
+		jobID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var (
+			delivery    = domain.WebhookDelivery{JobID: jobID}
+			updatedAt   int64
+			deliveredAt sql.NullInt64
+		)
+		if err := rows.Scan(
+			&delivery.Event,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&updatedAt,
+			&deliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		delivery.UpdatedAt = fromUnixNano(updatedAt)
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = fromUnixNano(deliveredAt.Int64)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+func nullUnixNano(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+func TestSQLiteJobStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T) contractStore {
+		s, err := NewSQLiteJobStore(context.Background(), filepath.Join(t.TempDir(), "pixelflow.db"))
+		if err != nil {
+			t.Fatalf("open sqlite store: %v", err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	})
+}
+
+// sqliteInitialSchemaSQL is the jobs table as the first SQLite release created
+// it, before any column was added.
+const sqliteInitialSchemaSQL = `
+CREATE TABLE jobs (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL DEFAULT 'anonymous',
+	status TEXT NOT NULL,
+	source_type TEXT NOT NULL,
+	webhook_url TEXT NOT NULL DEFAULT '',
+	pipeline TEXT NOT NULL,
+	object_key TEXT NOT NULL,
+	deadline_seconds INTEGER NOT NULL DEFAULT 0,
+	emit_sidecar INTEGER NOT NULL DEFAULT 0,
+	delete_source INTEGER NOT NULL DEFAULT 0,
+	processing_time_ms INTEGER NOT NULL DEFAULT 0,
+	error_message TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+INSERT INTO jobs (id, status, source_type, pipeline, object_key, created_at, updated_at)
+VALUES ('job-legacy', 'failed', 's3_presigned', '[]', 'uploads/job-legacy/source', 1, 1);
+`
+
+func TestSQLiteJobStoreUpgradesInitialSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pixelflow.db")
+
+	legacy, err := sql.Open(sqliteDriverName, path)
+	if err != nil {
+		t.Fatalf("open legacy database: %v", err)
+	}
+	if _, err := legacy.ExecContext(ctx, sqliteInitialSchemaSQL); err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("close legacy database: %v", err)
+	}
+
+	s, err := NewSQLiteJobStore(ctx, path)
+	if err != nil {
+		t.Fatalf("open sqlite store over legacy schema: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	job, ok, err := s.Get(ctx, "job-legacy")
+	if err != nil || !ok {
+		t.Fatalf("get legacy job after upgrade: ok=%v err=%v", ok, err)
+	}
+	if job.RetryCount != 0 || job.MaxRetry != nil || job.TimeoutSeconds != 0 {
+		t.Fatalf("expected added columns to default to zero values, got %+v", job)
+	}
+
+	retried, err := s.ClaimRetry(ctx, "job-legacy", 3)
+	if err != nil {
+		t.Fatalf("claim retry on legacy job: %v", err)
+	}
+	if retried.Status != domain.JobStatusQueued || retried.RetryCount != 1 {
+		t.Fatalf("expected queued retry 1, got status=%s retry_count=%d", retried.Status, retried.RetryCount)
+	}
+
+	maxRetry := 2
+	if err := s.Create(ctx, domain.Job{
+		ID:             "job-new",
+		UserID:         "user-1",
+		Status:         domain.JobStatusCreated,
+		SourceType:     domain.SourceTypeS3Presigned,
+		ObjectKey:      "uploads/job-new/source",
+		MaxRetry:       &maxRetry,
+		TimeoutSeconds: 90,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create job on upgraded schema: %v", err)
+	}
+	created, ok, err := s.Get(ctx, "job-new")
+	if err != nil || !ok {
+		t.Fatalf("get new job: ok=%v err=%v", ok, err)
+	}
+	if created.MaxRetry == nil || *created.MaxRetry != 2 || created.TimeoutSeconds != 90 {
+		t.Fatalf("expected max_retry=2 timeout_seconds=90, got %v/%d", created.MaxRetry, created.TimeoutSeconds)
+	}
+}
@@ -0,0 +1,354 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/pixelflow/internal/domain"
+)
+
+type contractStore interface {
+	JobStore
+	UsageStore
+	WebhookDeliveryStore
+}
+
+func TestMemoryJobStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T) contractStore { return NewMemoryJobStore() })
+}
+
+func runStoreContract(t *testing.T, newStore func(t *testing.T) contractStore) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	maxRetry := 0
+	seed := domain.Job{
+		ID:              "job-1",
+		UserID:          "user-1",
+		Status:          domain.JobStatusCreated,
+		SourceType:      domain.SourceTypeS3Presigned,
+		WebhookURL:      "http://hooks.local",
+		WebhookHeaders:  map[string]string{"Authorization": "Bearer receiver-token"},
+		ObjectKey:       "uploads/job-1/source",
+		DeadlineSeconds: 30,
+		MaxRetry:        &maxRetry,
+		TimeoutSeconds:  600,
+		EmitSidecar:     true,
+		EmitManifest:    true,
+		OutputSubdir:    "batch-1",
+		Pipeline:        []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		job, ok, err := s.Get(ctx, "job-1")
+		if err != nil || !ok {
+			t.Fatalf("get: ok=%v err=%v", ok, err)
+		}
+		if job.UserID != "user-1" || job.ObjectKey != seed.ObjectKey || job.DeadlineSeconds != 30 || !job.EmitSidecar || !job.EmitManifest || job.OutputSubdir != "batch-1" {
+			t.Fatalf("unexpected job fields: %+v", job)
+		}
+		if job.MaxRetry == nil || *job.MaxRetry != 0 || job.TimeoutSeconds != 600 {
+			t.Fatalf("unexpected retry/timeout overrides: max_retry=%v timeout_seconds=%d", job.MaxRetry, job.TimeoutSeconds)
+		}
+		if len(job.Pipeline) != 1 || job.Pipeline[0].Width != 100 {
+			t.Fatalf("unexpected pipeline: %+v", job.Pipeline)
+		}
+		if job.WebhookHeaders["Authorization"] != "Bearer receiver-token" {
+			t.Fatalf("unexpected webhook headers: %+v", job.WebhookHeaders)
+		}
+		if !job.CreatedAt.Equal(createdAt) {
+			t.Fatalf("created_at = %v, want %v", job.CreatedAt, createdAt)
+		}
+
+		if _, ok, err := s.Get(ctx, "missing"); err != nil || ok {
+			t.Fatalf("expected missing job, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("status transitions", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		job, err := s.UpdateStatus(ctx, "job-1", domain.JobStatusProcessing)
+		if err != nil || job.Status != domain.JobStatusProcessing {
+			t.Fatalf("update status: status=%s err=%v", job.Status, err)
+		}
+		job, err = s.FinishProcessing(ctx, "job-1", domain.JobStatusFailed, "decode failed", 1500*time.Millisecond)
+		if err != nil || job.Status != domain.JobStatusFailed || job.ErrorMessage != "decode failed" || job.ProcessingTimeMS != 1500 {
+			t.Fatalf("finish processing: status=%s error_message=%q processing_time_ms=%d err=%v", job.Status, job.ErrorMessage, job.ProcessingTimeMS, err)
+		}
+		job, err = s.FinishProcessing(ctx, "job-1", domain.JobStatusSucceeded, "", time.Second)
+		if err != nil || job.Status != domain.JobStatusSucceeded || job.ErrorMessage != "" {
+			t.Fatalf("expected a later success to clear the error: status=%s error_message=%q err=%v", job.Status, job.ErrorMessage, err)
+		}
+
+		if _, err := s.UpdateStatus(ctx, "missing", domain.JobStatusQueued); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+		if err := s.RecordFailure(ctx, "missing", "boom"); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("record outputs", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		job, _, err := s.Get(ctx, "job-1")
+		if err != nil || job.OutputPaths != nil {
+			t.Fatalf("expected no output paths before the job ran, got %v (err=%v)", job.OutputPaths, err)
+		}
+
+		paths := []string{"outputs/job-1/thumb.png", "outputs/job-1/thumb.png.json"}
+		if err := s.RecordOutputs(ctx, "job-1", paths); err != nil {
+			t.Fatalf("record outputs: %v", err)
+		}
+		job, _, err = s.Get(ctx, "job-1")
+		if err != nil || len(job.OutputPaths) != 2 || job.OutputPaths[1] != paths[1] {
+			t.Fatalf("unexpected output paths %v (err=%v)", job.OutputPaths, err)
+		}
+		if err := s.RecordOutputs(ctx, "missing", paths); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("create batch", func(t *testing.T) {
+		s := newStore(t)
+		second := seed
+		second.ID = "job-2"
+		if err := s.CreateBatch(ctx, []domain.Job{seed, second}); err != nil {
+			t.Fatalf("create batch: %v", err)
+		}
+		for _, id := range []string{"job-1", "job-2"} {
+			if _, ok, err := s.Get(ctx, id); err != nil || !ok {
+				t.Fatalf("get %s: ok=%v err=%v", id, ok, err)
+			}
+		}
+	})
+
+	t.Run("claim start", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		job, err := s.ClaimStart(ctx, "job-1", "critical")
+		if err != nil {
+			t.Fatalf("claim start: %v", err)
+		}
+		if job.Status != domain.JobStatusQueued || job.Queue != "critical" {
+			t.Fatalf("unexpected claimed job: status=%s queue=%q", job.Status, job.Queue)
+		}
+		if _, err := s.ClaimStart(ctx, "job-1", "default"); !errors.Is(err, ErrStartNotAllowed) {
+			t.Fatalf("expected ErrStartNotAllowed for a started job, got %v", err)
+		}
+		job, _, err = s.Get(ctx, "job-1")
+		if err != nil || job.Queue != "critical" {
+			t.Fatalf("expected the first claim's queue to stick, got %q (err=%v)", job.Queue, err)
+		}
+		if _, err := s.ClaimStart(ctx, "missing", ""); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("claim cancel", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if _, err := s.UpdateStatus(ctx, "job-1", domain.JobStatusProcessing); err != nil {
+			t.Fatalf("update status: %v", err)
+		}
+
+		job, err := s.ClaimCancel(ctx, "job-1", "cancelled by request")
+		if err != nil {
+			t.Fatalf("claim cancel: %v", err)
+		}
+		if job.Status != domain.JobStatusCancelled || job.ErrorMessage != "cancelled by request" {
+			t.Fatalf("unexpected cancelled job: status=%s error_message=%q", job.Status, job.ErrorMessage)
+		}
+		if _, err := s.ClaimCancel(ctx, "job-1", "again"); !errors.Is(err, ErrCancelNotAllowed) {
+			t.Fatalf("expected ErrCancelNotAllowed for a cancelled job, got %v", err)
+		}
+		if _, err := s.FinishProcessing(ctx, "job-1", domain.JobStatusSucceeded, "", time.Second); !errors.Is(err, ErrJobCancelled) {
+			t.Fatalf("expected ErrJobCancelled finishing a cancelled job, got %v", err)
+		}
+		job, err = s.FinishProcessing(ctx, "job-1", domain.JobStatusCancelled, "", 2*time.Second)
+		if err != nil {
+			t.Fatalf("finish cancelled job: %v", err)
+		}
+		if job.Status != domain.JobStatusCancelled || job.ProcessingTimeMS != 2000 || job.ErrorMessage != "cancelled by request" {
+			t.Fatalf("unexpected finished job: status=%s processing_time_ms=%d error_message=%q", job.Status, job.ProcessingTimeMS, job.ErrorMessage)
+		}
+
+		if _, err := s.ClaimCancel(ctx, "missing", ""); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+		if _, err := s.FinishProcessing(ctx, "missing", domain.JobStatusSucceeded, "", 0); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound finishing a missing job, got %v", err)
+		}
+	})
+
+	t.Run("claim retry", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if _, err := s.ClaimRetry(ctx, "job-1", 1); !errors.Is(err, ErrRetryNotAllowed) {
+			t.Fatalf("expected ErrRetryNotAllowed for non-failed job, got %v", err)
+		}
+		if err := s.RecordFailure(ctx, "job-1", "boom"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+
+		job, err := s.ClaimRetry(ctx, "job-1", 1)
+		if err != nil {
+			t.Fatalf("claim retry: %v", err)
+		}
+		if job.Status != domain.JobStatusQueued || job.RetryCount != 1 || job.ErrorMessage != "" {
+			t.Fatalf("unexpected claimed job: status=%s retry_count=%d error_message=%q", job.Status, job.RetryCount, job.ErrorMessage)
+		}
+
+		if err := s.RecordFailure(ctx, "job-1", "boom"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+		if _, err := s.ClaimRetry(ctx, "job-1", 1); !errors.Is(err, ErrRetryNotAllowed) {
+			t.Fatalf("expected ErrRetryNotAllowed at the retry cap, got %v", err)
+		}
+		if _, err := s.ClaimRetry(ctx, "missing", 1); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := s.Delete(ctx, "job-1"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if err := s.Delete(ctx, "job-1"); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("expected ErrJobNotFound on second delete, got %v", err)
+		}
+	})
+
+	t.Run("webhook deliveries", func(t *testing.T) {
+		s := newStore(t)
+		if err := s.Create(ctx, seed); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		empty, err := s.ListWebhookDeliveries(ctx, "job-1")
+		if err != nil || empty == nil || len(empty) != 0 {
+			t.Fatalf("expected empty non-nil deliveries, got %v err=%v", empty, err)
+		}
+
+		deliveredAt := createdAt.Add(2 * time.Minute)
+		for _, delivery := range []domain.WebhookDelivery{
+			{JobID: "job-1", Event: "job.processing", Status: domain.WebhookDeliveryDelivered, Attempts: 1, LastStatusCode: 200, UpdatedAt: createdAt, DeliveredAt: createdAt},
+			{JobID: "job-1", Event: "job.completed", Status: domain.WebhookDeliveryPending, UpdatedAt: createdAt.Add(time.Minute)},
+			{JobID: "job-1", Event: "job.completed", Status: domain.WebhookDeliveryPending, Attempts: 3, LastStatusCode: 502, LastError: "webhook returned status=502", UpdatedAt: createdAt.Add(time.Minute)},
+			{JobID: "job-1", Event: "job.completed", Status: domain.WebhookDeliveryDelivered, Attempts: 1, LastStatusCode: 200, UpdatedAt: deliveredAt, DeliveredAt: deliveredAt},
+		} {
+			if err := s.RecordWebhookDelivery(ctx, delivery); err != nil {
+				t.Fatalf("record webhook delivery: %v", err)
+			}
+		}
+
+		deliveries, err := s.ListWebhookDeliveries(ctx, "job-1")
+		if err != nil {
+			t.Fatalf("list webhook deliveries: %v", err)
+		}
+		if len(deliveries) != 2 || deliveries[0].Event != "job.processing" || deliveries[1].Event != "job.completed" {
+			t.Fatalf("unexpected deliveries: %+v", deliveries)
+		}
+		completed := deliveries[1]
+		if completed.Status != domain.WebhookDeliveryDelivered || completed.Attempts != 4 || completed.LastStatusCode != 200 || completed.LastError != "" {
+			t.Fatalf("unexpected accumulated delivery: %+v", completed)
+		}
+		if !completed.DeliveredAt.Equal(deliveredAt) || !completed.UpdatedAt.Equal(deliveredAt) {
+			t.Fatalf("unexpected delivery timestamps: %+v", completed)
+		}
+
+		if err := s.Delete(ctx, "job-1"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if deliveries, err := s.ListWebhookDeliveries(ctx, "job-1"); err != nil || len(deliveries) != 0 {
+			t.Fatalf("expected deliveries removed with the job, got %v err=%v", deliveries, err)
+		}
+	})
+
+	t.Run("usage summary", func(t *testing.T) {
+		s := newStore(t)
+		for i, id := range []string{"job-1", "job-2", "job-3"} {
+			job := seed
+			job.ID = id
+			if err := s.Create(ctx, job); err != nil {
+				t.Fatalf("create %s: %v", id, err)
+			}
+			if err := s.CreateUsageLog(ctx, domain.UsageLog{
+				UserID:          "user-1",
+				JobID:           id,
+				PixelsProcessed: 100,
+				BytesSaved:      10,
+				ComputeTimeMS:   5,
+				CreatedAt:       createdAt.Add(time.Duration(i) * time.Hour),
+			}); err != nil {
+				t.Fatalf("usage %s: %v", id, err)
+			}
+		}
+
+		summary, err := s.Summary(ctx, "user-1", time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatalf("summary: %v", err)
+		}
+		if summary.Jobs != 3 || summary.PixelsProcessed != 300 || summary.BytesSaved != 30 || summary.ComputeTimeMS != 15 {
+			t.Fatalf("unexpected summary: %+v", summary)
+		}
+
+		summary, err = s.Summary(ctx, "user-1", createdAt.Add(time.Hour), createdAt.Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("windowed summary: %v", err)
+		}
+		if summary.Jobs != 1 {
+			t.Fatalf("expected one job in window, got %+v", summary)
+		}
+
+		summary, err = s.Summary(ctx, "nobody", time.Time{}, time.Time{})
+		if err != nil || summary.Jobs != 0 {
+			t.Fatalf("expected empty summary, got %+v err=%v", summary, err)
+		}
+
+		page, err := s.ListUsage(ctx, "user-1", time.Time{}, time.Time{}, 2, 0)
+		if err != nil {
+			t.Fatalf("list usage: %v", err)
+		}
+		if len(page) != 2 || page[0].JobID != "job-3" || page[1].JobID != "job-2" {
+			t.Fatalf("expected newest-first page [job-3 job-2], got %+v", page)
+		}
+		page, err = s.ListUsage(ctx, "user-1", time.Time{}, time.Time{}, 2, 2)
+		if err != nil || len(page) != 1 || page[0].JobID != "job-1" || !page[0].CreatedAt.Equal(createdAt) {
+			t.Fatalf("expected second page [job-1], got %+v err=%v", page, err)
+		}
+		page, err = s.ListUsage(ctx, "user-1", createdAt.Add(time.Hour), createdAt.Add(2*time.Hour), 10, 0)
+		if err != nil || len(page) != 1 || page[0].JobID != "job-2" {
+			t.Fatalf("expected windowed page [job-2], got %+v err=%v", page, err)
+		}
+		page, err = s.ListUsage(ctx, "nobody", time.Time{}, time.Time{}, 10, 0)
+		if err != nil || page == nil || len(page) != 0 {
+			t.Fatalf("expected empty non-nil page, got %#v err=%v", page, err)
+		}
+	})
+}
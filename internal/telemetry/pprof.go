@@ -0,0 +1,30 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// PprofHandler serves the net/http/pprof endpoints under /debug/pprof/ on its
+// own mux rather than http.DefaultServeMux.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// NewPprofServer builds the profiling listener. It has no write timeout
+// because CPU profiles and traces stream for the requested duration.
+func NewPprofServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           PprofHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+}
@@ -0,0 +1,24 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofHandlerServesProfiles(t *testing.T) {
+	handler := PprofHandler()
+
+	for path, want := range map[string]int{
+		"/debug/pprof/":          http.StatusOK,
+		"/debug/pprof/goroutine": http.StatusOK,
+		"/debug/pprof/cmdline":   http.StatusOK,
+		"/metrics":               http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("GET %s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
@@ -19,7 +20,9 @@ type TraceConfig struct {
 	ServiceName  string
 	Exporter     string
 	OTLPEndpoint string
+	OTLPProtocol string
 	OTLPInsecure bool
+	SampleRatio  float64
 }
 
 func SetupTracing(ctx context.Context, cfg TraceConfig, logger *log.Logger) (func(context.Context) error, error) {
@@ -45,13 +48,7 @@ func SetupTracing(ctx context.Context, cfg TraceConfig, logger *log.Logger) (fun
 		if strings.TrimSpace(cfg.OTLPEndpoint) == "" {
 			return nil, fmt.Errorf("otlp trace exporter requires endpoint")
 		}
-		opts := []otlptracehttp.Option{
-			otlptracehttp.WithEndpoint(cfg.OTLPEndpoint),
-		}
-		if cfg.OTLPInsecure {
-			opts = append(opts, otlptracehttp.WithInsecure())
-		}
-		exp, err = otlptracehttp.New(ctx, opts...)
+		exp, err = newOTLPExporter(ctx, cfg)
 	default:
 		return nil, fmt.Errorf("unsupported trace exporter: %s", cfg.Exporter)
 	}
@@ -73,11 +70,50 @@ func SetupTracing(ctx context.Context, cfg TraceConfig, logger *log.Logger) (fun
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exp),
 		sdktrace.WithResource(res),
+		sdktrace.WithSampler(newSampler(cfg.SampleRatio)),
 	)
 	otel.SetTracerProvider(tp)
 	if logger != nil {
-		logger.Printf("tracing exporter enabled type=%s", exporterName)
+		logger.Printf("tracing exporter enabled type=%s sample_ratio=%g", exporterName, clampRatio(cfg.SampleRatio))
 	}
 
 	return tp.Shutdown, nil
 }
+
+func newSampler(ratio float64) sdktrace.Sampler {
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(clampRatio(ratio)))
+}
+
+func clampRatio(ratio float64) float64 {
+	switch {
+	case ratio < 0:
+		return 0
+	case ratio > 1:
+		return 1
+	default:
+		return ratio
+	}
+}
+
+func newOTLPExporter(ctx context.Context, cfg TraceConfig) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.OTLPProtocol)) {
+	case "", "http", "http/protobuf":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cfg.OTLPEndpoint),
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "grpc":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint),
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol: %s", cfg.OTLPProtocol)
+	}
+}
@@ -0,0 +1,40 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewSamplerZeroRatioHonorsRemoteParent(t *testing.T) {
+	sampler := newSampler(0)
+	traceID := trace.TraceID{1}
+
+	root := sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: traceID})
+	if root.Decision != sdktrace.Drop {
+		t.Fatalf("expected new root to be dropped, got %v", root.Decision)
+	}
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	child := sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: trace.ContextWithRemoteSpanContext(context.Background(), parent),
+		TraceID:       traceID,
+	})
+	if child.Decision != sdktrace.RecordAndSample {
+		t.Fatalf("expected sampled remote parent to be honored, got %v", child.Decision)
+	}
+}
+
+func TestNewSamplerFullRatioSamplesRoots(t *testing.T) {
+	result := newSampler(1).ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: trace.TraceID{9}})
+	if result.Decision != sdktrace.RecordAndSample {
+		t.Fatalf("expected root to be sampled, got %v", result.Decision)
+	}
+}
@@ -0,0 +1,142 @@
+//go:build ffmpeg
+
+package video
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported reports whether this build can extract video frames.
+const Supported = true
+
+// New resolves the ffmpeg and ffprobe binaries up front so a misconfigured
+// worker reports ErrUnavailable instead of failing every video job.
+func New(cfg Config) (*Extractor, error) {
+	if strings.TrimSpace(cfg.FFmpegPath) == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	if strings.TrimSpace(cfg.FFprobePath) == "" {
+		cfg.FFprobePath = "ffprobe"
+	}
+	for _, bin := range []*string{&cfg.FFmpegPath, &cfg.FFprobePath} {
+		resolved, err := exec.LookPath(*bin)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		*bin = resolved
+	}
+	return &Extractor{cfg: cfg}, nil
+}
+
+// inputArgs pin ffmpeg and ffprobe to the sniffed demuxer reading the spooled
+// file, so a crafted upload cannot switch to a playlist demuxer or reach
+// http://, tcp:// or other local files.
+func inputArgs(demuxer string) []string {
+	return []string{
+		"-protocol_whitelist", "file",
+		"-format_whitelist", "mov,matroska",
+		"-f", demuxer,
+	}
+}
+
+// ExtractFrame returns the frame at the given offset as a PNG. The video is
+// spooled to a temp file because common containers (MP4 with a trailing moov
+// atom) cannot be decoded from a pipe.
+func (e *Extractor) ExtractFrame(ctx context.Context, data []byte, at time.Duration) ([]byte, error) {
+	demuxer, err := Container(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "pixelflow-video-*")
+	if err != nil {
+		return nil, fmt.Errorf("spool video: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("spool video: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("spool video: %w", err)
+	}
+
+	duration, err := e.probeDuration(ctx, f.Name(), demuxer)
+	if err != nil {
+		return nil, err
+	}
+	if e.cfg.MaxDuration > 0 && duration > e.cfg.MaxDuration {
+		return nil, fmt.Errorf("%w: %s > %s", ErrTooLong, duration, e.cfg.MaxDuration)
+	}
+	if at >= duration {
+		return nil, fmt.Errorf("%w: %s >= %s", ErrFrameOutOfRange, at, duration)
+	}
+
+	args := []string{"-v", "error", "-nostdin", "-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64)}
+	args = append(args, inputArgs(demuxer)...)
+	args = append(args,
+		"-i", f.Name(),
+		"-frames:v", "1",
+		"-f", "image2pipe", "-c:v", "png",
+		"pipe:1",
+	)
+	frame, err := e.run(ctx, e.cfg.FFmpegPath, args...)
+	if err != nil {
+		return nil, invalid(ctx, err)
+	}
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("%w: no frame decoded at %s", ErrInvalidVideo, at)
+	}
+	return frame, nil
+}
+
+func (e *Extractor) probeDuration(ctx context.Context, path, demuxer string) (time.Duration, error) {
+	args := append([]string{"-v", "error"}, inputArgs(demuxer)...)
+	args = append(args,
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	out, err := e.run(ctx, e.cfg.FFprobePath, args...)
+	if err != nil {
+		return 0, invalid(ctx, err)
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("%w: unknown duration %q", ErrInvalidVideo, strings.TrimSpace(string(out)))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// invalid blames a failed ffmpeg run on the input unless the context ended,
+// so cancellations stay retryable.
+func invalid(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidVideo, err)
+}
+
+func (e *Extractor) run(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", bin, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", bin, err)
+	}
+	return stdout.Bytes(), nil
+}
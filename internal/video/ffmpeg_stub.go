@@ -0,0 +1,20 @@
+//go:build !ffmpeg
+
+package video
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Supported reports whether this build can extract video frames.
+const Supported = false
+
+func New(Config) (*Extractor, error) {
+	return nil, fmt.Errorf("%w: video sources require building with -tags ffmpeg", ErrUnavailable)
+}
+
+func (e *Extractor) ExtractFrame(context.Context, []byte, time.Duration) ([]byte, error) {
+	return nil, fmt.Errorf("%w: video sources require building with -tags ffmpeg", ErrUnavailable)
+}
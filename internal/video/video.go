@@ -0,0 +1,52 @@
+// Package video extracts still frames from short video sources so they can
+// run through the image pipeline. Extraction shells out to ffmpeg and ffprobe
+// and is only compiled into builds with -tags ffmpeg.
+package video
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnavailable     = errors.New("video frame extraction is unavailable")
+	ErrInvalidVideo    = errors.New("source is not a readable video")
+	ErrTooLong         = errors.New("video exceeds maximum duration")
+	ErrFrameOutOfRange = errors.New("frame timestamp is past the end of the video")
+)
+
+type Config struct {
+	// FFmpegPath and FFprobePath default to "ffmpeg" and "ffprobe" on PATH.
+	FFmpegPath  string
+	FFprobePath string
+	// MaxDuration rejects longer videos before decoding; <= 0 disables.
+	MaxDuration time.Duration
+}
+
+// Extractor decodes single frames with ffmpeg. New returns ErrUnavailable in
+// builds without the ffmpeg tag.
+type Extractor struct {
+	cfg Config
+}
+
+// matroskaMagic starts every Matroska and WebM file (the EBML header id).
+var matroskaMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// Container sniffs a video source and returns the ffmpeg demuxer that reads
+// it. Only ISO BMFF (MP4, QuickTime) and Matroska (MKV, WebM) are accepted;
+// HLS and concat playlists, which ffmpeg would follow to other files or URLs,
+// fail with ErrInvalidVideo like any other input.
+func Container(data []byte) (string, error) {
+	if bytes.HasPrefix(data, matroskaMagic) {
+		return "matroska", nil
+	}
+	if len(data) >= 8 {
+		switch string(data[4:8]) {
+		case "ftyp", "moov", "mdat", "wide", "free", "skip":
+			return "mov", nil
+		}
+	}
+	return "", fmt.Errorf("%w: not an mp4, mov, mkv, or webm container", ErrInvalidVideo)
+}
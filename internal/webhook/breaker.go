@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the receiver while the
+// breaker for its host is open.
+var ErrCircuitOpen = errors.New("webhook circuit open")
+
+// BreakerState is the state of a per-host circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// breakerIdleCooldowns is how many cooldowns a host may go without an
+// attempt before its entry is dropped, so one-off and long-dead receivers
+// don't stay in the map for the life of the process.
+const breakerIdleCooldowns = 10
+
+type hostBreaker struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastSeen time.Time
+}
+
+// breakers tracks consecutive failed attempts per receiver host. After
+// threshold failures the host is short-circuited for cooldown, then a single
+// half-open probe decides whether it closes again.
+type breakers struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	hosts     map[string]*hostBreaker
+	lastSweep time.Time
+	observer  func(host string, state BreakerState)
+}
+
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	return &breakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*hostBreaker),
+	}
+}
+
+func breakerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	return strings.ToLower(u.Host)
+}
+
+// allow reports whether an attempt to host may proceed, moving an open
+// breaker to half-open once its cooldown has elapsed.
+func (b *breakers) allow(host string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hb := b.hosts[host]
+	if hb == nil {
+		return true
+	}
+	hb.lastSeen = b.now()
+	switch hb.state {
+	case BreakerOpen:
+		if b.now().Sub(hb.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(host, hb, BreakerHalfOpen)
+		hb.probing = true
+		return true
+	case BreakerHalfOpen:
+		if hb.probing {
+			return false
+		}
+		hb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breakers) record(host string, success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hb := b.hosts[host]
+	if success {
+		if hb != nil {
+			if hb.state != BreakerClosed {
+				b.transition(host, hb, BreakerClosed)
+			}
+			delete(b.hosts, host)
+		}
+		return
+	}
+
+	now := b.now()
+	if hb == nil {
+		b.sweep(now)
+		hb = &hostBreaker{state: BreakerClosed}
+		b.hosts[host] = hb
+	}
+	hb.lastSeen = now
+	hb.probing = false
+	hb.failures++
+	if hb.state == BreakerHalfOpen || (hb.state == BreakerClosed && hb.failures >= b.threshold) {
+		hb.openedAt = now
+		b.transition(host, hb, BreakerOpen)
+	}
+}
+
+// sweep drops hosts with no attempt in breakerIdleCooldowns cooldowns. It
+// runs when a new host is added, at most once per cooldown. Callers hold mu.
+func (b *breakers) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.cooldown {
+		return
+	}
+	b.lastSweep = now
+	idle := breakerIdleCooldowns * b.cooldown
+	for host, hb := range b.hosts {
+		if now.Sub(hb.lastSeen) >= idle {
+			delete(b.hosts, host)
+		}
+	}
+}
+
+// release frees a half-open probe slot without counting an outcome.
+func (b *breakers) release(host string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hb := b.hosts[host]; hb != nil {
+		hb.probing = false
+	}
+}
+
+func (b *breakers) transition(host string, hb *hostBreaker, state BreakerState) {
+	hb.state = state
+	if b.observer != nil {
+		b.observer(host, state)
+	}
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecoversAfterCooldown(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		healthy atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{SigningSecret: "test-secret", MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	client.breakers.now = func() time.Time { return now }
+	var states []BreakerState
+	client.ObserveBreaker(func(_ string, state BreakerState) { states = append(states, state) })
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := client.Deliver(ctx, srv.URL, "job.completed", nil, map[string]any{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected delivery failure before the breaker opens, got %v", err)
+		}
+	}
+
+	attempts, err := client.Deliver(ctx, srv.URL, "job.completed", nil, map[string]any{})
+	if !errors.Is(err, ErrCircuitOpen) || len(attempts) != 0 || calls.Load() != 2 {
+		t.Fatalf("expected short-circuit without a request, err=%v attempts=%d calls=%d", err, len(attempts), calls.Load())
+	}
+
+	now = now.Add(time.Minute)
+	healthy.Store(true)
+	if _, err := client.Deliver(ctx, srv.URL, "job.completed", nil, map[string]any{}); err != nil {
+		t.Fatalf("expected half-open probe to succeed, got %v", err)
+	}
+	if _, err := client.Deliver(ctx, srv.URL, "job.completed", nil, map[string]any{}); err != nil {
+		t.Fatalf("expected closed breaker to deliver, got %v", err)
+	}
+
+	if want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed}; !slices.Equal(states, want) {
+		t.Fatalf("expected transitions %v, got %v", want, states)
+	}
+}
+
+func TestBreakerReopensWhenProbeFails(t *testing.T) {
+	b := newBreakers(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record("hooks.local", false)
+	if b.allow("hooks.local") {
+		t.Fatal("expected open breaker to reject")
+	}
+	now = now.Add(time.Minute)
+	if !b.allow("hooks.local") {
+		t.Fatal("expected a half-open probe after cooldown")
+	}
+	if b.allow("hooks.local") {
+		t.Fatal("expected only one concurrent half-open probe")
+	}
+	b.record("hooks.local", false)
+	if b.allow("hooks.local") {
+		t.Fatal("expected failed probe to reopen the breaker")
+	}
+	if !b.allow("other.local") {
+		t.Fatal("expected breakers to be tracked per host")
+	}
+}
+
+func TestBreakerDropsIdleHosts(t *testing.T) {
+	b := newBreakers(2, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record("once.local", false)
+	b.record("dead.local", false)
+	b.record("dead.local", false)
+	if b.allow("dead.local") {
+		t.Fatal("expected open breaker to reject")
+	}
+
+	now = now.Add(breakerIdleCooldowns * time.Minute)
+	b.record("busy.local", false)
+	if _, ok := b.hosts["once.local"]; ok {
+		t.Fatal("expected idle closed host to be dropped")
+	}
+	if _, ok := b.hosts["dead.local"]; ok {
+		t.Fatal("expected idle open host to be dropped")
+	}
+	if len(b.hosts) != 1 {
+		t.Fatalf("expected only the active host to remain, got %d", len(b.hosts))
+	}
+
+	b.record("busy.local", false)
+	now = now.Add(time.Minute)
+	b.record("other.local", false)
+	if _, ok := b.hosts["busy.local"]; !ok {
+		t.Fatal("expected a recently seen host to be kept")
+	}
+}
@@ -3,21 +3,36 @@ package webhook
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	signature "github.com/dunamismax/pixelflow/pkg/webhook"
 )
 
 const (
-	HeaderSignature = "X-Pixelflow-Signature"
-	HeaderTimestamp = "X-Pixelflow-Timestamp"
-	HeaderEvent     = "X-Pixelflow-Event"
+	HeaderSignature = signature.HeaderSignature
+	HeaderTimestamp = signature.HeaderTimestamp
+	HeaderEvent     = signature.HeaderEvent
+)
+
+const (
+	JitterNone    = "none"
+	JitterFull    = "full"
+	JitterPartial = "partial"
+)
+
+const (
+	OutcomeSuccess        = "success"
+	OutcomeHTTPError      = "http_error"
+	OutcomeTransportError = "transport_error"
 )
 
 type Config struct {
@@ -26,6 +41,16 @@ type Config struct {
 	MaxAttempts    int
 	InitialBackoff time.Duration
 	MaxBackoff     time.Duration
+	Jitter         string
+	Rand           func() float64
+	Events         []string
+	// AllowAddr, when set, is checked after DNS resolution for every
+	// connection; deliveries to addresses it rejects fail as transport errors.
+	AllowAddr func(netip.Addr) bool
+	// BreakerThreshold consecutive failed attempts to one host open its
+	// circuit for BreakerCooldown; <= 0 disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 type Client struct {
@@ -34,6 +59,10 @@ type Client struct {
 	maxAttempts    int
 	initialBackoff time.Duration
 	maxBackoff     time.Duration
+	jitter         string
+	rand           func() float64
+	events         map[string]struct{}
+	breakers       *breakers
 }
 
 func NewClient(cfg Config) *Client {
@@ -57,55 +86,144 @@ func NewClient(cfg Config) *Client {
 		maxBackoff = initialBackoff
 	}
 
+	jitter := strings.ToLower(strings.TrimSpace(cfg.Jitter))
+	switch jitter {
+	case JitterFull, JitterPartial:
+	default:
+		jitter = JitterNone
+	}
+
+	randFloat := cfg.Rand
+	if randFloat == nil {
+		randFloat = rand.Float64
+	}
+
+	var events map[string]struct{}
+	for _, event := range cfg.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if event == "" {
+			continue
+		}
+		if events == nil {
+			events = make(map[string]struct{})
+		}
+		events[event] = struct{}{}
+	}
+
+	httpClient := &http.Client{Timeout: timeout}
+	if cfg.AllowAddr != nil {
+		dialer := &net.Dialer{Timeout: timeout, Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !cfg.AllowAddr(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("webhook address not allowed: %s", address)
+			}
+			return nil
+		}}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+		httpClient.Transport = transport
+	}
+
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		httpClient:     httpClient,
 		signingSecret:  cfg.SigningSecret,
 		maxAttempts:    maxAttempts,
 		initialBackoff: initialBackoff,
 		maxBackoff:     maxBackoff,
+		jitter:         jitter,
+		rand:           randFloat,
+		events:         events,
+		breakers:       newBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// ObserveBreaker registers fn to be called on every circuit breaker state
+// change. It must be called before the client is used.
+func (c *Client) ObserveBreaker(fn func(host string, state BreakerState)) {
+	if c.breakers != nil {
+		c.breakers.observer = fn
+	}
+}
+
+func (c *Client) Enabled(event string) bool {
+	if c.events == nil {
+		return true
+	}
+	_, ok := c.events[strings.ToLower(strings.TrimSpace(event))]
+	return ok
+}
+
+// Attempt describes a single HTTP delivery attempt made by Deliver.
+type Attempt struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Outcome classifies the attempt as a success, a non-2xx response, or a
+// transport failure (connection error, timeout, cancellation).
+func (a Attempt) Outcome() string {
+	switch {
+	case a.Err != nil:
+		return OutcomeTransportError
+	case a.StatusCode >= 200 && a.StatusCode < 300:
+		return OutcomeSuccess
+	default:
+		return OutcomeHTTPError
 	}
 }
 
-func (c *Client) Send(ctx context.Context, endpoint, event string, payload any) error {
+// Send POSTs the signed payload to endpoint, retrying with backoff. headers
+// are added to every attempt; they cannot replace Content-Type or the
+// signature, timestamp, and event headers, which are always set last.
+func (c *Client) Send(ctx context.Context, endpoint, event string, headers map[string]string, payload any) error {
+	_, err := c.Deliver(ctx, endpoint, event, headers, payload)
+	return err
+}
+
+// Deliver behaves like Send but also returns every attempt it made so callers
+// can record delivery telemetry without this package depending on a metrics
+// backend.
+func (c *Client) Deliver(ctx context.Context, endpoint, event string, headers map[string]string, payload any) ([]Attempt, error) {
 	endpoint = strings.TrimSpace(endpoint)
 	if endpoint == "" {
-		return nil
+		return nil, nil
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("marshal webhook payload: %w", err)
+		return nil, fmt.Errorf("marshal webhook payload: %w", err)
 	}
 
 	timestamp := strconv.FormatInt(time.Now().UTC().Unix(), 10)
-	signature := c.sign(timestamp, body)
+	sig := c.sign(timestamp, body)
 
+	host := breakerHost(endpoint)
 	backoff := c.initialBackoff
+	attempts := make([]Attempt, 0, c.maxAttempts)
 	var lastErr error
 	for attempt := 1; attempt <= c.maxAttempts; attempt++ {
 		if err := ctx.Err(); err != nil {
-			return err
+			return attempts, err
+		}
+		if !c.breakers.allow(host) {
+			return attempts, fmt.Errorf("%w: host=%s", ErrCircuitOpen, host)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		record, resp, err := c.post(ctx, endpoint, event, headers, timestamp, sig, body)
 		if err != nil {
-			return fmt.Errorf("build webhook request: %w", err)
+			return attempts, err
 		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set(HeaderTimestamp, timestamp)
-		req.Header.Set(HeaderSignature, signature)
-		req.Header.Set(HeaderEvent, event)
-
-		resp, err := c.httpClient.Do(req)
-		if err == nil && resp != nil {
-			resp.Body.Close()
+		attempts = append(attempts, record)
+		if ctx.Err() != nil {
+			// Our own cancellation says nothing about the receiver's health.
+			c.breakers.release(host)
+		} else {
+			c.breakers.record(host, record.Outcome() == OutcomeSuccess)
 		}
 
-		if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			return nil
+		if record.Outcome() == OutcomeSuccess {
+			return attempts, nil
 		}
 
 		lastErr = classifyWebhookError(err, resp)
@@ -113,24 +231,77 @@ func (c *Client) Send(ctx context.Context, endpoint, event string, payload any)
 			break
 		}
 
+		delay := c.jitterDelay(backoff)
+		if retryAfter := retryAfterDelay(resp, time.Now()); retryAfter > delay {
+			delay = minDuration(retryAfter, c.maxBackoff)
+		}
+
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(backoff):
+			return attempts, ctx.Err()
+		case <-time.After(delay):
 		}
 
 		backoff = minDuration(backoff*2, c.maxBackoff)
 	}
 
-	return fmt.Errorf("webhook delivery failed after %d attempts: %w", c.maxAttempts, lastErr)
+	return attempts, fmt.Errorf("webhook delivery failed after %d attempts: %w", c.maxAttempts, lastErr)
+}
+
+// Probe makes a single signed delivery of payload to endpoint, without
+// retries or event filtering, so callers can check that a receiver accepts
+// and verifies our callbacks. Transport failures are reported in the Attempt.
+func (c *Client) Probe(ctx context.Context, endpoint, event string, payload any) (Attempt, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Attempt{}, fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UTC().Unix(), 10)
+	record, _, err := c.post(ctx, strings.TrimSpace(endpoint), event, nil, timestamp, c.sign(timestamp, body), body)
+	return record, err
+}
+
+// post sends one signed request. The returned error is only set when the
+// request cannot be built; transport failures are recorded in the Attempt.
+func (c *Client) post(ctx context.Context, endpoint, event string, headers map[string]string, timestamp, sig string, body []byte) (Attempt, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Attempt{}, nil, fmt.Errorf("build webhook request: %w", err)
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, sig)
+	req.Header.Set(HeaderEvent, event)
+
+	startedAt := time.Now()
+	resp, err := c.httpClient.Do(req)
+	record := Attempt{Duration: time.Since(startedAt), Err: err}
+	if err == nil && resp != nil {
+		resp.Body.Close()
+		record.StatusCode = resp.StatusCode
+	}
+	return record, resp, nil
+}
+
+func (c *Client) jitterDelay(backoff time.Duration) time.Duration {
+	switch c.jitter {
+	case JitterFull:
+		return time.Duration(c.rand() * float64(backoff))
+	case JitterPartial:
+		half := backoff / 2
+		return half + time.Duration(c.rand()*float64(backoff-half))
+	default:
+		return backoff
+	}
 }
 
 func (c *Client) sign(timestamp string, body []byte) string {
-	mac := hmac.New(sha256.New, []byte(c.signingSecret))
-	mac.Write([]byte(timestamp))
-	mac.Write([]byte("."))
-	mac.Write(body)
-	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return signature.Sign(c.signingSecret, timestamp, body)
 }
 
 func classifyWebhookError(err error, resp *http.Response) error {
@@ -143,6 +314,32 @@ func classifyWebhookError(err error, resp *http.Response) error {
 	return fmt.Errorf("webhook returned status=%d", resp.StatusCode)
 }
 
+func retryAfterDelay(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
 func minDuration(a, b time.Duration) time.Duration {
 	if a < b {
 		return a
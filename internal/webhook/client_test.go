@@ -4,8 +4,12 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	signature "github.com/dunamismax/pixelflow/pkg/webhook"
 )
 
 func TestSendAddsSigningHeaders(t *testing.T) {
@@ -31,7 +35,7 @@ func TestSendAddsSigningHeaders(t *testing.T) {
 		MaxBackoff:     20 * time.Millisecond,
 	})
 
-	err := client.Send(context.Background(), srv.URL, "job.completed", map[string]any{"job_id": "job-1"})
+	err := client.Send(context.Background(), srv.URL, "job.completed", nil, map[string]any{"job_id": "job-1"})
 	if err != nil {
 		t.Fatalf("send returned error: %v", err)
 	}
@@ -46,3 +50,217 @@ func TestSendAddsSigningHeaders(t *testing.T) {
 		t.Fatalf("expected event header job.completed, got %q", gotEvt)
 	}
 }
+
+func TestSendAddsCustomHeadersWithoutOverridingSignature(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{SigningSecret: "test-secret", MaxAttempts: 1})
+	headers := map[string]string{
+		"Authorization": "Bearer receiver-token",
+		HeaderEvent:     "spoofed",
+		"Content-Type":  "text/plain",
+	}
+	if err := client.Send(context.Background(), srv.URL, "job.completed", headers, map[string]any{"job_id": "job-1"}); err != nil {
+		t.Fatalf("send returned error: %v", err)
+	}
+
+	if got.Get("Authorization") != "Bearer receiver-token" {
+		t.Fatalf("expected custom Authorization header, got %q", got.Get("Authorization"))
+	}
+	if got.Get(HeaderEvent) != "job.completed" || got.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected reserved headers to win, got event=%q content-type=%q", got.Get(HeaderEvent), got.Get("Content-Type"))
+	}
+}
+
+func TestJitterDelayStaysWithinBackoff(t *testing.T) {
+	backoff := 8 * time.Second
+
+	cases := []struct {
+		jitter string
+		rand   float64
+		want   time.Duration
+	}{
+		{jitter: JitterNone, rand: 0.3, want: 8 * time.Second},
+		{jitter: JitterFull, rand: 0, want: 0},
+		{jitter: JitterFull, rand: 0.5, want: 4 * time.Second},
+		{jitter: JitterPartial, rand: 0, want: 4 * time.Second},
+		{jitter: JitterPartial, rand: 0.5, want: 6 * time.Second},
+		{jitter: JitterPartial, rand: 0.999999, want: 8*time.Second - 4*time.Microsecond},
+	}
+
+	for _, tc := range cases {
+		value := tc.rand
+		client := NewClient(Config{
+			MaxBackoff: 30 * time.Second,
+			Jitter:     tc.jitter,
+			Rand:       func() float64 { return value },
+		})
+		if got := client.jitterDelay(backoff); got != tc.want {
+			t.Fatalf("jitter=%s rand=%v: expected %s, got %s", tc.jitter, tc.rand, tc.want, got)
+		}
+	}
+}
+
+func TestSendHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{
+		SigningSecret:  "test-secret",
+		Timeout:        2 * time.Second,
+		MaxAttempts:    2,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	})
+
+	start := time.Now()
+	if err := client.Send(context.Background(), srv.URL, "job.completed", nil, map[string]any{"job_id": "job-1"}); err != nil {
+		t.Fatalf("send returned error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+	if elapsed < 1900*time.Millisecond || elapsed > 4*time.Second {
+		t.Fatalf("expected client to wait ~2s for Retry-After, waited %s", elapsed)
+	}
+}
+
+func TestDeliverReportsEachAttempt(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{
+		Timeout:        2 * time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+
+	attempts, err := client.Deliver(context.Background(), srv.URL, "job.completed", nil, map[string]any{"job_id": "job-1"})
+	if err != nil {
+		t.Fatalf("deliver returned error: %v", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	want := []string{OutcomeHTTPError, OutcomeHTTPError, OutcomeSuccess}
+	for i, attempt := range attempts {
+		if attempt.Outcome() != want[i] {
+			t.Fatalf("attempt %d outcome = %q, want %q", i, attempt.Outcome(), want[i])
+		}
+	}
+	if attempts[2].StatusCode != http.StatusNoContent {
+		t.Fatalf("expected final status 204, got %d", attempts[2].StatusCode)
+	}
+}
+
+func TestRetryAfterDelayParsesHTTPDate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{now.Add(5 * time.Second).Format(http.TimeFormat)}},
+	}
+	if got := retryAfterDelay(resp, now); got != 5*time.Second {
+		t.Fatalf("expected 5s, got %s", got)
+	}
+
+	resp.Header.Set("Retry-After", now.Add(-time.Minute).Format(http.TimeFormat))
+	if got := retryAfterDelay(resp, now); got != 0 {
+		t.Fatalf("expected 0 for past date, got %s", got)
+	}
+}
+
+func TestSendSignatureVerifiesWithExportedHelper(t *testing.T) {
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, verifyErr = signature.VerifyRequest(r, "test-secret", 0)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{SigningSecret: "test-secret", MaxAttempts: 1})
+	if err := client.Send(context.Background(), srv.URL, "job.completed", nil, map[string]any{"job_id": "job-1"}); err != nil {
+		t.Fatalf("send returned error: %v", err)
+	}
+	if verifyErr != nil {
+		t.Fatalf("expected delivery to verify, got %v", verifyErr)
+	}
+}
+
+func TestEnabledFiltersConfiguredEvents(t *testing.T) {
+	all := NewClient(Config{})
+	if !all.Enabled("job.processing") {
+		t.Fatal("expected every event to be enabled without an explicit event set")
+	}
+
+	terminal := NewClient(Config{Events: []string{"job.completed", " JOB.FAILED "}})
+	if terminal.Enabled("job.processing") {
+		t.Fatal("expected job.processing to be disabled")
+	}
+	if !terminal.Enabled("job.completed") || !terminal.Enabled("job.failed") {
+		t.Fatal("expected terminal events to be enabled")
+	}
+}
+
+func TestProbeMakesSingleVerifiableAttempt(t *testing.T) {
+	var (
+		calls     atomic.Int32
+		verifyErr error
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, verifyErr = signature.VerifyRequest(r, "test-secret", 0)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{SigningSecret: "test-secret", MaxAttempts: 3, Events: []string{"job.completed"}})
+	attempt, err := client.Probe(context.Background(), srv.URL, "webhook.test", map[string]any{"job_id": "webhook-test"})
+	if err != nil {
+		t.Fatalf("probe returned error: %v", err)
+	}
+	if calls.Load() != 1 || attempt.StatusCode != http.StatusInternalServerError || attempt.Outcome() != OutcomeHTTPError {
+		t.Fatalf("expected one failed attempt, calls=%d attempt=%+v", calls.Load(), attempt)
+	}
+	if verifyErr != nil {
+		t.Fatalf("probe signature did not verify: %v", verifyErr)
+	}
+}
+
+func TestAllowAddrBlocksDisallowedReceivers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{SigningSecret: "test-secret", AllowAddr: func(addr netip.Addr) bool { return !addr.IsLoopback() }})
+	attempt, err := client.Probe(context.Background(), srv.URL, "webhook.test", map[string]any{})
+	if err != nil {
+		t.Fatalf("probe returned error: %v", err)
+	}
+	if attempt.Outcome() != OutcomeTransportError {
+		t.Fatalf("expected loopback receiver to be blocked, got %+v", attempt)
+	}
+}
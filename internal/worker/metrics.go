@@ -2,21 +2,34 @@ package worker
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/dunamismax/pixelflow/internal/buildinfo"
+	"github.com/dunamismax/pixelflow/internal/pipeline"
+	"github.com/hibiken/asynq"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type metrics struct {
-	registry             *prometheus.Registry
-	jobsTotal            *prometheus.CounterVec
-	jobDuration          *prometheus.HistogramVec
-	activeJobs           prometheus.Gauge
-	pipelineOutputsTotal prometheus.Counter
-	pixelsProcessedTotal prometheus.Counter
-	bytesSavedTotal      prometheus.Counter
-	computeTimeMSTotal   prometheus.Counter
+	registry                  *prometheus.Registry
+	jobsTotal                 *prometheus.CounterVec
+	jobDuration               *prometheus.HistogramVec
+	stepDuration              *prometheus.HistogramVec
+	activeJobs                prometheus.Gauge
+	pipelineOutputsTotal      prometheus.Counter
+	pixelsProcessedTotal      prometheus.Counter
+	bytesSavedTotal           prometheus.Counter
+	computeTimeMSTotal        prometheus.Counter
+	jobsInterruptedTotal      prometheus.Counter
+	webhookAttemptsTotal      *prometheus.CounterVec
+	webhookDuration           *prometheus.HistogramVec
+	webhookFailuresTotal      *prometheus.CounterVec
+	webhookBreakerTotal       *prometheus.CounterVec
+	eventPublishFailuresTotal *prometheus.CounterVec
+	queueTasks                *prometheus.GaugeVec
+	queueLatency              *prometheus.GaugeVec
 }
 
 func newMetrics() *metrics {
@@ -37,6 +50,11 @@ func newMetrics() *metrics {
 			Help:    "Total processing duration for each worker job.",
 			Buckets: prometheus.DefBuckets,
 		}, []string{"source_type", "status"}),
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pixelflow_worker_step_duration_seconds",
+			Help:    "Transform duration for each pipeline step by action and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"action", "status"}),
 		activeJobs: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "pixelflow_worker_active_jobs",
 			Help: "Current number of active processing jobs in the worker.",
@@ -57,20 +75,89 @@ func newMetrics() *metrics {
 			Name: "pixelflow_usage_compute_time_ms_total",
 			Help: "Total compute time in milliseconds across successful jobs.",
 		}),
+		jobsInterruptedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pixelflow_worker_jobs_interrupted_total",
+			Help: "Total jobs interrupted by worker shutdown or a lost task lease.",
+		}),
+		webhookAttemptsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pixelflow_webhook_attempts_total",
+			Help: "Total webhook delivery attempts by event and outcome.",
+		}, []string{"event", "outcome"}),
+		webhookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pixelflow_webhook_duration_seconds",
+			Help:    "Duration of each webhook delivery attempt.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"event"}),
+		webhookFailuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pixelflow_webhook_failures_total",
+			Help: "Total webhook deliveries that failed after exhausting all attempts.",
+		}, []string{"event"}),
+		webhookBreakerTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pixelflow_webhook_breaker_transitions_total",
+			Help: "Total webhook circuit breaker state transitions by the state entered.",
+		}, []string{"state"}),
+		eventPublishFailuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pixelflow_event_publish_failures_total",
+			Help: "Total job events that could not be published to a configured event sink.",
+		}, []string{"event"}),
+		queueTasks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pixelflow_queue_tasks",
+			Help: "Current number of tasks in each queue by state.",
+		}, []string{"queue", "state"}),
+		queueLatency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pixelflow_queue_oldest_pending_seconds",
+			Help: "Age of the oldest pending task in each queue.",
+		}, []string{"queue"}),
 	}
 
+	backendInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pixelflow_worker_backend_info",
+		Help: "Always 1; the backend label names the image transformer this build uses.",
+	}, []string{"backend"})
+	backendInfo.WithLabelValues(pipeline.BackendName()).Set(1)
+
 	registry.MustRegister(
+		backendInfo,
 		m.jobsTotal,
 		m.jobDuration,
+		m.stepDuration,
 		m.activeJobs,
 		m.pipelineOutputsTotal,
 		m.pixelsProcessedTotal,
 		m.bytesSavedTotal,
 		m.computeTimeMSTotal,
+		m.jobsInterruptedTotal,
+		m.webhookAttemptsTotal,
+		m.webhookDuration,
+		m.webhookFailuresTotal,
+		m.webhookBreakerTotal,
+		m.eventPublishFailuresTotal,
+		m.queueTasks,
+		m.queueLatency,
 	)
 	return m
 }
 
+func (m *metrics) observeStep(action string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	m.stepDuration.WithLabelValues(action, status).Observe(duration.Seconds())
+}
+
+func (m *metrics) observeQueue(name string, info *asynq.QueueInfo) {
+	m.queueTasks.WithLabelValues(name, "pending").Set(float64(info.Pending))
+	m.queueTasks.WithLabelValues(name, "active").Set(float64(info.Active))
+	m.queueTasks.WithLabelValues(name, "scheduled").Set(float64(info.Scheduled))
+	m.queueTasks.WithLabelValues(name, "retry").Set(float64(info.Retry))
+	m.queueTasks.WithLabelValues(name, "archived").Set(float64(info.Archived))
+	m.queueLatency.WithLabelValues(name).Set(info.Latency.Seconds())
+}
+
 func (m *metrics) Handler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /version", buildinfo.HandlerWithBackend(pipeline.BackendName()))
+	mux.Handle("/", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	return mux
 }
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type expiredObjectPruner interface {
+	PruneExpired(ctx context.Context, prefix string, olderThan time.Time) (int, error)
+}
+
+var prunePrefixes = []string{"uploads/", "outputs/"}
+
+func RunPruner(ctx context.Context, logger *log.Logger, pruner expiredObjectPruner, maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pruneExpired(ctx, logger, pruner, time.Now().Add(-maxAge))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pruneExpired(ctx context.Context, logger *log.Logger, pruner expiredObjectPruner, olderThan time.Time) {
+	for _, prefix := range prunePrefixes {
+		deleted, err := pruner.PruneExpired(ctx, prefix, olderThan)
+		if err != nil {
+			logger.Printf("object prune failed prefix=%s deleted=%d err=%v", prefix, deleted, err)
+			continue
+		}
+		if deleted > 0 {
+			logger.Printf("object prune prefix=%s deleted=%d older_than=%s", prefix, deleted, olderThan.UTC().Format(time.RFC3339))
+		}
+	}
+}
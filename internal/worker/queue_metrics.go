@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+type queueInspector interface {
+	Queues() ([]string, error)
+	GetQueueInfo(queue string) (*asynq.QueueInfo, error)
+}
+
+// RunQueueMetrics samples per-queue task counts and pending latency every
+// interval until ctx is cancelled, then closes the inspector. An interval
+// <= 0 disables sampling.
+func (s *Server) RunQueueMetrics(ctx context.Context, interval time.Duration) {
+	if closer, ok := s.inspector.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				s.logger.Printf("queue inspector close error: %v", err)
+			}
+		}()
+	}
+	if s.inspector == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.sampleQueues()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) sampleQueues() {
+	known, err := s.inspector.Queues()
+	if err != nil {
+		s.logger.Printf("queue metrics list failed err=%v", err)
+		return
+	}
+
+	for _, name := range s.queueNames {
+		// asynq only registers a queue once a task has been enqueued to it.
+		info := &asynq.QueueInfo{Queue: name}
+		if slices.Contains(known, name) {
+			if info, err = s.inspector.GetQueueInfo(name); err != nil {
+				s.logger.Printf("queue metrics inspect failed queue=%s err=%v", name, err)
+				continue
+			}
+		}
+		s.metrics.observeQueue(name, info)
+	}
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeQueueInspector struct {
+	known  []string
+	infos  map[string]*asynq.QueueInfo
+	closed bool
+}
+
+func (f *fakeQueueInspector) Queues() ([]string, error) {
+	return f.known, nil
+}
+
+func (f *fakeQueueInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	return f.infos[queue], nil
+}
+
+func (f *fakeQueueInspector) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRunQueueMetricsRecordsGaugesAndStops(t *testing.T) {
+	inspector := &fakeQueueInspector{
+		known: []string{"default"},
+		infos: map[string]*asynq.QueueInfo{
+			"default": {Queue: "default", Pending: 7, Active: 2, Retry: 1, Latency: 90 * time.Second},
+		},
+	}
+	s := &Server{
+		logger:     log.New(io.Discard, "", 0),
+		metrics:    newMetrics(),
+		inspector:  inspector,
+		queueNames: []string{"critical", "default"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.RunQueueMetrics(ctx, time.Hour)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(s.metrics.queueTasks.WithLabelValues("default", "pending")) != 7 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for queue sample")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queue metrics loop did not stop after cancel")
+	}
+
+	if got := testutil.ToFloat64(s.metrics.queueTasks.WithLabelValues("default", "active")); got != 2 {
+		t.Fatalf("expected 2 active tasks, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.queueLatency.WithLabelValues("default")); got != 90 {
+		t.Fatalf("expected 90s pending latency, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.queueTasks.WithLabelValues("critical", "pending")); got != 0 {
+		t.Fatalf("expected unregistered queue to report 0 pending, got %v", got)
+	}
+	if !inspector.closed {
+		t.Fatal("expected inspector to be closed on exit")
+	}
+}
@@ -2,18 +2,29 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dunamismax/pixelflow/internal/config"
 	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/events"
+	"github.com/dunamismax/pixelflow/internal/httpsource"
 	"github.com/dunamismax/pixelflow/internal/pipeline"
 	"github.com/dunamismax/pixelflow/internal/queue"
+	"github.com/dunamismax/pixelflow/internal/requestid"
 	"github.com/dunamismax/pixelflow/internal/storage"
 	"github.com/dunamismax/pixelflow/internal/store"
+	"github.com/dunamismax/pixelflow/internal/video"
 	"github.com/dunamismax/pixelflow/internal/webhook"
 	"github.com/hibiken/asynq"
 	"go.opentelemetry.io/otel"
@@ -22,88 +33,326 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	terminalUpdateTimeout = 30 * time.Second
+	maxErrorMessageLength = 1024
+)
+
+// errJobCancelled is the cancel cause of a job interrupted through the API.
+var errJobCancelled = errors.New("job cancelled by request")
+
 type Server struct {
 	logger          *log.Logger
 	server          *asynq.Server
 	sem             chan struct{}
+	userSlots       *userSlots
+	inFlightMu      sync.Mutex
+	inFlight        map[string]context.CancelCauseFunc
+	cancels         cancelSubscriber
 	localProcessor  *pipeline.Processor
 	objectProcessor *pipeline.Processor
+	httpProcessor   *pipeline.Processor
+	videoProcessor  *pipeline.Processor
+	// videoErr explains why videoProcessor is nil.
+	videoErr        error
 	webhookClient   webhookSender
+	webhookQueue    webhookEnqueuer
+	deliveries      store.WebhookDeliveryStore
+	eventSinks      []events.Sink
+	outputURLs      outputPresigner
+	sources         sourceDeleter
+	outputURLExpiry time.Duration
 	jobStore        store.JobStore
 	usageStore      store.UsageStore
+	deadLetters     deadLetterEnqueuer
+	inspector       queueInspector
+	queueNames      []string
 	metrics         *metrics
 	tracer          trace.Tracer
 }
 
+// Option configures optional Server behaviour.
+type Option func(*Server)
+
+// WithEventSinks publishes job.completed and job.failed to each sink in
+// addition to the job's webhook.
+func WithEventSinks(sinks ...events.Sink) Option {
+	return func(s *Server) {
+		s.eventSinks = append(s.eventSinks, sinks...)
+	}
+}
+
+type deadLetterEnqueuer interface {
+	EnqueueDeadLetter(ctx context.Context, task *asynq.Task) (*asynq.TaskInfo, error)
+}
+
+type webhookEnqueuer interface {
+	EnqueueDeliverWebhook(ctx context.Context, queueName string, payload queue.DeliverWebhookPayload) (*asynq.TaskInfo, error)
+}
+
 type webhookSender interface {
-	Send(ctx context.Context, endpoint, event string, payload any) error
+	Deliver(ctx context.Context, endpoint, event string, headers map[string]string, payload any) ([]webhook.Attempt, error)
+	Enabled(event string) bool
+}
+
+type outputPresigner interface {
+	PresignedGetURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
+}
+
+// cancelSubscriber delivers job ids the API asked to cancel.
+type cancelSubscriber interface {
+	Subscribe(ctx context.Context, fn func(jobID string)) error
+}
+
+type sourceDeleter interface {
+	DeleteObject(ctx context.Context, objectKey string) error
+}
+
+type completedOutput struct {
+	pipeline.Output
+	URL string `json:"url,omitempty"`
 }
 
 func NewServer(
 	logger *log.Logger,
 	queueCfg config.QueueConfig,
 	workerCfg config.WorkerConfig,
-	storageClient *storage.Client,
+	storageClient storage.Backend,
+	httpSource *httpsource.Client,
 	webhookClient *webhook.Client,
 	jobStore store.JobStore,
 	usageStore store.UsageStore,
+	opts ...Option,
 ) (*Server, error) {
 	if storageClient == nil {
 		return nil, fmt.Errorf("storage client is required")
 	}
 
-	localProcessor, err := pipeline.NewLocalProcessor(workerCfg.LocalOutputDir)
+	tracer := otel.Tracer("pixelflow/worker")
+	workerMetrics := newMetrics()
+	processorOpts := []pipeline.ProcessorOption{
+		pipeline.WithMaxInputBytes(workerCfg.MaxInputBytes),
+		pipeline.WithMaxPixels(workerCfg.MaxPixels),
+		pipeline.WithMaxDecodeBytes(workerCfg.MaxDecodeBytes),
+		pipeline.WithAllowedInputFormats(workerCfg.AllowedInputFormats),
+		pipeline.WithStepDedup(workerCfg.DedupSteps),
+		pipeline.WithStepConcurrency(workerCfg.StepConcurrency),
+		pipeline.WithOutputConflict(workerCfg.OutputConflict),
+		pipeline.WithOverlayAssets(pipeline.ObjectStoreFetcher{Storage: storageClient, MaxBytes: workerCfg.MaxInputBytes}, workerCfg.OverlayAssetsPrefix),
+		pipeline.WithTracer(tracer),
+		pipeline.WithStepObserver(workerMetrics.observeStep),
+	}
+
+	switch strings.ToLower(strings.TrimSpace(workerCfg.OutputContentDisposition)) {
+	case "", "inline", "attachment":
+	default:
+		return nil, fmt.Errorf("output content disposition must be inline or attachment, got %q", workerCfg.OutputContentDisposition)
+	}
+	if unsupported := pipeline.UnsupportedInputFormats(workerCfg.AllowedInputFormats); len(unsupported) > 0 {
+		return nil, fmt.Errorf("allowed input formats %v cannot be decoded by the %s transformer (supported: %v)", unsupported, pipeline.BackendName(), pipeline.SupportedInputFormats())
+	}
+	if !pipeline.ValidOutputConflict(workerCfg.OutputConflict) {
+		return nil, fmt.Errorf("output conflict must be overwrite, error, or suffix, got %q", workerCfg.OutputConflict)
+	}
+	emitter := pipeline.ObjectStoreEmitter{
+		Storage:      storageClient,
+		OutputPrefix: "outputs",
+		CacheControl: workerCfg.OutputCacheControl,
+		Disposition:  workerCfg.OutputContentDisposition,
+		Conflict:     workerCfg.OutputConflict,
+	}
+
+	localProcessor, err := pipeline.NewLocalProcessor(workerCfg.LocalOutputDir, processorOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("initialize pipeline processor: %w", err)
 	}
 
+	// Manifests of object-store outputs carry the same presigned URLs as the
+	// job.completed event.
+	objectOpts := processorOpts
+	if workerCfg.OutputURLExpiry > 0 {
+		objectOpts = append(slices.Clip(processorOpts), pipeline.WithOutputURLs(func(ctx context.Context, objectKey string) (string, error) {
+			return storageClient.PresignedGetURL(ctx, objectKey, workerCfg.OutputURLExpiry)
+		}))
+	}
+
 	objectProcessor, err := pipeline.NewObjectStoreProcessor(
-		pipeline.ObjectStoreFetcher{Storage: storageClient},
-		pipeline.ObjectStoreEmitter{Storage: storageClient, OutputPrefix: "outputs"},
+		pipeline.ObjectStoreFetcher{Storage: storageClient, MaxBytes: workerCfg.MaxInputBytes},
+		emitter,
+		objectOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("initialize object-store processor: %w", err)
 	}
 
+	var httpProcessor *pipeline.Processor
+	if httpSource != nil {
+		httpProcessor, err = pipeline.NewObjectStoreProcessor(
+			pipeline.HTTPFetcher{Client: httpSource, MaxBytes: workerCfg.MaxInputBytes},
+			emitter,
+			objectOpts...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("initialize http source processor: %w", err)
+		}
+	}
+
+	var videoProcessor *pipeline.Processor
+	extractor, videoErr := video.New(video.Config{
+		FFmpegPath:  workerCfg.FFmpegPath,
+		FFprobePath: workerCfg.FFprobePath,
+		MaxDuration: workerCfg.VideoMaxDuration,
+	})
+	if videoErr == nil {
+		videoProcessor, err = pipeline.NewObjectStoreProcessor(
+			pipeline.VideoFrameFetcher{
+				Fetcher:   pipeline.ObjectStoreFetcher{Storage: storageClient, MaxBytes: workerCfg.MaxInputBytes},
+				Extractor: extractor,
+			},
+			emitter,
+			objectOpts...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("initialize video processor: %w", err)
+		}
+	} else if video.Supported {
+		logger.Printf("video sources disabled: %v", videoErr)
+	}
+
+	deliveries, _ := jobStore.(store.WebhookDeliveryStore)
 	if usageStore == nil {
 		if jobAndUsageStore, ok := jobStore.(store.UsageStore); ok {
 			usageStore = jobAndUsageStore
 		}
 	}
 
+	if webhookClient != nil {
+		webhookClient.ObserveBreaker(func(host string, state webhook.BreakerState) {
+			workerMetrics.webhookBreakerTotal.WithLabelValues(string(state)).Inc()
+			logger.Printf("webhook circuit breaker host=%s state=%s", host, state)
+		})
+	}
+
 	s := &Server{
-		logger: logger,
-		server: asynq.NewServer(
-			queueCfg.RedisClientOpt(),
-			asynq.Config{
-				Concurrency: workerCfg.Concurrency,
-				Queues: map[string]int{
-					queueCfg.Name: 1,
-				},
-				LogLevel: asynq.InfoLevel,
-				ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
-					retried, _ := asynq.GetRetryCount(ctx)
-					maxRetry, _ := asynq.GetMaxRetry(ctx)
-					logger.Printf("task failed type=%s retry=%d/%d err=%v", task.Type(), retried, maxRetry, err)
-				}),
-			},
-		),
-		sem:             make(chan struct{}, max(1, workerCfg.MaxActiveJobs)),
+		logger:          logger,
+		sem:             newJobSemaphore(workerCfg.MaxActiveJobs),
+		userSlots:       newUserSlots(workerCfg.MaxActiveJobsPerUser),
 		localProcessor:  localProcessor,
 		objectProcessor: objectProcessor,
+		httpProcessor:   httpProcessor,
+		videoProcessor:  videoProcessor,
+		videoErr:        videoErr,
 		webhookClient:   webhookClient,
+		outputURLs:      storageClient,
+		sources:         storageClient,
+		outputURLExpiry: workerCfg.OutputURLExpiry,
 		jobStore:        jobStore,
 		usageStore:      usageStore,
-		metrics:         newMetrics(),
-		tracer:          otel.Tracer("pixelflow/worker"),
+		deliveries:      deliveries,
+		metrics:         workerMetrics,
+		tracer:          tracer,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.inspector = asynq.NewInspector(queueCfg.RedisClientOpt())
+	s.cancels = queue.NewJobCancels(queueCfg.RedisClientOpt())
+	s.queueNames = slices.Sorted(maps.Keys(queueCfg.ServerQueues()))
+	if webhookClient != nil {
+		s.webhookQueue = queue.NewClient(queueCfg.RedisClientOpt(), queueCfg.Name, queue.WithMaxRetry(workerCfg.WebhookMaxRetry))
+	}
+	if name := strings.TrimSpace(workerCfg.DeadLetterQueue); name != "" {
+		s.deadLetters = queue.NewClient(queueCfg.RedisClientOpt(), name)
+		if !slices.Contains(s.queueNames, name) {
+			s.queueNames = append(s.queueNames, name)
+		}
+	}
+	s.server = asynq.NewServer(
+		queueCfg.RedisClientOpt(),
+		asynq.Config{
+			Concurrency:  workerCfg.Concurrency,
+			Queues:       queueCfg.ServerQueues(),
+			LogLevel:     asynq.InfoLevel,
+			ErrorHandler: asynq.ErrorHandlerFunc(s.handleTaskError),
+		},
+	)
 	return s, nil
 }
 
 func (s *Server) Run() error {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(queue.TypeProcessImage, s.handleProcessImage)
-	return s.server.Run(mux)
+	mux.HandleFunc(queue.TypeDeliverWebhook, s.handleDeliverWebhook)
+	subCtx, stopSub := context.WithCancel(context.Background())
+	if s.cancels != nil {
+		if err := s.cancels.Subscribe(subCtx, s.cancelInFlight); err != nil {
+			s.logger.Printf("job cancel subscription failed, processing jobs cannot be cancelled: %v", err)
+		}
+	}
+	err := s.server.Run(mux)
+	stopSub()
+	if closer, ok := s.cancels.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Printf("job cancel subscriber close error: %v", closeErr)
+		}
+	}
+	if closer, ok := s.deadLetters.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Printf("dead-letter client close error: %v", closeErr)
+		}
+	}
+	if closer, ok := s.webhookQueue.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Printf("webhook queue client close error: %v", closeErr)
+		}
+	}
+	return err
+}
+
+func (s *Server) handleTaskError(ctx context.Context, task *asynq.Task, err error) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if payload, ok := queue.RecoverProcessImagePayload(task); ok {
+		ctx = requestid.WithID(ctx, payload.RequestID)
+	}
+	requestid.Logf(ctx, s.logger, "task failed type=%s retry=%d/%d err=%v", task.Type(), retried, maxRetry, err)
+	if retried < maxRetry || errors.Is(err, asynq.SkipRetry) {
+		return
+	}
+	s.recordFinalFailure(ctx, task, err)
+}
+
+func (s *Server) recordFinalFailure(ctx context.Context, task *asynq.Task, err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), terminalUpdateTimeout)
+	defer cancel()
+
+	if payload, ok := queue.RecoverProcessImagePayload(task); ok && task.Type() == queue.TypeProcessImage && s.jobStore != nil {
+		if recordErr := s.jobStore.RecordFailure(ctx, payload.JobID, truncateErrorMessage(err)); recordErr != nil {
+			requestid.Logf(ctx, s.logger, "record failure for job %s: %v", payload.JobID, recordErr)
+		}
+	}
+
+	if s.deadLetters == nil {
+		return
+	}
+	info, dlqErr := s.deadLetters.EnqueueDeadLetter(ctx, task)
+	if dlqErr != nil {
+		requestid.Logf(ctx, s.logger, "dead-letter enqueue failed type=%s err=%v", task.Type(), dlqErr)
+		return
+	}
+	requestid.Logf(ctx, s.logger, "task dead-lettered type=%s queue=%s task_id=%s", task.Type(), info.Queue, info.ID)
+}
+
+func truncateErrorMessage(err error) string {
+	msg := err.Error()
+	if len(msg) <= maxErrorMessageLength {
+		return msg
+	}
+	cut := maxErrorMessageLength
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut]
 }
 
 func (s *Server) MetricsHandler() http.Handler {
@@ -116,14 +365,24 @@ func (s *Server) handleProcessImage(ctx context.Context, task *asynq.Task) error
 
 	payload, err := queue.ParseProcessImagePayload(task)
 	if err != nil {
+		s.rejectInvalidPayload(ctx, task, startedAt, err)
 		return fmt.Errorf("parse payload: %v: %w", err, asynq.SkipRetry)
 	}
+	ctx = requestid.WithID(ctx, payload.RequestID)
+
+	if !payload.DeadlineAt.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, payload.DeadlineAt)
+		defer cancel()
+	}
 
 	ctx, span := s.tracer.Start(ctx, "worker.process_image", trace.WithSpanKind(trace.SpanKindConsumer))
 	span.SetAttributes(
 		attribute.String("job.id", payload.JobID),
 		attribute.String("job.source_type", payload.SourceType),
 		attribute.Int("job.pipeline_steps", len(payload.Pipeline)),
+		attribute.Int("job.deadline_seconds", payload.DeadlineSeconds),
+		attribute.String("job.request_id", payload.RequestID),
 	)
 	defer span.End()
 	defer func() {
@@ -131,14 +390,34 @@ func (s *Server) handleProcessImage(ctx context.Context, task *asynq.Task) error
 		s.metrics.jobsTotal.WithLabelValues(payload.SourceType, outcome).Inc()
 	}()
 
-	s.sem <- struct{}{}
+	if err := s.acquireJobSlot(ctx, payload.UserID); err != nil {
+		if deadlineExceeded(ctx, payload) {
+			outcome = domain.JobStatusDeadlineExceeded
+			s.failJob(ctx, payload, outcome, startedAt, err, true)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "deadline exceeded")
+			return fmt.Errorf("wait for worker slot: %v: %w", err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("wait for worker slot: %w", err)
+	}
 	s.metrics.activeJobs.Inc()
+	ctx, cancelJob := context.WithCancelCause(ctx)
+	defer cancelJob(nil)
+	s.trackInFlight(payload.JobID, cancelJob)
 	defer func() {
-		<-s.sem
+		s.untrackInFlight(payload.JobID)
+		s.releaseJobSlot(payload.UserID)
 		s.metrics.activeJobs.Dec()
 	}()
 
-	s.logger.Printf(
+	if s.jobCancelled(ctx, payload.JobID) {
+		outcome = domain.JobStatusCancelled
+		requestid.Logf(ctx, s.logger, "Skipped cancelled job_id=%s", payload.JobID)
+		span.SetStatus(codes.Error, "cancelled")
+		return nil
+	}
+
+	requestid.Logf(ctx, s.logger,
 		"Working... job_id=%s source_type=%s outputs=%d object_key=%s",
 		payload.JobID,
 		payload.SourceType,
@@ -147,93 +426,543 @@ func (s *Server) handleProcessImage(ctx context.Context, task *asynq.Task) error
 	)
 
 	s.updateJobStatus(ctx, payload.JobID, domain.JobStatusProcessing)
+	s.dispatchWebhook(ctx, payload, "job.processing", withDeadline(map[string]any{
+		"job_id":       payload.JobID,
+		"status":       domain.JobStatusProcessing,
+		"source_type":  payload.SourceType,
+		"object_key":   payload.ObjectKey,
+		"requested_at": payload.RequestedAt,
+		"started_at":   startedAt.UTC(),
+	}, payload))
 
 	request := pipeline.Request{
-		JobID:      payload.JobID,
-		SourceType: payload.SourceType,
-		ObjectKey:  payload.ObjectKey,
-		Pipeline:   payload.Pipeline,
+		JobID:        payload.JobID,
+		SourceType:   payload.SourceType,
+		ObjectKey:    payload.ObjectKey,
+		Pipeline:     payload.Pipeline,
+		EmitSidecar:  payload.EmitSidecar,
+		EmitManifest: payload.EmitManifest,
+		OutputSubdir: payload.OutputSubdir,
+		FrameAt:      time.Duration(payload.FrameAtSeconds * float64(time.Second)),
 	}
 
 	var result pipeline.Result
 	switch payload.SourceType {
 	case domain.SourceTypeLocalFile:
 		result, err = s.localProcessor.Process(ctx, request)
+	case domain.SourceTypeHTTPURL:
+		if s.httpProcessor == nil {
+			err = fmt.Errorf("%w: %s", pipeline.ErrUnsupportedSourceType, payload.SourceType)
+			break
+		}
+		result, err = s.httpProcessor.Process(ctx, request)
+	case domain.SourceTypeVideo:
+		if s.videoProcessor == nil {
+			err = fmt.Errorf("%w: %s: %w", pipeline.ErrUnsupportedSourceType, payload.SourceType, s.videoErr)
+			break
+		}
+		result, err = s.videoProcessor.Process(ctx, request)
 	default:
 		result, err = s.objectProcessor.Process(ctx, request)
 	}
 	if err != nil {
-		s.updateJobStatus(ctx, payload.JobID, domain.JobStatusFailed)
 		span.RecordError(err)
+		if errors.Is(context.Cause(ctx), errJobCancelled) {
+			outcome = domain.JobStatusCancelled
+			s.cancelJob(ctx, payload, startedAt)
+			span.SetStatus(codes.Error, "cancelled")
+			return nil
+		}
+		if interrupted(ctx) {
+			outcome = domain.JobStatusOrphaned
+			s.orphanJob(ctx, payload.JobID)
+			span.SetStatus(codes.Error, "interrupted")
+			return fmt.Errorf("run pipeline: %w", err)
+		}
+		if deadlineExceeded(ctx, payload) {
+			outcome = domain.JobStatusDeadlineExceeded
+			s.failJob(ctx, payload, outcome, startedAt, err, true)
+			span.SetStatus(codes.Error, "deadline exceeded")
+			return fmt.Errorf("run pipeline: %v: %w", err, asynq.SkipRetry)
+		}
+		skipRetry := pipeline.IsPoisonInput(err) || errors.Is(err, pipeline.ErrOutputExists)
+		s.failJob(ctx, payload, domain.JobStatusFailed, startedAt, err, skipRetry || finalAttempt(ctx))
 		span.SetStatus(codes.Error, "pipeline failed")
-		s.dispatchWebhook(ctx, payload, "job.failed", map[string]any{
-			"job_id":       payload.JobID,
-			"status":       domain.JobStatusFailed,
-			"source_type":  payload.SourceType,
-			"object_key":   payload.ObjectKey,
-			"requested_at": payload.RequestedAt,
-			"failed_at":    time.Now().UTC(),
-			"error":        err.Error(),
-		})
+		if skipRetry {
+			return fmt.Errorf("run pipeline: %w: %w", err, asynq.SkipRetry)
+		}
 		return fmt.Errorf("run pipeline: %w", err)
 	}
 
-	s.logger.Printf("Processed job_id=%s outputs=%d", payload.JobID, len(result.Outputs))
-	s.updateJobStatus(ctx, payload.JobID, domain.JobStatusSucceeded)
+	processingTime := time.Since(startedAt)
+	requestid.Logf(ctx, s.logger, "Processed job_id=%s outputs=%d processing_time_ms=%d", payload.JobID, len(result.Outputs), processingTime.Milliseconds())
+	s.recordOutputs(ctx, payload.JobID, result)
+	if !s.finishJob(ctx, payload.JobID, domain.JobStatusSucceeded, "", processingTime) {
+		outcome = domain.JobStatusCancelled
+		span.SetStatus(codes.Error, "cancelled")
+		return nil
+	}
 	s.metrics.pipelineOutputsTotal.Add(float64(len(result.Outputs)))
-	s.recordUsage(ctx, payload.JobID, result, time.Since(startedAt))
+	s.recordUsage(ctx, payload.JobID, result, processingTime)
+	s.deleteSource(ctx, payload)
+	outcome = domain.JobStatusSucceeded
 
-	if err := s.dispatchWebhook(ctx, payload, "job.completed", map[string]any{
-		"job_id":       payload.JobID,
-		"status":       domain.JobStatusSucceeded,
-		"source_type":  payload.SourceType,
-		"object_key":   payload.ObjectKey,
-		"requested_at": payload.RequestedAt,
-		"completed_at": time.Now().UTC(),
-		"outputs":      result.Outputs,
-	}); err != nil {
+	completed := withDeadline(map[string]any{
+		"job_id":             payload.JobID,
+		"status":             domain.JobStatusSucceeded,
+		"source_type":        payload.SourceType,
+		"object_key":         payload.ObjectKey,
+		"requested_at":       payload.RequestedAt,
+		"completed_at":       time.Now().UTC(),
+		"processing_time_ms": processingTime.Milliseconds(),
+		"outputs":            s.completedOutputs(ctx, payload, result.Outputs),
+	}, payload)
+	if result.ManifestPath != "" {
+		manifest := map[string]any{"path": result.ManifestPath}
+		if url := s.outputURL(ctx, payload, result.ManifestPath); url != "" {
+			manifest["url"] = url
+		}
+		completed["manifest"] = manifest
+	}
+
+	// The job is done from here on: returning a retryable error would make
+	// asynq reprocess an image that already succeeded.
+	if err := s.publishEvent(ctx, payload, "job.completed", completed); err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "webhook dispatch failed")
-		return err
+		span.SetStatus(codes.Error, "event publish failed")
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
 	}
 
-	outcome = domain.JobStatusSucceeded
 	span.SetStatus(codes.Ok, "processed")
 	return nil
 }
 
+// newJobSemaphore bounds concurrently processing jobs below asynq's
+// Concurrency. Asynq already caps how many handlers run at once; the semaphore
+// exists so heavy image work can be limited more tightly than task intake
+// (for example while handlers wait on storage or webhooks). A limit <= 0
+// disables it and leaves asynq Concurrency as the only limiter.
+func newJobSemaphore(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+func (s *Server) acquireSlot(ctx context.Context) error {
+	if s.sem == nil {
+		return ctx.Err()
+	}
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		<-s.sem
+		return err
+	}
+	return nil
+}
+
+func (s *Server) releaseSlot() {
+	if s.sem == nil {
+		return
+	}
+	<-s.sem
+}
+
+// acquireJobSlot takes the user's slot before a global one, so a user at
+// their cap waits without holding a slot another user's job could use.
+func (s *Server) acquireJobSlot(ctx context.Context, userID string) error {
+	if err := s.userSlots.acquire(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.acquireSlot(ctx); err != nil {
+		s.userSlots.release(userID)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) releaseJobSlot(userID string) {
+	s.releaseSlot()
+	s.userSlots.release(userID)
+}
+
+// trackInFlight registers a running job and the func that cancels its
+// context, so cancelInFlight can interrupt it.
+func (s *Server) trackInFlight(jobID string, cancel context.CancelCauseFunc) {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	if s.inFlight == nil {
+		s.inFlight = make(map[string]context.CancelCauseFunc)
+	}
+	s.inFlight[jobID] = cancel
+}
+
+// cancelInFlight cancels jobID's context if this worker is running it. Every
+// worker receives every cancel, so unknown ids are ignored.
+func (s *Server) cancelInFlight(jobID string) {
+	s.inFlightMu.Lock()
+	cancel, ok := s.inFlight[jobID]
+	s.inFlightMu.Unlock()
+	if !ok {
+		return
+	}
+	s.logger.Printf("cancelling job_id=%s", jobID)
+	cancel(errJobCancelled)
+}
+
+// jobCancelled reports whether the API cancelled or deleted jobID before it
+// started; a deleted job's task must not write outputs nobody can remove.
+func (s *Server) jobCancelled(ctx context.Context, jobID string) bool {
+	if s.jobStore == nil {
+		return false
+	}
+	job, ok, err := s.jobStore.Get(ctx, jobID)
+	if err != nil {
+		requestid.Logf(ctx, s.logger, "job status lookup failed job_id=%s err=%v", jobID, err)
+		return false
+	}
+	return !ok || job.Status == domain.JobStatusCancelled
+}
+
+func (s *Server) untrackInFlight(jobID string) {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	delete(s.inFlight, jobID)
+}
+
+// interrupted reports whether asynq itself cancelled the handler, on
+// shutdown or a lost lease. Asynq has then already requeued or retried the
+// task and ignores the handler's result, so the job must not be failed.
+func interrupted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled) && !errors.Is(context.Cause(ctx), errJobCancelled)
+}
+
+// orphanJob marks an interrupted job orphaned until asynq runs its task
+// again, which moves it back to processing.
+func (s *Server) orphanJob(ctx context.Context, jobID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), terminalUpdateTimeout)
+	defer cancel()
+	requestid.Logf(ctx, s.logger, "job interrupted, task left to asynq job_id=%s", jobID)
+	s.metrics.jobsInterruptedTotal.Inc()
+	s.updateJobStatus(ctx, jobID, domain.JobStatusOrphaned)
+}
+
+// failJob records a failed attempt. Webhooks hear about every attempt, but
+// event sinks only get job.failed when final is set, i.e. asynq will not run
+// the task again, so they never see a failure followed by job.completed.
+func (s *Server) failJob(ctx context.Context, payload queue.ProcessImagePayload, status string, startedAt time.Time, cause error, final bool) {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), terminalUpdateTimeout)
+		defer cancel()
+	}
+
+	processingTime := time.Since(startedAt)
+	if !s.finishJob(ctx, payload.JobID, status, truncateErrorMessage(cause), processingTime) {
+		return
+	}
+	body := withDeadline(map[string]any{
+		"job_id":             payload.JobID,
+		"status":             status,
+		"source_type":        payload.SourceType,
+		"object_key":         payload.ObjectKey,
+		"requested_at":       payload.RequestedAt,
+		"failed_at":          time.Now().UTC(),
+		"processing_time_ms": processingTime.Milliseconds(),
+		"error":              cause.Error(),
+	}, payload)
+	if !final {
+		_ = s.dispatchWebhook(ctx, payload, "job.failed", body)
+		return
+	}
+	s.publishEvent(ctx, payload, "job.failed", body)
+}
+
+// finalAttempt reports whether asynq will stop retrying the task if this
+// attempt fails, matching the check in handleTaskError. Outside an asynq
+// handler every attempt is final.
+func finalAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return true
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if !ok {
+		return true
+	}
+	return retried >= maxRetry
+}
+
+// cancelJob records a job whose pipeline was interrupted by a cancel request.
+// The API already set the status; this adds the processing time.
+func (s *Server) cancelJob(ctx context.Context, payload queue.ProcessImagePayload, startedAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), terminalUpdateTimeout)
+	defer cancel()
+
+	processingTime := time.Since(startedAt)
+	requestid.Logf(ctx, s.logger, "Cancelled job_id=%s processing_time_ms=%d", payload.JobID, processingTime.Milliseconds())
+	s.finishJob(ctx, payload.JobID, domain.JobStatusCancelled, "", processingTime)
+}
+
+func (s *Server) rejectInvalidPayload(ctx context.Context, task *asynq.Task, startedAt time.Time, cause error) {
+	payload, ok := queue.RecoverProcessImagePayload(task)
+	if !ok {
+		requestid.Logf(ctx, s.logger, "invalid payload without recoverable job_id err=%v", cause)
+		return
+	}
+	ctx = requestid.WithID(ctx, payload.RequestID)
+
+	requestid.Logf(ctx, s.logger, "invalid payload job_id=%s err=%v", payload.JobID, cause)
+	s.metrics.jobsTotal.WithLabelValues(payload.SourceType, domain.JobStatusFailed).Inc()
+	s.failJob(ctx, payload, domain.JobStatusFailed, startedAt, fmt.Errorf("invalid payload: %w", cause), true)
+}
+
+func withDeadline(body map[string]any, payload queue.ProcessImagePayload) map[string]any {
+	if payload.DeadlineAt.IsZero() {
+		return body
+	}
+	body["deadline_seconds"] = payload.DeadlineSeconds
+	body["deadline_at"] = payload.DeadlineAt
+	return body
+}
+
+func deadlineExceeded(ctx context.Context, payload queue.ProcessImagePayload) bool {
+	return !payload.DeadlineAt.IsZero() && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
 func (s *Server) updateJobStatus(ctx context.Context, jobID, status string) {
 	if s.jobStore == nil {
 		return
 	}
 	if _, err := s.jobStore.UpdateStatus(ctx, jobID, status); err != nil {
-		s.logger.Printf("job status update failed job_id=%s status=%s err=%v", jobID, status, err)
+		requestid.Logf(ctx, s.logger, "job status update failed job_id=%s status=%s err=%v", jobID, status, err)
+	}
+}
+
+// recordOutputs stores every path the run wrote, sidecars and manifest
+// included, so deleting the job can remove them wherever they landed.
+func (s *Server) recordOutputs(ctx context.Context, jobID string, result pipeline.Result) {
+	if s.jobStore == nil {
+		return
+	}
+	paths := make([]string, 0, len(result.Outputs)+1)
+	for _, output := range result.Outputs {
+		if output.Path != "" {
+			paths = append(paths, output.Path)
+		}
+		if output.SidecarPath != "" {
+			paths = append(paths, output.SidecarPath)
+		}
+	}
+	if result.ManifestPath != "" {
+		paths = append(paths, result.ManifestPath)
+	}
+	if err := s.jobStore.RecordOutputs(ctx, jobID, paths); err != nil {
+		requestid.Logf(ctx, s.logger, "record outputs failed job_id=%s err=%v", jobID, err)
 	}
 }
 
+// finishJob records the job's final status and error message. It reports
+// false when the job was cancelled while it ran: the cancel wins and the
+// caller must not report any other outcome.
+func (s *Server) finishJob(ctx context.Context, jobID, status, errMsg string, processingTime time.Duration) bool {
+	if s.jobStore == nil {
+		return true
+	}
+	_, err := s.jobStore.FinishProcessing(ctx, jobID, status, errMsg, processingTime)
+	if errors.Is(err, store.ErrJobCancelled) {
+		requestid.Logf(ctx, s.logger, "job was cancelled before it finished job_id=%s status=%s", jobID, status)
+		return false
+	}
+	if err != nil {
+		requestid.Logf(ctx, s.logger, "job status update failed job_id=%s status=%s err=%v", jobID, status, err)
+	}
+	return true
+}
+
+// publishEvent hands a terminal job event to the job's webhook and every
+// configured sink, attempting all of them before reporting sink failures.
+// Webhook failures are logged and counted but never returned: the job's
+// outcome does not depend on its receiver.
+func (s *Server) publishEvent(ctx context.Context, payload queue.ProcessImagePayload, event string, body map[string]any) error {
+	_ = s.dispatchWebhook(ctx, payload, event, body)
+
+	var errs []error
+	for _, sink := range s.eventSinks {
+		err := sink.Publish(ctx, event, body)
+		if err == nil {
+			continue
+		}
+		s.metrics.eventPublishFailuresTotal.WithLabelValues(event).Inc()
+		requestid.Logf(ctx, s.logger, "event publish failed job_id=%s event=%s sink=%T err=%v", payload.JobID, event, sink, err)
+		errs = append(errs, fmt.Errorf("publish event: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// dispatchWebhook enqueues a webhook:deliver task for the event so a slow or
+// failing receiver does not hold the processing slot. Without a webhook queue
+// (tests, or no webhook client) it delivers inline.
 func (s *Server) dispatchWebhook(ctx context.Context, payload queue.ProcessImagePayload, event string, body map[string]any) error {
-	if payload.WebhookURL == "" || s.webhookClient == nil {
+	if payload.WebhookURL == "" || s.webhookClient == nil || !s.webhookClient.Enabled(event) {
 		return nil
 	}
 
-	if err := s.webhookClient.Send(ctx, payload.WebhookURL, event, body); err != nil {
-		s.logger.Printf("webhook delivery failed job_id=%s event=%s err=%v", payload.JobID, event, err)
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		requestid.Logf(ctx, s.logger, "webhook payload encode failed job_id=%s event=%s err=%v", payload.JobID, event, err)
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	delivery := queue.DeliverWebhookPayload{
+		JobID:      payload.JobID,
+		Event:      event,
+		URL:        payload.WebhookURL,
+		Headers:    payload.WebhookHeaders,
+		Body:       encoded,
+		RetryCount: payload.RetryCount,
+		RequestID:  payload.RequestID,
+	}
+	if s.webhookQueue == nil {
+		return s.deliverWebhook(ctx, delivery)
+	}
+
+	// Record pending before enqueueing so a fast handler's result is never
+	// overwritten by it.
+	s.recordWebhookDelivery(ctx, domain.WebhookDelivery{JobID: payload.JobID, Event: event, Status: domain.WebhookDeliveryPending})
+	queueName, _ := asynq.GetQueueName(ctx)
+	if _, err := s.webhookQueue.EnqueueDeliverWebhook(ctx, queueName, delivery); err != nil {
+		s.metrics.webhookFailuresTotal.WithLabelValues(event).Inc()
+		requestid.Logf(ctx, s.logger, "webhook enqueue failed job_id=%s event=%s err=%v", payload.JobID, event, err)
+		s.recordWebhookDelivery(ctx, domain.WebhookDelivery{JobID: payload.JobID, Event: event, Status: domain.WebhookDeliveryFailed, LastError: truncateErrorMessage(err)})
+		return fmt.Errorf("enqueue webhook: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleDeliverWebhook(ctx context.Context, task *asynq.Task) error {
+	delivery, err := queue.ParseDeliverWebhookPayload(task)
+	if err != nil {
+		requestid.Logf(ctx, s.logger, "invalid webhook task err=%v", err)
+		return fmt.Errorf("parse payload: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = requestid.WithID(ctx, delivery.RequestID)
+	if s.webhookClient == nil {
+		return fmt.Errorf("webhook client is not configured: %w", asynq.SkipRetry)
+	}
+	return s.deliverWebhook(ctx, delivery)
+}
+
+func (s *Server) deliverWebhook(ctx context.Context, delivery queue.DeliverWebhookPayload) error {
+	attempts, err := s.webhookClient.Deliver(ctx, delivery.URL, delivery.Event, delivery.Headers, delivery.Body)
+	state := domain.WebhookDelivery{
+		JobID:     delivery.JobID,
+		Event:     delivery.Event,
+		Status:    domain.WebhookDeliveryDelivered,
+		Attempts:  len(attempts),
+		UpdatedAt: time.Now().UTC(),
+	}
+	for _, attempt := range attempts {
+		s.metrics.webhookAttemptsTotal.WithLabelValues(delivery.Event, attempt.Outcome()).Inc()
+		s.metrics.webhookDuration.WithLabelValues(delivery.Event).Observe(attempt.Duration.Seconds())
+		state.LastStatusCode = attempt.StatusCode
+	}
+	if err != nil {
+		s.metrics.webhookFailuresTotal.WithLabelValues(delivery.Event).Inc()
+		requestid.Logf(ctx, s.logger, "webhook delivery failed job_id=%s event=%s attempts=%d err=%v", delivery.JobID, delivery.Event, len(attempts), err)
+		state.Status = domain.WebhookDeliveryPending
+		if lastTaskAttempt(ctx) {
+			state.Status = domain.WebhookDeliveryFailed
+		}
+		state.LastError = truncateErrorMessage(err)
+		s.recordWebhookDelivery(ctx, state)
 		return fmt.Errorf("dispatch webhook: %w", err)
 	}
 
+	state.DeliveredAt = state.UpdatedAt
+	s.recordWebhookDelivery(ctx, state)
 	return nil
 }
 
+func (s *Server) recordWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) {
+	if s.deliveries == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), terminalUpdateTimeout)
+		defer cancel()
+	}
+	if err := s.deliveries.RecordWebhookDelivery(ctx, delivery); err != nil {
+		requestid.Logf(ctx, s.logger, "webhook delivery record failed job_id=%s event=%s err=%v", delivery.JobID, delivery.Event, err)
+	}
+}
+
+// lastTaskAttempt reports whether asynq will not retry the running task after
+// a failure; outside an asynq task every failure is final.
+func lastTaskAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return true
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	return !ok || retried >= maxRetry
+}
+
+func (s *Server) completedOutputs(ctx context.Context, payload queue.ProcessImagePayload, outputs []pipeline.Output) []completedOutput {
+	completed := make([]completedOutput, 0, len(outputs))
+	for _, output := range outputs {
+		completed = append(completed, completedOutput{Output: output, URL: s.outputURL(ctx, payload, output.Path)})
+	}
+	return completed
+}
+
+// outputURL presigns an object-store output, returning "" for local outputs,
+// when presigning is disabled, or when it fails.
+func (s *Server) outputURL(ctx context.Context, payload queue.ProcessImagePayload, objectKey string) string {
+	if s.outputURLs == nil || s.outputURLExpiry <= 0 || payload.SourceType == domain.SourceTypeLocalFile {
+		return ""
+	}
+	url, err := s.outputURLs.PresignedGetURL(ctx, objectKey, s.outputURLExpiry)
+	if err != nil {
+		requestid.Logf(ctx, s.logger, "output presign failed job_id=%s key=%s err=%v", payload.JobID, objectKey, err)
+		return ""
+	}
+	return url
+}
+
+func (s *Server) deleteSource(ctx context.Context, payload queue.ProcessImagePayload) {
+	if !payload.DeleteSource || s.sources == nil || payload.SourceType == domain.SourceTypeLocalFile || payload.SourceType == domain.SourceTypeHTTPURL {
+		return
+	}
+	if err := s.sources.DeleteObject(ctx, payload.ObjectKey); err != nil {
+		requestid.Logf(ctx, s.logger, "source delete failed job_id=%s object_key=%s err=%v", payload.JobID, payload.ObjectKey, err)
+	}
+}
+
 func (s *Server) recordUsage(ctx context.Context, jobID string, result pipeline.Result, computeDuration time.Duration) {
 	if s.usageStore == nil {
 		return
 	}
+	if len(result.Outputs) == 0 {
+		requestid.Logf(ctx, s.logger, "usage log skipped job_id=%s reason=no outputs", jobID)
+		return
+	}
+	for _, output := range result.Outputs {
+		if !output.Success {
+			requestid.Logf(ctx, s.logger, "usage log skipped job_id=%s reason=incomplete output step=%s", jobID, output.StepID)
+			return
+		}
+	}
 
 	userID := "anonymous"
 	if s.jobStore != nil {
 		job, ok, err := s.jobStore.Get(ctx, jobID)
 		if err != nil {
-			s.logger.Printf("usage lookup failed job_id=%s err=%v", jobID, err)
+			requestid.Logf(ctx, s.logger, "usage lookup failed job_id=%s err=%v", jobID, err)
 		} else if ok && strings.TrimSpace(job.UserID) != "" {
 			userID = job.UserID
 		}
@@ -267,7 +996,7 @@ func (s *Server) recordUsage(ctx context.Context, jobID string, result pipeline.
 		CreatedAt:       time.Now().UTC(),
 	}
 	if err := s.usageStore.CreateUsageLog(ctx, usage); err != nil {
-		s.logger.Printf("usage log write failed job_id=%s err=%v", jobID, err)
+		requestid.Logf(ctx, s.logger, "usage log write failed job_id=%s err=%v", jobID, err)
 		return
 	}
 
@@ -275,10 +1004,3 @@ func (s *Server) recordUsage(ctx context.Context, jobID string, result pipeline.
 	s.metrics.bytesSavedTotal.Add(float64(bytesSaved))
 	s.metrics.computeTimeMSTotal.Add(float64(computeTimeMS))
 }
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
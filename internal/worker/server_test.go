@@ -1,15 +1,34 @@
 package worker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
 	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/dunamismax/pixelflow/internal/domain"
+	"github.com/dunamismax/pixelflow/internal/events"
 	"github.com/dunamismax/pixelflow/internal/pipeline"
+	"github.com/dunamismax/pixelflow/internal/queue"
 	"github.com/dunamismax/pixelflow/internal/store"
+	"github.com/dunamismax/pixelflow/internal/webhook"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
 )
 
 func TestRecordUsageWritesUsageLog(t *testing.T) {
@@ -38,8 +57,8 @@ func TestRecordUsageWritesUsageLog(t *testing.T) {
 	s.recordUsage(context.Background(), "job-1", pipeline.Result{
 		SourceBytes: 1_000,
 		Outputs: []pipeline.Output{
-			{Width: 10, Height: 10, Bytes: 300},
-			{Width: 20, Height: 20, Bytes: 400},
+			{Width: 10, Height: 10, Bytes: 300, Success: true},
+			{Width: 20, Height: 20, Bytes: 400, Success: true},
 		},
 	}, 250*time.Millisecond)
 
@@ -71,7 +90,7 @@ func TestRecordUsageClampsNegativeBytesSaved(t *testing.T) {
 	s.recordUsage(context.Background(), "job-2", pipeline.Result{
 		SourceBytes: 100,
 		Outputs: []pipeline.Output{
-			{Width: 5, Height: 5, Bytes: 200},
+			{Width: 5, Height: 5, Bytes: 200, Success: true},
 		},
 	}, 0)
 
@@ -83,6 +102,928 @@ func TestRecordUsageClampsNegativeBytesSaved(t *testing.T) {
 	}
 }
 
+func TestRecordUsageKeepsOnlyFinalAttempt(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	s := &Server{
+		logger:     log.New(io.Discard, "", 0),
+		jobStore:   jobStore,
+		usageStore: jobStore,
+		metrics:    newMetrics(),
+	}
+
+	s.recordUsage(context.Background(), "job-retry", pipeline.Result{
+		SourceBytes: 1_000,
+		Outputs:     []pipeline.Output{{Width: 10, Height: 10, Bytes: 100, Success: true}},
+	}, 900*time.Millisecond)
+	s.recordUsage(context.Background(), "job-retry", pipeline.Result{
+		SourceBytes: 1_000,
+		Outputs:     []pipeline.Output{{Width: 20, Height: 20, Bytes: 200, Success: true}},
+	}, 150*time.Millisecond)
+
+	usage, ok, err := jobStore.GetUsageLog(context.Background(), "job-retry")
+	if err != nil {
+		t.Fatalf("fetch usage log: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected usage log for retried job")
+	}
+	if usage.ComputeTimeMS != 150 {
+		t.Fatalf("expected compute_time_ms from final attempt (150), got %d", usage.ComputeTimeMS)
+	}
+	if usage.PixelsProcessed != 400 {
+		t.Fatalf("expected pixels_processed from final attempt (400), got %d", usage.PixelsProcessed)
+	}
+	if usage.BytesSaved != 800 {
+		t.Fatalf("expected bytes_saved from final attempt (800), got %d", usage.BytesSaved)
+	}
+}
+
+func TestRecordUsageSkipsIncompleteAttempt(t *testing.T) {
+	usageStore := &captureUsageStore{}
+	s := &Server{
+		logger:     log.New(io.Discard, "", 0),
+		usageStore: usageStore,
+		metrics:    newMetrics(),
+	}
+
+	s.recordUsage(context.Background(), "job-failed", pipeline.Result{
+		SourceBytes: 1_000,
+		Outputs: []pipeline.Output{
+			{Width: 10, Height: 10, Bytes: 100, Success: true},
+			{StepID: "broken", Success: false},
+		},
+	}, 100*time.Millisecond)
+	s.recordUsage(context.Background(), "job-failed", pipeline.Result{SourceBytes: 1_000}, 100*time.Millisecond)
+
+	if usageStore.called {
+		t.Fatal("expected no usage log for an attempt without complete outputs")
+	}
+}
+
+func TestHandleProcessImageMarksDeadlineExceeded(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:              "job-3",
+		Status:          domain.JobStatusQueued,
+		SourceType:      domain.SourceTypeLocalFile,
+		ObjectKey:       "input.png",
+		DeadlineSeconds: 1,
+		Pipeline:        []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		CreatedAt:       time.Now().UTC(),
+		UpdatedAt:       time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	s := &Server{
+		logger:   log.New(io.Discard, "", 0),
+		sem:      make(chan struct{}, 1),
+		jobStore: jobStore,
+		metrics:  newMetrics(),
+		tracer:   otel.Tracer("test"),
+	}
+
+	requestedAt := time.Now().UTC().Add(-2 * time.Second)
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:           "job-3",
+		SourceType:      domain.SourceTypeLocalFile,
+		ObjectKey:       "input.png",
+		Pipeline:        []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 100}},
+		RequestedAt:     requestedAt,
+		DeadlineSeconds: 1,
+		DeadlineAt:      requestedAt.Add(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+
+	err = s.handleProcessImage(context.Background(), task)
+	if !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected non-retryable error, got %v", err)
+	}
+
+	job, _, err := jobStore.Get(context.Background(), "job-3")
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if job.Status != domain.JobStatusDeadlineExceeded {
+		t.Fatalf("expected status=%s, got %s", domain.JobStatusDeadlineExceeded, job.Status)
+	}
+}
+
+func TestAcquireSlotWithoutSemaphoreIsUnlimited(t *testing.T) {
+	s := &Server{sem: newJobSemaphore(0)}
+	for i := 0; i < 3; i++ {
+		if err := s.acquireSlot(context.Background()); err != nil {
+			t.Fatalf("acquire slot %d: %v", i, err)
+		}
+	}
+	s.releaseSlot()
+
+	limited := &Server{sem: newJobSemaphore(1)}
+	if err := limited.acquireSlot(context.Background()); err != nil {
+		t.Fatalf("acquire first slot: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limited.acquireSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second acquire to wait for context, got %v", err)
+	}
+}
+
+func TestAcquireJobSlotCapsEachUser(t *testing.T) {
+	s := &Server{sem: newJobSemaphore(3), userSlots: newUserSlots(1)}
+	if err := s.acquireJobSlot(context.Background(), "user-a"); err != nil {
+		t.Fatalf("acquire first user-a slot: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquireJobSlot(ctx, "user-a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second user-a job to wait, got %v", err)
+	}
+	if got := len(s.sem); got != 1 {
+		t.Fatalf("expected the waiting job not to hold a global slot, got %d in use", got)
+	}
+	if err := s.acquireJobSlot(context.Background(), "user-b"); err != nil {
+		t.Fatalf("expected user-b to get a slot while user-a is capped: %v", err)
+	}
+	if err := s.acquireJobSlot(context.Background(), ""); err != nil {
+		t.Fatalf("acquire first anonymous slot: %v", err)
+	}
+	anonCtx, anonCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer anonCancel()
+	if err := s.acquireJobSlot(anonCtx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected jobs without a user to share one capped slot, got %v", err)
+	}
+
+	s.releaseJobSlot("user-a")
+	s.releaseJobSlot("user-b")
+	s.releaseJobSlot("")
+	if len(s.sem) != 0 || len(s.userSlots.slots) != 0 {
+		t.Fatalf("expected all slots released and idle users dropped, got global=%d users=%d", len(s.sem), len(s.userSlots.slots))
+	}
+	if err := s.acquireJobSlot(context.Background(), "user-a"); err != nil {
+		t.Fatalf("expected user-a to get a slot after release: %v", err)
+	}
+}
+
+func TestHandleProcessImageFailsJobOnInvalidPayload(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-4",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-4/source",
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	webhooks := &captureWebhookSender{}
+	var logs bytes.Buffer
+	s := &Server{
+		logger:        log.New(&logs, "", 0),
+		jobStore:      jobStore,
+		webhookClient: webhooks,
+		metrics:       newMetrics(),
+		tracer:        otel.Tracer("test"),
+	}
+
+	task := asynq.NewTask(queue.TypeProcessImage, []byte(`{"job_id":"job-4","source_type":"s3_presigned","webhook_url":"http://hooks.local","request_id":"req-42","pipeline":{"id":"oops"}}`))
+	err := s.handleProcessImage(context.Background(), task)
+	if !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected non-retryable error, got %v", err)
+	}
+
+	job, _, err := jobStore.Get(context.Background(), "job-4")
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if job.Status != domain.JobStatusFailed {
+		t.Fatalf("expected status=%s, got %s", domain.JobStatusFailed, job.Status)
+	}
+	if !strings.HasPrefix(job.ErrorMessage, "invalid payload") {
+		t.Fatalf("expected persisted invalid payload reason, got %q", job.ErrorMessage)
+	}
+
+	if !strings.Contains(logs.String(), "invalid payload job_id=job-4") || !strings.Contains(logs.String(), "request_id=req-42") {
+		t.Fatalf("expected invalid payload log with request id, got %q", logs.String())
+	}
+
+	if webhooks.event != "job.failed" {
+		t.Fatalf("expected job.failed webhook, got %q", webhooks.event)
+	}
+	raw, ok := webhooks.payload.(json.RawMessage)
+	if !ok {
+		t.Fatalf("expected JSON webhook payload, got %T", webhooks.payload)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("decode webhook payload: %v", err)
+	}
+	if msg, _ := body["error"].(string); !strings.HasPrefix(msg, "invalid payload") {
+		t.Fatalf("expected invalid payload reason, got %q", msg)
+	}
+}
+
+func TestHandleProcessImageSendsProcessingEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		disabled map[string]bool
+		want     []string
+	}{
+		{name: "all events", want: []string{"job.processing", "job.failed"}},
+		{name: "terminal only", disabled: map[string]bool{"job.processing": true}, want: []string{"job.failed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			localProcessor, err := pipeline.NewLocalProcessor(t.TempDir())
+			if err != nil {
+				t.Fatalf("new local processor: %v", err)
+			}
+
+			webhooks := &captureWebhookSender{disabled: tc.disabled}
+			s := &Server{
+				logger:         log.New(io.Discard, "", 0),
+				localProcessor: localProcessor,
+				webhookClient:  webhooks,
+				metrics:        newMetrics(),
+				tracer:         otel.Tracer("test"),
+			}
+
+			task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+				JobID:       "job-5",
+				SourceType:  domain.SourceTypeLocalFile,
+				WebhookURL:  "http://hooks.local",
+				ObjectKey:   "/does/not/exist.png",
+				Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+				RequestedAt: time.Now().UTC(),
+			})
+			if err != nil {
+				t.Fatalf("build task: %v", err)
+			}
+			if err := s.handleProcessImage(context.Background(), task); err == nil {
+				t.Fatal("expected missing source to fail the job")
+			}
+
+			if strings.Join(webhooks.events, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected events %v, got %v", tc.want, webhooks.events)
+			}
+		})
+	}
+}
+
+func TestHandleProcessImageDoesNotRetryOversizedInput(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatalf("encode input: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	localProcessor, err := pipeline.NewLocalProcessor(filepath.Join(tmp, "out"), pipeline.WithMaxPixels(1_000))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	s := &Server{
+		logger:         log.New(io.Discard, "", 0),
+		localProcessor: localProcessor,
+		metrics:        newMetrics(),
+		tracer:         otel.Tracer("test"),
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-6",
+		SourceType:  domain.SourceTypeLocalFile,
+		ObjectKey:   inputPath,
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+
+	err = s.handleProcessImage(context.Background(), task)
+	if !errors.Is(err, asynq.SkipRetry) || !errors.Is(err, pipeline.ErrTooManyPixels) {
+		t.Fatalf("expected non-retryable pixel limit error, got %v", err)
+	}
+}
+
+func TestHandleProcessImageWebhookFailureDoesNotRerunPipeline(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatalf("encode input: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	var deliveries atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		deliveries.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-8",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeLocalFile,
+		ObjectKey:  inputPath,
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+	localProcessor, err := pipeline.NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	usage := &captureUsageStore{}
+	s := &Server{
+		logger:         log.New(io.Discard, "", 0),
+		localProcessor: localProcessor,
+		webhookClient:  webhook.NewClient(webhook.Config{SigningSecret: "test-secret", MaxAttempts: 1}),
+		jobStore:       jobStore,
+		usageStore:     usage,
+		metrics:        newMetrics(),
+		tracer:         otel.Tracer("test"),
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-8",
+		SourceType:  domain.SourceTypeLocalFile,
+		WebhookURL:  receiver.URL,
+		ObjectKey:   inputPath,
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+
+	if err := s.handleProcessImage(context.Background(), task); err != nil {
+		t.Fatalf("expected task to complete despite webhook 500, got %v", err)
+	}
+	if deliveries.Load() != 2 {
+		t.Fatalf("expected job.processing and job.completed deliveries, got %d", deliveries.Load())
+	}
+	job, _, err := jobStore.Get(context.Background(), "job-8")
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if job.Status != domain.JobStatusSucceeded {
+		t.Fatalf("expected status=%s, got %s", domain.JobStatusSucceeded, job.Status)
+	}
+	if want := filepath.Join(tmp, "out", "job-8", "thumb.png"); len(job.OutputPaths) != 1 || job.OutputPaths[0] != want {
+		t.Fatalf("expected recorded output paths [%s], got %v", want, job.OutputPaths)
+	}
+	if got := testutil.ToFloat64(s.metrics.jobsTotal.WithLabelValues(domain.SourceTypeLocalFile, domain.JobStatusSucceeded)); got != 1 {
+		t.Fatalf("expected job counted as succeeded, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.webhookFailuresTotal.WithLabelValues("job.completed")); got != 1 {
+		t.Fatalf("expected the webhook failure to be recorded, got %v", got)
+	}
+}
+
+func TestHandleProcessImageDoesNotRetryTruncatedInput(t *testing.T) {
+	tmp := t.TempDir()
+	inputPath := filepath.Join(tmp, "input.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatalf("encode input: %v", err)
+	}
+	if err := os.WriteFile(inputPath, buf.Bytes()[:buf.Len()/2], 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	localProcessor, err := pipeline.NewLocalProcessor(filepath.Join(tmp, "out"))
+	if err != nil {
+		t.Fatalf("new local processor: %v", err)
+	}
+	s := &Server{
+		logger:         log.New(io.Discard, "", 0),
+		localProcessor: localProcessor,
+		metrics:        newMetrics(),
+		tracer:         otel.Tracer("test"),
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-truncated",
+		SourceType:  domain.SourceTypeLocalFile,
+		ObjectKey:   inputPath,
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+
+	err = s.handleProcessImage(context.Background(), task)
+	if !errors.Is(err, asynq.SkipRetry) || !errors.Is(err, pipeline.ErrEmptyImage) {
+		t.Fatalf("expected non-retryable empty image error, got %v", err)
+	}
+}
+
+func TestShutdownRequeuesInterruptedJobAsOrphaned(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisOpt := asynq.RedisClientOpt{Addr: mr.Addr()}
+
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-7",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-7/source",
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	fetcher := blockingFetcher{started: make(chan struct{})}
+	objectProcessor, err := pipeline.NewObjectStoreProcessor(fetcher, nil)
+	if err != nil {
+		t.Fatalf("new object processor: %v", err)
+	}
+	webhooks := &captureWebhookSender{}
+	s := &Server{
+		logger:          log.New(io.Discard, "", 0),
+		objectProcessor: objectProcessor,
+		webhookClient:   webhooks,
+		jobStore:        jobStore,
+		metrics:         newMetrics(),
+		tracer:          otel.Tracer("test"),
+	}
+	s.server = asynq.NewServer(redisOpt, asynq.Config{
+		Concurrency:     1,
+		Queues:          map[string]int{"default": 1},
+		ShutdownTimeout: 50 * time.Millisecond,
+		LogLevel:        asynq.FatalLevel,
+	})
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(queue.TypeProcessImage, s.handleProcessImage)
+	if err := s.server.Start(mux); err != nil {
+		t.Fatalf("start asynq server: %v", err)
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-7",
+		SourceType:  domain.SourceTypeS3Presigned,
+		ObjectKey:   "uploads/job-7/source",
+		WebhookURL:  "https://example.com/hook",
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+	client := asynq.NewClient(redisOpt)
+	defer client.Close()
+	info, err := client.Enqueue(task, asynq.Queue("default"))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case <-fetcher.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("handler never started")
+	}
+	// Shutdown outlasts ShutdownTimeout, so asynq requeues the task and then
+	// cancels the still-running handler.
+	s.server.Shutdown()
+
+	deadline := time.Now().Add(5 * time.Second)
+	var job domain.Job
+	for {
+		job, _, _ = jobStore.Get(context.Background(), "job-7")
+		if job.Status == domain.JobStatusOrphaned || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != domain.JobStatusOrphaned {
+		t.Fatalf("expected status=%s, got %s", domain.JobStatusOrphaned, job.Status)
+	}
+	if job.ErrorMessage != "" {
+		t.Fatalf("expected no recorded failure, got %q", job.ErrorMessage)
+	}
+	for _, event := range webhooks.events {
+		if event == "job.failed" {
+			t.Fatal("expected no job.failed event for a requeued job")
+		}
+	}
+	if got := testutil.ToFloat64(s.metrics.jobsInterruptedTotal); got != 1 {
+		t.Fatalf("expected 1 interrupted job, got %v", got)
+	}
+
+	inspector := asynq.NewInspector(redisOpt)
+	defer inspector.Close()
+	requeued, err := inspector.GetTaskInfo("default", info.ID)
+	if err != nil {
+		t.Fatalf("get task info: %v", err)
+	}
+	if requeued.State != asynq.TaskStatePending || requeued.Retried != 0 {
+		t.Fatalf("expected the task back in pending without a retry, got state=%s retried=%d", requeued.State, requeued.Retried)
+	}
+}
+
+// unavailableFetcher fails every fetch with a retryable error.
+type unavailableFetcher struct{}
+
+func (unavailableFetcher) Fetch(context.Context, pipeline.Request) ([]byte, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+func TestFailedAttemptsReachSinksOnlyWhenFinal(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisOpt := asynq.RedisClientOpt{Addr: mr.Addr()}
+
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-8",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-8/source",
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	objectProcessor, err := pipeline.NewObjectStoreProcessor(unavailableFetcher{}, nil)
+	if err != nil {
+		t.Fatalf("new object processor: %v", err)
+	}
+	var mu sync.Mutex
+	var published []string
+	archived := make(chan struct{})
+	sink := events.SinkFunc(func(_ context.Context, event string, _ any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, event)
+		return nil
+	})
+	s := &Server{
+		logger:          log.New(io.Discard, "", 0),
+		objectProcessor: objectProcessor,
+		jobStore:        jobStore,
+		eventSinks:      []events.Sink{sink},
+		metrics:         newMetrics(),
+		tracer:          otel.Tracer("test"),
+	}
+	s.server = asynq.NewServer(redisOpt, asynq.Config{
+		Concurrency:              1,
+		Queues:                   map[string]int{"default": 1},
+		RetryDelayFunc:           func(int, error, *asynq.Task) time.Duration { return 0 },
+		DelayedTaskCheckInterval: 50 * time.Millisecond,
+		LogLevel:                 asynq.FatalLevel,
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, _ *asynq.Task, _ error) {
+			if finalAttempt(ctx) {
+				close(archived)
+			}
+		}),
+	})
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(queue.TypeProcessImage, s.handleProcessImage)
+	if err := s.server.Start(mux); err != nil {
+		t.Fatalf("start asynq server: %v", err)
+	}
+	defer s.server.Shutdown()
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-8",
+		SourceType:  domain.SourceTypeS3Presigned,
+		ObjectKey:   "uploads/job-8/source",
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+	client := asynq.NewClient(redisOpt)
+	defer client.Close()
+	if _, err := client.Enqueue(task, asynq.Queue("default"), asynq.MaxRetry(2)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case <-archived:
+	case <-time.After(20 * time.Second):
+		t.Fatal("task never ran out of retries")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(published) != 1 || published[0] != "job.failed" {
+		t.Fatalf("expected a single job.failed after the last of 3 attempts, got %v", published)
+	}
+}
+
+type captureDeadLetters struct {
+	tasks []*asynq.Task
+}
+
+func (c *captureDeadLetters) EnqueueDeadLetter(_ context.Context, task *asynq.Task) (*asynq.TaskInfo, error) {
+	c.tasks = append(c.tasks, task)
+	return &asynq.TaskInfo{ID: "dlq-1", Queue: "dead-letter"}, nil
+}
+
+func TestRecordFinalFailurePersistsErrorAndDeadLetters(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-dlq",
+		Status:     domain.JobStatusFailed,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-dlq/source",
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	deadLetters := &captureDeadLetters{}
+	s := &Server{
+		logger:      log.New(io.Discard, "", 0),
+		jobStore:    jobStore,
+		deadLetters: deadLetters,
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{JobID: "job-dlq", SourceType: domain.SourceTypeS3Presigned})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+	s.recordFinalFailure(context.Background(), task, errors.New("fetch stage: "+strings.Repeat("x", 2*maxErrorMessageLength)))
+
+	job, _, err := jobStore.Get(context.Background(), "job-dlq")
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.Status != domain.JobStatusFailed {
+		t.Fatalf("expected failed status, got %s", job.Status)
+	}
+	if !strings.HasPrefix(job.ErrorMessage, "fetch stage: ") || len(job.ErrorMessage) != maxErrorMessageLength {
+		t.Fatalf("expected truncated error message, got %d bytes", len(job.ErrorMessage))
+	}
+	if len(deadLetters.tasks) != 1 || !bytes.Equal(deadLetters.tasks[0].Payload(), task.Payload()) {
+		t.Fatalf("expected original task to be dead-lettered, got %d", len(deadLetters.tasks))
+	}
+}
+
+type captureObjectStore struct {
+	deleted  []string
+	prefixes []string
+	cutoff   time.Time
+}
+
+func (c *captureObjectStore) DeleteObject(_ context.Context, objectKey string) error {
+	c.deleted = append(c.deleted, objectKey)
+	return nil
+}
+
+func (c *captureObjectStore) PruneExpired(_ context.Context, prefix string, olderThan time.Time) (int, error) {
+	c.prefixes = append(c.prefixes, prefix)
+	c.cutoff = olderThan
+	return 0, nil
+}
+
+func TestDeleteSourceOnlyWhenRequested(t *testing.T) {
+	objects := &captureObjectStore{}
+	s := &Server{logger: log.New(io.Discard, "", 0), sources: objects}
+
+	s.deleteSource(context.Background(), queue.ProcessImagePayload{JobID: "keep", SourceType: domain.SourceTypeS3Presigned, ObjectKey: "uploads/keep/source"})
+	s.deleteSource(context.Background(), queue.ProcessImagePayload{JobID: "local", SourceType: domain.SourceTypeLocalFile, ObjectKey: "/tmp/input.png", DeleteSource: true})
+	s.deleteSource(context.Background(), queue.ProcessImagePayload{JobID: "drop", SourceType: domain.SourceTypeS3Presigned, ObjectKey: "uploads/drop/source", DeleteSource: true})
+
+	if strings.Join(objects.deleted, ",") != "uploads/drop/source" {
+		t.Fatalf("expected only uploads/drop/source to be deleted, got %v", objects.deleted)
+	}
+}
+
+func TestPruneExpiredCoversUploadsAndOutputs(t *testing.T) {
+	objects := &captureObjectStore{}
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	pruneExpired(context.Background(), log.New(io.Discard, "", 0), objects, cutoff)
+
+	if strings.Join(objects.prefixes, ",") != "uploads/,outputs/" {
+		t.Fatalf("expected uploads/ and outputs/ to be pruned, got %v", objects.prefixes)
+	}
+	if !objects.cutoff.Equal(cutoff) {
+		t.Fatalf("expected cutoff %s, got %s", cutoff, objects.cutoff)
+	}
+}
+
+func TestDispatchWebhookRecordsMetrics(t *testing.T) {
+	webhooks := &captureWebhookSender{}
+	s := &Server{
+		logger:        log.New(io.Discard, "", 0),
+		webhookClient: webhooks,
+		metrics:       newMetrics(),
+	}
+	payload := queue.ProcessImagePayload{JobID: "job-1", WebhookURL: "http://hooks.local"}
+
+	if err := s.dispatchWebhook(context.Background(), payload, "job.completed", map[string]any{}); err != nil {
+		t.Fatalf("dispatch webhook: %v", err)
+	}
+	webhooks.err = errors.New("hook down")
+	if err := s.dispatchWebhook(context.Background(), payload, "job.completed", map[string]any{}); err == nil {
+		t.Fatal("expected dispatch error")
+	}
+
+	if got := testutil.ToFloat64(s.metrics.webhookAttemptsTotal.WithLabelValues("job.completed", webhook.OutcomeSuccess)); got != 1 {
+		t.Fatalf("expected 1 successful attempt, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.webhookAttemptsTotal.WithLabelValues("job.completed", webhook.OutcomeHTTPError)); got != 1 {
+		t.Fatalf("expected 1 http_error attempt, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.webhookFailuresTotal.WithLabelValues("job.completed")); got != 1 {
+		t.Fatalf("expected 1 final failure, got %v", got)
+	}
+}
+
+func TestPublishEventFansOutToSinks(t *testing.T) {
+	webhooks := &captureWebhookSender{}
+	var published []string
+	healthy := events.SinkFunc(func(_ context.Context, event string, _ any) error {
+		published = append(published, event)
+		return nil
+	})
+	broken := events.SinkFunc(func(context.Context, string, any) error {
+		return errors.New("broker down")
+	})
+	s := &Server{
+		logger:        log.New(io.Discard, "", 0),
+		webhookClient: webhooks,
+		eventSinks:    []events.Sink{broken, healthy},
+		metrics:       newMetrics(),
+	}
+	payload := queue.ProcessImagePayload{JobID: "job-1", WebhookURL: "http://hooks.local"}
+
+	if err := s.publishEvent(context.Background(), payload, "job.completed", map[string]any{"job_id": "job-1"}); err == nil {
+		t.Fatal("expected sink failure to be reported")
+	}
+	if webhooks.event != "job.completed" || len(published) != 1 || published[0] != "job.completed" {
+		t.Fatalf("expected webhook and healthy sink to receive job.completed, got webhook=%q sink=%v", webhooks.event, published)
+	}
+	if got := testutil.ToFloat64(s.metrics.eventPublishFailuresTotal.WithLabelValues("job.completed")); got != 1 {
+		t.Fatalf("expected 1 publish failure, got %v", got)
+	}
+
+	s.eventSinks = []events.Sink{healthy}
+	if err := s.publishEvent(context.Background(), queue.ProcessImagePayload{JobID: "job-2"}, "job.failed", map[string]any{"job_id": "job-2"}); err != nil {
+		t.Fatalf("publish without webhook url: %v", err)
+	}
+	if len(published) != 2 || published[1] != "job.failed" {
+		t.Fatalf("expected sinks without a webhook url, got %v", published)
+	}
+
+	webhooks.err = errors.New("hook down")
+	if err := s.publishEvent(context.Background(), payload, "job.completed", map[string]any{"job_id": "job-1"}); err != nil {
+		t.Fatalf("expected webhook failure not to fail the event, got %v", err)
+	}
+}
+
+func TestDispatchWebhookEnqueuesDelivery(t *testing.T) {
+	webhooks := &captureWebhookSender{}
+	enqueuer := &captureWebhookEnqueuer{}
+	s := &Server{
+		logger:        log.New(io.Discard, "", 0),
+		webhookClient: webhooks,
+		webhookQueue:  enqueuer,
+		metrics:       newMetrics(),
+	}
+	payload := queue.ProcessImagePayload{
+		JobID:          "job-1",
+		WebhookURL:     "http://hooks.local",
+		WebhookHeaders: map[string]string{"Authorization": "Bearer token"},
+		RetryCount:     2,
+		RequestID:      "req-1",
+	}
+
+	if err := s.dispatchWebhook(context.Background(), payload, "job.completed", map[string]any{"job_id": "job-1"}); err != nil {
+		t.Fatalf("dispatch webhook: %v", err)
+	}
+	if len(webhooks.events) != 0 {
+		t.Fatalf("expected no inline delivery, got %v", webhooks.events)
+	}
+	if len(enqueuer.payloads) != 1 {
+		t.Fatalf("expected one enqueued delivery, got %d", len(enqueuer.payloads))
+	}
+	got := enqueuer.payloads[0]
+	if got.JobID != "job-1" || got.Event != "job.completed" || got.URL != "http://hooks.local" || got.RetryCount != 2 || got.RequestID != "req-1" {
+		t.Fatalf("unexpected delivery payload: %+v", got)
+	}
+	if got.Headers["Authorization"] != "Bearer token" || string(got.Body) != `{"job_id":"job-1"}` {
+		t.Fatalf("unexpected delivery headers/body: %+v body=%s", got.Headers, got.Body)
+	}
+}
+
+func TestHandleDeliverWebhookRetriesIndependently(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-1",
+		Status:     domain.JobStatusSucceeded,
+		SourceType: domain.SourceTypeS3Presigned,
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+	webhooks := &captureWebhookSender{err: errors.New("hook down")}
+	deadLetters := &captureDeadLetters{}
+	s := &Server{
+		logger:        log.New(io.Discard, "", 0),
+		jobStore:      jobStore,
+		webhookClient: webhooks,
+		deliveries:    jobStore,
+		deadLetters:   deadLetters,
+		metrics:       newMetrics(),
+	}
+
+	task, err := queue.NewDeliverWebhookTask(queue.DeliverWebhookPayload{
+		JobID: "job-1",
+		Event: "job.completed",
+		URL:   "http://hooks.local",
+		Body:  json.RawMessage(`{"job_id":"job-1"}`),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+	err = s.handleDeliverWebhook(context.Background(), task)
+	if err == nil || errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected retryable delivery error, got %v", err)
+	}
+	if webhooks.endpoint != "http://hooks.local" || webhooks.event != "job.completed" {
+		t.Fatalf("unexpected delivery: endpoint=%q event=%q", webhooks.endpoint, webhooks.event)
+	}
+
+	s.recordFinalFailure(context.Background(), task, err)
+	job, _, err := jobStore.Get(context.Background(), "job-1")
+	if err != nil {
+		t.Fatalf("fetch job: %v", err)
+	}
+	if job.Status != domain.JobStatusSucceeded || job.ErrorMessage != "" {
+		t.Fatalf("expected webhook failure to leave the job succeeded, got status=%s error=%q", job.Status, job.ErrorMessage)
+	}
+	deliveries, err := jobStore.ListWebhookDeliveries(context.Background(), "job-1")
+	if err != nil {
+		t.Fatalf("list webhook deliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != domain.WebhookDeliveryFailed || deliveries[0].Attempts != 1 || deliveries[0].LastStatusCode != http.StatusBadGateway || deliveries[0].LastError == "" {
+		t.Fatalf("expected failed delivery to be recorded, got %+v", deliveries)
+	}
+	if len(deadLetters.tasks) != 1 || deadLetters.tasks[0].Type() != queue.TypeDeliverWebhook {
+		t.Fatalf("expected exhausted webhook task to be dead-lettered, got %d", len(deadLetters.tasks))
+	}
+
+	if err := s.handleDeliverWebhook(context.Background(), asynq.NewTask(queue.TypeDeliverWebhook, []byte(`{"job_id":"job-1"}`))); !errors.Is(err, asynq.SkipRetry) {
+		t.Fatalf("expected malformed webhook task to skip retries, got %v", err)
+	}
+}
+
+type captureWebhookEnqueuer struct {
+	payloads []queue.DeliverWebhookPayload
+}
+
+func (c *captureWebhookEnqueuer) EnqueueDeliverWebhook(_ context.Context, _ string, payload queue.DeliverWebhookPayload) (*asynq.TaskInfo, error) {
+	c.payloads = append(c.payloads, payload)
+	return &asynq.TaskInfo{}, nil
+}
+
+type captureWebhookSender struct {
+	endpoint string
+	event    string
+	headers  map[string]string
+	payload  any
+	events   []string
+	disabled map[string]bool
+	err      error
+}
+
+func (c *captureWebhookSender) Deliver(_ context.Context, endpoint, event string, headers map[string]string, payload any) ([]webhook.Attempt, error) {
+	c.endpoint = endpoint
+	c.event = event
+	c.headers = headers
+	c.payload = payload
+	c.events = append(c.events, event)
+	if c.err != nil {
+		return []webhook.Attempt{{StatusCode: http.StatusBadGateway}}, c.err
+	}
+	return []webhook.Attempt{{StatusCode: http.StatusOK}}, nil
+}
+
+func (c *captureWebhookSender) Enabled(event string) bool {
+	return !c.disabled[event]
+}
+
 type captureUsageStore struct {
 	called bool
 	log    domain.UsageLog
@@ -93,3 +1034,221 @@ func (s *captureUsageStore) CreateUsageLog(_ context.Context, usage domain.Usage
 	s.log = usage
 	return nil
 }
+
+func (s *captureUsageStore) Summary(_ context.Context, userID string, _, _ time.Time) (domain.UsageSummary, error) {
+	return domain.UsageSummary{UserID: userID}, nil
+}
+
+func (s *captureUsageStore) ListUsage(_ context.Context, _ string, _, _ time.Time, _, _ int) ([]domain.UsageLog, error) {
+	return nil, nil
+}
+
+type fakeOutputPresigner struct {
+	expiry time.Duration
+}
+
+func (p *fakeOutputPresigner) PresignedGetURL(_ context.Context, objectKey string, expiry time.Duration) (string, error) {
+	p.expiry = expiry
+	return "https://storage.example/" + objectKey + "?signed=1", nil
+}
+
+func TestCompletedOutputsIncludePresignedURL(t *testing.T) {
+	presigner := &fakeOutputPresigner{}
+	s := &Server{
+		logger:          log.New(io.Discard, "", 0),
+		outputURLs:      presigner,
+		outputURLExpiry: 30 * time.Minute,
+	}
+
+	outputs := s.completedOutputs(context.Background(), queue.ProcessImagePayload{
+		JobID:      "job-urls",
+		SourceType: domain.SourceTypeS3Presigned,
+	}, []pipeline.Output{{StepID: "thumb", Path: "outputs/job-urls/thumb.jpeg", Success: true}})
+
+	if len(outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(outputs))
+	}
+	if outputs[0].Path != "outputs/job-urls/thumb.jpeg" {
+		t.Fatalf("expected object key to be kept, got %q", outputs[0].Path)
+	}
+	if outputs[0].URL != "https://storage.example/outputs/job-urls/thumb.jpeg?signed=1" {
+		t.Fatalf("unexpected output url %q", outputs[0].URL)
+	}
+	if presigner.expiry != 30*time.Minute {
+		t.Fatalf("expected presign expiry 30m, got %s", presigner.expiry)
+	}
+
+	local := s.completedOutputs(context.Background(), queue.ProcessImagePayload{
+		JobID:      "job-local",
+		SourceType: domain.SourceTypeLocalFile,
+	}, []pipeline.Output{{StepID: "thumb", Path: "/tmp/thumb.jpeg", Success: true}})
+	if local[0].URL != "" {
+		t.Fatalf("expected no url for local outputs, got %q", local[0].URL)
+	}
+}
+
+// blockingFetcher waits until its job is cancelled.
+type blockingFetcher struct {
+	started chan struct{}
+}
+
+func (f blockingFetcher) Fetch(ctx context.Context, _ pipeline.Request) ([]byte, error) {
+	close(f.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandleProcessImageCancelsRunningJob(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-9",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-9/source",
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	fetcher := blockingFetcher{started: make(chan struct{})}
+	objectProcessor, err := pipeline.NewObjectStoreProcessor(fetcher, nil)
+	if err != nil {
+		t.Fatalf("new object processor: %v", err)
+	}
+	s := &Server{
+		logger:          log.New(io.Discard, "", 0),
+		objectProcessor: objectProcessor,
+		jobStore:        jobStore,
+		metrics:         newMetrics(),
+		tracer:          otel.Tracer("test"),
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-9",
+		SourceType:  domain.SourceTypeS3Presigned,
+		ObjectKey:   "uploads/job-9/source",
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.handleProcessImage(context.Background(), task) }()
+	<-fetcher.started
+	s.cancelInFlight("unknown-job")
+	s.cancelInFlight("job-9")
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected cancelled job to complete the task, got %v", err)
+	}
+	job, _, _ := jobStore.Get(context.Background(), "job-9")
+	if job.Status != domain.JobStatusCancelled {
+		t.Fatalf("expected status=%s, got %s", domain.JobStatusCancelled, job.Status)
+	}
+
+	// A job cancelled while queued is skipped without running the pipeline.
+	if err := s.handleProcessImage(context.Background(), task); err != nil {
+		t.Fatalf("expected cancelled job to be skipped, got %v", err)
+	}
+
+	// So is a task whose job was deleted; blockingFetcher would panic on a
+	// second run because its started channel is already closed.
+	if err := jobStore.Delete(context.Background(), "job-9"); err != nil {
+		t.Fatalf("delete job: %v", err)
+	}
+	if err := s.handleProcessImage(context.Background(), task); err != nil {
+		t.Fatalf("expected deleted job to be skipped, got %v", err)
+	}
+}
+
+// cancellingFetcher cancels its job through the store, as the API does when
+// the worker misses the cancel signal, and then fails the fetch.
+type cancellingFetcher struct {
+	jobStore store.JobStore
+}
+
+func (f cancellingFetcher) Fetch(ctx context.Context, req pipeline.Request) ([]byte, error) {
+	if _, err := f.jobStore.ClaimCancel(ctx, req.JobID, "cancelled by request"); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("fetch failed")
+}
+
+func TestHandleProcessImageKeepsCancelledStatus(t *testing.T) {
+	jobStore := store.NewMemoryJobStore()
+	if err := jobStore.Create(context.Background(), domain.Job{
+		ID:         "job-9",
+		Status:     domain.JobStatusQueued,
+		SourceType: domain.SourceTypeS3Presigned,
+		ObjectKey:  "uploads/job-9/source",
+		Pipeline:   []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("seed job: %v", err)
+	}
+
+	objectProcessor, err := pipeline.NewObjectStoreProcessor(cancellingFetcher{jobStore: jobStore}, nil)
+	if err != nil {
+		t.Fatalf("new object processor: %v", err)
+	}
+	webhooks := &captureWebhookSender{}
+	s := &Server{
+		logger:          log.New(io.Discard, "", 0),
+		objectProcessor: objectProcessor,
+		webhookClient:   webhooks,
+		jobStore:        jobStore,
+		metrics:         newMetrics(),
+		tracer:          otel.Tracer("test"),
+	}
+
+	task, err := queue.NewProcessImageTask(queue.ProcessImagePayload{
+		JobID:       "job-9",
+		SourceType:  domain.SourceTypeS3Presigned,
+		ObjectKey:   "uploads/job-9/source",
+		Pipeline:    []domain.PipelineStep{{ID: "thumb", Action: "resize", Width: 10}},
+		WebhookURL:  "https://example.com/hook",
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("build task: %v", err)
+	}
+	_ = s.handleProcessImage(context.Background(), task)
+
+	job, _, _ := jobStore.Get(context.Background(), "job-9")
+	if job.Status != domain.JobStatusCancelled || job.ErrorMessage != "cancelled by request" {
+		t.Fatalf("expected the cancel to win, got status=%s error_message=%q", job.Status, job.ErrorMessage)
+	}
+	for _, event := range webhooks.events {
+		if event == "job.failed" {
+			t.Fatalf("expected no job.failed event for a cancelled job, got %v", webhooks.events)
+		}
+	}
+}
+
+func TestMetricsHandlerReportsTransformerBackend(t *testing.T) {
+	handler := newMetrics().Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `pixelflow_worker_backend_info{backend="` + pipeline.BackendName() + `"} 1`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected metrics to contain %s", want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info struct {
+		Backend string `json:"backend"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("unmarshal version: %v", err)
+	}
+	if info.Backend != pipeline.BackendName() {
+		t.Fatalf("expected /version backend %q, got %q", pipeline.BackendName(), info.Backend)
+	}
+}
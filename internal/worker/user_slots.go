@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// anonymousUser is the shared key for jobs without a user_id, including
+// payloads enqueued before user_id was added, so they are capped together
+// rather than skipping the cap.
+const anonymousUser = "anonymous"
+
+// userSlots caps how many of one user's jobs process at once, so a single
+// tenant can't hold every worker slot. A nil *userSlots disables the cap.
+//
+// A job waiting for its user's slot blocks inside its asynq handler, so it
+// still occupies one of WORKER_CONCURRENCY's goroutines while it waits.
+type userSlots struct {
+	limit int
+
+	mu    sync.Mutex
+	slots map[string]*userSlot
+}
+
+type userSlot struct {
+	sem chan struct{}
+	// refs counts holders and waiters; the entry is dropped at zero so idle
+	// users don't accumulate.
+	refs int
+}
+
+func newUserSlots(limit int) *userSlots {
+	if limit <= 0 {
+		return nil
+	}
+	return &userSlots{limit: limit, slots: make(map[string]*userSlot)}
+}
+
+func slotKey(userID string) string {
+	if userID == "" {
+		return anonymousUser
+	}
+	return userID
+}
+
+func (u *userSlots) acquire(ctx context.Context, userID string) error {
+	if u == nil {
+		return ctx.Err()
+	}
+	userID = slotKey(userID)
+	u.mu.Lock()
+	slot, ok := u.slots[userID]
+	if !ok {
+		slot = &userSlot{sem: make(chan struct{}, u.limit)}
+		u.slots[userID] = slot
+	}
+	slot.refs++
+	u.mu.Unlock()
+
+	select {
+	case slot.sem <- struct{}{}:
+		if err := ctx.Err(); err != nil {
+			<-slot.sem
+			u.unref(userID, slot)
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		u.unref(userID, slot)
+		return ctx.Err()
+	}
+}
+
+func (u *userSlots) release(userID string) {
+	if u == nil {
+		return
+	}
+	userID = slotKey(userID)
+	u.mu.Lock()
+	slot := u.slots[userID]
+	u.mu.Unlock()
+	if slot == nil {
+		return
+	}
+	<-slot.sem
+	u.unref(userID, slot)
+}
+
+func (u *userSlots) unref(userID string, slot *userSlot) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	slot.refs--
+	if slot.refs == 0 {
+		delete(u.slots, userID)
+	}
+}
@@ -0,0 +1,98 @@
+// Package webhook verifies PixelFlow webhook deliveries.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Pixelflow-Signature"
+	HeaderTimestamp = "X-Pixelflow-Timestamp"
+	HeaderEvent     = "X-Pixelflow-Event"
+
+	// DefaultTolerance is the maximum age (or clock skew) accepted for a
+	// delivery timestamp when no tolerance is given.
+	DefaultTolerance = 5 * time.Minute
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook signature is missing")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrInvalidTimestamp = errors.New("webhook timestamp is invalid")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance window")
+)
+
+// Sign returns the X-Pixelflow-Signature value for a timestamp and raw body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature against the raw body and rejects timestamps older
+// (or further in the future) than DefaultTolerance.
+func Verify(secret string, timestamp string, body []byte, signature string) error {
+	return verifyAt(secret, timestamp, body, signature, DefaultTolerance, time.Now())
+}
+
+// VerifyRequest reads and verifies r's body using the PixelFlow headers. A
+// tolerance <= 0 uses DefaultTolerance. The body is restored on r so handlers
+// can decode it afterwards, and is also returned for convenience.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("read webhook body: %w", io.ErrUnexpectedEOF)
+	}
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read webhook body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if err := verifyAt(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature), tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func verifyAt(secret, timestamp string, body []byte, signature string, tolerance time.Duration, now time.Time) error {
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := Sign(secret, strings.TrimSpace(timestamp), body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyAcceptsSignedPayload(t *testing.T) {
+	body := []byte(`{"job_id":"job-1"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := Sign("secret", timestamp, body)
+
+	if err := Verify("secret", timestamp, body, signature); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := Verify("other-secret", timestamp, body, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for wrong secret, got %v", err)
+	}
+	if err := Verify("secret", timestamp, []byte(`{"job_id":"job-2"}`), signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for tampered body, got %v", err)
+	}
+	if err := Verify("secret", timestamp, body, ""); !errors.Is(err, ErrMissingSignature) {
+		t.Fatalf("expected ErrMissingSignature, got %v", err)
+	}
+	if err := Verify("secret", "not-a-number", body, signature); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Fatalf("expected ErrInvalidTimestamp, got %v", err)
+	}
+}
+
+func TestVerifyRejectsReplayedTimestamp(t *testing.T) {
+	body := []byte(`{"job_id":"job-1"}`)
+	old := strconv.FormatInt(time.Now().Add(-DefaultTolerance-time.Minute).Unix(), 10)
+
+	if err := Verify("secret", old, body, Sign("secret", old, body)); !errors.Is(err, ErrStaleTimestamp) {
+		t.Fatalf("expected ErrStaleTimestamp, got %v", err)
+	}
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	body := []byte(`{"job_id":"job-1"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req := httptest.NewRequest("POST", "/hooks", bytes.NewReader(body))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign("secret", timestamp, body))
+
+	verified, err := VerifyRequest(req, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("verify request: %v", err)
+	}
+	if !bytes.Equal(verified, body) {
+		t.Fatalf("expected verified body %q, got %q", body, verified)
+	}
+
+	reread, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("re-read body: %v", err)
+	}
+	if !bytes.Equal(reread, body) {
+		t.Fatalf("expected restored body %q, got %q", body, reread)
+	}
+}